@@ -2,6 +2,7 @@ package v1
 
 import (
 	bc "application/blockchain"
+	"application/model"
 	"application/pkg/app"
 	"bytes"
 	"encoding/json"
@@ -24,23 +25,23 @@ func QueryAccountList(c *gin.Context) {
 	body := new(AccountRequestBody)
 	// Parse Body parameters
 	if err := c.ShouldBind(body); err != nil {
-		appG.Response(http.StatusBadRequest, "Failed", fmt.Sprintf("Parameter error: %s", err.Error()))
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
 		return
 	}
-	var bodyBytes [][]byte
+	request := model.QueryAccountListRequest{}
 	for _, val := range body.Args {
-		bodyBytes = append(bodyBytes, []byte(val.AccountId))
+		request.AccountIds = append(request.AccountIds, val.AccountId)
 	}
-	// Invoke smart contract
-	resp, err := bc.ChannelQuery("queryAccountList", bodyBytes)
+	// Invoke the smart contract
+	resp, err := bc.ChannelQueryRequest("queryAccountPrivateList", request)
 	if err != nil {
-		appG.Response(http.StatusInternalServerError, "Failed", err.Error())
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
 		return
 	}
 	// Deserialize JSON
 	var data []map[string]interface{}
 	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
-		appG.Response(http.StatusInternalServerError, "Failed", err.Error())
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
 		return
 	}
 	appG.Response(http.StatusOK, "Success", data)
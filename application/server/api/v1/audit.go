@@ -0,0 +1,46 @@
+package v1
+
+import (
+	bc "application/blockchain"
+	"application/model"
+	"application/pkg/app"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditLedgerRequestBody struct {
+	AccountId string `json:"accountId"` // Admin running the audit (Account ID)
+}
+
+// AuditLedger checks the ledger invariants and lists the violations found (admin)
+func AuditLedger(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(AuditLedgerRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.AccountId == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	request := model.AuditLedgerRequest{AccountId: body.AccountId}
+	// Invoke the smart contract
+	resp, err := bc.ChannelQueryRequest("auditLedger", request)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	// Deserialize JSON
+	var data []map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
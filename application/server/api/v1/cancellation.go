@@ -0,0 +1,77 @@
+package v1
+
+import (
+	bc "application/blockchain"
+	"application/model"
+	"application/pkg/app"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CancellationPenaltyRequestBody struct {
+	AccountId     string  `json:"accountId"`     // Admin setting the penalty (Account ID)
+	Canceller     string  `json:"canceller"`     // Party cancelling: seller, buyer or admin
+	SellingStatus string  `json:"sellingStatus"` // Status of the sale when cancelled: payment or delivery
+	PaidBy        string  `json:"paidBy"`        // Party paying the penalty: seller or buyer
+	Rate          float64 `json:"rate"`          // Percentage of the price paid to the other party
+}
+
+// SetCancellationPenalty sets the penalty owed when a party cancels a sale in a status (admin)
+func SetCancellationPenalty(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(CancellationPenaltyRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.AccountId == "" || body.Canceller == "" || body.SellingStatus == "" || body.PaidBy == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	if body.Rate < 0 || body.Rate > 100 {
+		appG.Response(http.StatusBadRequest, "Failure", "Rate must be between 0 and 100")
+		return
+	}
+	request := model.SetCancellationPenaltyRequest{
+		AccountId:     body.AccountId,
+		Canceller:     body.Canceller,
+		SellingStatus: body.SellingStatus,
+		PaidBy:        body.PaidBy,
+		Rate:          body.Rate,
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelExecuteRequest("setCancellationPenalty", request)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
+
+// QueryCancellationPenaltyList lists the cancellation penalties in force
+func QueryCancellationPenaltyList(c *gin.Context) {
+	appG := app.Gin{C: c}
+	// Invoke the smart contract
+	resp, err := bc.ChannelQueryRequest("queryCancellationPenaltyList", nil)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	// Deserialize JSON
+	var data []map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
@@ -0,0 +1,179 @@
+package v1
+
+import (
+	bc "application/blockchain"
+	"application/model"
+	"application/pkg/app"
+	"application/pkg/certificate"
+	"application/pkg/timeutil"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CertificateContent is the signed part of an ownership certificate
+type CertificateContent struct {
+	RealEstate    model.RealEstate `json:"realEstate"`    // Record of the real estate when the certificate was issued
+	TransferTxID  string           `json:"transferTxId"`  // Transaction that gave the real estate to its proprietor
+	TransferBlock uint64           `json:"transferBlock"` // Block holding the transfer transaction
+	IssueTime     string           `json:"issueTime"`     // Issue time (RFC 3339, UTC)
+}
+
+// Certificate is an ownership certificate signed by the server
+type Certificate struct {
+	CertificateContent
+	Algorithm string `json:"algorithm"` // Signature algorithm
+	Signature string `json:"signature"` // Base64 signature of the JSON serialization of CertificateContent
+	PublicKey string `json:"publicKey"` // PEM public key verifying the signature
+}
+
+// CertificateVerification reports whether a certificate is genuine and still matches the ledger
+type CertificateVerification struct {
+	Valid          bool     `json:"valid"`          // Whether the certificate is genuine and still current
+	SignatureValid bool     `json:"signatureValid"` // Whether the certificate was signed by this server and not altered
+	Current        bool     `json:"current"`        // Whether the ledger still shows the same proprietor, record and transfer
+	Reasons        []string `json:"reasons"`        // Why the certificate is not valid
+}
+
+// certificateTemplate renders a certificate as a printable HTML page
+var certificateTemplate = template.Must(template.New("certificate").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Ownership Certificate {{.RealEstate.RealEstateID}}</title></head>
+<body style="font-family: sans-serif; max-width: 720px; margin: 40px auto">
+<h1>Ownership Certificate</h1>
+<p>This certifies that account <strong>{{.RealEstate.Proprietor}}</strong> is the registered proprietor of real estate <strong>{{.RealEstate.RealEstateID}}</strong>.</p>
+<table>
+<tr><td>Property type</td><td>{{.RealEstate.PropertyType}}</td></tr>
+<tr><td>Total area (㎡)</td><td>{{.RealEstate.TotalArea}}</td></tr>
+<tr><td>Living space (㎡)</td><td>{{.RealEstate.LivingSpace}}</td></tr>
+<tr><td>Encumbrances</td><td>{{range .RealEstate.Encumbrances}}{{.Type}} {{.Reference}} since {{.StartTime}}{{if .EndTime}} until {{.EndTime}}{{end}}{{if .BlocksTransfer}} (blocks transfers){{end}}<br>{{else}}None{{end}}</td></tr>
+<tr><td>Easements</td><td>{{range .RealEstate.Easements}}{{.Type}}: {{.Description}} ({{.DominantRealEstateID}} over {{.ServientRealEstateID}}){{if .EndTime}} until {{.EndTime}}{{end}}<br>{{else}}None{{end}}</td></tr>
+<tr><td>Transfer transaction</td><td><code>{{.TransferTxID}}</code></td></tr>
+<tr><td>Transfer block</td><td>{{.TransferBlock}}</td></tr>
+<tr><td>Issued</td><td>{{.IssueTime}}</td></tr>
+</table>
+<h2>Signature</h2>
+<p>{{.Algorithm}}</p>
+<pre style="white-space: pre-wrap; word-break: break-all">{{.Signature}}</pre>
+<pre>{{.PublicKey}}</pre>
+<p>Check this certificate against the ledger with POST /api/v1/verifyCertificate and its JSON form.</p>
+</body>
+</html>
+`))
+
+// IssueCertificate issues a signed ownership certificate for a real estate, as JSON or with format=html as a page
+func IssueCertificate(c *gin.Context) {
+	appG := app.Gin{C: c}
+	realEstateID := c.Query("realEstateId")
+	if realEstateID == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	realEstate, err := queryRealEstate(realEstateID)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err)
+		return
+	}
+	block, err := bc.QueryBlockNumber(realEstate.TransferTxID)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", fmt.Sprintf("Failed to find the block of transfer %s: %s", realEstate.TransferTxID, err.Error()))
+		return
+	}
+	content := CertificateContent{
+		RealEstate:    realEstate,
+		TransferTxID:  realEstate.TransferTxID,
+		TransferBlock: block,
+		IssueTime:     timeutil.Format(time.Now()),
+	}
+	payload, err := json.Marshal(content)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	signature, err := certificate.Sign(payload)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	publicKey, err := certificate.PublicKey()
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	cert := Certificate{CertificateContent: content, Algorithm: certificate.Algorithm, Signature: signature, PublicKey: publicKey}
+	if c.Query("format") == "html" {
+		var page bytes.Buffer
+		if err := certificateTemplate.Execute(&page, cert); err != nil {
+			appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", cert)
+}
+
+// VerifyCertificate checks the signature of a certificate and that the ledger still shows what it certifies
+func VerifyCertificate(c *gin.Context) {
+	appG := app.Gin{C: c}
+	cert := new(Certificate)
+	// Parse the body parameters
+	if err := c.ShouldBindJSON(cert); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if cert.RealEstate.RealEstateID == "" || cert.Signature == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	verification := CertificateVerification{Reasons: []string{}}
+	payload, err := json.Marshal(cert.CertificateContent)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	verification.SignatureValid = certificate.Verify(payload, cert.Signature)
+	if !verification.SignatureValid {
+		verification.Reasons = append(verification.Reasons, "The signature does not match the certificate or was not made by this server")
+	}
+	// Changes on the ledger since the certificate was issued
+	var changes []string
+	realEstate, err := queryRealEstate(cert.RealEstate.RealEstateID)
+	if err != nil {
+		if app.ErrorOf(err).Code != app.CodeNotFound {
+			appG.Response(http.StatusInternalServerError, "Failure", err)
+			return
+		}
+		changes = append(changes, "The real estate is no longer on the ledger")
+	} else {
+		if realEstate.Proprietor != cert.RealEstate.Proprietor || realEstate.TransferTxID != cert.TransferTxID {
+			changes = append(changes, fmt.Sprintf("The real estate has been transferred since, by transaction %s", realEstate.TransferTxID))
+		} else if !reflect.DeepEqual(realEstate, cert.RealEstate) {
+			changes = append(changes, "The record of the real estate has changed since the certificate was issued")
+		}
+		if block, err := bc.QueryBlockNumber(cert.TransferTxID); err != nil || block != cert.TransferBlock {
+			changes = append(changes, fmt.Sprintf("Transfer %s is not in block %d", cert.TransferTxID, cert.TransferBlock))
+		}
+	}
+	verification.Current = len(changes) == 0
+	verification.Reasons = append(verification.Reasons, changes...)
+	verification.Valid = verification.SignatureValid && verification.Current
+	appG.Response(http.StatusOK, "Success", verification)
+}
+
+// queryRealEstate queries a real estate by ID along with the transaction that gave it to its proprietor
+func queryRealEstate(realEstateID string) (model.RealEstate, error) {
+	var realEstate model.RealEstate
+	resp, err := bc.ChannelQueryRequest("queryRealEstate", model.QueryRealEstateRequest{RealEstateID: realEstateID})
+	if err != nil {
+		return realEstate, err
+	}
+	err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &realEstate)
+	return realEstate, err
+}
@@ -0,0 +1,126 @@
+package v1
+
+import (
+	bc "application/blockchain"
+	"application/model"
+	"application/pkg/app"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CourtOrderRequestBody struct {
+	AccountId      string `json:"accountId"`      // Admin or authority carrying out the order (Account ID)
+	RealEstateID   string `json:"realEstateId"`   // Real estate ID
+	OrderReference string `json:"orderReference"` // Reference of the court order
+}
+
+type ForceTransferRequestBody struct {
+	AccountId      string `json:"accountId"`      // Admin or authority carrying out the order (Account ID)
+	RealEstateID   string `json:"realEstateId"`   // Real estate ID
+	NewProprietor  string `json:"newProprietor"`  // New proprietor (Account ID)
+	OrderReference string `json:"orderReference"` // Reference of the court order
+}
+
+type CourtOrderListQueryRequestBody struct {
+	RealEstateID string `json:"realEstateId"` // Real estate ID, all court orders when empty
+}
+
+// FreezeRealEstate freezes a real estate by a court order
+func FreezeRealEstate(c *gin.Context) {
+	courtOrder(c, "freezeRealEstate", func(body *CourtOrderRequestBody) interface{} {
+		return model.FreezeRealEstateRequest{AccountId: body.AccountId, RealEstateID: body.RealEstateID, OrderReference: body.OrderReference}
+	})
+}
+
+// UnfreezeRealEstate lifts the freeze of a court order
+func UnfreezeRealEstate(c *gin.Context) {
+	courtOrder(c, "unfreezeRealEstate", func(body *CourtOrderRequestBody) interface{} {
+		return model.UnfreezeRealEstateRequest{AccountId: body.AccountId, RealEstateID: body.RealEstateID, OrderReference: body.OrderReference}
+	})
+}
+
+// courtOrder invokes a court order function with the request built from the body
+func courtOrder(c *gin.Context, fcn string, request func(body *CourtOrderRequestBody) interface{}) {
+	appG := app.Gin{C: c}
+	body := new(CourtOrderRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.AccountId == "" || body.RealEstateID == "" || body.OrderReference == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelExecuteRequest(fcn, request(body))
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
+
+func ForceTransfer(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(ForceTransferRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.AccountId == "" || body.RealEstateID == "" || body.NewProprietor == "" || body.OrderReference == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	request := model.ForceTransferRequest{
+		AccountId:      body.AccountId,
+		RealEstateID:   body.RealEstateID,
+		NewProprietor:  body.NewProprietor,
+		OrderReference: body.OrderReference,
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelExecuteRequest("forceTransfer", request)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
+
+func QueryCourtOrderList(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(CourtOrderListQueryRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelQueryRequest("queryCourtOrderList", model.QueryCourtOrderListRequest{RealEstateID: body.RealEstateID})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	// Deserialize JSON
+	var data []map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
@@ -0,0 +1,148 @@
+package v1
+
+import (
+	bc "application/blockchain"
+	"application/model"
+	"application/pkg/app"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GrantDelegationRequestBody struct {
+	Principal    string `json:"principal"`    // Account granting the authority (Account ID)
+	Agent        string `json:"agent"`        // Account acting on its behalf (Account ID)
+	Scope        string `json:"scope"`        // sell, buy, donate or acceptDonation
+	RealEstateID string `json:"realEstateId"` // Real estate the authority is limited to, empty for any
+	ValidityDays int    `json:"validityDays"` // Days the delegation is valid
+}
+
+type RevokeDelegationRequestBody struct {
+	Principal    string `json:"principal"`    // Account that granted the delegation (Account ID)
+	DelegationID string `json:"delegationId"` // Delegation ID
+}
+
+type DelegationListQueryRequestBody struct {
+	Principal string `json:"principal"` // Account that granted them (Account ID), optional
+	Agent     string `json:"agent"`     // Account they were granted to (Account ID), optional
+}
+
+type DelegatedActionListQueryRequestBody struct {
+	Principal string `json:"principal"` // Account acted for (Account ID)
+}
+
+func GrantDelegation(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(GrantDelegationRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.Principal == "" || body.Agent == "" || body.Scope == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	if body.ValidityDays <= 0 {
+		appG.Response(http.StatusBadRequest, "Failure", "ValidityDays must be greater than 0")
+		return
+	}
+	request := model.GrantDelegationRequest{
+		Principal:    body.Principal,
+		Agent:        body.Agent,
+		Scope:        body.Scope,
+		RealEstateID: body.RealEstateID,
+		ValidityDays: body.ValidityDays,
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelExecuteRequest("grantDelegation", request)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
+
+func RevokeDelegation(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(RevokeDelegationRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.Principal == "" || body.DelegationID == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelExecuteRequest("revokeDelegation", model.RevokeDelegationRequest{Principal: body.Principal, DelegationID: body.DelegationID})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
+
+func QueryDelegationList(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(DelegationListQueryRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelQueryRequest("queryDelegationList", model.QueryDelegationListRequest{Principal: body.Principal, Agent: body.Agent})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	// Deserialize JSON
+	var data []map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
+
+func QueryDelegatedActionList(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(DelegatedActionListQueryRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.Principal == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelQueryRequest("queryDelegatedActionList", model.QueryDelegatedActionListRequest{Principal: body.Principal})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	// Deserialize JSON
+	var data []map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
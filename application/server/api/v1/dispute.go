@@ -0,0 +1,174 @@
+package v1
+
+import (
+	bc "application/blockchain"
+	"application/model"
+	"application/pkg/app"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RaiseDisputeRequestBody struct {
+	ObjectOfSale string   `json:"objectOfSale"` // Sale object (RealEstateID being sold)
+	Seller       string   `json:"seller"`       // Seller (Seller's Account ID)
+	RaisedBy     string   `json:"raisedBy"`     // Buyer or seller raising the dispute (Account ID)
+	Reason       string   `json:"reason"`       // Reason for the dispute
+	Evidence     []string `json:"evidence"`     // SHA-256 hashes of the evidence
+}
+
+type DisputeEvidenceRequestBody struct {
+	ObjectOfSale string   `json:"objectOfSale"` // Sale object (RealEstateID being sold)
+	Seller       string   `json:"seller"`       // Seller (Seller's Account ID)
+	AccountId    string   `json:"accountId"`    // Buyer or seller submitting the evidence (Account ID)
+	Evidence     []string `json:"evidence"`     // SHA-256 hashes of the evidence
+}
+
+type ResolveDisputeRequestBody struct {
+	ObjectOfSale   string  `json:"objectOfSale"`   // Sale object (RealEstateID being sold)
+	Seller         string  `json:"seller"`         // Seller (Seller's Account ID)
+	Arbitrator     string  `json:"arbitrator"`     // Arbitrator (Arbitrator's Account ID)
+	Decision       string  `json:"decision"`       // refund, partialRefund, complete or penalty
+	Amount         float64 `json:"amount"`         // Partial refund or penalty amount
+	PenalizedParty string  `json:"penalizedParty"` // Party paying the penalty (Account ID)
+	DecisionNote   string  `json:"decisionNote"`   // Arbitrator's reasoning
+}
+
+type DisputeListQueryRequestBody struct {
+	Seller       string `json:"seller"`       // Seller (Seller's Account ID)
+	ObjectOfSale string `json:"objectOfSale"` // Sale object, only used together with the seller
+}
+
+func RaiseDispute(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(RaiseDisputeRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.ObjectOfSale == "" || body.Seller == "" || body.RaisedBy == "" || body.Reason == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	request := model.RaiseDisputeRequest{
+		ObjectOfSale: body.ObjectOfSale,
+		Seller:       body.Seller,
+		RaisedBy:     body.RaisedBy,
+		Reason:       body.Reason,
+		Evidence:     body.Evidence,
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelExecuteRequest("raiseDispute", request)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
+
+func AddDisputeEvidence(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(DisputeEvidenceRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.ObjectOfSale == "" || body.Seller == "" || body.AccountId == "" || len(body.Evidence) == 0 {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	request := model.AddDisputeEvidenceRequest{
+		ObjectOfSale: body.ObjectOfSale,
+		Seller:       body.Seller,
+		AccountId:    body.AccountId,
+		Evidence:     body.Evidence,
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelExecuteRequest("addDisputeEvidence", request)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
+
+func ResolveDispute(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(ResolveDisputeRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.ObjectOfSale == "" || body.Seller == "" || body.Arbitrator == "" || body.Decision == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	if body.Amount < 0 {
+		appG.Response(http.StatusBadRequest, "Failure", "Amount cannot be negative")
+		return
+	}
+	request := model.ResolveDisputeRequest{
+		ObjectOfSale:   body.ObjectOfSale,
+		Seller:         body.Seller,
+		Arbitrator:     body.Arbitrator,
+		Decision:       body.Decision,
+		Amount:         body.Amount,
+		PenalizedParty: body.PenalizedParty,
+		DecisionNote:   body.DecisionNote,
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelExecuteRequest("resolveDispute", request)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
+
+func QueryDisputeList(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(DisputeListQueryRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	request := model.QueryDisputeListRequest{Seller: body.Seller}
+	if body.Seller != "" {
+		request.ObjectOfSale = body.ObjectOfSale
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelQueryRequest("queryDisputeList", request)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	// Deserialize JSON
+	var data []map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
@@ -2,6 +2,7 @@ package v1
 
 import (
 	bc "application/blockchain"
+	"application/model"
 	"application/pkg/app"
 	"bytes"
 	"encoding/json"
@@ -15,6 +16,7 @@ type DonatingRequestBody struct {
 	ObjectOfDonating string `json:"objectOfDonating"` // Object of Donation
 	Donor            string `json:"donor"`            // Donor
 	Grantee          string `json:"grantee"`          // Grantee
+	Agent            string `json:"agent"`            // Agent donating on behalf of the donor, optional
 }
 
 type DonatingListQueryRequestBody struct {
@@ -30,6 +32,7 @@ type UpdateDonatingRequestBody struct {
 	Donor            string `json:"donor"`            // Donor
 	Grantee          string `json:"grantee"`          // Grantee
 	Status           string `json:"status"`           // Status to be updated
+	Agent            string `json:"agent"`            // Agent acting for the grantee, or for the donor to cancel, optional
 }
 
 func CreateDonating(c *gin.Context) {
@@ -37,26 +40,28 @@ func CreateDonating(c *gin.Context) {
 	body := new(DonatingRequestBody)
 	// Parse Body parameters
 	if err := c.ShouldBind(body); err != nil {
-		appG.Response(http.StatusBadRequest, "Failed", fmt.Sprintf("Parameter error: %s", err.Error()))
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
 		return
 	}
 	if body.ObjectOfDonating == "" || body.Donor == "" || body.Grantee == "" {
-		appG.Response(http.StatusBadRequest, "Failed", "ObjectOfDonating, Donor, and Grantee cannot be empty")
+		appG.Response(http.StatusBadRequest, "Failure", "ObjectOfDonating, Donor, and Grantee cannot be empty")
 		return
 	}
-	var bodyBytes [][]byte
-	bodyBytes = append(bodyBytes, []byte(body.ObjectOfDonating))
-	bodyBytes = append(bodyBytes, []byte(body.Donor))
-	bodyBytes = append(bodyBytes, []byte(body.Grantee))
-	// Invoke smart contract
-	resp, err := bc.ChannelExecute("createDonating", bodyBytes)
+	request := model.CreateDonatingRequest{
+		ObjectOfDonating: body.ObjectOfDonating,
+		Donor:            body.Donor,
+		Grantee:          body.Grantee,
+		Agent:            body.Agent,
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelExecuteRequest("createDonating", request)
 	if err != nil {
-		appG.Response(http.StatusInternalServerError, "Failed", err.Error())
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
 		return
 	}
 	var data map[string]interface{}
 	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
-		appG.Response(http.StatusInternalServerError, "Failed", err.Error())
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
 		return
 	}
 	appG.Response(http.StatusOK, "Success", data)
@@ -67,23 +72,20 @@ func QueryDonatingList(c *gin.Context) {
 	body := new(DonatingListQueryRequestBody)
 	// Parse Body parameters
 	if err := c.ShouldBind(body); err != nil {
-		appG.Response(http.StatusBadRequest, "Failed", fmt.Sprintf("Parameter error: %s", err.Error()))
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
 		return
 	}
-	var bodyBytes [][]byte
-	if body.Donor != "" {
-		bodyBytes = append(bodyBytes, []byte(body.Donor))
-	}
-	// Invoke smart contract
-	resp, err := bc.ChannelQuery("queryDonatingList", bodyBytes)
+	request := model.QueryDonatingListRequest{Donor: body.Donor}
+	// Invoke the smart contract
+	resp, err := bc.ChannelQueryRequest("queryDonatingList", request)
 	if err != nil {
-		appG.Response(http.StatusInternalServerError, "Failed", err.Error())
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
 		return
 	}
 	// Deserialize JSON
 	var data []map[string]interface{}
 	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
-		appG.Response(http.StatusInternalServerError, "Failed", err.Error())
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
 		return
 	}
 	appG.Response(http.StatusOK, "Success", data)
@@ -94,25 +96,24 @@ func QueryDonatingListByGrantee(c *gin.Context) {
 	body := new(DonatingListQueryByGranteeRequestBody)
 	// Parse Body parameters
 	if err := c.ShouldBind(body); err != nil {
-		appG.Response(http.StatusBadRequest, "Failed", fmt.Sprintf("Parameter error: %s", err.Error()))
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
 		return
 	}
 	if body.Grantee == "" {
-		appG.Response(http.StatusBadRequest, "Failed", "AccountId must be specified")
+		appG.Response(http.StatusBadRequest, "Failure", "AccountId must be specified")
 		return
 	}
-	var bodyBytes [][]byte
-	bodyBytes = append(bodyBytes, []byte(body.Grantee))
-	// Invoke smart contract
-	resp, err := bc.ChannelQuery("queryDonatingListByGrantee", bodyBytes)
+	request := model.QueryDonatingListByGranteeRequest{Grantee: body.Grantee}
+	// Invoke the smart contract
+	resp, err := bc.ChannelQueryRequest("queryDonatingListByGrantee", request)
 	if err != nil {
-		appG.Response(http.StatusInternalServerError, "Failed", err.Error())
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
 		return
 	}
 	// Deserialize JSON
 	var data []map[string]interface{}
 	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
-		appG.Response(http.StatusInternalServerError, "Failed", err.Error())
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
 		return
 	}
 	appG.Response(http.StatusOK, "Success", data)
@@ -123,27 +124,29 @@ func UpdateDonating(c *gin.Context) {
 	body := new(UpdateDonatingRequestBody)
 	// Parse Body parameters
 	if err := c.ShouldBind(body); err != nil {
-		appG.Response(http.StatusBadRequest, "Failed", fmt.Sprintf("Parameter error: %s", err.Error()))
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
 		return
 	}
 	if body.ObjectOfDonating == "" || body.Donor == "" || body.Grantee == "" || body.Status == "" {
-		appG.Response(http.StatusBadRequest, "Failed", "Parameters cannot be empty")
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
 		return
 	}
-	var bodyBytes [][]byte
-	bodyBytes = append(bodyBytes, []byte(body.ObjectOfDonating))
-	bodyBytes = append(bodyBytes, []byte(body.Donor))
-	bodyBytes = append(bodyBytes, []byte(body.Grantee))
-	bodyBytes = append(bodyBytes, []byte(body.Status))
-	// Invoke smart contract
-	resp, err := bc.ChannelExecute("updateDonating", bodyBytes)
+	request := model.UpdateDonatingRequest{
+		ObjectOfDonating: body.ObjectOfDonating,
+		Donor:            body.Donor,
+		Grantee:          body.Grantee,
+		Status:           body.Status,
+		Agent:            body.Agent,
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelExecuteRequest("updateDonating", request)
 	if err != nil {
-		appG.Response(http.StatusInternalServerError, "Failed", err.Error())
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
 		return
 	}
 	var data map[string]interface{}
 	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
-		appG.Response(http.StatusInternalServerError, "Failed", err.Error())
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
 		return
 	}
 	appG.Response(http.StatusOK, "Success", data)
@@ -0,0 +1,118 @@
+package v1
+
+import (
+	bc "application/blockchain"
+	"application/model"
+	"application/pkg/app"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EasementRequestBody struct {
+	AccountId            string `json:"accountId"`            // Admin registering the easement (Account ID)
+	Type                 string `json:"type"`                 // rightOfWay, utility, drainage, light, support or other
+	Description          string `json:"description"`          // What the easement allows
+	DominantRealEstateID string `json:"dominantRealEstateId"` // Real estate that benefits from the easement
+	ServientRealEstateID string `json:"servientRealEstateId"` // Real estate burdened by the easement
+	TermDays             int    `json:"termDays"`             // Term in days, permanent when 0
+}
+
+type ReleaseEasementRequestBody struct {
+	AccountId  string `json:"accountId"`  // Admin releasing the easement (Account ID)
+	EasementID string `json:"easementId"` // Easement ID
+}
+
+type EasementListQueryRequestBody struct {
+	RealEstateID string `json:"realEstateId"` // Real estate ID, all easements when empty
+}
+
+func RegisterEasement(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(EasementRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.AccountId == "" || body.Type == "" || body.Description == "" || body.DominantRealEstateID == "" || body.ServientRealEstateID == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	if body.TermDays < 0 {
+		appG.Response(http.StatusBadRequest, "Failure", "TermDays cannot be negative")
+		return
+	}
+	request := model.RegisterEasementRequest{
+		AccountId:            body.AccountId,
+		Type:                 body.Type,
+		Description:          body.Description,
+		DominantRealEstateID: body.DominantRealEstateID,
+		ServientRealEstateID: body.ServientRealEstateID,
+		TermDays:             body.TermDays,
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelExecuteRequest("registerEasement", request)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
+
+func ReleaseEasement(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(ReleaseEasementRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.AccountId == "" || body.EasementID == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelExecuteRequest("releaseEasement", model.ReleaseEasementRequest{AccountId: body.AccountId, EasementID: body.EasementID})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
+
+func QueryEasementList(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(EasementListQueryRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelQueryRequest("queryEasementList", model.QueryEasementListRequest{RealEstateID: body.RealEstateID})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	// Deserialize JSON
+	var data []map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
@@ -0,0 +1,80 @@
+package v1
+
+import (
+	bc "application/blockchain"
+	"application/model"
+	"application/pkg/app"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EndorsementPolicyRequestBody struct {
+	AccountId    string   `json:"accountId"`    // Operator (Admin's Account ID)
+	RealEstateID string   `json:"realEstateId"` // Real estate ID
+	Orgs         []string `json:"orgs"`         // MSP IDs of the organizations that must endorse, empty to remove the policy
+}
+
+type EndorsementPolicyQueryRequestBody struct {
+	RealEstateID string `json:"realEstateId"` // Real estate ID
+}
+
+func SetEndorsementPolicy(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(EndorsementPolicyRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.AccountId == "" || body.RealEstateID == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	request := model.SetEndorsementPolicyRequest{
+		AccountId:    body.AccountId,
+		RealEstateID: body.RealEstateID,
+		Orgs:         body.Orgs,
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelExecuteRequest("setEndorsementPolicy", request)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
+
+func QueryEndorsementPolicy(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(EndorsementPolicyQueryRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.RealEstateID == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelQueryRequest("queryEndorsementPolicy", model.QueryEndorsementPolicyRequest{RealEstateID: body.RealEstateID})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
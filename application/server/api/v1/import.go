@@ -0,0 +1,254 @@
+package v1
+
+import (
+	bc "application/blockchain"
+	"application/model"
+	"application/pkg/app"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Statuses of the rows of an import report
+const (
+	ImportRowCreated = "created" // Registered on the ledger
+	ImportRowInvalid = "invalid" // Rejected by the validation, never sent to the chaincode
+	ImportRowFailed  = "failed"  // Valid, but its batch was refused by the chaincode
+)
+
+// ImportRowResult is the outcome of one row of the imported file
+type ImportRowResult struct {
+	Row          int    `json:"row"`          // Position of the row in the file, from 1, not counting the CSV header
+	Status       string `json:"status"`       // created, invalid or failed
+	Batch        int    `json:"batch"`        // Batch the row was sent in, from 1, 0 when it was invalid
+	RealEstateID string `json:"realEstateId"` // ID of the created real estate, or the requested one
+	Message      string `json:"message"`      // Why the row was not created
+}
+
+// ImportReport summarizes an import and lists the result of every row
+type ImportReport struct {
+	Created int               `json:"created"` // Number of rows created
+	Invalid int               `json:"invalid"` // Number of rows rejected by the validation
+	Failed  int               `json:"failed"`  // Number of valid rows in batches refused by the chaincode
+	Rows    []ImportRowResult `json:"rows"`    // Result of each row, in file order
+}
+
+// importRealEstateIDPattern is the format of registrar-assigned IDs accepted by the chaincode
+var importRealEstateIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9./-]{0,63}$`)
+
+// ImportRealEstate registers the real estates of a CSV or JSON file (admin).
+// The form carries the admin's accountId and the file. A CSV file starts with a header naming the
+// proprietor, totalArea, livingSpace and optional realEstateId and propertyType columns; a JSON file is an array of objects with the same fields.
+// Every row is validated, the valid rows are created in batches of at most model.RealEstateBatchLimit,
+// each batch all or none, and the report gives the result of every row.
+func ImportRealEstate(c *gin.Context) {
+	appG := app.Gin{C: c}
+	accountId := c.PostForm("accountId")
+	if accountId == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	reader, err := file.Open()
+	if err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Failed to open the file: %s", err.Error()))
+		return
+	}
+	defer reader.Close()
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Failed to read the file: %s", err.Error()))
+		return
+	}
+	var rows []importRow
+	if strings.EqualFold(filepath.Ext(file.Filename), ".json") || bytes.HasPrefix(bytes.TrimSpace(content), []byte("[")) {
+		rows, err = parseImportJSON(content)
+	} else {
+		rows, err = parseImportCSV(content)
+	}
+	if err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Failed to parse the file: %s", err.Error()))
+		return
+	}
+	if len(rows) == 0 {
+		appG.Response(http.StatusBadRequest, "Failure", "The file has no rows")
+		return
+	}
+	report := ImportReport{Rows: make([]ImportRowResult, len(rows))}
+	// Validate every row, so that a batch is only refused for reasons only the ledger knows
+	var valid []int
+	requested := map[string]int{}
+	for i, row := range rows {
+		report.Rows[i] = ImportRowResult{Row: i + 1, RealEstateID: row.item.RealEstateID}
+		message := row.err
+		if message == "" {
+			message = validateImportRow(accountId, row.item)
+		}
+		if message == "" && row.item.RealEstateID != "" {
+			if first, ok := requested[row.item.RealEstateID]; ok {
+				message = fmt.Sprintf("Real estate ID %s is already used by row %d", row.item.RealEstateID, first)
+			} else {
+				requested[row.item.RealEstateID] = i + 1
+			}
+		}
+		if message != "" {
+			report.Rows[i].Status, report.Rows[i].Message = ImportRowInvalid, message
+			report.Invalid++
+			continue
+		}
+		valid = append(valid, i)
+	}
+	// Create the valid rows in transaction-sized batches
+	for start, batch := 0, 1; start < len(valid); start, batch = start+model.RealEstateBatchLimit, batch+1 {
+		end := start + model.RealEstateBatchLimit
+		if end > len(valid) {
+			end = len(valid)
+		}
+		request := model.CreateRealEstateBatchRequest{AccountId: accountId}
+		for _, i := range valid[start:end] {
+			request.RealEstates = append(request.RealEstates, rows[i].item)
+		}
+		created, err := createRealEstateBatch(request)
+		for n, i := range valid[start:end] {
+			report.Rows[i].Batch = batch
+			if err != nil {
+				report.Rows[i].Status, report.Rows[i].Message = ImportRowFailed, err.Error()
+				report.Failed++
+				continue
+			}
+			report.Rows[i].Status, report.Rows[i].RealEstateID = ImportRowCreated, created[n]
+			report.Created++
+		}
+	}
+	appG.Response(http.StatusOK, "Success", report)
+}
+
+// importRow is a parsed row of the imported file, with the reason it could not be parsed if any
+type importRow struct {
+	item model.RealEstateBatchItem
+	err  string
+}
+
+// parseImportCSV reads the rows of a CSV file, whose header names the columns
+func parseImportCSV(content []byte) ([]importRow, error) {
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		// Spreadsheets often save CSV files with a byte order mark
+		columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+	for _, name := range []string{"proprietor", "totalArea", "livingSpace"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("the header has no %s column", name)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		var row importRow
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) && parseErr.Err == csv.ErrFieldCount {
+			row.err = "Incorrect number of columns"
+		} else if err != nil {
+			return nil, err
+		}
+		row.item.Proprietor = field(record, "proprietor")
+		row.item.RealEstateID = field(record, "realEstateId")
+		row.item.PropertyType = field(record, "propertyType")
+		if row.item.TotalArea, err = strconv.ParseFloat(field(record, "totalArea"), 64); err != nil && row.err == "" {
+			row.err = fmt.Sprintf("Failed to convert the totalArea column: %s", err)
+		}
+		if row.item.LivingSpace, err = strconv.ParseFloat(field(record, "livingSpace"), 64); err != nil && row.err == "" {
+			row.err = fmt.Sprintf("Failed to convert the livingSpace column: %s", err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+// parseImportJSON reads the rows of a JSON array of real estates
+func parseImportJSON(content []byte) ([]importRow, error) {
+	var objects []json.RawMessage
+	if err := json.Unmarshal(content, &objects); err != nil {
+		return nil, err
+	}
+	rows := make([]importRow, len(objects))
+	for i, object := range objects {
+		decoder := json.NewDecoder(bytes.NewReader(object))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&rows[i].item); err != nil {
+			rows[i].err = fmt.Sprintf("Failed to convert the row: %s", err)
+		}
+	}
+	return rows, nil
+}
+
+// validateImportRow applies the checks of CreateRealEstate to a row, returning why it is invalid or an empty string
+func validateImportRow(accountId string, item model.RealEstateBatchItem) string {
+	if item.Proprietor == "" {
+		return "Parameters cannot be empty"
+	}
+	if item.Proprietor == accountId {
+		return "The operator should be an admin and cannot be the same as the owner"
+	}
+	if item.TotalArea <= 0 || item.LivingSpace <= 0 || item.LivingSpace > item.TotalArea {
+		return "TotalArea and LivingSpace must be greater than 0, and LivingSpace must be less than or equal to TotalArea"
+	}
+	if _, ok := model.PropertyTypeConstant()[item.PropertyType]; item.PropertyType != "" && !ok {
+		return fmt.Sprintf("Unknown property type %s", item.PropertyType)
+	}
+	if item.RealEstateID != "" && !importRealEstateIDPattern.MatchString(item.RealEstateID) {
+		return fmt.Sprintf("Real estate ID %s must be up to 64 letters, digits, '.', '/' or '-'", item.RealEstateID)
+	}
+	return ""
+}
+
+// createRealEstateBatch invokes createRealEstateBatch and returns the IDs of the created real estates in request order
+func createRealEstateBatch(request model.CreateRealEstateBatchRequest) ([]string, error) {
+	resp, err := bc.ChannelExecuteRequest("createRealEstateBatch", request)
+	if err != nil {
+		return nil, errors.New(app.ErrorOf(err).Message)
+	}
+	var created []struct {
+		RealEstateID string `json:"realEstateId"`
+	}
+	if err = json.Unmarshal(resp.Payload, &created); err != nil {
+		return nil, err
+	}
+	if len(created) != len(request.RealEstates) {
+		return nil, fmt.Errorf("Expected %d real estates to be created, got %d", len(request.RealEstates), len(created))
+	}
+	ids := make([]string, len(created))
+	for i, realEstate := range created {
+		ids[i] = realEstate.RealEstateID
+	}
+	return ids, nil
+}
@@ -0,0 +1,103 @@
+package v1
+
+import (
+	bc "application/blockchain"
+	"application/model"
+	"application/pkg/app"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type KycRequestBody struct {
+	AccountId string `json:"accountId"` // Account requesting verification (Account ID)
+}
+
+type ReviewKycRequestBody struct {
+	Verifier     string `json:"verifier"`     // Verifier (Verifier's Account ID)
+	AccountId    string `json:"accountId"`    // Account under review (Account ID)
+	Decision     string `json:"decision"`     // verified or rejected
+	ValidityDays int    `json:"validityDays"` // Days the verification is valid, required when verified
+}
+
+func RequestKyc(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(KycRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.AccountId == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelExecuteRequest("requestKyc", model.RequestKycRequest{AccountId: body.AccountId})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
+
+func ReviewKyc(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(ReviewKycRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.Verifier == "" || body.AccountId == "" || body.Decision == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	if body.Decision == "verified" && body.ValidityDays <= 0 {
+		appG.Response(http.StatusBadRequest, "Failure", "ValidityDays must be greater than 0 to verify an account")
+		return
+	}
+	request := model.ReviewKycRequest{
+		Verifier:     body.Verifier,
+		AccountId:    body.AccountId,
+		Decision:     body.Decision,
+		ValidityDays: body.ValidityDays,
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelExecuteRequest("reviewKyc", request)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
+
+func QueryPendingKycList(c *gin.Context) {
+	appG := app.Gin{C: c}
+	// Invoke the smart contract
+	resp, err := bc.ChannelQueryRequest("queryPendingKycList", nil)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	// Deserialize JSON
+	var data []map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
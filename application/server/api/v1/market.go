@@ -0,0 +1,57 @@
+package v1
+
+import (
+	bc "application/blockchain"
+	"application/model"
+	"application/pkg/app"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ActiveListingsQueryRequestBody struct {
+	PageSize int     `json:"pageSize"` // Number of listings per page
+	Bookmark string  `json:"bookmark"` // Bookmark returned by the previous page, empty for the first one
+	MinPrice float64 `json:"minPrice"` // Lowest price, optional
+	MaxPrice float64 `json:"maxPrice"` // Highest price, optional
+	MinArea  float64 `json:"minArea"`  // Smallest total area, optional
+	MaxArea  float64 `json:"maxArea"`  // Largest total area, optional
+}
+
+// QueryActiveListings queries one page of the sales in progress across all sellers with the real estate they sell
+func QueryActiveListings(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(ActiveListingsQueryRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.PageSize <= 0 {
+		appG.Response(http.StatusBadRequest, "Failure", "PageSize must be greater than 0")
+		return
+	}
+	request := model.QueryActiveListingsRequest{
+		PageSize: body.PageSize,
+		Bookmark: body.Bookmark,
+		MinPrice: body.MinPrice,
+		MaxPrice: body.MaxPrice,
+		MinArea:  body.MinArea,
+		MaxArea:  body.MaxArea,
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelQueryRequest("queryActiveListings", request)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
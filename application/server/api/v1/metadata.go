@@ -0,0 +1,29 @@
+package v1
+
+import (
+	bc "application/blockchain"
+	"application/pkg/app"
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMetadata returns the chaincode functions with their parameter and return schemas
+func GetMetadata(c *gin.Context) {
+	appG := app.Gin{C: c}
+	// Invoke the smart contract
+	resp, err := bc.ChannelQuery("getMetadata", [][]byte{})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	// Deserialize JSON
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
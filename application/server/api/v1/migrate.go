@@ -0,0 +1,81 @@
+package v1
+
+import (
+	bc "application/blockchain"
+	"application/model"
+	"application/pkg/app"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MigrateRequestBody struct {
+	AccountId  string `json:"accountId"`  // Admin running the migration (Account ID)
+	ObjectType string `json:"objectType"` // Object type of the records to upgrade, e.g. selling-key
+	BatchSize  int    `json:"batchSize"`  // Number of records upgraded by this batch
+	Bookmark   string `json:"bookmark"`   // Bookmark returned by the previous batch, empty for the first one
+}
+
+// migrationBatch is the batch of record keys returned by queryMigrationBatch
+type migrationBatch struct {
+	Keys     []string `json:"keys"`     // Keys of the records in this batch
+	Bookmark string   `json:"bookmark"` // Bookmark of the next record
+	Done     bool     `json:"done"`     // Whether all records of the object type have been returned
+}
+
+// Migrate upgrades one batch of ledger records to the current schema version (admin)
+func Migrate(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(MigrateRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.AccountId == "" || body.ObjectType == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	if body.BatchSize <= 0 {
+		appG.Response(http.StatusBadRequest, "Failure", "BatchSize must be greater than 0")
+		return
+	}
+	// The keys of the batch are read by a query, as Fabric refuses writes in a transaction that paginates
+	resp, err := bc.ChannelQueryRequest("queryMigrationBatch", model.QueryMigrationBatchRequest{
+		ObjectType: body.ObjectType,
+		BatchSize:  body.BatchSize,
+		Bookmark:   body.Bookmark,
+	})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	var batch migrationBatch
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &batch); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	data := map[string]interface{}{"objectType": body.ObjectType, "scanned": 0, "migrated": 0}
+	if len(batch.Keys) > 0 {
+		// Invoke the smart contract
+		resp, err = bc.ChannelExecuteRequest("migrate", model.MigrateRequest{
+			AccountId:  body.AccountId,
+			ObjectType: body.ObjectType,
+			Keys:       batch.Keys,
+		})
+		if err != nil {
+			appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+			return
+		}
+		if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+			appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+			return
+		}
+	}
+	data["bookmark"] = batch.Bookmark
+	data["done"] = batch.Done
+	appG.Response(http.StatusOK, "Success", data)
+}
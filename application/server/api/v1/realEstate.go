@@ -2,21 +2,23 @@ package v1
 
 import (
 	bc "application/blockchain"
+	"application/model"
 	"application/pkg/app"
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
 type RealEstateRequestBody struct {
-	AccountId   string  `json:"accountId"`   // Operator ID
-	Proprietor  string  `json:"proprietor"`  // Proprietor (Owner) (Owner's Account ID)
-	TotalArea   float64 `json:"totalArea"`   // Total Area
-	LivingSpace float64 `json:"livingSpace"` // Living Space
+	AccountId    string  `json:"accountId"`    // Operator ID
+	Proprietor   string  `json:"proprietor"`   // Proprietor (Owner) (Owner's Account ID)
+	TotalArea    float64 `json:"totalArea"`    // Total Area
+	LivingSpace  float64 `json:"livingSpace"`  // Living Space
+	RealEstateID string  `json:"realEstateId"` // Cadastral or parcel number, generated by the chaincode when empty
+	PropertyType string  `json:"propertyType"` // apartment, house, commercial or land, optional
 }
 
 type RealEstateQueryRequestBody struct {
@@ -35,13 +37,20 @@ func CreateRealEstate(c *gin.Context) {
 		appG.Response(http.StatusBadRequest, "Failure", "TotalArea and LivingSpace must be greater than 0, and LivingSpace must be less than or equal to TotalArea")
 		return
 	}
-	var bodyBytes [][]byte
-	bodyBytes = append(bodyBytes, []byte(body.AccountId))
-	bodyBytes = append(bodyBytes, []byte(body.Proprietor))
-	bodyBytes = append(bodyBytes, []byte(strconv.FormatFloat(body.TotalArea, 'E', -1, 64)))
-	bodyBytes = append(bodyBytes, []byte(strconv.FormatFloat(body.LivingSpace, 'E', -1, 64)))
+	if _, ok := model.PropertyTypeConstant()[body.PropertyType]; body.PropertyType != "" && !ok {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Unknown property type %s", body.PropertyType))
+		return
+	}
+	request := model.CreateRealEstateRequest{
+		AccountId:    body.AccountId,
+		Proprietor:   body.Proprietor,
+		TotalArea:    body.TotalArea,
+		LivingSpace:  body.LivingSpace,
+		RealEstateID: body.RealEstateID,
+		PropertyType: body.PropertyType,
+	}
 	// Invoke the smart contract
-	resp, err := bc.ChannelExecute("createRealEstate", bodyBytes)
+	resp, err := bc.ChannelExecuteRequest("createRealEstate", request)
 	if err != nil {
 		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
 		return
@@ -62,12 +71,9 @@ func QueryRealEstateList(c *gin.Context) {
 		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
 		return
 	}
-	var bodyBytes [][]byte
-	if body.Proprietor != "" {
-		bodyBytes = append(bodyBytes, []byte(body.Proprietor))
-	}
+	request := model.QueryRealEstateListRequest{Proprietor: body.Proprietor}
 	// Invoke the smart contract
-	resp, err := bc.ChannelQuery("queryRealEstateList", bodyBytes)
+	resp, err := bc.ChannelQueryRequest("queryRealEstateList", request)
 	if err != nil {
 		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
 		return
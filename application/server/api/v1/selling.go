@@ -2,12 +2,12 @@ package v1
 
 import (
 	bc "application/blockchain"
+	"application/model"
 	"application/pkg/app"
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,12 +17,24 @@ type SellingRequestBody struct {
 	Seller       string  `json:"seller"`       // Initiator of the sale, seller (Seller's Account ID)
 	Price        float64 `json:"price"`        // Price
 	SalePeriod   int     `json:"salePeriod"`   // Validity period of the smart contract (in days)
+	// Optional payment schedule; the deposit and installments must add up to the price
+	Deposit         float64                  `json:"deposit"`         // Earnest money paid when the buyer commits
+	OnMissedPayment string                   `json:"onMissedPayment"` // forfeit or grace
+	GracePeriod     int                      `json:"gracePeriod"`     // Extra time granted for a missed installment (in days)
+	Installments    []InstallmentRequestBody `json:"installments"`    // Installments due after the deposit
+	Agent           string                   `json:"agent"`           // Agent listing on behalf of the seller (Account ID), optional
+}
+
+type InstallmentRequestBody struct {
+	Amount  float64 `json:"amount"`  // Amount due
+	DueDays int     `json:"dueDays"` // Days after the deposit when the installment is due
 }
 
 type SellingByBuyRequestBody struct {
 	ObjectOfSale string `json:"objectOfSale"` // Sale object (RealEstateID being sold)
 	Seller       string `json:"seller"`       // Initiator of the sale, seller (Seller's Account ID)
 	Buyer        string `json:"buyer"`        // Buyer (Buyer's Account ID)
+	Agent        string `json:"agent"`        // Agent buying on behalf of the buyer (Account ID), optional
 }
 
 type SellingListQueryRequestBody struct {
@@ -33,11 +45,20 @@ type SellingListQueryByBuyRequestBody struct {
 	Buyer string `json:"buyer"` // Buyer (Buyer's Account ID)
 }
 
+type PayInstallmentRequestBody struct {
+	ObjectOfSale string `json:"objectOfSale"` // Sale object (RealEstateID being sold)
+	Seller       string `json:"seller"`       // Initiator of the sale, seller (Seller's Account ID)
+	Buyer        string `json:"buyer"`        // Buyer (Buyer's Account ID)
+}
+
 type UpdateSellingRequestBody struct {
 	ObjectOfSale string `json:"objectOfSale"` // Sale object (RealEstateID being sold)
 	Seller       string `json:"seller"`       // Initiator of the sale, seller (Seller's Account ID)
 	Buyer        string `json:"buyer"`        // Buyer (Buyer's Account ID)
 	Status       string `json:"status"`       // Status to be changed
+	Operator     string `json:"operator"`     // Party cancelling the sale (Account ID), required to cancel
+	Reason       string `json:"reason"`       // Reason for cancelling, required to cancel
+	Agent        string `json:"agent"`        // Agent acting for the seller or the party cancelling (Account ID), optional
 }
 
 func CreateSelling(c *gin.Context) {
@@ -56,13 +77,27 @@ func CreateSelling(c *gin.Context) {
 		appG.Response(http.StatusBadRequest, "Failure", "Price and SalePeriod (in days) must be greater than 0")
 		return
 	}
-	var bodyBytes [][]byte
-	bodyBytes = append(bodyBytes, []byte(body.ObjectOfSale))
-	bodyBytes = append(bodyBytes, []byte(body.Seller))
-	bodyBytes = append(bodyBytes, []byte(strconv.FormatFloat(body.Price, 'E', -1, 64)))
-	bodyBytes = append(bodyBytes, []byte(strconv.Itoa(body.SalePeriod)))
+	request := model.CreateSellingRequest{
+		ObjectOfSale: body.ObjectOfSale,
+		Seller:       body.Seller,
+		Price:        body.Price,
+		SalePeriod:   body.SalePeriod,
+		Agent:        body.Agent,
+	}
+	if body.Deposit > 0 || len(body.Installments) > 0 {
+		if body.Deposit <= 0 || len(body.Installments) == 0 || body.OnMissedPayment == "" {
+			appG.Response(http.StatusBadRequest, "Failure", "A payment schedule needs a deposit, installments and a missed payment policy")
+			return
+		}
+		request.Deposit = body.Deposit
+		request.OnMissedPayment = body.OnMissedPayment
+		request.GracePeriod = body.GracePeriod
+		for _, val := range body.Installments {
+			request.Installments = append(request.Installments, model.InstallmentRequest{Amount: val.Amount, DueDays: val.DueDays})
+		}
+	}
 	// Invoke the smart contract
-	resp, err := bc.ChannelExecute("createSelling", bodyBytes)
+	resp, err := bc.ChannelExecuteRequest("createSelling", request)
 	if err != nil {
 		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
 		return
@@ -87,12 +122,45 @@ func CreateSellingByBuy(c *gin.Context) {
 		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
 		return
 	}
-	var bodyBytes [][]byte
-	bodyBytes = append(bodyBytes, []byte(body.ObjectOfSale))
-	bodyBytes = append(bodyBytes, []byte(body.Seller))
-	bodyBytes = append(bodyBytes, []byte(body.Buyer))
+	request := model.CreateSellingByBuyRequest{
+		ObjectOfSale: body.ObjectOfSale,
+		Seller:       body.Seller,
+		Buyer:        body.Buyer,
+		Agent:        body.Agent,
+	}
 	// Invoke the smart contract
-	resp, err := bc.ChannelExecute("createSellingByBuy", bodyBytes)
+	resp, err := bc.ChannelExecuteRequest("createSellingByBuy", request)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
+
+func PayInstallment(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(PayInstallmentRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.ObjectOfSale == "" || body.Seller == "" || body.Buyer == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	request := model.PayInstallmentRequest{
+		ObjectOfSale: body.ObjectOfSale,
+		Seller:       body.Seller,
+		Buyer:        body.Buyer,
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelExecuteRequest("payInstallment", request)
 	if err != nil {
 		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
 		return
@@ -113,12 +181,9 @@ func QuerySellingList(c *gin.Context) {
 		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
 		return
 	}
-	var bodyBytes [][]byte
-	if body.Seller != "" {
-		bodyBytes = append(bodyBytes, []byte(body.Seller))
-	}
+	request := model.QuerySellingListRequest{Seller: body.Seller}
 	// Invoke the smart contract
-	resp, err := bc.ChannelQuery("querySellingList", bodyBytes)
+	resp, err := bc.ChannelQueryRequest("querySellingPrivateList", request)
 	if err != nil {
 		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
 		return
@@ -144,10 +209,9 @@ func QuerySellingListByBuyer(c *gin.Context) {
 		appG.Response(http.StatusBadRequest, "Failure", "Buyer Account ID must be specified for the query")
 		return
 	}
-	var bodyBytes [][]byte
-	bodyBytes = append(bodyBytes, []byte(body.Buyer))
+	request := model.QuerySellingListByBuyerRequest{Buyer: body.Buyer}
 	// Invoke the smart contract
-	resp, err := bc.ChannelQuery("querySellingListByBuyer", bodyBytes)
+	resp, err := bc.ChannelQueryRequest("querySellingPrivateListByBuyer", request)
 	if err != nil {
 		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
 		return
@@ -173,13 +237,23 @@ func UpdateSelling(c *gin.Context) {
 		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
 		return
 	}
-	var bodyBytes [][]byte
-	bodyBytes = append(bodyBytes, []byte(body.ObjectOfSale))
-	bodyBytes = append(bodyBytes, []byte(body.Seller))
-	bodyBytes = append(bodyBytes, []byte(body.Buyer))
-	bodyBytes = append(bodyBytes, []byte(body.Status))
+	request := model.UpdateSellingRequest{
+		ObjectOfSale: body.ObjectOfSale,
+		Seller:       body.Seller,
+		Buyer:        body.Buyer,
+		Status:       body.Status,
+		Agent:        body.Agent,
+	}
+	if body.Status == "cancelled" {
+		if body.Operator == "" || body.Reason == "" {
+			appG.Response(http.StatusBadRequest, "Failure", "Operator and Reason are required to cancel")
+			return
+		}
+		request.Operator = body.Operator
+		request.Reason = body.Reason
+	}
 	// Invoke the smart contract
-	resp, err := bc.ChannelExecute("updateSelling", bodyBytes)
+	resp, err := bc.ChannelExecuteRequest("updateSelling", request)
 	if err != nil {
 		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
 		return
@@ -0,0 +1,31 @@
+package v1
+
+import (
+	bc "application/blockchain"
+	"application/model"
+	"application/pkg/app"
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QueryStateMachine returns the transition table of the selling or donating state machine with its diagrams
+func QueryStateMachine(c *gin.Context) {
+	appG := app.Gin{C: c}
+	machine := c.DefaultQuery("machine", "selling")
+	// Invoke the smart contract
+	resp, err := bc.ChannelQueryRequest("queryStateMachine", model.QueryStateMachineRequest{Machine: machine})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	// Deserialize JSON
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
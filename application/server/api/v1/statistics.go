@@ -0,0 +1,204 @@
+package v1
+
+import (
+	bc "application/blockchain"
+	"application/model"
+	"application/pkg/app"
+	"application/pkg/timeutil"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MarketStatisticsRequestBody struct {
+	StartDate string   `json:"startDate"` // Earliest completion date (2006-01-02) in the client's time zone, optional
+	EndDate   string   `json:"endDate"`   // Latest completion date (2006-01-02) in the client's time zone, inclusive, optional
+	GroupBy   []string `json:"groupBy"`   // month and/or propertyType; all sales form a single group when empty
+}
+
+// MarketStatistics are the aggregate statistics of the completed sales
+type MarketStatistics struct {
+	Groups  []MarketStatisticsGroup `json:"groups"`  // Statistics of each group, ordered by month then property type
+	Skipped int                     `json:"skipped"` // Completed sales left out because they were completed before completion times were recorded
+}
+
+// MarketStatisticsGroup are the statistics of the completed sales of a month and/or property type
+type MarketStatisticsGroup struct {
+	Month                      string  `json:"month,omitempty"`            // Month of completion (2006-01), when grouped by month
+	PropertyType               string  `json:"propertyType,omitempty"`     // Property type, when grouped by property type
+	Volume                     int     `json:"volume"`                     // Number of completed sales
+	TotalValue                 float64 `json:"totalValue"`                 // Sum of the prices
+	AveragePrice               float64 `json:"averagePrice"`               // Average price
+	MedianPrice                float64 `json:"medianPrice"`                // Median price
+	AveragePricePerSquareMetre float64 `json:"averagePricePerSquareMetre"` // Average of price / total area
+	MedianPricePerSquareMetre  float64 `json:"medianPricePerSquareMetre"`  // Median of price / total area
+	AverageDaysOnMarket        float64 `json:"averageDaysOnMarket"`        // Average days from listing to completion
+	MedianDaysOnMarket         float64 `json:"medianDaysOnMarket"`         // Median days from listing to completion
+}
+
+// unspecifiedPropertyType groups the real estate registered without a property type
+const unspecifiedPropertyType = "Unspecified"
+
+// QueryMarketStatistics aggregates the prices, price per square metre and time on market of the completed sales
+func QueryMarketStatistics(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(MarketStatisticsRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	// Dates and months are those of the client's time zone
+	loc := appG.Location()
+	var start, end time.Time
+	var err error
+	if body.StartDate != "" {
+		if start, err = time.ParseInLocation("2006-01-02", body.StartDate, loc); err != nil {
+			appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("StartDate must be formatted as 2006-01-02: %s", err.Error()))
+			return
+		}
+	}
+	if body.EndDate != "" {
+		if end, err = time.ParseInLocation("2006-01-02", body.EndDate, loc); err != nil {
+			appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("EndDate must be formatted as 2006-01-02: %s", err.Error()))
+			return
+		}
+		end = end.AddDate(0, 0, 1)
+	}
+	byMonth, byPropertyType := false, false
+	for _, v := range body.GroupBy {
+		switch v {
+		case "month":
+			byMonth = true
+		case "propertyType":
+			byPropertyType = true
+		default:
+			appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Cannot group by %s, only by month and propertyType", v))
+			return
+		}
+	}
+	// Query the sales with their prices and the real estate for their areas and types
+	resp, err := bc.ChannelQueryRequest("querySellingPrivateList", model.QuerySellingListRequest{})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	var sellingList []model.Selling
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &sellingList); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	resp, err = bc.ChannelQueryRequest("queryRealEstateList", model.QueryRealEstateListRequest{})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	var realEstateList []model.RealEstate
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &realEstateList); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	realEstates := map[string]model.RealEstate{}
+	for _, v := range realEstateList {
+		realEstates[v.RealEstateID] = v
+	}
+	statistics := MarketStatistics{Groups: []MarketStatisticsGroup{}}
+	groups := map[MarketStatisticsGroup]*marketSample{}
+	for _, v := range sellingList {
+		if v.SellingStatus != model.SellingStatusConstant()["done"] {
+			continue
+		}
+		if v.CompleteTime == "" {
+			statistics.Skipped++
+			continue
+		}
+		completed, err := timeutil.Parse(v.CompleteTime)
+		if err != nil {
+			appG.Response(http.StatusInternalServerError, "Failure", fmt.Sprintf("Invalid completion time of the sale of %s: %s", v.ObjectOfSale, err.Error()))
+			return
+		}
+		if (!start.IsZero() && completed.Before(start)) || (!end.IsZero() && !completed.Before(end)) {
+			continue
+		}
+		realEstate := realEstates[v.ObjectOfSale]
+		var key MarketStatisticsGroup
+		if byMonth {
+			key.Month = completed.In(loc).Format("2006-01")
+		}
+		if byPropertyType {
+			key.PropertyType = realEstate.PropertyType
+			if key.PropertyType == "" {
+				key.PropertyType = unspecifiedPropertyType
+			}
+		}
+		sample, ok := groups[key]
+		if !ok {
+			sample = &marketSample{}
+			groups[key] = sample
+		}
+		sample.prices = append(sample.prices, v.Price)
+		if realEstate.TotalArea > 0 {
+			sample.pricesPerSquareMetre = append(sample.pricesPerSquareMetre, v.Price/realEstate.TotalArea)
+		}
+		if listed, err := timeutil.Parse(v.CreateTime); err == nil {
+			sample.daysOnMarket = append(sample.daysOnMarket, completed.Sub(listed).Hours()/24)
+		}
+	}
+	for key, sample := range groups {
+		group := key
+		group.Volume = len(sample.prices)
+		group.TotalValue = sum(sample.prices)
+		group.AveragePrice, group.MedianPrice = average(sample.prices), median(sample.prices)
+		group.AveragePricePerSquareMetre, group.MedianPricePerSquareMetre = average(sample.pricesPerSquareMetre), median(sample.pricesPerSquareMetre)
+		group.AverageDaysOnMarket, group.MedianDaysOnMarket = average(sample.daysOnMarket), median(sample.daysOnMarket)
+		statistics.Groups = append(statistics.Groups, group)
+	}
+	sort.Slice(statistics.Groups, func(i, j int) bool {
+		a, b := statistics.Groups[i], statistics.Groups[j]
+		if a.Month != b.Month {
+			return a.Month < b.Month
+		}
+		return a.PropertyType < b.PropertyType
+	})
+	appG.Response(http.StatusOK, "Success", statistics)
+}
+
+// marketSample collects the values of the completed sales of a group
+type marketSample struct {
+	prices               []float64
+	pricesPerSquareMetre []float64 // Only for real estate with a known total area
+	daysOnMarket         []float64
+}
+
+func sum(values []float64) float64 {
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+func average(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	return sum(values) / float64(len(values))
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
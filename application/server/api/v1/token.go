@@ -0,0 +1,260 @@
+package v1
+
+import (
+	bc "application/blockchain"
+	"application/model"
+	"application/pkg/app"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TokenRequestBody struct {
+	TokenID string `json:"tokenId"` // Token ID (Real estate ID)
+}
+
+type BalanceOfRequestBody struct {
+	Owner string `json:"owner"` // Owner (Owner's Account ID)
+}
+
+type ApproveRequestBody struct {
+	AccountId string `json:"accountId"` // Owner or one of its operators (Account ID)
+	Approved  string `json:"approved"`  // Account approved, empty to clear the approval (Account ID)
+	TokenID   string `json:"tokenId"`   // Token ID (Real estate ID)
+}
+
+type SetApprovalForAllRequestBody struct {
+	AccountId string `json:"accountId"` // Owner (Owner's Account ID)
+	Operator  string `json:"operator"`  // Operator (Operator's Account ID)
+	Approved  bool   `json:"approved"`  // Whether the operator is approved
+}
+
+type TransferFromRequestBody struct {
+	AccountId string `json:"accountId"` // Owner, approved account or operator (Account ID)
+	From      string `json:"from"`      // Current owner (Account ID)
+	To        string `json:"to"`        // New owner (Account ID)
+	TokenID   string `json:"tokenId"`   // Token ID (Real estate ID)
+}
+
+type IsApprovedForAllRequestBody struct {
+	Owner    string `json:"owner"`    // Owner (Owner's Account ID)
+	Operator string `json:"operator"` // Operator (Operator's Account ID)
+}
+
+func OwnerOf(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(TokenRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.TokenID == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelQueryRequest("ownerOf", model.OwnerOfRequest{TokenID: body.TokenID})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", map[string]interface{}{"tokenId": body.TokenID, "owner": string(resp.Payload)})
+}
+
+func BalanceOf(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(BalanceOfRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.Owner == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelQueryRequest("balanceOf", model.BalanceOfRequest{Owner: body.Owner})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	balance, err := strconv.Atoi(string(resp.Payload))
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", map[string]interface{}{"owner": body.Owner, "balance": balance})
+}
+
+func Approve(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(ApproveRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.AccountId == "" || body.TokenID == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	request := model.ApproveRequest{
+		AccountId: body.AccountId,
+		Approved:  body.Approved,
+		TokenID:   body.TokenID,
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelExecuteRequest("approve", request)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
+
+func SetApprovalForAll(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(SetApprovalForAllRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.AccountId == "" || body.Operator == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	request := model.SetApprovalForAllRequest{
+		AccountId: body.AccountId,
+		Operator:  body.Operator,
+		Approved:  body.Approved,
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelExecuteRequest("setApprovalForAll", request)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
+
+func TransferFrom(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(TransferFromRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.AccountId == "" || body.From == "" || body.To == "" || body.TokenID == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	request := model.TransferFromRequest{
+		AccountId: body.AccountId,
+		From:      body.From,
+		To:        body.To,
+		TokenID:   body.TokenID,
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelExecuteRequest("transferFrom", request)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
+
+func GetApproved(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(TokenRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.TokenID == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelQueryRequest("getApproved", model.GetApprovedRequest{TokenID: body.TokenID})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", map[string]interface{}{"tokenId": body.TokenID, "approved": string(resp.Payload)})
+}
+
+func IsApprovedForAll(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(IsApprovedForAllRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.Owner == "" || body.Operator == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelQueryRequest("isApprovedForAll", model.IsApprovedForAllRequest{Owner: body.Owner, Operator: body.Operator})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	approved, err := strconv.ParseBool(string(resp.Payload))
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", map[string]interface{}{"owner": body.Owner, "operator": body.Operator, "approved": approved})
+}
+
+func TokenMetadata(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(TokenRequestBody)
+	// Parse the body parameters
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Parameter error: %s", err.Error()))
+		return
+	}
+	if body.TokenID == "" {
+		appG.Response(http.StatusBadRequest, "Failure", "Parameters cannot be empty")
+		return
+	}
+	// Invoke the smart contract
+	resp, err := bc.ChannelQueryRequest("tokenMetadata", model.TokenMetadataRequest{TokenID: body.TokenID})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "Failure", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "Success", data)
+}
@@ -0,0 +1,22 @@
+package blockchain
+
+import (
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/ledger"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+)
+
+// QueryBlockNumber returns the number of the block that holds a transaction
+func QueryBlockNumber(txID string) (uint64, error) {
+	// Create a ledger client, indicating the identity on the channel
+	ctx := sdk.ChannelContext(channelName, fabsdk.WithUser(user))
+	cli, err := ledger.New(ctx)
+	if err != nil {
+		return 0, err
+	}
+	block, err := cli.QueryBlockByTxID(fab.TransactionID(txID), ledger.WithTargetEndpoints(endpoints...))
+	if err != nil {
+		return 0, err
+	}
+	return block.GetHeader().GetNumber(), nil
+}
@@ -0,0 +1,89 @@
+package blockchain
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+)
+
+// RequestSchemaVersion is the version of the JSON request documents the chaincode accepts
+const RequestSchemaVersion = 1
+
+const (
+	transientSaltKey = "salt" // Transient data key of the random salt of the private data, see chaincode/model/model.go
+	saltLength       = 32     // Random bytes of the salt, at least the SaltMinLength the chaincode requires
+)
+
+// ChannelExecuteRequest invokes a chaincode function with its request struct, see model/request.go.
+// Fields tagged contract:"transient" are passed in the transient data along with a random salt for the private data written.
+func ChannelExecuteRequest(fcn string, request interface{}) (channel.Response, error) {
+	args, transient, err := requestDocument(request)
+	if err != nil {
+		return channel.Response{}, err
+	}
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return channel.Response{}, err
+	}
+	transient[transientSaltKey] = salt
+	return ChannelExecute(fcn, args, transient)
+}
+
+// ChannelQueryRequest queries a chaincode function with its request struct, see model/request.go
+func ChannelQueryRequest(fcn string, request interface{}) (channel.Response, error) {
+	args, _, err := requestDocument(request)
+	if err != nil {
+		return channel.Response{}, err
+	}
+	return ChannelQuery(fcn, args)
+}
+
+// requestDocument serializes a request struct into the single JSON document argument, adding the schema version.
+// The fields tagged contract:"transient" are left out of the document and returned as transient data instead.
+func requestDocument(request interface{}) ([][]byte, map[string][]byte, error) {
+	fields := map[string]json.RawMessage{}
+	transient := map[string][]byte{}
+	if request != nil {
+		body, err := json.Marshal(request)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return nil, nil, err
+		}
+		for _, name := range transientFields(reflect.TypeOf(request)) {
+			if value, ok := fields[name]; ok {
+				transient[name] = value
+				delete(fields, name)
+			}
+		}
+	}
+	fields["schemaVersion"], _ = json.Marshal(RequestSchemaVersion)
+	document, err := json.Marshal(fields)
+	if err != nil {
+		return nil, nil, err
+	}
+	return [][]byte{document}, transient, nil
+}
+
+// transientFields returns the JSON names of the fields of a request struct tagged contract:"transient"
+func transientFields(t reflect.Type) []string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if tag := field.Tag.Get("contract"); tag == "" || strings.Split(tag, ",")[0] != "transient" {
+			continue
+		}
+		names = append(names, strings.Split(field.Tag.Get("json"), ",")[0])
+	}
+	return names
+}
@@ -28,8 +28,8 @@ func Init() {
 	}
 }
 
-// ChannelExecute interacts with the blockchain
-func ChannelExecute(fcn string, args [][]byte) (channel.Response, error) {
+// ChannelExecute interacts with the blockchain, passing private data in the transient map so that it stays out of the transaction
+func ChannelExecute(fcn string, args [][]byte, transient map[string][]byte) (channel.Response, error) {
 	// Create a client, indicating the identity on the channel
 	ctx := sdk.ChannelContext(channelName, fabsdk.WithUser(user))
 	cli, err := channel.New(ctx)
@@ -38,9 +38,10 @@ func ChannelExecute(fcn string, args [][]byte) (channel.Response, error) {
 	}
 	// Write operation to the blockchain ledger (invokes the chaincode)
 	resp, err := cli.Execute(channel.Request{
-		ChaincodeID: chainCodeName,
-		Fcn:         fcn,
-		Args:        args,
+		ChaincodeID:  chainCodeName,
+		Fcn:          fcn,
+		Args:         args,
+		TransientMap: transient,
 	}, channel.WithTargetEndpoints(endpoints...))
 	if err != nil {
 		return channel.Response{}, err
@@ -4,21 +4,18 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"time"
 
 	"application/blockchain"
+	"application/pkg/certificate"
 	"application/pkg/cron"
 	"application/routers"
 )
 
 func main() {
-	timeLocal, err := time.LoadLocation("Asia/Shanghai")
-	if err != nil {
-		log.Printf("Time zone setting failed: %s", err)
-	}
-	time.Local = timeLocal
-
 	blockchain.Init()
+	if err := certificate.Init(); err != nil {
+		panic(err)
+	}
 	go cron.Init()
 
 	endPoint := fmt.Sprintf("0.0.0.0:%d", 8888)
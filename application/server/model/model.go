@@ -12,6 +12,86 @@ type Selling struct {
 	CreateTime    string  `json:"createTime"`    // Creation time
 	SalePeriod    int     `json:"salePeriod"`    // Validity period of the smart contract (in days)
 	SellingStatus string  `json:"sellingStatus"` // Sales status
+	// Payment schedule; without installments the buyer pays the full price at once
+	Deposit         float64       `json:"deposit"`         // Earnest money paid when the buyer commits
+	Installments    []Installment `json:"installments"`    // Installments due after the deposit
+	OnMissedPayment string        `json:"onMissedPayment"` // What happens when an installment deadline is missed
+	GracePeriod     int           `json:"gracePeriod"`     // Extra time granted for a missed installment (in days)
+	AmountPaid      float64       `json:"amountPaid"`      // Amount paid by the buyer and held in escrow
+	// Cancellation
+	CancelledBy   string  `json:"cancelledBy"`   // Account ID of the party that cancelled the sale
+	CancelReason  string  `json:"cancelReason"`  // Reason given for cancelling
+	PenaltyPaidBy string  `json:"penaltyPaidBy"` // Party that paid the cancellation penalty (seller or buyer)
+	Penalty       float64 `json:"penalty"`       // Cancellation penalty paid to the other party
+	// Completion
+	CompleteTime string `json:"completeTime"` // Time the title was transferred to the buyer
+}
+
+// RealEstate Real estate registered on the ledger
+type RealEstate struct {
+	RealEstateID string        `json:"realEstateId"`        // Real estate ID
+	Proprietor   string        `json:"proprietor"`          // Owner (Owner's AccountID)
+	Encumbrances []Encumbrance `json:"encumbrances"`        // Encumbrances on the real estate
+	TotalArea    float64       `json:"totalArea"`           // Total area
+	LivingSpace  float64       `json:"livingSpace"`         // Living space
+	PropertyType string        `json:"propertyType"`        // Property type, a value of PropertyTypeConstant or empty
+	TransferTxID string        `json:"transferTxId"`        // ID of the transaction that gave the real estate to its proprietor
+	Easements    []Easement    `json:"easements,omitempty"` // Active easements benefiting or burdening the real estate
+}
+
+// Easement A right of a dominant real estate over a servient one
+type Easement struct {
+	EasementID           string `json:"easementId"`           // Easement ID
+	Type                 string `json:"type"`                 // Easement type
+	Description          string `json:"description"`          // What the easement allows
+	DominantRealEstateID string `json:"dominantRealEstateId"` // Real estate that benefits from the easement
+	ServientRealEstateID string `json:"servientRealEstateId"` // Real estate burdened by the easement
+	StartTime            string `json:"startTime"`            // Time it was registered
+	EndTime              string `json:"endTime"`              // End of its term, empty when permanent
+	CreatedBy            string `json:"createdBy"`            // Admin that registered it (AccountID)
+	EasementStatus       string `json:"easementStatus"`       // Easement status
+}
+
+// Encumbrance A process or right that binds a real estate
+type Encumbrance struct {
+	Type           string `json:"type"`           // Encumbrance type, a value of EncumbranceTypeConstant
+	Reference      string `json:"reference"`      // Reference of the record or document that created it, e.g. the sale
+	CreatedBy      string `json:"createdBy"`      // AccountID of the party that created it
+	StartTime      string `json:"startTime"`      // Time it takes effect
+	EndTime        string `json:"endTime"`        // Time it lapses, empty when it lasts until it is removed
+	BlocksTransfer bool   `json:"blocksTransfer"` // Whether the real estate cannot be sold or donated while it is in force
+}
+
+// EncumbranceTypeConstant Encumbrance Types
+var EncumbranceTypeConstant = func() map[string]string {
+	return map[string]string{
+		"sale":      "Sale",          // Listed for sale
+		"donation":  "Donation",      // Offered as a donation
+		"courtLock": "Court Lock",    // Frozen by a court order
+		"mortgage":  "Mortgage Lien", // Secures a loan
+		"easement":  "Easement",      // Right of way or use granted to another party
+		"lease":     "Lease",         // Let to a tenant
+		"legacy":    "Legacy",        // Sale or donation recorded before encumbrances were typed
+	}
+}
+
+// PropertyTypeConstant Property Types
+var PropertyTypeConstant = func() map[string]string {
+	return map[string]string{
+		"apartment":  "Apartment",  // Unit in a multi-unit residential building
+		"house":      "House",      // Detached or semi-detached residential building
+		"commercial": "Commercial", // Office, retail or industrial premises
+		"land":       "Land",       // Undeveloped plot
+	}
+}
+
+// Installment One dated payment of a sale's payment schedule
+type Installment struct {
+	Amount    float64 `json:"amount"`    // Amount due
+	DueDays   int     `json:"dueDays"`   // Days after the deposit when the installment is due
+	DueTime   string  `json:"dueTime"`   // Deadline of the installment
+	GraceTime string  `json:"graceTime"` // Extended deadline granted after the deadline was missed
+	PaidTime  string  `json:"paidTime"`  // Payment time, empty while unpaid
 }
 
 // SellingStatusConstant Sales Status
@@ -22,6 +102,9 @@ var SellingStatusConstant = func() map[string]string {
 		"expired":   "Expired",     // Sale period has expired
 		"delivery":  "In Delivery", // Buyer has made the purchase and payment, waiting for seller to confirm receipt; if the seller fails to confirm receipt, the buyer can cancel and get a refund
 		"done":      "Completed",   // Seller confirms receipt of funds, transaction completed
+		"dispute":   "In Dispute",  // Buyer or seller raised a dispute during delivery; the sale is frozen until an arbitrator resolves it
+		"payment":   "In Payment",  // Buyer paid the deposit and is paying installments; the sale moves to delivery after the final payment
+		"forfeited": "Forfeited",   // Buyer missed an installment deadline and the deposit went to the seller
 	}
 }
 
@@ -40,7 +123,7 @@ type Donating struct {
 var DonatingStatusConstant = func() map[string]string {
 	return map[string]string{
 		"donatingStart": "In Donation", // Donor initiates the donation contract, waiting for the Grantee to confirm acceptance
-		"cancelled":     "Canceled",   // Donor cancels the donation before the Grantee confirms acceptance or the Grantee cancels the acceptance of the donation
-		"done":          "Completed",  // Grantee confirms acceptance, transaction completed
+		"cancelled":     "Canceled",    // Donor cancels the donation before the Grantee confirms acceptance or the Grantee cancels the acceptance of the donation
+		"done":          "Completed",   // Grantee confirms acceptance, transaction completed
 	}
 }
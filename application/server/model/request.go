@@ -0,0 +1,365 @@
+package model
+
+// Requests of the chaincode functions, the same structs as chaincode/model/request.go.
+// They are sent as JSON request documents by blockchain.ChannelExecuteRequest and blockchain.ChannelQueryRequest;
+// fields the chaincode does not require are omitted when empty, and fields tagged contract:"transient" are private and
+// passed in the transient data instead of the document.
+
+// QueryAccountListRequest queries accounts by ID, or all accounts when none are given
+type QueryAccountListRequest struct {
+	AccountIds []string `json:"accountIds,omitempty"` // Account IDs
+}
+
+// CreateRealEstateRequest creates a real estate (admin)
+type CreateRealEstateRequest struct {
+	AccountId    string  `json:"accountId"`              // Account ID of the admin
+	Proprietor   string  `json:"proprietor"`             // Owner (Owner) (Owner's AccountId)
+	TotalArea    float64 `json:"totalArea"`              // Total area
+	LivingSpace  float64 `json:"livingSpace"`            // Living space
+	RealEstateID string  `json:"realEstateId,omitempty"` // Cadastral or parcel number assigned by the registrar, generated when empty
+	PropertyType string  `json:"propertyType,omitempty"` // Property type, a key of PropertyTypeConstant
+}
+
+// RealEstateBatchItem is one real estate of a batch registration
+type RealEstateBatchItem struct {
+	Proprietor   string  `json:"proprietor"`             // Owner (Owner's AccountId)
+	TotalArea    float64 `json:"totalArea"`              // Total area
+	LivingSpace  float64 `json:"livingSpace"`            // Living space
+	RealEstateID string  `json:"realEstateId,omitempty"` // Cadastral or parcel number assigned by the registrar, generated when empty
+	PropertyType string  `json:"propertyType,omitempty"` // Property type, a key of PropertyTypeConstant
+}
+
+// CreateRealEstateBatchRequest creates several real estates at once, all or none (admin)
+type CreateRealEstateBatchRequest struct {
+	AccountId   string                `json:"accountId"`   // Account ID of the admin
+	RealEstates []RealEstateBatchItem `json:"realEstates"` // Real estates to create, at most RealEstateBatchLimit
+}
+
+// RealEstateBatchLimit is the largest number of real estates a single createRealEstateBatch transaction may register
+const RealEstateBatchLimit = 100
+
+// QueryRealEstateRequest queries a real estate by ID whoever owns it
+type QueryRealEstateRequest struct {
+	RealEstateID string `json:"realEstateId"` // Real estate ID
+}
+
+// QueryRealEstateListRequest queries real estate by owner and ID prefix
+type QueryRealEstateListRequest struct {
+	Proprietor   string `json:"proprietor,omitempty"`   // Owner (Owner's AccountId)
+	RealEstateID string `json:"realEstateId,omitempty"` // Real estate ID
+}
+
+// InstallmentRequest is one installment of the payment schedule requested by the seller
+type InstallmentRequest struct {
+	Amount  float64 `json:"amount"`  // Amount due
+	DueDays int     `json:"dueDays"` // Days after the deposit by which the installment must be paid
+}
+
+// CreateSellingRequest initiates a sale, optionally paid with a deposit and installments.
+// The price, deposit and installments are private and passed in the transient data.
+type CreateSellingRequest struct {
+	ObjectOfSale    string               `json:"objectOfSale"`                                // Object of sale (the real estate RealEstateID being sold)
+	Seller          string               `json:"seller"`                                      // Seller (the seller's AccountId)
+	Price           float64              `json:"price" contract:"transient"`                  // Price
+	SalePeriod      int                  `json:"salePeriod"`                                  // Validity period of the smart contract (in days)
+	Deposit         float64              `json:"deposit,omitempty" contract:"transient"`      // Deposit paid by the buyer on purchase
+	OnMissedPayment string               `json:"onMissedPayment,omitempty"`                   // Missed payment policy
+	GracePeriod     int                  `json:"gracePeriod,omitempty"`                       // Days a missed installment is extended by under the grace policy
+	Agent           string               `json:"agent,omitempty"`                             // AccountId of the seller's agent acting on its behalf
+	Installments    []InstallmentRequest `json:"installments,omitempty" contract:"transient"` // Installments due after the deposit
+}
+
+// CreateSellingByBuyRequest buys a real estate on sale
+type CreateSellingByBuyRequest struct {
+	ObjectOfSale string `json:"objectOfSale"`    // Object of sale
+	Seller       string `json:"seller"`          // Seller's AccountId
+	Buyer        string `json:"buyer"`           // Buyer's AccountId
+	Agent        string `json:"agent,omitempty"` // AccountId of the buyer's agent acting on its behalf
+}
+
+// QuerySellingListRequest queries sales by seller and real estate prefix
+type QuerySellingListRequest struct {
+	Seller       string `json:"seller,omitempty"`       // Seller's AccountId
+	ObjectOfSale string `json:"objectOfSale,omitempty"` // Object of sale
+}
+
+// QuerySellingListByBuyerRequest queries the purchases of a buyer
+type QuerySellingListByBuyerRequest struct {
+	Buyer string `json:"buyer"` // Buyer's AccountId
+}
+
+// UpdateSellingRequest confirms, cancels or expires a sale
+type UpdateSellingRequest struct {
+	ObjectOfSale string `json:"objectOfSale"`       // Object of sale
+	Seller       string `json:"seller"`             // Seller's AccountId
+	Buyer        string `json:"buyer,omitempty"`    // Buyer's AccountId, empty while the sale has no buyer
+	Status       string `json:"status"`             // done, cancelled or expired
+	Operator     string `json:"operator,omitempty"` // AccountId of the party cancelling
+	Reason       string `json:"reason,omitempty"`   // Reason for cancelling
+	Agent        string `json:"agent,omitempty"`    // AccountId of the agent acting for the seller, or for the party cancelling
+}
+
+// SetCancellationPenaltyRequest sets the penalty owed when a party cancels a sale in a status (admin)
+type SetCancellationPenaltyRequest struct {
+	AccountId     string  `json:"accountId"`     // Account ID of the admin
+	Canceller     string  `json:"canceller"`     // seller, buyer or admin
+	SellingStatus string  `json:"sellingStatus"` // payment or delivery
+	PaidBy        string  `json:"paidBy"`        // seller or buyer
+	Rate          float64 `json:"rate"`          // Percentage of the price paid, 0 for none
+}
+
+// PayInstallmentRequest pays the next installment of a sale
+type PayInstallmentRequest struct {
+	ObjectOfSale string `json:"objectOfSale"` // Object of sale
+	Seller       string `json:"seller"`       // Seller's AccountId
+	Buyer        string `json:"buyer"`        // Buyer's AccountId
+}
+
+// ProcessMissedInstallmentRequest applies the missed payment policy of a sale
+type ProcessMissedInstallmentRequest struct {
+	ObjectOfSale string `json:"objectOfSale"` // Object of sale
+	Seller       string `json:"seller"`       // Seller's AccountId
+}
+
+// CreateDonatingRequest initiates a donation
+type CreateDonatingRequest struct {
+	ObjectOfDonating string `json:"objectOfDonating"` // Object of donation (the real estate RealEstateID being donated)
+	Donor            string `json:"donor"`            // Donor's AccountId
+	Grantee          string `json:"grantee"`          // Grantee's AccountId
+	Agent            string `json:"agent,omitempty"`  // AccountId of the donor's agent acting on its behalf
+}
+
+// QueryDonatingListRequest queries donations by donor and real estate prefix
+type QueryDonatingListRequest struct {
+	Donor            string `json:"donor,omitempty"`            // Donor's AccountId
+	ObjectOfDonating string `json:"objectOfDonating,omitempty"` // Object of donation
+}
+
+// QueryDonatingListByGranteeRequest queries the donations received by a grantee
+type QueryDonatingListByGranteeRequest struct {
+	Grantee string `json:"grantee"` // Grantee's AccountId
+}
+
+// UpdateDonatingRequest confirms or cancels a donation
+type UpdateDonatingRequest struct {
+	ObjectOfDonating string `json:"objectOfDonating"` // Object of donation
+	Donor            string `json:"donor"`            // Donor's AccountId
+	Grantee          string `json:"grantee"`          // Grantee's AccountId
+	Status           string `json:"status"`           // done or cancelled
+	Agent            string `json:"agent,omitempty"`  // AccountId of the agent acting for the grantee, or for the donor to cancel
+}
+
+// RaiseDisputeRequest raises a dispute on a sale in delivery
+type RaiseDisputeRequest struct {
+	ObjectOfSale string   `json:"objectOfSale"`       // Object of sale
+	Seller       string   `json:"seller"`             // Seller's AccountId
+	RaisedBy     string   `json:"raisedBy"`           // AccountId of the party raising the dispute
+	Reason       string   `json:"reason"`             // Reason for the dispute
+	Evidence     []string `json:"evidence,omitempty"` // Hashes of the evidence documents
+}
+
+// AddDisputeEvidenceRequest adds evidence to the open dispute of a sale
+type AddDisputeEvidenceRequest struct {
+	ObjectOfSale string   `json:"objectOfSale"` // Object of sale
+	Seller       string   `json:"seller"`       // Seller's AccountId
+	AccountId    string   `json:"accountId"`    // AccountId of the party submitting the evidence
+	Evidence     []string `json:"evidence"`     // Hashes of the evidence documents
+}
+
+// ResolveDisputeRequest resolves the open dispute of a sale (arbitrator)
+type ResolveDisputeRequest struct {
+	ObjectOfSale   string  `json:"objectOfSale"`             // Object of sale
+	Seller         string  `json:"seller"`                   // Seller's AccountId
+	Arbitrator     string  `json:"arbitrator"`               // Arbitrator's AccountId
+	Decision       string  `json:"decision"`                 // refund, partialRefund, complete or penalty
+	Amount         float64 `json:"amount,omitempty"`         // Amount refunded or paid as a penalty
+	PenalizedParty string  `json:"penalizedParty,omitempty"` // AccountId of the party paying the penalty
+	DecisionNote   string  `json:"decisionNote,omitempty"`   // Arbitrator's note on the decision
+}
+
+// QueryDisputeListRequest queries disputes by seller and real estate prefix
+type QueryDisputeListRequest struct {
+	Seller       string `json:"seller,omitempty"`       // Seller's AccountId
+	ObjectOfSale string `json:"objectOfSale,omitempty"` // Object of sale
+}
+
+// FreezeRealEstateRequest freezes a real estate by a court order (admin or authority)
+type FreezeRealEstateRequest struct {
+	AccountId      string `json:"accountId"`      // Account ID of the admin or authority
+	RealEstateID   string `json:"realEstateId"`   // Real estate ID
+	OrderReference string `json:"orderReference"` // Reference of the court order
+}
+
+// UnfreezeRealEstateRequest lifts the freeze of a court order (admin or authority)
+type UnfreezeRealEstateRequest struct {
+	AccountId      string `json:"accountId"`      // Account ID of the admin or authority
+	RealEstateID   string `json:"realEstateId"`   // Real estate ID
+	OrderReference string `json:"orderReference"` // Reference of the court order that froze it
+}
+
+// ForceTransferRequest transfers a real estate to a new proprietor by a court order (admin or authority)
+type ForceTransferRequest struct {
+	AccountId      string `json:"accountId"`      // Account ID of the admin or authority
+	RealEstateID   string `json:"realEstateId"`   // Real estate ID
+	NewProprietor  string `json:"newProprietor"`  // AccountId of the new proprietor
+	OrderReference string `json:"orderReference"` // Reference of the court order
+}
+
+// QueryCourtOrderListRequest queries the court orders carried out on a real estate
+type QueryCourtOrderListRequest struct {
+	RealEstateID string `json:"realEstateId,omitempty"` // Real estate ID
+}
+
+// RegisterEasementRequest registers an easement of a dominant real estate over a servient one (admin)
+type RegisterEasementRequest struct {
+	AccountId            string `json:"accountId"`            // Account ID of the admin
+	Type                 string `json:"type"`                 // rightOfWay, utility, drainage, light, support or other
+	Description          string `json:"description"`          // What the easement allows
+	DominantRealEstateID string `json:"dominantRealEstateId"` // Real estate that benefits from the easement
+	ServientRealEstateID string `json:"servientRealEstateId"` // Real estate burdened by the easement
+	TermDays             int    `json:"termDays,omitempty"`   // Term of the easement in days, permanent when 0
+}
+
+// ReleaseEasementRequest releases an easement before the end of its term (admin)
+type ReleaseEasementRequest struct {
+	AccountId  string `json:"accountId"`  // Account ID of the admin
+	EasementID string `json:"easementId"` // Easement ID
+}
+
+// QueryEasementListRequest queries the easements of a real estate, as dominant or servient
+type QueryEasementListRequest struct {
+	RealEstateID string `json:"realEstateId,omitempty"` // Real estate ID
+}
+
+// RequestKycRequest requests identity verification for an account
+type RequestKycRequest struct {
+	AccountId string `json:"accountId"` // Account ID
+}
+
+// ReviewKycRequest verifies or rejects the pending identity verification of an account (verifier)
+type ReviewKycRequest struct {
+	Verifier     string `json:"verifier"`               // Verifier's AccountId
+	AccountId    string `json:"accountId"`              // Account ID under review
+	Decision     string `json:"decision"`               // verified or rejected
+	ValidityDays int    `json:"validityDays,omitempty"` // Days the verification is valid, required when verified
+}
+
+// SetEndorsementPolicyRequest sets the organizations that must endorse changes to a real estate (admin)
+type SetEndorsementPolicyRequest struct {
+	AccountId    string   `json:"accountId"`      // Account ID of the admin
+	RealEstateID string   `json:"realEstateId"`   // Real estate ID
+	Orgs         []string `json:"orgs,omitempty"` // MSP IDs of the organizations, none to fall back to the chaincode policy
+}
+
+// QueryEndorsementPolicyRequest queries the endorsement policy of a real estate
+type QueryEndorsementPolicyRequest struct {
+	RealEstateID string `json:"realEstateId"` // Real estate ID
+}
+
+// GrantDelegationRequest lets an agent act on behalf of a principal within a scope
+type GrantDelegationRequest struct {
+	Principal    string `json:"principal"`    // AccountId granting the authority
+	Agent        string `json:"agent"`        // AccountId acting on its behalf
+	Scope        string `json:"scope"`        // sell, buy, donate or acceptDonation
+	RealEstateID string `json:"realEstateId"` // Real estate the authority is limited to, empty for any
+	ValidityDays int    `json:"validityDays"` // Days the delegation is valid
+}
+
+// RevokeDelegationRequest revokes a delegation before it lapses
+type RevokeDelegationRequest struct {
+	Principal    string `json:"principal"`    // AccountId that granted the delegation
+	DelegationID string `json:"delegationId"` // Delegation ID
+}
+
+// QueryDelegationListRequest queries the delegations granted by a principal and/or to an agent
+type QueryDelegationListRequest struct {
+	Principal string `json:"principal,omitempty"` // AccountId that granted them
+	Agent     string `json:"agent,omitempty"`     // AccountId they were granted to
+}
+
+// QueryDelegatedActionListRequest queries the actions taken on behalf of a principal
+type QueryDelegatedActionListRequest struct {
+	Principal string `json:"principal"` // AccountId acted for
+}
+
+// OwnerOfRequest queries the owner of a real estate token
+type OwnerOfRequest struct {
+	TokenID string `json:"tokenId"` // RealEstateID
+}
+
+// BalanceOfRequest counts the real estate tokens of an owner
+type BalanceOfRequest struct {
+	Owner string `json:"owner"` // Owner's AccountId
+}
+
+// ApproveRequest approves an account to transfer a real estate token, or clears the approval
+type ApproveRequest struct {
+	AccountId string `json:"accountId"` // AccountId of the owner or of one of its operators
+	Approved  string `json:"approved"`  // AccountId approved, empty to clear the approval
+	TokenID   string `json:"tokenId"`   // RealEstateID
+}
+
+// SetApprovalForAllRequest approves or revokes an operator for every real estate token of an owner
+type SetApprovalForAllRequest struct {
+	AccountId string `json:"accountId"` // Owner's AccountId
+	Operator  string `json:"operator"`  // Operator's AccountId
+	Approved  bool   `json:"approved"`  // Whether the operator is approved
+}
+
+// TransferFromRequest transfers a real estate token
+type TransferFromRequest struct {
+	AccountId string `json:"accountId"` // AccountId of the owner, the approved account or an operator
+	From      string `json:"from"`      // Current owner's AccountId
+	To        string `json:"to"`        // New owner's AccountId
+	TokenID   string `json:"tokenId"`   // RealEstateID
+}
+
+// GetApprovedRequest queries the account approved to transfer a real estate token
+type GetApprovedRequest struct {
+	TokenID string `json:"tokenId"` // RealEstateID
+}
+
+// IsApprovedForAllRequest queries whether an operator is approved for every real estate token of an owner
+type IsApprovedForAllRequest struct {
+	Owner    string `json:"owner"`    // Owner's AccountId
+	Operator string `json:"operator"` // Operator's AccountId
+}
+
+// TokenMetadataRequest queries the metadata of a real estate token
+type TokenMetadataRequest struct {
+	TokenID string `json:"tokenId"` // RealEstateID
+}
+
+// QueryActiveListingsRequest queries one page of the sales in progress across all sellers
+type QueryActiveListingsRequest struct {
+	PageSize int     `json:"pageSize"`           // Largest number of listings returned
+	Bookmark string  `json:"bookmark,omitempty"` // Bookmark returned by the previous page
+	MinPrice float64 `json:"minPrice,omitempty"` // Lowest price, 0 for none
+	MaxPrice float64 `json:"maxPrice,omitempty"` // Highest price, 0 for none
+	MinArea  float64 `json:"minArea,omitempty"`  // Smallest total area, 0 for none
+	MaxArea  float64 `json:"maxArea,omitempty"`  // Largest total area, 0 for none
+}
+
+// AuditLedgerRequest checks the ledger invariants (admin)
+type AuditLedgerRequest struct {
+	AccountId string `json:"accountId"` // Account ID of the admin
+}
+
+// QueryMigrationBatchRequest queries the keys of one batch of records of an object type to migrate
+type QueryMigrationBatchRequest struct {
+	ObjectType string `json:"objectType"`         // Object type to migrate
+	BatchSize  int    `json:"batchSize"`          // Largest number of keys returned
+	Bookmark   string `json:"bookmark,omitempty"` // Bookmark returned by the previous batch
+}
+
+// MigrateRequest migrates one batch of records of an object type, by the keys queryMigrationBatch returned (admin)
+type MigrateRequest struct {
+	AccountId  string   `json:"accountId"`  // Account ID of the admin
+	ObjectType string   `json:"objectType"` // Object type to migrate
+	Keys       []string `json:"keys"`       // Keys of the records
+}
+
+// QueryStateMachineRequest exports the transition table of a state machine
+type QueryStateMachineRequest struct {
+	Machine string `json:"machine"` // selling or donating
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Error codes returned by the chaincode in its error envelope
+const (
+	CodeNotFound          = "NOT_FOUND"
+	CodeForbidden         = "FORBIDDEN"
+	CodeConflict          = "CONFLICT"
+	CodeInvalidArgument   = "INVALID_ARGUMENT"
+	CodeInsufficientFunds = "INSUFFICIENT_FUNDS"
+	CodeInternal          = "INTERNAL"
+)
+
+// Error is the data of every failed response
+type Error struct {
+	Code    string `json:"code"`    // One of the error codes
+	Message string `json:"message"` // Human-readable description
+}
+
+// StatusOfCode returns the HTTP status of each error code
+func StatusOfCode() map[string]int {
+	return map[string]int{
+		CodeNotFound:          http.StatusNotFound,
+		CodeForbidden:         http.StatusForbidden,
+		CodeConflict:          http.StatusConflict,
+		CodeInvalidArgument:   http.StatusBadRequest,
+		CodeInsufficientFunds: http.StatusPaymentRequired,
+		CodeInternal:          http.StatusInternalServerError,
+	}
+}
+
+// ErrorOf returns the Error of a failed chaincode call, for handlers that report failures inside a successful response
+func ErrorOf(err error) Error {
+	_, e := failure(http.StatusInternalServerError, err)
+	return e
+}
+
+// failure turns the data of a failed response into an Error, taking the code from the chaincode error envelope
+// embedded in SDK error messages, or from the HTTP status the handler chose otherwise
+func failure(httpCode int, data interface{}) (int, Error) {
+	if e, ok := data.(Error); ok {
+		return httpCode, e
+	}
+	message := fmt.Sprint(data)
+	if err, ok := data.(error); ok {
+		message = err.Error()
+	}
+	// The SDK reports the chaincode message after its own context, e.g. "... Description: {"code":...}"
+	if i := strings.Index(message, `{"code":`); i >= 0 {
+		var e Error
+		if err := json.NewDecoder(strings.NewReader(message[i:])).Decode(&e); err == nil {
+			if status, ok := StatusOfCode()[e.Code]; ok {
+				return status, e
+			}
+		}
+	}
+	for code, status := range StatusOfCode() {
+		if status == httpCode {
+			return httpCode, Error{Code: code, Message: message}
+		}
+	}
+	return httpCode, Error{Code: CodeInternal, Message: message}
+}
@@ -1,6 +1,8 @@
 package app
 
 import (
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -14,7 +16,15 @@ type Response struct {
 	Data interface{} `json:"data"`
 }
 
+// Response writes the JSON body. Failures always carry "Failure" and an Error as data, and chaincode
+// error codes override the HTTP status chosen by the handler. Times in successful data are given in the client's time zone.
 func (g *Gin) Response(httpCode int, errMsg string, data interface{}) {
+	if httpCode >= http.StatusBadRequest {
+		httpCode, data = failure(httpCode, data)
+		errMsg = "Failure"
+	} else {
+		data = g.localize(data)
+	}
 	g.C.JSON(httpCode, Response{
 		Code: httpCode,
 		Msg:  errMsg,
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"application/pkg/timeutil"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TimezoneHeader is the request header carrying the IANA time zone of the client, e.g. Europe/Paris.
+// The timezone query parameter is accepted as well.
+const TimezoneHeader = "X-Timezone"
+
+// timezoneKey is the context key of the time zone of the request
+const timezoneKey = "timezone"
+
+// Timezone reads the time zone of the client, so that responses give the times in it.
+// Without one the times are given in UTC, as stored on the ledger.
+func Timezone() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.GetHeader(TimezoneHeader)
+		if name == "" {
+			name = c.Query("timezone")
+		}
+		if name == "" {
+			c.Next()
+			return
+		}
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			appG := Gin{C: c}
+			appG.Response(http.StatusBadRequest, "Failure", fmt.Sprintf("Unknown time zone %s: %s", name, err.Error()))
+			c.Abort()
+			return
+		}
+		c.Set(timezoneKey, loc)
+		c.Next()
+	}
+}
+
+// Location returns the time zone of the client, UTC when it gave none
+func (g *Gin) Location() *time.Location {
+	if loc, ok := g.C.Get(timezoneKey); ok {
+		return loc.(*time.Location)
+	}
+	return time.UTC
+}
+
+// localize gives the times of the data of a response in the time zone of the client
+func (g *Gin) localize(data interface{}) interface{} {
+	loc := g.Location()
+	if loc == time.UTC {
+		return data
+	}
+	dataByte, err := json.Marshal(data)
+	if err != nil {
+		return data
+	}
+	decoder := json.NewDecoder(bytes.NewReader(dataByte))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return data
+	}
+	return timeutil.Localize(value, loc)
+}
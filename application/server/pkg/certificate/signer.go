@@ -0,0 +1,85 @@
+package certificate
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// Algorithm names how certificates are signed
+const Algorithm = "ECDSA-P256-SHA256"
+
+var (
+	keyPath = "certificate-key.pem" // Private key the server signs certificates with, created on first start
+	key     *ecdsa.PrivateKey
+)
+
+// Init loads the signing key, creating it when the key file does not exist yet
+func Init() error {
+	data, err := ioutil.ReadFile(keyPath)
+	if os.IsNotExist(err) {
+		if key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			return err
+		}
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600)
+	}
+	if err != nil {
+		return err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return fmt.Errorf("%s is not a PEM file", keyPath)
+	}
+	key, err = x509.ParseECPrivateKey(block.Bytes)
+	return err
+}
+
+// PublicKey returns the PEM encoded public key that verifies the signatures
+func PublicKey() (string, error) {
+	if key == nil {
+		return "", errors.New("The certificate signing key is not loaded")
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// Sign returns the base64 encoded signature of a payload
+func Sign(payload []byte) (string, error) {
+	if key == nil {
+		return "", errors.New("The certificate signing key is not loaded")
+	}
+	digest := sha256.Sum256(payload)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// Verify reports whether a signature returned by Sign matches the payload
+func Verify(payload []byte, signature string) bool {
+	if key == nil {
+		return false
+	}
+	der, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	digest := sha256.Sum256(payload)
+	return ecdsa.VerifyASN1(&key.PublicKey, digest[:], der)
+}
@@ -9,6 +9,7 @@ import (
 
 	bc "application/blockchain"
 	"application/model"
+	"application/pkg/timeutil"
 
 	"github.com/robfig/cron/v3"
 )
@@ -30,9 +31,9 @@ func Init() {
 func GoRun() {
 	log.Printf("Scheduled task has started")
 	// First, retrieve all sales
-	resp, err := bc.ChannelQuery("querySellingList", [][]byte{}) // Call the smart contract
+	resp, err := bc.ChannelQueryRequest("querySellingPrivateList", model.QuerySellingListRequest{}) // Call the smart contract
 	if err != nil {
-		log.Printf("Scheduled task - querySellingList failed: %s", err.Error())
+		log.Printf("Scheduled task - querySellingPrivateList failed: %s", err.Error())
 		return
 	}
 	// Deserialize JSON
@@ -47,19 +48,23 @@ func GoRun() {
 			v.SellingStatus == model.SellingStatusConstant()["delivery"] {
 			// Calculate the validity period in days
 			day, _ := time.ParseDuration(fmt.Sprintf("%dh", v.SalePeriod*24))
-			local, _ := time.LoadLocation("Local")
-			t, _ := time.ParseInLocation("2006-01-02 15:04:05", v.CreateTime, local)
+			t, err := timeutil.Parse(v.CreateTime)
+			if err != nil {
+				log.Printf("Scheduled task - Invalid creation time of the sale of %s: %s", v.ObjectOfSale, err.Error())
+				continue
+			}
 			vTime := t.Add(day)
 			// If time.Now() is greater than vTime, it means it has expired
-			if time.Now().Local().After(vTime) {
+			if time.Now().After(vTime) {
 				// Change the status to "Expired"
-				var bodyBytes [][]byte
-				bodyBytes = append(bodyBytes, []byte(v.ObjectOfSale))
-				bodyBytes = append(bodyBytes, []byte(v.Seller))
-				bodyBytes = append(bodyBytes, []byte(v.Buyer))
-				bodyBytes = append(bodyBytes, []byte("expired"))
+				request := model.UpdateSellingRequest{
+					ObjectOfSale: v.ObjectOfSale,
+					Seller:       v.Seller,
+					Buyer:        v.Buyer,
+					Status:       "expired",
+				}
 				// Call the smart contract
-				resp, err := bc.ChannelExecute("updateSelling", bodyBytes)
+				resp, err := bc.ChannelExecuteRequest("updateSelling", request)
 				if err != nil {
 					return
 				}
@@ -70,5 +75,30 @@ func GoRun() {
 				fmt.Println(data)
 			}
 		}
+		// Select those paying installments whose next deadline has passed
+		if v.SellingStatus == model.SellingStatusConstant()["payment"] {
+			for _, installment := range v.Installments {
+				if installment.PaidTime != "" {
+					continue
+				}
+				deadline := installment.DueTime
+				if installment.GraceTime != "" {
+					deadline = installment.GraceTime
+				}
+				t, err := timeutil.Parse(deadline)
+				if err != nil {
+					log.Printf("Scheduled task - Invalid installment deadline of the sale of %s: %s", v.ObjectOfSale, err.Error())
+					break
+				}
+				if time.Now().After(t) {
+					// Apply the missed payment policy: grant the grace period or forfeit the deposit
+					request := model.ProcessMissedInstallmentRequest{ObjectOfSale: v.ObjectOfSale, Seller: v.Seller}
+					if _, err := bc.ChannelExecuteRequest("processMissedInstallment", request); err != nil {
+						log.Printf("Scheduled task - processMissedInstallment failed: %s", err.Error())
+					}
+				}
+				break
+			}
+		}
 	}
 }
@@ -0,0 +1,54 @@
+package timeutil
+
+import (
+	"strings"
+	"time"
+)
+
+// Layout is the layout of the times stored by the chaincode: RFC 3339 in UTC, with nanoseconds
+const Layout = time.RFC3339Nano
+
+// LegacyLayout is the layout of the times the chaincode wrote before schema version 4, without a time zone
+const LegacyLayout = "2006-01-02 15:04:05"
+
+// LegacyLocation is the time zone the legacy times were written in, China Standard Time
+var LegacyLocation = time.FixedZone("CST", 8*60*60)
+
+// Format formats a time in the layout of the chaincode, in UTC
+func Format(t time.Time) string {
+	return t.UTC().Format(Layout)
+}
+
+// Parse parses a time returned by the chaincode, accepting the legacy layout of records not migrated yet
+func Parse(value string) (time.Time, error) {
+	t, err := time.Parse(Layout, value)
+	if err == nil {
+		return t, nil
+	}
+	if legacy, legacyErr := time.ParseInLocation(LegacyLayout, value, LegacyLocation); legacyErr == nil {
+		return legacy, nil
+	}
+	return t, err
+}
+
+// Localize rewrites the times of a decoded JSON value in a time zone, keeping the RFC 3339 layout.
+// Times are the string fields whose names end with Time or Expiry; empty or invalid ones are left as they are.
+func Localize(value interface{}, loc *time.Location) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if s, ok := field.(string); ok && (strings.HasSuffix(key, "Time") || strings.HasSuffix(key, "Expiry")) {
+				if t, err := Parse(s); err == nil {
+					v[key] = t.In(loc).Format(Layout)
+				}
+				continue
+			}
+			v[key] = Localize(field, loc)
+		}
+	case []interface{}:
+		for i, element := range v {
+			v[i] = Localize(element, loc)
+		}
+	}
+	return value
+}
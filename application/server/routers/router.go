@@ -2,28 +2,70 @@ package routers
 
 import (
 	v1 "application/api/v1"
+	"application/pkg/app"
 	"github.com/gin-gonic/gin"
 )
 
 // InitializeRouter initializes the routing information
 func InitRouter() *gin.Engine {
 	r := gin.Default()
+	r.Use(app.Timezone())
 
 	apiV1 := r.Group("/api/v1")
 	{
 		apiV1.GET("/hello", v1.Hello)
+		apiV1.GET("/metadata", v1.GetMetadata)
+		apiV1.GET("/stateMachine", v1.QueryStateMachine)
 		apiV1.POST("/queryAccountList", v1.QueryAccountList)
+		apiV1.POST("/requestKyc", v1.RequestKyc)
+		apiV1.POST("/reviewKyc", v1.ReviewKyc)
+		apiV1.POST("/queryPendingKycList", v1.QueryPendingKycList)
 		apiV1.POST("/createRealEstate", v1.CreateRealEstate)
+		apiV1.POST("/importRealEstate", v1.ImportRealEstate)
 		apiV1.POST("/queryRealEstateList", v1.QueryRealEstateList)
+		apiV1.GET("/certificate", v1.IssueCertificate)
+		apiV1.POST("/verifyCertificate", v1.VerifyCertificate)
 		apiV1.POST("/createSelling", v1.CreateSelling)
 		apiV1.POST("/createSellingByBuy", v1.CreateSellingByBuy)
+		apiV1.POST("/payInstallment", v1.PayInstallment)
 		apiV1.POST("/querySellingList", v1.QuerySellingList)
 		apiV1.POST("/querySellingListByBuyer", v1.QuerySellingListByBuyer)
+		apiV1.POST("/queryActiveListings", v1.QueryActiveListings)
 		apiV1.POST("/updateSelling", v1.UpdateSelling)
+		apiV1.POST("/setCancellationPenalty", v1.SetCancellationPenalty)
+		apiV1.POST("/queryCancellationPenaltyList", v1.QueryCancellationPenaltyList)
 		apiV1.POST("/createDonating", v1.CreateDonating)
 		apiV1.POST("/queryDonatingList", v1.QueryDonatingList)
 		apiV1.POST("/queryDonatingListByGrantee", v1.QueryDonatingListByGrantee)
 		apiV1.POST("/updateDonating", v1.UpdateDonating)
+		apiV1.POST("/raiseDispute", v1.RaiseDispute)
+		apiV1.POST("/addDisputeEvidence", v1.AddDisputeEvidence)
+		apiV1.POST("/resolveDispute", v1.ResolveDispute)
+		apiV1.POST("/queryDisputeList", v1.QueryDisputeList)
+		apiV1.POST("/registerEasement", v1.RegisterEasement)
+		apiV1.POST("/releaseEasement", v1.ReleaseEasement)
+		apiV1.POST("/queryEasementList", v1.QueryEasementList)
+		apiV1.POST("/freezeRealEstate", v1.FreezeRealEstate)
+		apiV1.POST("/unfreezeRealEstate", v1.UnfreezeRealEstate)
+		apiV1.POST("/forceTransfer", v1.ForceTransfer)
+		apiV1.POST("/queryCourtOrderList", v1.QueryCourtOrderList)
+		apiV1.POST("/setEndorsementPolicy", v1.SetEndorsementPolicy)
+		apiV1.POST("/queryEndorsementPolicy", v1.QueryEndorsementPolicy)
+		apiV1.POST("/grantDelegation", v1.GrantDelegation)
+		apiV1.POST("/revokeDelegation", v1.RevokeDelegation)
+		apiV1.POST("/queryDelegationList", v1.QueryDelegationList)
+		apiV1.POST("/queryDelegatedActionList", v1.QueryDelegatedActionList)
+		apiV1.POST("/ownerOf", v1.OwnerOf)
+		apiV1.POST("/balanceOf", v1.BalanceOf)
+		apiV1.POST("/approve", v1.Approve)
+		apiV1.POST("/setApprovalForAll", v1.SetApprovalForAll)
+		apiV1.POST("/transferFrom", v1.TransferFrom)
+		apiV1.POST("/getApproved", v1.GetApproved)
+		apiV1.POST("/isApprovedForAll", v1.IsApprovedForAll)
+		apiV1.POST("/tokenMetadata", v1.TokenMetadata)
+		apiV1.POST("/queryMarketStatistics", v1.QueryMarketStatistics)
+		apiV1.POST("/auditLedger", v1.AuditLedger)
+		apiV1.POST("/migrate", v1.Migrate)
 	}
 	return r
 }
@@ -16,6 +16,14 @@ export function querySellingListByBuyer(data) {
   })
 }
 
+export function queryActiveListings(data) {
+  return request({
+    url: '/queryActiveListings',
+    method: 'post',
+    data
+  })
+}
+
 export function createSellingByBuy(data) {
   return request({
     url: '/createSellingByBuy',
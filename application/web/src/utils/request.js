@@ -6,6 +6,12 @@ const service = axios.create({
   timeout: 5000,
 });
 
+// Ask the server for times in the browser's time zone
+service.interceptors.request.use((config) => {
+  config.headers['X-Timezone'] = Intl.DateTimeFormat().resolvedOptions().timeZone;
+  return config;
+});
+
 service.interceptors.response.use(
   (response) => {
     const res = response.data;
@@ -29,7 +35,7 @@ service.interceptors.response.use(
       return Promise.reject(error);
     } else {
       Message({
-        message: 'Failure: ' + error.response.data.data,
+        message: 'Failure: ' + (error.response.data.data.message || error.response.data.data),
         type: 'error',
         duration: 5 * 1000,
       });
@@ -2,6 +2,7 @@ package api
 
 import (
 	"chaincode/model"
+	"chaincode/pkg/errcode"
 	"chaincode/pkg/utils"
 	"encoding/json"
 	"fmt"
@@ -11,11 +12,11 @@ import (
 )
 
 // QueryAccountList queries the list of accounts.
-func QueryAccountList(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+func QueryAccountList(stub shim.ChaincodeStubInterface, req *model.QueryAccountListRequest) pb.Response {
 	var accountList []model.Account
-	results, err := utils.GetStateByPartialCompositeKeys(stub, model.AccountKey, args)
+	results, err := utils.GetStateByPartialCompositeKeys(stub, model.AccountKey, req.AccountIds)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("%s", err))
+		return errcode.Response(err)
 	}
 	for _, v := range results {
 		if v != nil {
@@ -33,3 +34,125 @@ func QueryAccountList(stub shim.ChaincodeStubInterface, args []string) pb.Respon
 	}
 	return shim.Success(accountListByte)
 }
+
+// QueryAccountPrivateList queries the list of accounts including their names and balances (collection members only)
+func QueryAccountPrivateList(stub shim.ChaincodeStubInterface, req *model.QueryAccountListRequest) pb.Response {
+	if err := checkCollectionAccess(stub, model.AccountCollection); err != nil {
+		return errcode.Response(err)
+	}
+	var accountList []model.Account
+	results, err := utils.GetStateByPartialCompositeKeys(stub, model.AccountKey, req.AccountIds)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	for _, v := range results {
+		if v != nil {
+			var account model.Account
+			err := json.Unmarshal(v, &account)
+			if err != nil {
+				return shim.Error(fmt.Sprintf("QueryAccountPrivateList - Deserialization error: %s", err))
+			}
+			if err := readAccountPrivate(stub, &account); err != nil {
+				return errcode.Response(err)
+			}
+			accountList = append(accountList, account)
+		}
+	}
+	accountListByte, err := json.Marshal(accountList)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("QueryAccountPrivateList - Serialization error: %s", err))
+	}
+	return shim.Success(accountListByte)
+}
+
+// getAccount loads a single account by its AccountId, including its private name and balance
+func getAccount(stub shim.ChaincodeStubInterface, accountId string) (model.Account, error) {
+	var account model.Account
+	resultsAccount, err := utils.GetStateByPartialCompositeKeys(stub, model.AccountKey, []string{accountId})
+	if err != nil || len(resultsAccount) != 1 {
+		return account, errcode.New(errcode.NotFound, "Failed to verify account %s: %v", accountId, err)
+	}
+	if err = json.Unmarshal(resultsAccount[0], &account); err != nil {
+		return account, fmt.Errorf("Failed to query account %s - Deserialization error: %s", accountId, err)
+	}
+	if err := readAccountPrivate(stub, &account); err != nil {
+		return account, err
+	}
+	return account, nil
+}
+
+// PutAccount writes an account, keeping its name and balance in the private data collection
+func PutAccount(stub shim.ChaincodeStubInterface, account *model.Account) error {
+	salt, err := privateSalt(stub)
+	if err != nil {
+		return err
+	}
+	return putAccount(stub, account, salt)
+}
+
+// PutSeedAccount writes an account seeded when the chaincode is instantiated.
+// Instantiation cannot pass transient data, and the seeded names and balances are published with the chaincode,
+// so they are written without a salt; the first transaction that changes the account salts it.
+func PutSeedAccount(stub shim.ChaincodeStubInterface, account *model.Account) error {
+	return putAccount(stub, account, "")
+}
+
+// putAccount writes an account with the salt of its private data
+func putAccount(stub shim.ChaincodeStubInterface, account *model.Account, salt string) error {
+	accountPrivate := model.AccountPrivate{
+		UserName:      account.UserName,
+		Balance:       account.Balance,
+		Salt:          salt,
+		SchemaVersion: model.SchemaVersion,
+	}
+	hash, err := utils.WritePrivateLedger(accountPrivate, stub, model.AccountCollection, model.AccountKey, []string{account.AccountId})
+	if err != nil {
+		return err
+	}
+	account.SchemaVersion = model.SchemaVersion
+	public := publicAccount(*account)
+	public.PrivateHash = hash
+	if err := utils.WriteLedger(public, stub, model.AccountKey, []string{account.AccountId}); err != nil {
+		return err
+	}
+	account.PrivateHash = hash
+	return nil
+}
+
+// publicAccount returns an account without its private name and balance
+func publicAccount(account model.Account) model.Account {
+	account.UserName = ""
+	account.Balance = 0
+	return account
+}
+
+// readAccountPrivate fills in the private name and balance of an account read from the public ledger
+func readAccountPrivate(stub shim.ChaincodeStubInterface, account *model.Account) error {
+	// Accounts written before the private data collections keep everything on the public ledger
+	if account.PrivateHash == "" {
+		return nil
+	}
+	var accountPrivate model.AccountPrivate
+	if err := utils.ReadPrivateLedger(&accountPrivate, stub, model.AccountCollection, model.AccountKey, []string{account.AccountId}, account.PrivateHash); err != nil {
+		return err
+	}
+	account.UserName = accountPrivate.UserName
+	account.Balance = accountPrivate.Balance
+	return nil
+}
+
+// adjustBalance adds amount (which may be negative) to the balance of an account
+func adjustBalance(stub shim.ChaincodeStubInterface, accountId string, amount float64) error {
+	account, err := getAccount(stub, accountId)
+	if err != nil {
+		return err
+	}
+	if account.Balance+amount < 0 {
+		return errcode.New(errcode.InsufficientFunds, "The balance of %s is %f, which is not enough to pay %f", accountId, account.Balance, -amount)
+	}
+	account.Balance += amount
+	if err := PutAccount(stub, &account); err != nil {
+		return fmt.Errorf("Failed to update the balance of %s: %s", accountId, err)
+	}
+	return nil
+}
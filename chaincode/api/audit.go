@@ -0,0 +1,296 @@
+package api
+
+import (
+	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// AuditLedger checks the ledger invariants and returns the violations found (admin)
+func AuditLedger(stub shim.ChaincodeStubInterface, req *model.AuditLedgerRequest) pb.Response {
+	accountId := req.AccountId // Account ID for verifying admin rights
+	// Balances and prices are read from the private data collections
+	if err := checkCollectionAccess(stub, model.AccountCollection); err != nil {
+		return errcode.Response(err)
+	}
+	if err := checkCollectionAccess(stub, model.SellingCollection); err != nil {
+		return errcode.Response(err)
+	}
+	// Verify if it's an admin operation
+	account, err := getAccount(stub, accountId)
+	if err != nil {
+		return errcode.Responsef(errcode.Forbidden, "Operator permission verification failed: %s", err)
+	}
+	if account.Role != model.AccountRoleConstant()["admin"] {
+		return errcode.Responsef(errcode.Forbidden, "Operator does not have sufficient permissions")
+	}
+	violations := []model.AuditViolation{}
+	sellingList, err := auditSellingList(stub)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	donatingList, err := auditDonatingList(stub)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	encumbrance, err := auditEncumbrance(stub, sellingList, donatingList)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	violations = append(violations, encumbrance...)
+	sellingBuy, err := auditSellingBuy(stub, sellingList)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	violations = append(violations, sellingBuy...)
+	supply, err := auditSupply(stub, sellingList)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	violations = append(violations, supply...)
+	donatingGrantee, err := auditDonatingGrantee(stub, donatingList)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	violations = append(violations, donatingGrantee...)
+	violationsByte, err := json.Marshal(violations)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("AuditLedger - Serialization error: %s", err))
+	}
+	return shim.Success(violationsByte)
+}
+
+// auditEncumbrance checks that every real estate encumbered by a sale or donation has exactly one active listing or donation,
+// and that every active listing or donation is matched by exactly one such encumbrance
+func auditEncumbrance(stub shim.ChaincodeStubInterface, sellingList []model.Selling, donatingList []model.Donating) ([]model.AuditViolation, error) {
+	var violations []model.AuditViolation
+	invariant := model.AuditInvariantConstant()["encumbrance"]
+	types := model.EncumbranceTypeConstant()
+	results, err := utils.GetStateByPartialCompositeKeys(stub, model.RealEstateKey, []string{})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range results {
+		var realEstate model.RealEstate
+		if err := json.Unmarshal(v, &realEstate); err != nil {
+			return nil, fmt.Errorf("RealEstate - Deserialization error: %s", err)
+		}
+		active := 0
+		for _, selling := range sellingList {
+			if selling.Seller == realEstate.Proprietor && selling.ObjectOfSale == realEstate.RealEstateID && sellingActive(selling) {
+				active++
+			}
+		}
+		for _, donating := range donatingList {
+			if donating.Donor == realEstate.Proprietor && donating.ObjectOfDonating == realEstate.RealEstateID && donatingActive(donating) {
+				active++
+			}
+		}
+		// Encumbrances of sales and donations, including those recorded before encumbrances were typed
+		held := 0
+		for _, encumbrance := range realEstate.Encumbrances {
+			switch encumbrance.Type {
+			case types["sale"], types["donation"], types["legacy"]:
+				held++
+			}
+		}
+		keys := []string{realEstate.Proprietor, realEstate.RealEstateID}
+		if held > 0 && active != 1 {
+			violations = append(violations, model.AuditViolation{
+				Invariant: invariant,
+				Keys:      keys,
+				Detail:    fmt.Sprintf("Real estate encumbered by a sale or donation has %d active listings or donations instead of 1", active),
+			})
+		}
+		if held != 1 && active > 0 {
+			violations = append(violations, model.AuditViolation{
+				Invariant: invariant,
+				Keys:      keys,
+				Detail:    fmt.Sprintf("Real estate has %d sale or donation encumbrances but %d active listings or donations", held, active),
+			})
+		}
+	}
+	return violations, nil
+}
+
+// auditSellingBuy checks that every sale with a buyer is indexed for the buyer
+func auditSellingBuy(stub shim.ChaincodeStubInterface, sellingList []model.Selling) ([]model.AuditViolation, error) {
+	var violations []model.AuditViolation
+	invariant := model.AuditInvariantConstant()["sellingBuy"]
+	indexes, err := getSellingBuyerIndexes(stub, "")
+	if err != nil {
+		return nil, err
+	}
+	indexed := map[[4]string]bool{}
+	for _, index := range indexes {
+		indexed[[4]string{index.Buyer, index.Seller, index.ObjectOfSale, index.ListingTime}] = true
+	}
+	for _, selling := range sellingList {
+		if selling.Buyer != "" && !indexed[[4]string{selling.Buyer, selling.Seller, selling.ObjectOfSale, selling.CreateTime}] {
+			violations = append(violations, model.AuditViolation{
+				Invariant: invariant,
+				Keys:      []string{selling.Seller, selling.ObjectOfSale},
+				Detail:    fmt.Sprintf("Sale to %s is not indexed for the buyer", selling.Buyer),
+			})
+		}
+	}
+	return violations, nil
+}
+
+// auditSupply checks that balances plus the funds held in escrow by sales add up to the total minted
+func auditSupply(stub shim.ChaincodeStubInterface, sellingList []model.Selling) ([]model.AuditViolation, error) {
+	invariant := model.AuditInvariantConstant()["supply"]
+	resultsSupply, err := utils.GetStateByPartialCompositeKeys(stub, model.SupplyKey, []string{})
+	if err != nil {
+		return nil, err
+	}
+	if len(resultsSupply) != 1 {
+		return []model.AuditViolation{{
+			Invariant: invariant,
+			Keys:      []string{},
+			Detail:    "The total minted is not recorded on the ledger",
+		}}, nil
+	}
+	var supply model.Supply
+	if err := json.Unmarshal(resultsSupply[0], &supply); err != nil {
+		return nil, fmt.Errorf("Supply - Deserialization error: %s", err)
+	}
+	results, err := utils.GetStateByPartialCompositeKeys(stub, model.AccountKey, []string{})
+	if err != nil {
+		return nil, err
+	}
+	var balances float64
+	for _, v := range results {
+		var account model.Account
+		if err := json.Unmarshal(v, &account); err != nil {
+			return nil, fmt.Errorf("Account - Deserialization error: %s", err)
+		}
+		if err := readAccountPrivate(stub, &account); err != nil {
+			return nil, err
+		}
+		balances += account.Balance
+	}
+	var escrow float64
+	for _, selling := range sellingList {
+		escrow += sellingEscrow(selling)
+	}
+	if math.Abs(balances+escrow-supply.TotalMinted) > 1e-6 {
+		return []model.AuditViolation{{
+			Invariant: invariant,
+			Keys:      []string{},
+			Detail:    fmt.Sprintf("Balances %f plus escrow %f do not add up to the total minted %f", balances, escrow, supply.TotalMinted),
+		}}, nil
+	}
+	return nil, nil
+}
+
+// auditDonatingGrantee checks that every donation is indexed for its grantee and that every index entry has its donation
+func auditDonatingGrantee(stub shim.ChaincodeStubInterface, donatingList []model.Donating) ([]model.AuditViolation, error) {
+	var violations []model.AuditViolation
+	invariant := model.AuditInvariantConstant()["donatingGrantee"]
+	indexes, err := getDonatingGranteeIndexes(stub, "")
+	if err != nil {
+		return nil, err
+	}
+	donated := map[[3]string]bool{}
+	for _, donating := range donatingList {
+		donated[[3]string{donating.Grantee, donating.Donor, donating.ObjectOfDonating}] = true
+	}
+	indexed := map[[3]string]bool{}
+	for _, index := range indexes {
+		keys := [3]string{index.Grantee, index.Donor, index.ObjectOfDonating}
+		indexed[keys] = true
+		if !donated[keys] {
+			violations = append(violations, model.AuditViolation{
+				Invariant: invariant,
+				Keys:      keys[:],
+				Detail:    fmt.Sprintf("No donation of %s by %s exists for this grantee", index.ObjectOfDonating, index.Donor),
+			})
+		}
+	}
+	for _, donating := range donatingList {
+		if !indexed[[3]string{donating.Grantee, donating.Donor, donating.ObjectOfDonating}] {
+			violations = append(violations, model.AuditViolation{
+				Invariant: invariant,
+				Keys:      []string{donating.Donor, donating.ObjectOfDonating, donating.Grantee},
+				Detail:    fmt.Sprintf("Donation to %s is not indexed for the grantee", donating.Grantee),
+			})
+		}
+	}
+	return violations, nil
+}
+
+// auditSellingList loads every sale including its private details
+func auditSellingList(stub shim.ChaincodeStubInterface) ([]model.Selling, error) {
+	var sellingList []model.Selling
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.SellingKey, []string{})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range results {
+		var selling model.Selling
+		if err := json.Unmarshal(v, &selling); err != nil {
+			return nil, fmt.Errorf("Selling - Deserialization error: %s", err)
+		}
+		if err := readSellingPrivate(stub, &selling, model.SellingKey, []string{selling.Seller, selling.ObjectOfSale}); err != nil {
+			return nil, err
+		}
+		sellingList = append(sellingList, selling)
+	}
+	return sellingList, nil
+}
+
+// auditDonatingList loads every donation
+func auditDonatingList(stub shim.ChaincodeStubInterface) ([]model.Donating, error) {
+	var donatingList []model.Donating
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.DonatingKey, []string{})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range results {
+		var donating model.Donating
+		if err := json.Unmarshal(v, &donating); err != nil {
+			return nil, fmt.Errorf("Donating - Deserialization error: %s", err)
+		}
+		donatingList = append(donatingList, donating)
+	}
+	return donatingList, nil
+}
+
+// sellingActive reports whether a sale still encumbers its real estate
+func sellingActive(selling model.Selling) bool {
+	switch selling.SellingStatus {
+	case model.SellingStatusConstant()["saleStart"],
+		model.SellingStatusConstant()["delivery"],
+		model.SellingStatusConstant()["payment"],
+		model.SellingStatusConstant()["dispute"]:
+		return true
+	}
+	return false
+}
+
+// sellingEscrow returns the funds paid by the buyer that the sale still holds
+func sellingEscrow(selling model.Selling) float64 {
+	switch selling.SellingStatus {
+	case model.SellingStatusConstant()["delivery"],
+		model.SellingStatusConstant()["payment"],
+		model.SellingStatusConstant()["dispute"]:
+		// Sales bought before payment schedules existed did not record the amount paid
+		if selling.AmountPaid == 0 {
+			return selling.Price
+		}
+		return selling.AmountPaid
+	}
+	return 0
+}
+
+// donatingActive reports whether a donation still encumbers its real estate
+func donatingActive(donating model.Donating) bool {
+	return donating.DonatingStatus == model.DonatingStatusConstant()["donatingStart"]
+}
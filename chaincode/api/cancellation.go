@@ -0,0 +1,146 @@
+package api
+
+import (
+	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/utils"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// cancellationPenalty is the compensation owed by one party when a sale is cancelled
+type cancellationPenalty struct {
+	PaidBy string  // seller or buyer, empty when no penalty applies
+	Amount float64 // Amount paid to the other party
+}
+
+// SetCancellationPenalty sets the penalty owed when a party cancels a sale in a given status (admin)
+func SetCancellationPenalty(stub shim.ChaincodeStubInterface, req *model.SetCancellationPenaltyRequest) pb.Response {
+	// A penalty compensates the buyer or the seller, so the sale must have a buyer
+	if req.SellingStatus == "saleStart" {
+		return errcode.Responsef(errcode.InvalidArgument, "A sale that has not been bought has no party to compensate")
+	}
+	allowed := false
+	for _, party := range model.CancellationRulesConstant()[req.SellingStatus] {
+		if party == req.Canceller {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return errcode.Responsef(errcode.Forbidden, "%s cannot cancel a sale in %s status", req.Canceller, req.SellingStatus)
+	}
+	if req.PaidBy != "seller" && req.PaidBy != "buyer" {
+		return errcode.Responsef(errcode.InvalidArgument, "The penalty must be paid by the seller or the buyer")
+	}
+	if req.Rate < 0 || req.Rate > 100 {
+		return errcode.Responsef(errcode.InvalidArgument, "The penalty rate must be between 0 and 100")
+	}
+	// Verify if it's an admin operation
+	account, err := getAccount(stub, req.AccountId)
+	if err != nil {
+		return errcode.Responsef(errcode.Forbidden, "Operator permission verification failed: %s", err)
+	}
+	if account.Role != model.AccountRoleConstant()["admin"] {
+		return errcode.Responsef(errcode.Forbidden, "Operator does not have sufficient permissions")
+	}
+	penalty := &model.CancellationPenalty{
+		Canceller:     req.Canceller,
+		SellingStatus: req.SellingStatus,
+		PaidBy:        req.PaidBy,
+		Rate:          req.Rate,
+		SchemaVersion: model.SchemaVersion,
+	}
+	if err := utils.WriteLedger(penalty, stub, model.CancellationPenaltyKey, []string{penalty.Canceller, penalty.SellingStatus}); err != nil {
+		return errcode.Response(err)
+	}
+	penaltyByte, err := json.Marshal(penalty)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Serialization error for the cancellation penalty: %s", err))
+	}
+	return shim.Success(penaltyByte)
+}
+
+// QueryCancellationPenaltyList queries the cancellation penalties in force
+func QueryCancellationPenaltyList(stub shim.ChaincodeStubInterface) pb.Response {
+	penaltyList := []model.CancellationPenalty{}
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.CancellationPenaltyKey, []string{})
+	if err != nil {
+		return errcode.Response(err)
+	}
+	for _, v := range results {
+		if v != nil {
+			var penalty model.CancellationPenalty
+			if err := json.Unmarshal(v, &penalty); err != nil {
+				return shim.Error(fmt.Sprintf("QueryCancellationPenaltyList - Deserialization error: %s", err))
+			}
+			penaltyList = append(penaltyList, penalty)
+		}
+	}
+	penaltyListByte, err := json.Marshal(penaltyList)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("QueryCancellationPenaltyList - Serialization error: %s", err))
+	}
+	return shim.Success(penaltyListByte)
+}
+
+// checkCancellation checks that the operator may cancel the sale in its current status, records who cancelled and why,
+// and returns the penalty owed
+func checkCancellation(stub shim.ChaincodeStubInterface, selling *model.Selling, operator string, reason string) (cancellationPenalty, error) {
+	var penalty cancellationPenalty
+	if operator == "" || reason == "" {
+		return penalty, errcode.New(errcode.InvalidArgument, "The operator and the reason must be given to cancel a sale")
+	}
+	account, err := getAccount(stub, operator)
+	if err != nil {
+		return penalty, errcode.New(errcode.Forbidden, "Operator verification failed: %s", err)
+	}
+	party := ""
+	switch {
+	case account.Role == model.AccountRoleConstant()["admin"]:
+		party = "admin"
+	case operator == selling.Seller:
+		party = "seller"
+	case operator == selling.Buyer:
+		party = "buyer"
+	}
+	status := sellingStatusKey(selling.SellingStatus)
+	allowed := false
+	for _, v := range model.CancellationRulesConstant()[status] {
+		if party != "" && v == party {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return penalty, errcode.New(errcode.Forbidden, "%s cannot cancel a sale in %s status", operator, selling.SellingStatus)
+	}
+	selling.CancelledBy = operator
+	selling.CancelReason = reason
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.CancellationPenaltyKey, []string{party, status})
+	if err != nil {
+		return penalty, err
+	}
+	if len(results) == 1 {
+		var rule model.CancellationPenalty
+		if err := json.Unmarshal(results[0], &rule); err != nil {
+			return penalty, fmt.Errorf("CancellationPenalty - Deserialization error: %s", err)
+		}
+		if rule.Rate > 0 {
+			penalty.PaidBy = rule.PaidBy
+			penalty.Amount = selling.Price * rule.Rate / 100
+		}
+	}
+	return penalty, nil
+}
+
+// sellingStatusKey returns the SellingStatusConstant key of a sale status
+func sellingStatusKey(status string) string {
+	for key, v := range model.SellingStatusConstant() {
+		if v == status {
+			return key
+		}
+	}
+	return ""
+}
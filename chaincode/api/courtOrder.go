@@ -0,0 +1,240 @@
+package api
+
+import (
+	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/timeutil"
+	"chaincode/pkg/utils"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// FreezeRealEstate encumbers a real estate with a court lock, which blocks its sale, donation and transfer (admin or authority)
+func FreezeRealEstate(stub shim.ChaincodeStubInterface, req *model.FreezeRealEstateRequest) pb.Response {
+	if err := checkAuthority(stub, req.AccountId); err != nil {
+		return errcode.Response(err)
+	}
+	realEstate, err := getRealEstate(stub, req.RealEstateID)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	if courtLock(realEstate, req.OrderReference) != nil {
+		return errcode.Responsef(errcode.Conflict, "Real estate %s is already frozen by order %s", realEstate.RealEstateID, req.OrderReference)
+	}
+	if err := addEncumbrance(stub, &realEstate, "courtLock", req.OrderReference, req.AccountId, "", true); err != nil {
+		return errcode.Response(err)
+	}
+	if err := utils.WriteLedger(realEstate, stub, model.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
+		return errcode.Response(err)
+	}
+	if err := putCourtOrder(stub, "freeze", req.OrderReference, req.AccountId, realEstate.RealEstateID, realEstate.Proprietor, ""); err != nil {
+		return errcode.Response(err)
+	}
+	realEstateByte, err := json.Marshal(realEstate)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("FreezeRealEstate - Serialization error: %s", err))
+	}
+	return shim.Success(realEstateByte)
+}
+
+// UnfreezeRealEstate removes the court lock of an order, leaving those of other orders in place (admin or authority)
+func UnfreezeRealEstate(stub shim.ChaincodeStubInterface, req *model.UnfreezeRealEstateRequest) pb.Response {
+	if err := checkAuthority(stub, req.AccountId); err != nil {
+		return errcode.Response(err)
+	}
+	realEstate, err := getRealEstate(stub, req.RealEstateID)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	if courtLock(realEstate, req.OrderReference) == nil {
+		return errcode.Responsef(errcode.NotFound, "Real estate %s is not frozen by order %s", realEstate.RealEstateID, req.OrderReference)
+	}
+	removeEncumbrance(&realEstate, "courtLock", req.OrderReference)
+	if err := utils.WriteLedger(realEstate, stub, model.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
+		return errcode.Response(err)
+	}
+	if err := putCourtOrder(stub, "unfreeze", req.OrderReference, req.AccountId, realEstate.RealEstateID, realEstate.Proprietor, ""); err != nil {
+		return errcode.Response(err)
+	}
+	realEstateByte, err := json.Marshal(realEstate)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("UnfreezeRealEstate - Serialization error: %s", err))
+	}
+	return shim.Success(realEstateByte)
+}
+
+// ForceTransfer transfers a real estate to a new proprietor by a court order (admin or authority).
+// The sale in progress is cancelled and its buyer refunded in full, the donation in progress is cancelled,
+// and the court lock of the same order is lifted. Other encumbrances pass to the new proprietor.
+func ForceTransfer(stub shim.ChaincodeStubInterface, req *model.ForceTransferRequest) pb.Response {
+	authority := req.AccountId
+	newProprietor := req.NewProprietor
+	orderReference := req.OrderReference
+	if err := checkAuthority(stub, authority); err != nil {
+		return errcode.Response(err)
+	}
+	realEstate, err := getRealEstate(stub, req.RealEstateID)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	proprietor := realEstate.Proprietor
+	if newProprietor == proprietor {
+		return errcode.Responsef(errcode.InvalidArgument, "The real estate already belongs to %s", newProprietor)
+	}
+	accountNewProprietor, err := getAccount(stub, newProprietor)
+	if err != nil {
+		return errcode.Responsef(errcode.NotFound, "New proprietor information verification failed: %s", err)
+	}
+	if accountNewProprietor.Role == model.AccountRoleConstant()["admin"] {
+		return errcode.Responsef(errcode.Forbidden, "Cannot transfer real estate to the admin")
+	}
+	// A court lock of another order still holds the title
+	removeEncumbrance(&realEstate, "courtLock", orderReference)
+	for _, v := range realEstate.Encumbrances {
+		if v.Type == model.EncumbranceTypeConstant()["courtLock"] {
+			return errcode.Responsef(errcode.Conflict, "Real estate %s is frozen by order %s", realEstate.RealEstateID, v.Reference)
+		}
+	}
+	// Cancel the sale in progress, refunding the buyer through closeSelling
+	selling, err := getSelling(stub, proprietor, realEstate.RealEstateID)
+	if err != nil && errcode.CodeOf(err) != errcode.NotFound {
+		return errcode.Response(err)
+	}
+	if err == nil {
+		if err := courtCancelSelling(stub, selling, realEstate, authority, orderReference); err != nil {
+			return errcode.Response(err)
+		}
+		removeEncumbrance(&realEstate, "sale", sellingReference(selling.Seller, selling.ObjectOfSale))
+	}
+	// Cancel the donations in progress
+	resultsDonating, err := utils.GetStateByPartialCompositeKeys2(stub, model.DonatingKey, []string{proprietor, realEstate.RealEstateID})
+	if err != nil {
+		return errcode.Response(err)
+	}
+	for _, v := range resultsDonating {
+		var donating model.Donating
+		if err := json.Unmarshal(v, &donating); err != nil {
+			return shim.Error(fmt.Sprintf("ForceTransfer - Deserialization error: %s", err))
+		}
+		transition, err := DonatingMachine.Next(donating.DonatingStatus, "courtOrder", nil)
+		if err != nil {
+			continue
+		}
+		if _, err := transition.Apply(&donation{stub: stub, donating: donating, realEstate: realEstate}); err != nil {
+			return errcode.Response(err)
+		}
+		removeEncumbrance(&realEstate, "donation", donatingReference(donating.Donor, donating.ObjectOfDonating, donating.Grantee))
+	}
+	// Transfer the real estate; the record under the former proprietor is deleted after any write above
+	if err := transferRealEstate(stub, &realEstate, newProprietor); err != nil {
+		return errcode.Response(err)
+	}
+	if err := putCourtOrder(stub, "forceTransfer", orderReference, authority, realEstate.RealEstateID, proprietor, newProprietor); err != nil {
+		return errcode.Response(err)
+	}
+	realEstateByte, err := json.Marshal(realEstate)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("ForceTransfer - Serialization error: %s", err))
+	}
+	return shim.Success(realEstateByte)
+}
+
+// QueryCourtOrderList queries the court orders carried out (all, or on a real estate)
+func QueryCourtOrderList(stub shim.ChaincodeStubInterface, req *model.QueryCourtOrderListRequest) pb.Response {
+	var courtOrderList []model.CourtOrder
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.CourtOrderKey, utils.KeyPrefix(req.RealEstateID))
+	if err != nil {
+		return errcode.Response(err)
+	}
+	for _, v := range results {
+		if v != nil {
+			var courtOrder model.CourtOrder
+			if err := json.Unmarshal(v, &courtOrder); err != nil {
+				return shim.Error(fmt.Sprintf("QueryCourtOrderList - Deserialization error: %s", err))
+			}
+			courtOrderList = append(courtOrderList, courtOrder)
+		}
+	}
+	courtOrderListByte, err := json.Marshal(courtOrderList)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("QueryCourtOrderList - Serialization error: %s", err))
+	}
+	return shim.Success(courtOrderListByte)
+}
+
+// courtCancelSelling cancels a sale that has not completed and refunds its buyer in full.
+// A dispute open on the sale is closed with a refund in the name of the authority.
+func courtCancelSelling(stub shim.ChaincodeStubInterface, selling model.Selling, realEstate model.RealEstate, authority string, orderReference string) error {
+	transition, err := SellingMachine.Next(selling.SellingStatus, "courtOrder", nil)
+	if err != nil {
+		// Completed or closed sales are left alone
+		return nil
+	}
+	if selling.SellingStatus == model.SellingStatusConstant()["dispute"] {
+		dispute, err := getOpenDispute(stub, selling.Seller, selling.ObjectOfSale)
+		if err != nil {
+			return err
+		}
+		now, err := utils.GetTxTime(stub)
+		if err != nil {
+			return err
+		}
+		dispute.DisputeStatus = model.DisputeStatusConstant()["resolved"]
+		dispute.Arbitrator = authority
+		dispute.Decision = model.DisputeDecisionConstant()["refund"]
+		dispute.DecisionNote = fmt.Sprintf("Court order %s", orderReference)
+		dispute.ResolveTime = timeutil.Format(now)
+		if err := utils.WriteLedger(dispute, stub, model.DisputeKey, []string{dispute.Seller, dispute.ObjectOfSale, dispute.CreateTime}); err != nil {
+			return err
+		}
+	}
+	selling.CancelledBy = authority
+	selling.CancelReason = fmt.Sprintf("Court order %s", orderReference)
+	_, err = transition.Apply(&sale{stub: stub, selling: selling, realEstate: realEstate})
+	return err
+}
+
+// checkAuthority verifies that an account may carry out court orders
+func checkAuthority(stub shim.ChaincodeStubInterface, accountId string) error {
+	account, err := getAccount(stub, accountId)
+	if err != nil {
+		return errcode.New(errcode.Forbidden, "Operator permission verification failed: %s", err)
+	}
+	if account.Role != model.AccountRoleConstant()["admin"] && account.Role != model.AccountRoleConstant()["authority"] {
+		return errcode.New(errcode.Forbidden, "Operator does not have sufficient permissions")
+	}
+	return nil
+}
+
+// courtLock returns the court lock of an order on a real estate, or nil
+func courtLock(realEstate model.RealEstate, orderReference string) *model.Encumbrance {
+	for i, v := range realEstate.Encumbrances {
+		if v.Type == model.EncumbranceTypeConstant()["courtLock"] && v.Reference == orderReference {
+			return &realEstate.Encumbrances[i]
+		}
+	}
+	return nil
+}
+
+// putCourtOrder records an action taken on a court order
+func putCourtOrder(stub shim.ChaincodeStubInterface, action string, orderReference string, authority string, realEstateID string, proprietor string, newProprietor string) error {
+	now, err := utils.GetTxTime(stub)
+	if err != nil {
+		return err
+	}
+	courtOrder := &model.CourtOrder{
+		RealEstateID:   realEstateID,
+		Action:         model.CourtOrderActionConstant()[action],
+		OrderReference: orderReference,
+		Authority:      authority,
+		Proprietor:     proprietor,
+		NewProprietor:  newProprietor,
+		TxID:           stub.GetTxID(),
+		CreateTime:     timeutil.Format(now),
+		SchemaVersion:  model.SchemaVersion,
+	}
+	return utils.WriteLedger(courtOrder, stub, model.CourtOrderKey, []string{courtOrder.RealEstateID, courtOrder.TxID})
+}
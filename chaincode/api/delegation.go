@@ -0,0 +1,223 @@
+package api
+
+import (
+	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/timeutil"
+	"chaincode/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// GrantDelegation lets an agent act on behalf of the principal within a scope, for a limited time
+func GrantDelegation(stub shim.ChaincodeStubInterface, req *model.GrantDelegationRequest) pb.Response {
+	principal := req.Principal
+	agent := req.Agent
+	if principal == agent {
+		return errcode.Responsef(errcode.InvalidArgument, "The principal and agent cannot be the same person")
+	}
+	scope, ok := model.DelegationScopeConstant()[req.Scope]
+	if !ok {
+		return errcode.Responsef(errcode.InvalidArgument, "Delegation scope %s is not supported", req.Scope)
+	}
+	if req.ValidityDays <= 0 {
+		return errcode.Responsef(errcode.InvalidArgument, "The validity of the delegation must be greater than 0 days")
+	}
+	if _, err := getAccount(stub, principal); err != nil {
+		return errcode.Responsef(errcode.NotFound, "Principal information verification failed: %s", err)
+	}
+	accountAgent, err := getAccount(stub, agent)
+	if err != nil {
+		return errcode.Responsef(errcode.NotFound, "Agent information verification failed: %s", err)
+	}
+	if accountAgent.Role == model.AccountRoleConstant()["admin"] {
+		return errcode.Responsef(errcode.Forbidden, "The admin cannot act as an agent")
+	}
+	if req.RealEstateID != "" {
+		if _, err := getRealEstate(stub, req.RealEstateID); err != nil {
+			return errcode.Response(err)
+		}
+	}
+	now, err := utils.GetTxTime(stub)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	// No duplicate delegations in force for the same scope and real estate
+	delegations, err := getDelegations(stub, principal)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	for _, v := range delegations {
+		if v.Agent == agent && v.Scope == scope && v.RealEstateID == req.RealEstateID && delegationInForce(v, now) {
+			return errcode.Responsef(errcode.Conflict, "Delegation %s of the same scope is already in force", v.DelegationID)
+		}
+	}
+	delegation := &model.Delegation{
+		DelegationID:     stub.GetTxID()[:16],
+		Principal:        principal,
+		Agent:            agent,
+		Scope:            scope,
+		RealEstateID:     req.RealEstateID,
+		StartTime:        timeutil.Format(now),
+		EndTime:          timeutil.Format(now.AddDate(0, 0, req.ValidityDays)),
+		DelegationStatus: model.DelegationStatusConstant()["active"],
+		SchemaVersion:    model.SchemaVersion,
+	}
+	if err := utils.WriteLedger(delegation, stub, model.DelegationKey, []string{delegation.Principal, delegation.DelegationID}); err != nil {
+		return errcode.Response(err)
+	}
+	delegationByte, err := json.Marshal(delegation)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("GrantDelegation - Serialization error: %s", err))
+	}
+	return shim.Success(delegationByte)
+}
+
+// RevokeDelegation revokes an active delegation before it lapses (principal)
+func RevokeDelegation(stub shim.ChaincodeStubInterface, req *model.RevokeDelegationRequest) pb.Response {
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.DelegationKey, []string{req.Principal, req.DelegationID})
+	if err != nil || len(results) != 1 {
+		return errcode.Responsef(errcode.NotFound, "Delegation %s of %s does not exist", req.DelegationID, req.Principal)
+	}
+	var delegation model.Delegation
+	if err := json.Unmarshal(results[0], &delegation); err != nil {
+		return shim.Error(fmt.Sprintf("RevokeDelegation - Deserialization error: %s", err))
+	}
+	if delegation.DelegationStatus != model.DelegationStatusConstant()["active"] {
+		return errcode.Responsef(errcode.Conflict, "Delegation %s is %s", delegation.DelegationID, delegation.DelegationStatus)
+	}
+	now, err := utils.GetTxTime(stub)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	delegation.DelegationStatus = model.DelegationStatusConstant()["revoked"]
+	delegation.RevokeTime = timeutil.Format(now)
+	if err := utils.WriteLedger(delegation, stub, model.DelegationKey, []string{delegation.Principal, delegation.DelegationID}); err != nil {
+		return errcode.Response(err)
+	}
+	delegationByte, err := json.Marshal(delegation)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("RevokeDelegation - Serialization error: %s", err))
+	}
+	return shim.Success(delegationByte)
+}
+
+// QueryDelegationList queries the delegations granted by a principal and/or to an agent (all when both are empty)
+func QueryDelegationList(stub shim.ChaincodeStubInterface, req *model.QueryDelegationListRequest) pb.Response {
+	delegations, err := getDelegations(stub, req.Principal)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	var delegationList []model.Delegation
+	for _, v := range delegations {
+		if req.Agent == "" || v.Agent == req.Agent {
+			delegationList = append(delegationList, v)
+		}
+	}
+	delegationListByte, err := json.Marshal(delegationList)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("QueryDelegationList - Serialization error: %s", err))
+	}
+	return shim.Success(delegationListByte)
+}
+
+// QueryDelegatedActionList queries the actions agents took on behalf of a principal
+func QueryDelegatedActionList(stub shim.ChaincodeStubInterface, req *model.QueryDelegatedActionListRequest) pb.Response {
+	var actionList []model.DelegatedAction
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.DelegatedActionKey, []string{req.Principal})
+	if err != nil {
+		return errcode.Response(err)
+	}
+	for _, v := range results {
+		var action model.DelegatedAction
+		if err := json.Unmarshal(v, &action); err != nil {
+			return shim.Error(fmt.Sprintf("QueryDelegatedActionList - Deserialization error: %s", err))
+		}
+		actionList = append(actionList, action)
+	}
+	actionListByte, err := json.Marshal(actionList)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("QueryDelegatedActionList - Serialization error: %s", err))
+	}
+	return shim.Success(actionListByte)
+}
+
+// actOnBehalf checks that the agent may act for the principal within the scope on a real estate
+// and records the action. Without an agent, or when the principal acts itself, there is nothing to check.
+func actOnBehalf(stub shim.ChaincodeStubInterface, principal string, agent string, scope string, realEstateID string, function string) error {
+	if agent == "" || agent == principal {
+		return nil
+	}
+	delegation, err := findDelegation(stub, principal, agent, scope, realEstateID)
+	if err != nil {
+		return err
+	}
+	if delegation == nil {
+		return errcode.New(errcode.Forbidden, "%s holds no delegation from %s to %s real estate %s", agent, principal, scope, realEstateID)
+	}
+	now, err := utils.GetTxTime(stub)
+	if err != nil {
+		return err
+	}
+	action := &model.DelegatedAction{
+		DelegationID:  delegation.DelegationID,
+		Principal:     principal,
+		Agent:         agent,
+		Function:      function,
+		RealEstateID:  realEstateID,
+		TxID:          stub.GetTxID(),
+		CreateTime:    timeutil.Format(now),
+		SchemaVersion: model.SchemaVersion,
+	}
+	return utils.WriteLedger(action, stub, model.DelegatedActionKey, []string{action.Principal, action.TxID})
+}
+
+// findDelegation returns a delegation in force from the principal to the agent within the scope
+// that covers the real estate, or nil
+func findDelegation(stub shim.ChaincodeStubInterface, principal string, agent string, scope string, realEstateID string) (*model.Delegation, error) {
+	delegations, err := getDelegations(stub, principal)
+	if err != nil {
+		return nil, err
+	}
+	now, err := utils.GetTxTime(stub)
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range delegations {
+		if v.Agent == agent && v.Scope == model.DelegationScopeConstant()[scope] && (v.RealEstateID == "" || v.RealEstateID == realEstateID) &&
+			delegationInForce(v, now) {
+			return &delegations[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// delegationInForce reports whether a delegation is active and has not lapsed at a time
+func delegationInForce(delegation model.Delegation, at time.Time) bool {
+	if delegation.DelegationStatus != model.DelegationStatusConstant()["active"] {
+		return false
+	}
+	end, err := timeutil.Parse(delegation.EndTime)
+	return err == nil && at.Before(end)
+}
+
+// getDelegations returns the delegations granted by a principal, or all of them when it is empty
+func getDelegations(stub shim.ChaincodeStubInterface, principal string) ([]model.Delegation, error) {
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.DelegationKey, utils.KeyPrefix(principal))
+	if err != nil {
+		return nil, err
+	}
+	var delegations []model.Delegation
+	for _, v := range results {
+		var delegation model.Delegation
+		if err := json.Unmarshal(v, &delegation); err != nil {
+			return nil, fmt.Errorf("Delegation - Deserialization error: %s", err)
+		}
+		delegations = append(delegations, delegation)
+	}
+	return delegations, nil
+}
@@ -0,0 +1,266 @@
+package api
+
+import (
+	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/timeutil"
+	"chaincode/pkg/utils"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// RaiseDispute lets the buyer or seller of a sale in delivery raise a dispute, which freezes the sale
+func RaiseDispute(stub shim.ChaincodeStubInterface, req *model.RaiseDisputeRequest) pb.Response {
+	objectOfSale := req.ObjectOfSale
+	seller := req.Seller
+	raisedBy := req.RaisedBy
+	reason := req.Reason
+	evidence := req.Evidence
+	if err := checkEvidence(evidence); err != nil {
+		return errcode.Response(err)
+	}
+	// Obtain selling information based on 'objectOfSale' and 'seller'
+	selling, err := getSelling(stub, seller, objectOfSale)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	// Only a sale that has been paid for and is waiting for delivery can be disputed
+	transition, err := SellingMachine.Next(selling.SellingStatus, "raiseDispute", nil)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	if raisedBy != selling.Seller && raisedBy != selling.Buyer {
+		return errcode.Responsef(errcode.Forbidden, "Only the buyer or the seller of this sale can raise a dispute")
+	}
+	createTime, _ := stub.GetTxTimestamp()
+	dispute := &model.Dispute{
+		ObjectOfSale:  objectOfSale,
+		Seller:        seller,
+		Buyer:         selling.Buyer,
+		RaisedBy:      raisedBy,
+		Reason:        reason,
+		Evidence:      evidence,
+		CreateTime:    timeutil.Format(time.Unix(int64(createTime.GetSeconds()), int64(createTime.GetNanos()))),
+		DisputeStatus: model.DisputeStatusConstant()["open"],
+		SchemaVersion: model.SchemaVersion,
+	}
+	if err := utils.WriteLedger(dispute, stub, model.DisputeKey, []string{dispute.Seller, dispute.ObjectOfSale, dispute.CreateTime}); err != nil {
+		return errcode.Response(err)
+	}
+	// Freeze the sale; the real estate stays encumbered until the dispute is resolved
+	selling.SellingStatus = transition.To
+	if err := putSelling(stub, &selling); err != nil {
+		return errcode.Response(err)
+	}
+	disputeByte, err := json.Marshal(dispute)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Serialization error for the dispute: %s", err))
+	}
+	return shim.Success(disputeByte)
+}
+
+// AddDisputeEvidence appends evidence hashes to the open dispute of a sale
+func AddDisputeEvidence(stub shim.ChaincodeStubInterface, req *model.AddDisputeEvidenceRequest) pb.Response {
+	objectOfSale := req.ObjectOfSale
+	seller := req.Seller
+	accountId := req.AccountId
+	evidence := req.Evidence
+	if err := checkEvidence(evidence); err != nil {
+		return errcode.Response(err)
+	}
+	dispute, err := getOpenDispute(stub, seller, objectOfSale)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	if accountId != dispute.Seller && accountId != dispute.Buyer {
+		return errcode.Responsef(errcode.Forbidden, "Only the buyer or the seller of this sale can submit evidence")
+	}
+	dispute.Evidence = append(dispute.Evidence, evidence...)
+	if err := utils.WriteLedger(dispute, stub, model.DisputeKey, []string{dispute.Seller, dispute.ObjectOfSale, dispute.CreateTime}); err != nil {
+		return errcode.Response(err)
+	}
+	disputeByte, err := json.Marshal(dispute)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Serialization error for the dispute: %s", err))
+	}
+	return shim.Success(disputeByte)
+}
+
+// ResolveDispute lets an arbitrator close the open dispute of a sale with a refund, a partial refund, a completion or a penalty
+func ResolveDispute(stub shim.ChaincodeStubInterface, req *model.ResolveDisputeRequest) pb.Response {
+	objectOfSale := req.ObjectOfSale
+	seller := req.Seller
+	arbitrator := req.Arbitrator
+	decision := req.Decision
+	formattedAmount := req.Amount
+	penalizedParty := req.PenalizedParty
+	decisionNote := req.DecisionNote
+	if _, ok := model.DisputeDecisionConstant()[decision]; !ok {
+		return errcode.Responsef(errcode.InvalidArgument, "Decision %s is not supported", decision)
+	}
+	// Verify that the operator is an arbitrator
+	account, err := getAccount(stub, arbitrator)
+	if err != nil {
+		return errcode.Responsef(errcode.Forbidden, "Operator permission verification failed: %s", err)
+	}
+	if account.Role != model.AccountRoleConstant()["arbitrator"] {
+		return errcode.Responsef(errcode.Forbidden, "Operator does not have sufficient permissions")
+	}
+	dispute, err := getOpenDispute(stub, seller, objectOfSale)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	// Obtain the frozen sale and the real estate it concerns
+	selling, err := getSelling(stub, seller, objectOfSale)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	if _, err := SellingMachine.Next(selling.SellingStatus, "resolve", &sale{decision: decision}); err != nil {
+		return errcode.Response(err)
+	}
+	resultsRealEstate, err := utils.GetStateByPartialCompositeKeys2(stub, model.RealEstateKey, []string{seller, objectOfSale})
+	if err != nil || len(resultsRealEstate) != 1 {
+		return errcode.Responsef(errcode.NotFound, "Failed to retrieve real estate information based on %s and %s: %s", objectOfSale, seller, err)
+	}
+	var realEstate model.RealEstate
+	if err = json.Unmarshal(resultsRealEstate[0], &realEstate); err != nil {
+		return shim.Error(fmt.Sprintf("ResolveDispute - Deserialization error: %s", err))
+	}
+	sellingBuy, err := getSellingBuy(stub, selling)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	// Settle the escrowed price according to the decision
+	switch decision {
+	case "refund":
+		formattedAmount = 0
+		penalizedParty = ""
+		if err := refundDisputedSelling(stub, selling, realEstate, selling.Price); err != nil {
+			return errcode.Response(err)
+		}
+	case "partialRefund":
+		if formattedAmount <= 0 || formattedAmount >= selling.Price {
+			return errcode.Responsef(errcode.InvalidArgument, "The partial refund must be greater than 0 and less than the price")
+		}
+		penalizedParty = ""
+		if err := adjustBalance(stub, selling.Buyer, formattedAmount); err != nil {
+			return errcode.Response(err)
+		}
+		if _, err := completeSelling(selling, realEstate, sellingBuy, selling.Price-formattedAmount, stub); err != nil {
+			return errcode.Response(err)
+		}
+	case "complete":
+		formattedAmount = 0
+		penalizedParty = ""
+		if _, err := completeSelling(selling, realEstate, sellingBuy, selling.Price, stub); err != nil {
+			return errcode.Response(err)
+		}
+	case "penalty":
+		// The penalty is settled out of the escrowed price, so it cannot exceed it
+		if formattedAmount <= 0 || formattedAmount > selling.Price {
+			return errcode.Responsef(errcode.InvalidArgument, "The penalty must be greater than 0 and at most the price")
+		}
+		if penalizedParty != selling.Seller && penalizedParty != selling.Buyer {
+			return errcode.Responsef(errcode.InvalidArgument, "The penalized party must be the buyer or the seller of this sale")
+		}
+		// Each account is written once, as a transaction does not read its own writes
+		refund := selling.Price + formattedAmount
+		if penalizedParty == selling.Buyer {
+			refund = selling.Price - formattedAmount
+		}
+		if err := adjustBalance(stub, selling.Seller, selling.Price-refund); err != nil {
+			return errcode.Response(err)
+		}
+		if err := refundDisputedSelling(stub, selling, realEstate, refund); err != nil {
+			return errcode.Response(err)
+		}
+	}
+	// Record the decision
+	resolveTime, _ := stub.GetTxTimestamp()
+	dispute.DisputeStatus = model.DisputeStatusConstant()["resolved"]
+	dispute.Arbitrator = arbitrator
+	dispute.Decision = model.DisputeDecisionConstant()[decision]
+	dispute.Amount = formattedAmount
+	dispute.PenalizedParty = penalizedParty
+	dispute.DecisionNote = decisionNote
+	dispute.ResolveTime = timeutil.Format(time.Unix(int64(resolveTime.GetSeconds()), int64(resolveTime.GetNanos())))
+	if err := utils.WriteLedger(dispute, stub, model.DisputeKey, []string{dispute.Seller, dispute.ObjectOfSale, dispute.CreateTime}); err != nil {
+		return errcode.Response(err)
+	}
+	disputeByte, err := json.Marshal(dispute)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Serialization error for the dispute: %s", err))
+	}
+	return shim.Success(disputeByte)
+}
+
+// QueryDisputeList queries disputes (can query all, by seller, or by seller and objectOfSale)
+func QueryDisputeList(stub shim.ChaincodeStubInterface, req *model.QueryDisputeListRequest) pb.Response {
+	var disputeList []model.Dispute
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.DisputeKey, utils.KeyPrefix(req.Seller, req.ObjectOfSale))
+	if err != nil {
+		return errcode.Response(err)
+	}
+	for _, v := range results {
+		if v != nil {
+			var dispute model.Dispute
+			err := json.Unmarshal(v, &dispute)
+			if err != nil {
+				return shim.Error(fmt.Sprintf("QueryDisputeList - Deserialization error: %s", err))
+			}
+			disputeList = append(disputeList, dispute)
+		}
+	}
+	disputeListByte, err := json.Marshal(disputeList)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("QueryDisputeList - Serialization error: %s", err))
+	}
+	return shim.Success(disputeListByte)
+}
+
+// refundDisputedSelling cancels a disputed sale, releases the real estate and credits refund to the buyer
+func refundDisputedSelling(stub shim.ChaincodeStubInterface, selling model.Selling, realEstate model.RealEstate, refund float64) error {
+	if err := adjustBalance(stub, selling.Buyer, refund); err != nil {
+		return fmt.Errorf("Failed to refund the buyer's account: %w", err)
+	}
+	removeEncumbrance(&realEstate, "sale", sellingReference(selling.Seller, selling.ObjectOfSale))
+	if err := utils.WriteLedger(realEstate, stub, model.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
+		return err
+	}
+	selling.SellingStatus = model.SellingStatusConstant()["cancelled"]
+	return putSelling(stub, &selling)
+}
+
+// getOpenDispute returns the open dispute of a sale
+func getOpenDispute(stub shim.ChaincodeStubInterface, seller string, objectOfSale string) (model.Dispute, error) {
+	var dispute model.Dispute
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.DisputeKey, []string{seller, objectOfSale})
+	if err != nil {
+		return dispute, err
+	}
+	for _, v := range results {
+		var d model.Dispute
+		if err := json.Unmarshal(v, &d); err != nil {
+			return dispute, fmt.Errorf("Dispute - Deserialization error: %s", err)
+		}
+		if d.DisputeStatus == model.DisputeStatusConstant()["open"] {
+			return d, nil
+		}
+	}
+	return dispute, errcode.New(errcode.NotFound, "No open dispute found for %s and %s", objectOfSale, seller)
+}
+
+// checkEvidence ensures every piece of evidence is a hex encoded SHA-256 hash
+func checkEvidence(evidence []string) error {
+	for _, v := range evidence {
+		if b, err := hex.DecodeString(v); err != nil || len(b) != 32 {
+			return errcode.New(errcode.InvalidArgument, "Evidence %s is not a hex encoded SHA-256 hash", v)
+		}
+	}
+	return nil
+}
@@ -2,6 +2,9 @@ package api
 
 import (
 	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/statemachine"
+	"chaincode/pkg/timeutil"
 	"chaincode/pkg/utils"
 	"encoding/json"
 	"fmt"
@@ -12,72 +15,77 @@ import (
 )
 
 // CreateDonating initiates a donation.
-func CreateDonating(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// Validate parameters
-	if len(args) != 3 {
-		return shim.Error("Incorrect number of parameters")
-	}
-	objectOfDonating := args[0]
-	donor := args[1]
-	grantee := args[2]
-	if objectOfDonating == "" || donor == "" || grantee == "" {
-		return shim.Error("Empty parameters are not allowed")
-	}
+func CreateDonating(stub shim.ChaincodeStubInterface, req *model.CreateDonatingRequest) pb.Response {
+	objectOfDonating := req.ObjectOfDonating
+	donor := req.Donor
+	grantee := req.Grantee
 	if donor == grantee {
-		return shim.Error("The donor and grantee cannot be the same person")
+		return errcode.Responsef(errcode.InvalidArgument, "The donor and grantee cannot be the same person")
 	}
 	// Check if objectOfDonating belongs to the donor
 	resultsRealEstate, err := utils.GetStateByPartialCompositeKeys2(stub, model.RealEstateKey, []string{donor, objectOfDonating})
 	if err != nil || len(resultsRealEstate) != 1 {
-		return shim.Error(fmt.Sprintf("Verification failed: %s", err))
+		return errcode.Responsef(errcode.NotFound, "Verification failed: %s", err)
 	}
 	var realEstate model.RealEstate
 	if err = json.Unmarshal(resultsRealEstate[0], &realEstate); err != nil {
 		return shim.Error(fmt.Sprintf("CreateDonating - Deserialization error: %s", err))
 	}
+	// An agent may donate on behalf of the donor
+	if err := actOnBehalf(stub, donor, req.Agent, "donate", objectOfDonating, "createDonating"); err != nil {
+		return errcode.Response(err)
+	}
 	// Get grantee information
 	resultsAccount, err := utils.GetStateByPartialCompositeKeys(stub, model.AccountKey, []string{grantee})
 	if err != nil || len(resultsAccount) != 1 {
-		return shim.Error(fmt.Sprintf("Grantee information verification failed: %s", err))
+		return errcode.Responsef(errcode.NotFound, "Grantee information verification failed: %s", err)
 	}
 	var accountGrantee model.Account
 	if err = json.Unmarshal(resultsAccount[0], &accountGrantee); err != nil {
 		return shim.Error(fmt.Sprintf("Querying operator information - Deserialization error: %s", err))
 	}
-	if accountGrantee.UserName == "Admin" {
-		return shim.Error("Cannot donate to the admin")
+	if accountGrantee.Role == model.AccountRoleConstant()["admin"] {
+		return errcode.Responsef(errcode.Forbidden, "Cannot donate to the admin")
+	}
+	// No duplicate donations allowed, nor donations while another encumbrance blocks transfers
+	if err := checkTransferable(stub, realEstate); err != nil {
+		return errcode.Response(err)
 	}
-	// Check if the record already exists, no duplicate donations allowed
-	// If Encumbrance is true, it means the real estate is already under collateral
-	if realEstate.Encumbrance {
-		return shim.Error("This real estate is already being used as collateral and cannot be donated")
+	transition, err := DonatingMachine.Next(statemachine.Initial, "create", nil)
+	if err != nil {
+		return errcode.Response(err)
 	}
 	createTime, _ := stub.GetTxTimestamp()
 	donating := &model.Donating{
 		ObjectOfDonating: objectOfDonating,
 		Donor:            donor,
 		Grantee:          grantee,
-		CreateTime:       time.Unix(int64(createTime.GetSeconds()), int64(createTime.GetNanos())).Local().Format("2006-01-02 15:04:05"),
-		DonatingStatus:   model.DonatingStatusConstant()["donatingStart"],
+		CreateTime:       timeutil.Format(time.Unix(int64(createTime.GetSeconds()), int64(createTime.GetNanos()))),
+		DonatingStatus:   transition.To,
+		SchemaVersion:    model.SchemaVersion,
 	}
 	// Write to the ledger
 	if err := utils.WriteLedger(donating, stub, model.DonatingKey, []string{donating.Donor, donating.ObjectOfDonating, donating.Grantee}); err != nil {
-		return shim.Error(fmt.Sprintf("%s", err))
+		return errcode.Response(err)
+	}
+	// Encumber the real estate with the donation
+	if err := addEncumbrance(stub, &realEstate, "donation", donatingReference(donor, objectOfDonating, grantee), donor, "", true); err != nil {
+		return errcode.Response(err)
 	}
-	// Set the real estate as under collateral status
-	realEstate.Encumbrance = true
 	if err := utils.WriteLedger(realEstate, stub, model.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
-		return shim.Error(fmt.Sprintf("%s", err))
+		return errcode.Response(err)
 	}
-	// Write the donation transaction for grantee to query
-	donatingGrantee := &model.DonatingGrantee{
-		Grantee:    grantee,
-		CreateTime: time.Unix(int64(createTime.GetSeconds()), int64(createTime.GetNanos())).Local().Format("2006-01-02 15:04:05"),
-		Donating:   *donating,
+	// Index the donation transaction for grantee to query
+	index := &model.DonatingGranteeIndex{
+		Grantee:          grantee,
+		Donor:            donor,
+		ObjectOfDonating: objectOfDonating,
+		SchemaVersion:    model.SchemaVersion,
 	}
-	if err := utils.WriteLedger(donatingGrantee, stub, model.DonatingGranteeKey, []string{donatingGrantee.Grantee, donatingGrantee.CreateTime}); err != nil {
-		return shim.Error(fmt.Sprintf("Failed to write this donation transaction: %s", err))
+	if err := utils.WriteLedger(index, stub, model.DonatingGranteeIndexKey, []string{index.Grantee, index.Donor, index.ObjectOfDonating}); err != nil {
+		return shim.Error(fmt.Sprintf("Failed to index this donation transaction: %s", err))
 	}
+	donatingGrantee := donatingGranteeOf(*donating)
 	donatingGranteeByte, err := json.Marshal(donatingGrantee)
 	if err != nil {
 		return shim.Error(fmt.Sprintf("Serialization of created information failed: %s", err))
@@ -87,11 +95,11 @@ func CreateDonating(stub shim.ChaincodeStubInterface, args []string) pb.Response
 }
 
 // QueryDonatingList queries the list of donations (all or by the donor) for donors to query.
-func QueryDonatingList(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+func QueryDonatingList(stub shim.ChaincodeStubInterface, req *model.QueryDonatingListRequest) pb.Response {
 	var donatingList []model.Donating
-	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.DonatingKey, args)
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.DonatingKey, utils.KeyPrefix(req.Donor, req.ObjectOfDonating))
 	if err != nil {
-		return shim.Error(fmt.Sprintf("%s", err))
+		return errcode.Response(err)
 	}
 	for _, v := range results {
 		if v != nil {
@@ -111,24 +119,25 @@ func QueryDonatingList(stub shim.ChaincodeStubInterface, args []string) pb.Respo
 }
 
 // QueryDonatingListByGrantee queries the list of donations by grantee (for grantees to query).
-func QueryDonatingListByGrantee(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 1 {
-		return shim.Error("Must specify the grantee AccountId to query")
-	}
+func QueryDonatingListByGrantee(stub shim.ChaincodeStubInterface, req *model.QueryDonatingListByGranteeRequest) pb.Response {
 	var donatingGranteeList []model.DonatingGrantee
-	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.DonatingGranteeKey, args)
+	indexes, err := getDonatingGranteeIndexes(stub, req.Grantee)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("%s", err))
+		return errcode.Response(err)
 	}
-	for _, v := range results {
-		if v != nil {
-			var donatingGrantee model.DonatingGrantee
-			err := json.Unmarshal(v, &donatingGrantee)
-			if err != nil {
-				return shim.Error(fmt.Sprintf("QueryDonatingListByGrantee - Deserialization error: %s", err))
-			}
-			donatingGranteeList = append(donatingGranteeList, donatingGrantee)
+	for _, index := range indexes {
+		results, err := utils.GetStateByPartialCompositeKeys2(stub, model.DonatingKey, []string{index.Donor, index.ObjectOfDonating, index.Grantee})
+		if err != nil {
+			return errcode.Response(err)
+		}
+		if len(results) != 1 {
+			continue
 		}
+		var donating model.Donating
+		if err := json.Unmarshal(results[0], &donating); err != nil {
+			return shim.Error(fmt.Sprintf("QueryDonatingListByGrantee - Deserialization error: %s", err))
+		}
+		donatingGranteeList = append(donatingGranteeList, donatingGranteeOf(donating))
 	}
 	donatingGranteeListByte, err := json.Marshal(donatingGranteeList)
 	if err != nil {
@@ -138,25 +147,18 @@ func QueryDonatingListByGrantee(stub shim.ChaincodeStubInterface, args []string)
 }
 
 // UpdateDonating updates the donation status (confirm or cancel).
-func UpdateDonating(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// Validate parameters
-	if len(args) != 4 {
-		return shim.Error("Incorrect number of parameters")
-	}
-	objectOfDonating := args[0]
-	donor := args[1]
-	grantee := args[2]
-	status := args[3]
-	if objectOfDonating == "" || donor == "" || grantee == "" || status == "" {
-		return shim.Error("Empty parameters are not allowed")
-	}
+func UpdateDonating(stub shim.ChaincodeStubInterface, req *model.UpdateDonatingRequest) pb.Response {
+	objectOfDonating := req.ObjectOfDonating
+	donor := req.Donor
+	grantee := req.Grantee
+	status := req.Status
 	if donor == grantee {
-		return shim.Error("The donor and grantee cannot be the same person")
+		return errcode.Responsef(errcode.InvalidArgument, "The donor and grantee cannot be the same person")
 	}
 	// Get the real estate information that the donor wants to donate to, confirm its existence
 	resultsRealEstate, err := utils.GetStateByPartialCompositeKeys2(stub, model.RealEstateKey, []string{donor, objectOfDonating})
 	if err != nil || len(resultsRealEstate) != 1 {
-		return shim.Error(fmt.Sprintf("Failed to get real estate information for %s and %s: %s", objectOfDonating, donor, err))
+		return errcode.Responsef(errcode.NotFound, "Failed to get real estate information for %s and %s: %s", objectOfDonating, donor, err)
 	}
 	var realEstate model.RealEstate
 	if err = json.Unmarshal(resultsRealEstate[0], &realEstate); err != nil {
@@ -165,7 +167,7 @@ func UpdateDonating(stub shim.ChaincodeStubInterface, args []string) pb.Response
 	// Get grantee information
 	resultsGranteeAccount, err := utils.GetStateByPartialCompositeKeys(stub, model.AccountKey, []string{grantee})
 	if err != nil || len(resultsGranteeAccount) != 1 {
-		return shim.Error(fmt.Sprintf("Grantee information verification failed: %s", err))
+		return errcode.Responsef(errcode.NotFound, "Grantee information verification failed: %s", err)
 	}
 	var accountGrantee model.Account
 	if err = json.Unmarshal(resultsGranteeAccount[0], &accountGrantee); err != nil {
@@ -174,89 +176,148 @@ func UpdateDonating(stub shim.ChaincodeStubInterface, args []string) pb.Response
 	// Get the donation information for the objectOfDonating, donor, and grantee
 	resultsDonating, err := utils.GetStateByPartialCompositeKeys2(stub, model.DonatingKey, []string{donor, objectOfDonating, grantee})
 	if err != nil || len(resultsDonating) != 1 {
-		return shim.Error(fmt.Sprintf("Failed to get donation information for %s, %s, and %s: %s", objectOfDonating, donor, grantee, err))
+		return errcode.Responsef(errcode.NotFound, "Failed to get donation information for %s, %s, and %s: %s", objectOfDonating, donor, grantee, err)
 	}
 	var donating model.Donating
 	if err = json.Unmarshal(resultsDonating[0], &donating); err != nil {
 		return shim.Error(fmt.Sprintf("UpdateDonating - Deserialization error: %s", err))
 	}
-	// Regardless of completion or cancellation, ensure the donation is in the "donatingStart" status
-	if donating.DonatingStatus != model.DonatingStatusConstant()["donatingStart"] {
-		return shim.Error("This transaction is not in the 'donatingStart' status and cannot be confirmed/cancelled")
-	}
-	// Get the donation transaction for grantee to purchase
-	var donatingGrantee model.DonatingGrantee
-	resultsDonatingGrantee, err := utils.GetStateByPartialCompositeKeys2(stub, model.DonatingGranteeKey, []string{grantee})
-	if err != nil || len(resultsDonatingGrantee) == 0 {
-		return shim.Error(fmt.Sprintf("Failed to get grantee information for %s: %s", grantee, err))
-	}
-	for _, v := range resultsDonatingGrantee {
-		if v != nil {
-			var s model.DonatingGrantee
-			err := json.Unmarshal(v, &s)
-			if err != nil {
-				return shim.Error(fmt.Sprintf("UpdateDonating - Deserialization error: %s", err))
-			}
-			if s.Donating.ObjectOfDonating == objectOfDonating && s.Donating.Donor == donor && s.Grantee == grantee {
-				// Must also check that the status is "donatingStart" to prevent cases where the real estate is already transacted but got canceled
-				if s.Donating.DonatingStatus == model.DonatingStatusConstant()["donatingStart"] {
-					donatingGrantee = s
-					break
-				}
-			}
-		}
+	// Map the requested status to the event of the donating state machine
+	event, ok := map[string]string{"done": "confirm", "cancelled": "cancel"}[status]
+	if !ok {
+		return errcode.Responsef(errcode.InvalidArgument, "Status %s is not supported", status)
 	}
-	var data []byte
-	// Check the donation status
-	switch status {
-	case "done":
-		// Transfer real estate information to the grantee and reset the collateral status
-		realEstate.Proprietor = grantee
-		realEstate.Encumbrance = false
-		if err := utils.WriteLedger(realEstate, stub, model.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
-			return shim.Error(fmt.Sprintf("%s", err))
-		}
-		// Clear the original real estate information
-		if err := utils.DelLedger(stub, model.RealEstateKey, []string{donor, objectOfDonating}); err != nil {
-			return shim.Error(fmt.Sprintf("%s", err))
-		}
-		// Set the donation status to "done" and update the real estate ID
-		donating.DonatingStatus = model.DonatingStatusConstant()["done"]
-		donating.ObjectOfDonating = realEstate.RealEstateID
-		if err := utils.WriteLedger(donating, stub, model.DonatingKey, []string{donating.Donor, objectOfDonating, grantee}); err != nil {
-			return shim.Error(fmt.Sprintf("%s", err))
+	// An agent of the grantee accepts or declines, an agent of the donor may only cancel
+	principal, scope := grantee, "acceptDonation"
+	if status == "cancelled" && req.Agent != "" {
+		delegation, err := findDelegation(stub, grantee, req.Agent, scope, objectOfDonating)
+		if err != nil {
+			return errcode.Response(err)
 		}
-		donatingGrantee.Donating = donating
-		if err := utils.WriteLedger(donatingGrantee, stub, model.DonatingGranteeKey, []string{donatingGrantee.Grantee, donatingGrantee.CreateTime}); err != nil {
-			return shim.Error(fmt.Sprintf("Failed to write this donation transaction: %s", err))
+		if delegation == nil {
+			principal, scope = donor, "donate"
 		}
-		data, err = json.Marshal(donatingGrantee)
+	}
+	if err := actOnBehalf(stub, principal, req.Agent, scope, objectOfDonating, "updateDonating"); err != nil {
+		return errcode.Response(err)
+	}
+	// The title only passes between accounts with verified identities
+	if status == "done" {
+		accountDonor, err := getAccount(stub, donor)
 		if err != nil {
-			return shim.Error(fmt.Sprintf("Serialization of donation transaction information failed: %s", err))
+			return errcode.Responsef(errcode.NotFound, "Donor information verification failed: %s", err)
 		}
-		break
-	case "cancelled":
-		// Reset the collateral status of real estate information
-		realEstate.Encumbrance = false
-		if err := utils.WriteLedger(realEstate, stub, model.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
-			return shim.Error(fmt.Sprintf("%s", err))
+		for _, account := range []model.Account{accountDonor, accountGrantee} {
+			if err := checkKyc(stub, account); err != nil {
+				return errcode.Response(err)
+			}
 		}
-		// Update the donation status to "cancelled"
-		donating.DonatingStatus = model.DonatingStatusConstant()["cancelled"]
-		if err := utils.WriteLedger(donating, stub, model.DonatingKey, []string{donating.Donor, donating.ObjectOfDonating, donating.Grantee}); err != nil {
-			return shim.Error(fmt.Sprintf("%s", err))
+	}
+	// Regardless of completion or cancellation, ensure the donation can leave its current status
+	transition, err := DonatingMachine.Next(donating.DonatingStatus, event, nil)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	data, err := transition.Apply(&donation{stub: stub, donating: donating, realEstate: realEstate})
+	if err != nil {
+		return errcode.Response(err)
+	}
+	return shim.Success(data)
+}
+
+// completeDonating transfers the real estate to the grantee and marks the donation as done
+func completeDonating(stub shim.ChaincodeStubInterface, donating model.Donating, realEstate model.RealEstate) ([]byte, error) {
+	donor := donating.Donor
+	objectOfDonating := donating.ObjectOfDonating
+	grantee := donating.Grantee
+	// Transfer real estate information to the grantee and reset the collateral status
+	removeEncumbrance(&realEstate, "donation", donatingReference(donor, objectOfDonating, grantee))
+	if err := checkTransferable(stub, realEstate); err != nil {
+		return nil, err
+	}
+	if err := transferRealEstate(stub, &realEstate, grantee); err != nil {
+		return nil, err
+	}
+	// Set the donation status to "done" and update the real estate ID
+	donating.DonatingStatus = model.DonatingStatusConstant()["done"]
+	donating.ObjectOfDonating = realEstate.RealEstateID
+	if err := utils.WriteLedger(donating, stub, model.DonatingKey, []string{donating.Donor, objectOfDonating, grantee}); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(donatingGranteeOf(donating))
+	if err != nil {
+		return nil, fmt.Errorf("Serialization of donation transaction information failed: %s", err)
+	}
+	return data, nil
+}
+
+// closeDonating releases the real estate and marks the donation as cancelled
+func closeDonating(stub shim.ChaincodeStubInterface, donating model.Donating, realEstate model.RealEstate) ([]byte, error) {
+	// Remove the encumbrance of the donation
+	removeEncumbrance(&realEstate, "donation", donatingReference(donating.Donor, donating.ObjectOfDonating, donating.Grantee))
+	if err := utils.WriteLedger(realEstate, stub, model.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
+		return nil, err
+	}
+	// Update the donation status to "cancelled"
+	donating.DonatingStatus = model.DonatingStatusConstant()["cancelled"]
+	if err := utils.WriteLedger(donating, stub, model.DonatingKey, []string{donating.Donor, donating.ObjectOfDonating, donating.Grantee}); err != nil {
+		return nil, err
+	}
+	return json.Marshal(donatingGranteeOf(donating))
+}
+
+// donatingGranteeOf returns the grantee's view of a donation
+func donatingGranteeOf(donating model.Donating) model.DonatingGrantee {
+	return model.DonatingGrantee{
+		Grantee:       donating.Grantee,
+		CreateTime:    donating.CreateTime,
+		Donating:      donating,
+		SchemaVersion: model.SchemaVersion,
+	}
+}
+
+// getDonatingGranteeIndexes returns the donations indexed for a grantee, or for all grantees when it is empty.
+// Copies written before the index count as index entries until they are migrated.
+func getDonatingGranteeIndexes(stub shim.ChaincodeStubInterface, grantee string) ([]model.DonatingGranteeIndex, error) {
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.DonatingGranteeIndexKey, utils.KeyPrefix(grantee))
+	if err != nil {
+		return nil, err
+	}
+	var indexes []model.DonatingGranteeIndex
+	indexed := map[[3]string]bool{}
+	for _, v := range results {
+		var index model.DonatingGranteeIndex
+		if err := json.Unmarshal(v, &index); err != nil {
+			return nil, fmt.Errorf("DonatingGranteeIndex - Deserialization error: %s", err)
 		}
-		donatingGrantee.Donating = donating
-		if err := utils.WriteLedger(donatingGrantee, stub, model.DonatingGranteeKey, []string{donatingGrantee.Grantee, donatingGrantee.CreateTime}); err != nil {
-			return shim.Error(fmt.Sprintf("%s", err))
+		indexes = append(indexes, index)
+		indexed[[3]string{index.Grantee, index.Donor, index.ObjectOfDonating}] = true
+	}
+	legacy, err := utils.GetStateByPartialCompositeKeys2(stub, model.DonatingGranteeKey, utils.KeyPrefix(grantee))
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range legacy {
+		var donatingGrantee model.DonatingGrantee
+		if err := json.Unmarshal(v, &donatingGrantee); err != nil {
+			return nil, fmt.Errorf("DonatingGrantee - Deserialization error: %s", err)
 		}
-		data, err = json.Marshal(donatingGrantee)
-		if err != nil {
-			return shim.Error(fmt.Sprintf("%s", err))
+		// Earlier donations of the same real estate to the grantee were replaced by the latest one
+		index := donatingGranteeIndexOf(donatingGrantee)
+		if key := [3]string{index.Grantee, index.Donor, index.ObjectOfDonating}; !indexed[key] {
+			indexes = append(indexes, index)
+			indexed[key] = true
 		}
-		break
-	default:
-		return shim.Error(fmt.Sprintf("Status %s is not supported", status))
 	}
-	return shim.Success(data)
+	return indexes, nil
+}
+
+// donatingGranteeIndexOf returns the index entry of a grantee's copy of a donation
+func donatingGranteeIndexOf(donatingGrantee model.DonatingGrantee) model.DonatingGranteeIndex {
+	return model.DonatingGranteeIndex{
+		Grantee:          donatingGrantee.Grantee,
+		Donor:            donatingGrantee.Donating.Donor,
+		ObjectOfDonating: donatingGrantee.Donating.ObjectOfDonating,
+		SchemaVersion:    model.SchemaVersion,
+	}
 }
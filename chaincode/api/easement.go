@@ -0,0 +1,186 @@
+package api
+
+import (
+	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/timeutil"
+	"chaincode/pkg/utils"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// RegisterEasement registers an easement of a dominant real estate over a servient one (admin)
+func RegisterEasement(stub shim.ChaincodeStubInterface, req *model.RegisterEasementRequest) pb.Response {
+	accountId := req.AccountId
+	// Verify if it's an admin operation
+	account, err := getAccount(stub, accountId)
+	if err != nil {
+		return errcode.Responsef(errcode.Forbidden, "Operator permission verification failed: %s", err)
+	}
+	if account.Role != model.AccountRoleConstant()["admin"] {
+		return errcode.Responsef(errcode.Forbidden, "Operator does not have sufficient permissions")
+	}
+	easementType, ok := model.EasementTypeConstant()[req.Type]
+	if !ok {
+		return errcode.Responsef(errcode.InvalidArgument, "Easement type %s is not supported", req.Type)
+	}
+	if req.Description == "" {
+		return errcode.Responsef(errcode.InvalidArgument, "The description of the easement cannot be empty")
+	}
+	if req.TermDays < 0 {
+		return errcode.Responsef(errcode.InvalidArgument, "The term of the easement cannot be negative")
+	}
+	if req.DominantRealEstateID == req.ServientRealEstateID {
+		return errcode.Responsef(errcode.InvalidArgument, "The dominant and servient real estate cannot be the same")
+	}
+	if _, err := getRealEstate(stub, req.DominantRealEstateID); err != nil {
+		return errcode.Response(err)
+	}
+	servient, err := getRealEstate(stub, req.ServientRealEstateID)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	// No duplicate easements of the same type between the same real estate
+	easements, err := getEasements(stub)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	for _, v := range easements {
+		if v.Type == easementType && v.DominantRealEstateID == req.DominantRealEstateID && v.ServientRealEstateID == req.ServientRealEstateID &&
+			v.EasementStatus == model.EasementStatusConstant()["active"] {
+			return errcode.Responsef(errcode.Conflict, "Easement %s of the same type is already registered", v.EasementID)
+		}
+	}
+	now, err := utils.GetTxTime(stub)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	easement := &model.Easement{
+		EasementID:           stub.GetTxID()[:16],
+		Type:                 easementType,
+		Description:          req.Description,
+		DominantRealEstateID: req.DominantRealEstateID,
+		ServientRealEstateID: req.ServientRealEstateID,
+		StartTime:            timeutil.Format(now),
+		CreatedBy:            accountId,
+		EasementStatus:       model.EasementStatusConstant()["active"],
+		SchemaVersion:        model.SchemaVersion,
+	}
+	if req.TermDays > 0 {
+		easement.EndTime = timeutil.Format(now.AddDate(0, 0, req.TermDays))
+	}
+	if err := utils.WriteLedger(easement, stub, model.EasementKey, []string{easement.EasementID}); err != nil {
+		return errcode.Response(err)
+	}
+	// Encumber the servient real estate, without blocking its transfer
+	if err := addEncumbrance(stub, &servient, "easement", easement.EasementID, accountId, easement.EndTime, false); err != nil {
+		return errcode.Response(err)
+	}
+	if err := utils.WriteLedger(servient, stub, model.RealEstateKey, []string{servient.Proprietor, servient.RealEstateID}); err != nil {
+		return errcode.Response(err)
+	}
+	easementByte, err := json.Marshal(easement)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("RegisterEasement - Serialization error: %s", err))
+	}
+	return shim.Success(easementByte)
+}
+
+// ReleaseEasement releases an active easement and removes its encumbrance from the servient real estate (admin)
+func ReleaseEasement(stub shim.ChaincodeStubInterface, req *model.ReleaseEasementRequest) pb.Response {
+	accountId := req.AccountId
+	// Verify if it's an admin operation
+	account, err := getAccount(stub, accountId)
+	if err != nil {
+		return errcode.Responsef(errcode.Forbidden, "Operator permission verification failed: %s", err)
+	}
+	if account.Role != model.AccountRoleConstant()["admin"] {
+		return errcode.Responsef(errcode.Forbidden, "Operator does not have sufficient permissions")
+	}
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.EasementKey, []string{req.EasementID})
+	if err != nil || len(results) != 1 {
+		return errcode.Responsef(errcode.NotFound, "Easement %s does not exist", req.EasementID)
+	}
+	var easement model.Easement
+	if err := json.Unmarshal(results[0], &easement); err != nil {
+		return shim.Error(fmt.Sprintf("ReleaseEasement - Deserialization error: %s", err))
+	}
+	if easement.EasementStatus != model.EasementStatusConstant()["active"] {
+		return errcode.Responsef(errcode.Conflict, "Easement %s is %s", easement.EasementID, easement.EasementStatus)
+	}
+	easement.EasementStatus = model.EasementStatusConstant()["released"]
+	if err := utils.WriteLedger(easement, stub, model.EasementKey, []string{easement.EasementID}); err != nil {
+		return errcode.Response(err)
+	}
+	servient, err := getRealEstate(stub, easement.ServientRealEstateID)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	removeEncumbrance(&servient, "easement", easement.EasementID)
+	if err := utils.WriteLedger(servient, stub, model.RealEstateKey, []string{servient.Proprietor, servient.RealEstateID}); err != nil {
+		return errcode.Response(err)
+	}
+	easementByte, err := json.Marshal(easement)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("ReleaseEasement - Serialization error: %s", err))
+	}
+	return shim.Success(easementByte)
+}
+
+// QueryEasementList queries easements (all, or those of a real estate as dominant or servient)
+func QueryEasementList(stub shim.ChaincodeStubInterface, req *model.QueryEasementListRequest) pb.Response {
+	easements, err := getEasements(stub)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	var easementList []model.Easement
+	for _, v := range easements {
+		if req.RealEstateID == "" || v.DominantRealEstateID == req.RealEstateID || v.ServientRealEstateID == req.RealEstateID {
+			easementList = append(easementList, v)
+		}
+	}
+	easementListByte, err := json.Marshal(easementList)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("QueryEasementList - Serialization error: %s", err))
+	}
+	return shim.Success(easementListByte)
+}
+
+// getEasements returns every easement on the ledger
+func getEasements(stub shim.ChaincodeStubInterface) ([]model.Easement, error) {
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.EasementKey, []string{})
+	if err != nil {
+		return nil, err
+	}
+	var easements []model.Easement
+	for _, v := range results {
+		var easement model.Easement
+		if err := json.Unmarshal(v, &easement); err != nil {
+			return nil, fmt.Errorf("Easement - Deserialization error: %s", err)
+		}
+		easements = append(easements, easement)
+	}
+	return easements, nil
+}
+
+// attachEasements attaches to each real estate the active easements it benefits from or is burdened by
+func attachEasements(stub shim.ChaincodeStubInterface, realEstates []model.RealEstate) error {
+	easements, err := getEasements(stub)
+	if err != nil {
+		return err
+	}
+	for i := range realEstates {
+		for _, v := range easements {
+			if v.EasementStatus != model.EasementStatusConstant()["active"] {
+				continue
+			}
+			if v.DominantRealEstateID == realEstates[i].RealEstateID || v.ServientRealEstateID == realEstates[i].RealEstateID {
+				realEstates[i].Easements = append(realEstates[i].Easements, v)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/timeutil"
+	"chaincode/pkg/utils"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// sellingReference identifies the encumbrance of a sale; a real estate is listed once at a time, so the sale key is enough
+func sellingReference(seller string, objectOfSale string) string {
+	return fmt.Sprintf("%s/%s/%s", model.SellingKey, seller, objectOfSale)
+}
+
+// donatingReference identifies the encumbrance of a donation by the donation key
+func donatingReference(donor string, objectOfDonating string, grantee string) string {
+	return fmt.Sprintf("%s/%s/%s/%s", model.DonatingKey, donor, objectOfDonating, grantee)
+}
+
+// addEncumbrance adds an encumbrance that takes effect with the transaction
+func addEncumbrance(stub shim.ChaincodeStubInterface, realEstate *model.RealEstate, encumbranceType string, reference string, createdBy string, endTime string, blocksTransfer bool) error {
+	now, err := utils.GetTxTime(stub)
+	if err != nil {
+		return err
+	}
+	realEstate.Encumbrances = append(realEstate.Encumbrances, model.Encumbrance{
+		Type:           model.EncumbranceTypeConstant()[encumbranceType],
+		Reference:      reference,
+		CreatedBy:      createdBy,
+		StartTime:      timeutil.Format(now),
+		EndTime:        endTime,
+		BlocksTransfer: blocksTransfer,
+	})
+	return nil
+}
+
+// removeEncumbrance removes the encumbrance of a type and reference, leaving those of other processes in place.
+// Sales and donations also remove the legacy encumbrance, which stood for the one sale or donation in progress.
+func removeEncumbrance(realEstate *model.RealEstate, encumbranceType string, reference string) {
+	types := model.EncumbranceTypeConstant()
+	kept := make([]model.Encumbrance, 0, len(realEstate.Encumbrances))
+	for _, v := range realEstate.Encumbrances {
+		if v.Type == types[encumbranceType] && v.Reference == reference {
+			continue
+		}
+		if v.Type == types["legacy"] && (encumbranceType == "sale" || encumbranceType == "donation") {
+			continue
+		}
+		kept = append(kept, v)
+	}
+	realEstate.Encumbrances = kept
+}
+
+// checkTransferable refuses to sell or donate a real estate while an encumbrance in force blocks transfers
+func checkTransferable(stub shim.ChaincodeStubInterface, realEstate model.RealEstate) error {
+	now, err := utils.GetTxTime(stub)
+	if err != nil {
+		return err
+	}
+	for _, v := range realEstate.Encumbrances {
+		if v.BlocksTransfer && encumbranceInForce(v, now) {
+			return errcode.New(errcode.Conflict, "Real estate %s is encumbered by %s %s and cannot be transferred", realEstate.RealEstateID, v.Type, v.Reference)
+		}
+	}
+	return nil
+}
+
+// encumbranceInForce reports whether an encumbrance applies at a time
+func encumbranceInForce(encumbrance model.Encumbrance, at time.Time) bool {
+	if encumbrance.EndTime == "" {
+		return true
+	}
+	end, err := timeutil.Parse(encumbrance.EndTime)
+	return err != nil || at.Before(end)
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/statebased"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// A real estate may carry a key-level endorsement policy on top of the chaincode one.
+// Peers then only accept changes to its key, including its transfer, when a member of every organization
+// of the policy has endorsed them. The policy belongs to the key, so it is carried over to the new key on transfer.
+
+// SetEndorsementPolicy sets the organizations that must endorse changes to a real estate,
+// or removes the policy when there are none (admin)
+func SetEndorsementPolicy(stub shim.ChaincodeStubInterface, req *model.SetEndorsementPolicyRequest) pb.Response {
+	account, err := getAccount(stub, req.AccountId)
+	if err != nil {
+		return errcode.Responsef(errcode.Forbidden, "Operator permission verification failed: %s", err)
+	}
+	if account.Role != model.AccountRoleConstant()["admin"] {
+		return errcode.Responsef(errcode.Forbidden, "Operator does not have sufficient permissions")
+	}
+	realEstate, err := getRealEstate(stub, req.RealEstateID)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	key, err := realEstateKey(stub, realEstate)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	var policy []byte
+	orgs := []string{}
+	if len(req.Orgs) > 0 {
+		for _, org := range req.Orgs {
+			if org == "" {
+				return errcode.Responsef(errcode.InvalidArgument, "The MSP ID of an organization cannot be empty")
+			}
+		}
+		ep, err := statebased.NewStateEP(nil)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("SetEndorsementPolicy - Failed to create the policy: %s", err))
+		}
+		if err := ep.AddOrgs(statebased.RoleTypeMember, req.Orgs...); err != nil {
+			return shim.Error(fmt.Sprintf("SetEndorsementPolicy - Failed to add the organizations: %s", err))
+		}
+		if policy, err = ep.Policy(); err != nil {
+			return shim.Error(fmt.Sprintf("SetEndorsementPolicy - Failed to serialize the policy: %s", err))
+		}
+		orgs = sortedOrgs(ep)
+	}
+	if err := stub.SetStateValidationParameter(key, policy); err != nil {
+		return errcode.Response(err)
+	}
+	endorsementPolicyByte, err := json.Marshal(model.EndorsementPolicy{RealEstateID: realEstate.RealEstateID, Orgs: orgs})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("SetEndorsementPolicy - Serialization error: %s", err))
+	}
+	return shim.Success(endorsementPolicyByte)
+}
+
+// QueryEndorsementPolicy queries the organizations that must endorse changes to a real estate
+func QueryEndorsementPolicy(stub shim.ChaincodeStubInterface, req *model.QueryEndorsementPolicyRequest) pb.Response {
+	realEstate, err := getRealEstate(stub, req.RealEstateID)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	endorsementPolicy, err := getEndorsementPolicy(stub, realEstate)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	endorsementPolicyByte, err := json.Marshal(endorsementPolicy)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("QueryEndorsementPolicy - Serialization error: %s", err))
+	}
+	return shim.Success(endorsementPolicyByte)
+}
+
+// getEndorsementPolicy reads the key-level endorsement policy of a real estate
+func getEndorsementPolicy(stub shim.ChaincodeStubInterface, realEstate model.RealEstate) (model.EndorsementPolicy, error) {
+	endorsementPolicy := model.EndorsementPolicy{RealEstateID: realEstate.RealEstateID, Orgs: []string{}}
+	key, err := realEstateKey(stub, realEstate)
+	if err != nil {
+		return endorsementPolicy, err
+	}
+	policy, err := stub.GetStateValidationParameter(key)
+	if err != nil {
+		return endorsementPolicy, fmt.Errorf("Failed to read the endorsement policy of %s: %s", realEstate.RealEstateID, err)
+	}
+	if len(policy) == 0 {
+		return endorsementPolicy, nil
+	}
+	ep, err := statebased.NewStateEP(policy)
+	if err != nil {
+		return endorsementPolicy, fmt.Errorf("Failed to parse the endorsement policy of %s: %s", realEstate.RealEstateID, err)
+	}
+	endorsementPolicy.Orgs = sortedOrgs(ep)
+	return endorsementPolicy, nil
+}
+
+// sortedOrgs lists the organizations of a policy in a deterministic order, as endorsing peers must agree on the response
+func sortedOrgs(ep statebased.KeyEndorsementPolicy) []string {
+	orgs := ep.ListOrgs()
+	sort.Strings(orgs)
+	return orgs
+}
+
+// moveEndorsementPolicy carries the key-level endorsement policy of a real estate over from the key of its former proprietor
+func moveEndorsementPolicy(stub shim.ChaincodeStubInterface, realEstate model.RealEstate, from string) error {
+	oldKey, err := stub.CreateCompositeKey(model.RealEstateKey, []string{from, realEstate.RealEstateID})
+	if err != nil {
+		return fmt.Errorf("%s - Failed to create the composite key: %s", model.RealEstateKey, err)
+	}
+	policy, err := stub.GetStateValidationParameter(oldKey)
+	if err != nil {
+		return fmt.Errorf("Failed to read the endorsement policy of %s: %s", realEstate.RealEstateID, err)
+	}
+	if len(policy) == 0 {
+		return nil
+	}
+	key, err := realEstateKey(stub, realEstate)
+	if err != nil {
+		return err
+	}
+	return stub.SetStateValidationParameter(key, policy)
+}
+
+// realEstateKey returns the ledger key of a real estate under its proprietor
+func realEstateKey(stub shim.ChaincodeStubInterface, realEstate model.RealEstate) (string, error) {
+	key, err := stub.CreateCompositeKey(model.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID})
+	if err != nil {
+		return "", fmt.Errorf("%s - Failed to create the composite key: %s", model.RealEstateKey, err)
+	}
+	return key, nil
+}
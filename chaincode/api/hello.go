@@ -8,7 +8,7 @@ import (
 )
 
 // Hello is a test function
-func Hello(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+func Hello(stub shim.ChaincodeStubInterface) pb.Response {
 	err := utils.WriteLedger(map[string]interface{}{"msg": "hello"}, stub, "hello", []string{})
 	if err != nil {
 		return shim.Error(err.Error())
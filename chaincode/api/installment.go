@@ -0,0 +1,207 @@
+package api
+
+import (
+	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/statemachine"
+	"chaincode/pkg/timeutil"
+	"chaincode/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// paymentSchedule is the deposit and installments requested by the seller when initiating a sale
+type paymentSchedule struct {
+	Deposit         float64
+	Installments    []model.Installment
+	OnMissedPayment string
+	GracePeriod     int
+}
+
+// parsePaymentSchedule checks the deposit, missed payment policy and installments requested for a sale.
+// The deposit and the installments must add up to the price.
+func parsePaymentSchedule(req *model.CreateSellingRequest) (paymentSchedule, error) {
+	var schedule paymentSchedule
+	if len(req.Installments) == 0 {
+		return schedule, errcode.New(errcode.InvalidArgument, "Incorrect number of payment schedule parameters")
+	}
+	deposit := req.Deposit
+	if deposit <= 0 {
+		return schedule, errcode.New(errcode.InvalidArgument, "The deposit must be greater than 0")
+	}
+	if _, ok := model.MissedPaymentConstant()[req.OnMissedPayment]; !ok {
+		return schedule, errcode.New(errcode.InvalidArgument, "Missed payment policy %s is not supported", req.OnMissedPayment)
+	}
+	gracePeriod := req.GracePeriod
+	if req.OnMissedPayment == "grace" && gracePeriod <= 0 {
+		return schedule, errcode.New(errcode.InvalidArgument, "The grace period must be greater than 0 days")
+	}
+	total := deposit
+	lastDueDays := 0
+	for _, installment := range req.Installments {
+		amount := installment.Amount
+		dueDays := installment.DueDays
+		if amount <= 0 {
+			return schedule, errcode.New(errcode.InvalidArgument, "Installment amounts must be greater than 0")
+		}
+		if dueDays <= lastDueDays {
+			return schedule, errcode.New(errcode.InvalidArgument, "Installments must be due after the deposit and in increasing order")
+		}
+		lastDueDays = dueDays
+		total += amount
+		schedule.Installments = append(schedule.Installments, model.Installment{Amount: amount, DueDays: dueDays})
+	}
+	if math.Abs(total-req.Price) > 1e-6 {
+		return schedule, errcode.New(errcode.InvalidArgument, "The deposit and installments add up to %f instead of the price %f", total, req.Price)
+	}
+	schedule.Deposit = deposit
+	schedule.OnMissedPayment = req.OnMissedPayment
+	schedule.GracePeriod = gracePeriod
+	return schedule, nil
+}
+
+// PayInstallment pays the next installment of a sale in payment; the final payment moves the sale to delivery
+func PayInstallment(stub shim.ChaincodeStubInterface, req *model.PayInstallmentRequest) pb.Response {
+	objectOfSale := req.ObjectOfSale
+	seller := req.Seller
+	buyer := req.Buyer
+	selling, transition, err := getSellingForEvent(stub, seller, objectOfSale, "payInstallment")
+	if err != nil {
+		return errcode.Response(err)
+	}
+	if selling.Buyer != buyer {
+		return errcode.Responsef(errcode.Forbidden, "Only the buyer of this sale can pay its installments")
+	}
+	sellingBuy, err := getSellingBuy(stub, selling)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	now, err := utils.GetTxTime(stub)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	next := nextInstallment(selling)
+	deadline, err := installmentDeadline(selling.Installments[next])
+	if err != nil {
+		return errcode.Response(err)
+	}
+	if now.After(deadline) {
+		return errcode.Responsef(errcode.Conflict, "The deadline of this installment has passed")
+	}
+	if err := adjustBalance(stub, buyer, -selling.Installments[next].Amount); err != nil {
+		return errcode.Response(err)
+	}
+	selling.Installments[next].PaidTime = timeutil.Format(now)
+	selling.AmountPaid += selling.Installments[next].Amount
+	// After the final payment the seller can confirm receipt and transfer the title
+	selling.SellingStatus = transition.To
+	if err := putSelling(stub, &selling); err != nil {
+		return errcode.Response(err)
+	}
+	sellingBuy.Selling = selling
+	sellingBuyByte, err := json.Marshal(publicSellingBuy(sellingBuy))
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Serialization error for the installment payment: %s", err))
+	}
+	return shim.Success(sellingBuyByte)
+}
+
+// ProcessMissedInstallment applies the missed payment policy once the deadline of the next installment has passed.
+// Under the grace policy the first missed deadline is extended by the grace period; otherwise the deposit is
+// forfeited to the seller, installments already paid are refunded and the real estate is released.
+func ProcessMissedInstallment(stub shim.ChaincodeStubInterface, req *model.ProcessMissedInstallmentRequest) pb.Response {
+	objectOfSale := req.ObjectOfSale
+	seller := req.Seller
+	selling, transition, err := getSellingForEvent(stub, seller, objectOfSale, "missInstallment")
+	if err != nil {
+		return errcode.Response(err)
+	}
+	now, err := utils.GetTxTime(stub)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	next := nextInstallment(selling)
+	installment := selling.Installments[next]
+	deadline, err := installmentDeadline(installment)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	if !now.After(deadline) {
+		return errcode.Responsef(errcode.Conflict, "The deadline of the next installment has not passed yet")
+	}
+	if transition.To == model.SellingStatusConstant()["payment"] {
+		selling.Installments[next].GraceTime = timeutil.Format(deadline.AddDate(0, 0, selling.GracePeriod))
+	} else if _, err := transition.Apply(&sale{stub: stub, selling: selling}); err != nil {
+		return errcode.Response(err)
+	}
+	selling.SellingStatus = transition.To
+	if err := putSelling(stub, &selling); err != nil {
+		return errcode.Response(err)
+	}
+	sellingByte, err := json.Marshal(publicSelling(selling))
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Serialization error for the sale: %s", err))
+	}
+	return shim.Success(sellingByte)
+}
+
+// getSellingForEvent returns a sale and the transition event takes it through
+func getSellingForEvent(stub shim.ChaincodeStubInterface, seller string, objectOfSale string, event string) (model.Selling, statemachine.Transition, error) {
+	selling, err := getSelling(stub, seller, objectOfSale)
+	if err != nil {
+		return selling, statemachine.Transition{}, err
+	}
+	transition, err := SellingMachine.Next(selling.SellingStatus, event, &sale{stub: stub, selling: selling})
+	return selling, transition, err
+}
+
+// forfeitSelling forfeits the deposit of a sale whose installment was missed to the seller,
+// refunds the installments already paid and releases the real estate
+func forfeitSelling(stub shim.ChaincodeStubInterface, selling model.Selling) error {
+	resultsRealEstate, err := utils.GetStateByPartialCompositeKeys2(stub, model.RealEstateKey, []string{selling.Seller, selling.ObjectOfSale})
+	if err != nil || len(resultsRealEstate) != 1 {
+		return fmt.Errorf("Failed to retrieve real estate information based on %s and %s: %s", selling.ObjectOfSale, selling.Seller, err)
+	}
+	var realEstate model.RealEstate
+	if err = json.Unmarshal(resultsRealEstate[0], &realEstate); err != nil {
+		return fmt.Errorf("ProcessMissedInstallment - Deserialization error: %s", err)
+	}
+	if err := adjustBalance(stub, selling.Seller, selling.Deposit); err != nil {
+		return err
+	}
+	if refund := selling.AmountPaid - selling.Deposit; refund > 0 {
+		if err := adjustBalance(stub, selling.Buyer, refund); err != nil {
+			return err
+		}
+	}
+	removeEncumbrance(&realEstate, "sale", sellingReference(selling.Seller, selling.ObjectOfSale))
+	return utils.WriteLedger(realEstate, stub, model.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID})
+}
+
+// nextInstallment returns the index of the first unpaid installment; a sale in payment always has one
+func nextInstallment(selling model.Selling) int {
+	for i, v := range selling.Installments {
+		if v.PaidTime == "" {
+			return i
+		}
+	}
+	return len(selling.Installments) - 1
+}
+
+// installmentDeadline returns the deadline of an installment, taking a granted grace period into account
+func installmentDeadline(installment model.Installment) (time.Time, error) {
+	deadline := installment.DueTime
+	if installment.GraceTime != "" {
+		deadline = installment.GraceTime
+	}
+	t, err := timeutil.Parse(deadline)
+	if err != nil {
+		return t, fmt.Errorf("Failed to parse the installment deadline %s: %s", deadline, err)
+	}
+	return t, nil
+}
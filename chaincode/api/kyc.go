@@ -0,0 +1,135 @@
+package api
+
+import (
+	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/timeutil"
+	"chaincode/pkg/utils"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// RequestKyc puts an account in line for identity verification
+func RequestKyc(stub shim.ChaincodeStubInterface, req *model.RequestKycRequest) pb.Response {
+	account, err := getAccount(stub, req.AccountId)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	if account.Role != model.AccountRoleConstant()["owner"] {
+		return errcode.Responsef(errcode.Forbidden, "Only owner accounts are verified")
+	}
+	switch kycStatusOf(account) {
+	case model.KycStatusConstant()["pending"]:
+		return errcode.Responsef(errcode.Conflict, "Verification of %s is already pending", account.AccountId)
+	case model.KycStatusConstant()["verified"]:
+		if err := checkKyc(stub, account); err == nil {
+			return errcode.Responsef(errcode.Conflict, "%s is verified until %s", account.AccountId, account.KycExpiry)
+		}
+	}
+	account.KycStatus = model.KycStatusConstant()["pending"]
+	if err := PutAccount(stub, &account); err != nil {
+		return errcode.Response(err)
+	}
+	return publicAccountResponse(account)
+}
+
+// ReviewKyc lets a verifier verify or reject the pending identity verification of an account
+func ReviewKyc(stub shim.ChaincodeStubInterface, req *model.ReviewKycRequest) pb.Response {
+	// Verify that the operator is a verifier
+	verifier, err := getAccount(stub, req.Verifier)
+	if err != nil {
+		return errcode.Responsef(errcode.Forbidden, "Operator permission verification failed: %s", err)
+	}
+	if verifier.Role != model.AccountRoleConstant()["verifier"] {
+		return errcode.Responsef(errcode.Forbidden, "Operator does not have sufficient permissions")
+	}
+	account, err := getAccount(stub, req.AccountId)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	if kycStatusOf(account) != model.KycStatusConstant()["pending"] {
+		return errcode.Responsef(errcode.Conflict, "Verification of %s is not pending", account.AccountId)
+	}
+	switch req.Decision {
+	case "verified":
+		if req.ValidityDays <= 0 {
+			return errcode.Responsef(errcode.InvalidArgument, "A verification must be valid for at least one day")
+		}
+		now, err := utils.GetTxTime(stub)
+		if err != nil {
+			return errcode.Response(err)
+		}
+		account.KycExpiry = timeutil.Format(now.AddDate(0, 0, req.ValidityDays))
+	case "rejected":
+		account.KycExpiry = ""
+	default:
+		return errcode.Responsef(errcode.InvalidArgument, "Decision %s is not supported", req.Decision)
+	}
+	account.KycStatus = model.KycStatusConstant()[req.Decision]
+	account.KycVerifier = verifier.AccountId
+	if err := PutAccount(stub, &account); err != nil {
+		return errcode.Response(err)
+	}
+	return publicAccountResponse(account)
+}
+
+// QueryPendingKycList queries the accounts waiting for identity verification
+func QueryPendingKycList(stub shim.ChaincodeStubInterface) pb.Response {
+	var accountList []model.Account
+	results, err := utils.GetStateByPartialCompositeKeys(stub, model.AccountKey, nil)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	for _, v := range results {
+		if v != nil {
+			var account model.Account
+			if err := json.Unmarshal(v, &account); err != nil {
+				return shim.Error(fmt.Sprintf("QueryPendingKycList - Deserialization error: %s", err))
+			}
+			if kycStatusOf(account) == model.KycStatusConstant()["pending"] {
+				accountList = append(accountList, account)
+			}
+		}
+	}
+	accountListByte, err := json.Marshal(accountList)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("QueryPendingKycList - Serialization error: %s", err))
+	}
+	return shim.Success(accountListByte)
+}
+
+// checkKyc refuses accounts whose identity is not verified, or whose verification has expired
+func checkKyc(stub shim.ChaincodeStubInterface, account model.Account) error {
+	if status := kycStatusOf(account); status != model.KycStatusConstant()["verified"] {
+		return errcode.New(errcode.Forbidden, "The identity of %s is not verified (%s)", account.AccountId, status)
+	}
+	now, err := utils.GetTxTime(stub)
+	if err != nil {
+		return err
+	}
+	expiry, err := timeutil.Parse(account.KycExpiry)
+	if err != nil || !now.Before(expiry) {
+		return errcode.New(errcode.Forbidden, "The identity verification of %s expired on %s", account.AccountId, account.KycExpiry)
+	}
+	return nil
+}
+
+// kycStatusOf returns the verification status of an account; accounts written before verification are unverified
+func kycStatusOf(account model.Account) string {
+	if account.KycStatus == "" {
+		return model.KycStatusConstant()["unverified"]
+	}
+	return account.KycStatus
+}
+
+// publicAccountResponse returns an account without its private name and balance
+func publicAccountResponse(account model.Account) pb.Response {
+	accountByte, err := json.Marshal(publicAccount(account))
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Account serialization error: %s", err))
+	}
+	return shim.Success(accountByte)
+}
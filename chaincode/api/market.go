@@ -0,0 +1,129 @@
+package api
+
+import (
+	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/utils"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// QueryActiveListings queries one page of the sales in progress across all sellers with the real estate they sell,
+// optionally within a price range and a total area range (collection members only)
+func QueryActiveListings(stub shim.ChaincodeStubInterface, req *model.QueryActiveListingsRequest) pb.Response {
+	if req.PageSize <= 0 || req.PageSize > model.ActiveListingPageLimit {
+		return errcode.Responsef(errcode.InvalidArgument, "The page size must be between 1 and %d", model.ActiveListingPageLimit)
+	}
+	if req.MinPrice < 0 || req.MaxPrice < 0 || req.MinArea < 0 || req.MaxArea < 0 {
+		return errcode.Responsef(errcode.InvalidArgument, "The price and area bounds cannot be negative")
+	}
+	if (req.MaxPrice > 0 && req.MinPrice > req.MaxPrice) || (req.MaxArea > 0 && req.MinArea > req.MaxArea) {
+		return errcode.Responsef(errcode.InvalidArgument, "The lower bound cannot exceed the upper bound")
+	}
+	// Prices are read from the private data collection
+	if err := checkCollectionAccess(stub, model.SellingCollection); err != nil {
+		return errcode.Response(err)
+	}
+	// A call examines a bounded number of index entries, resuming at the bookmark of the previous page
+	page := model.ActiveListingPage{Listings: []model.ActiveListing{}, Bookmark: req.Bookmark}
+	for scanned := 0; len(page.Listings) < req.PageSize && scanned < model.ActiveListingScanLimit; {
+		// An entry yields at most one listing, so every entry fetched fits in the page and the bookmark skips none
+		fetch := req.PageSize - len(page.Listings)
+		if fetch > model.ActiveListingScanLimit-scanned {
+			fetch = model.ActiveListingScanLimit - scanned
+		}
+		fetched, bookmark, err := scanActiveListings(stub, req, &page, fetch)
+		if err != nil {
+			return errcode.Response(err)
+		}
+		scanned += fetched
+		page.Bookmark = bookmark
+		if fetched < fetch || bookmark == "" {
+			page.Done = true
+			break
+		}
+	}
+	pageByte, err := json.Marshal(page)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("QueryActiveListings - Serialization error: %s", err))
+	}
+	return shim.Success(pageByte)
+}
+
+// scanActiveListings examines up to fetch entries of the status index from the bookmark of the page, adding the
+// listings that match the query. It returns the number of entries examined and the bookmark of the next one.
+func scanActiveListings(stub shim.ChaincodeStubInterface, req *model.QueryActiveListingsRequest, page *model.ActiveListingPage, fetch int) (int, string, error) {
+	resultIterator, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination(model.SellingStatusIndexKey,
+		[]string{model.SellingStatusConstant()["saleStart"]}, int32(fetch), page.Bookmark)
+	if err != nil {
+		return 0, "", fmt.Errorf("%s - Error getting all data: %s", model.SellingStatusIndexKey, err)
+	}
+	defer resultIterator.Close()
+	fetched := 0
+	for resultIterator.HasNext() {
+		val, err := resultIterator.Next()
+		if err != nil {
+			return 0, "", fmt.Errorf("%s - Error with returned data: %s", model.SellingStatusIndexKey, err)
+		}
+		fetched++
+		var index model.SellingStatusIndex
+		if err := json.Unmarshal(val.GetValue(), &index); err != nil {
+			return 0, "", fmt.Errorf("SellingStatusIndex - Deserialization error: %s", err)
+		}
+		listing, ok, err := getActiveListing(stub, index)
+		if err != nil {
+			return 0, "", err
+		}
+		if ok && listingMatches(listing, req) {
+			page.Listings = append(page.Listings, listing)
+		}
+	}
+	return fetched, metadata.GetBookmark(), nil
+}
+
+// getActiveListing joins a status index entry with its sale and real estate.
+// It reports false when the sale is no longer in the indexed status, and fails when the sale or its real estate is missing.
+func getActiveListing(stub shim.ChaincodeStubInterface, index model.SellingStatusIndex) (model.ActiveListing, bool, error) {
+	var listing model.ActiveListing
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.SellingKey, []string{index.Seller, index.ObjectOfSale})
+	if err != nil {
+		return listing, false, err
+	}
+	if len(results) != 1 {
+		return listing, false, errcode.New(errcode.NotFound, "The sale of %s by %s in the status index is missing", index.ObjectOfSale, index.Seller)
+	}
+	if err := json.Unmarshal(results[0], &listing.Selling); err != nil {
+		return listing, false, fmt.Errorf("Selling - Deserialization error: %s", err)
+	}
+	if listing.Selling.SellingStatus != index.SellingStatus {
+		return listing, false, nil
+	}
+	if err := readSellingPrivate(stub, &listing.Selling, model.SellingKey, []string{index.Seller, index.ObjectOfSale}); err != nil {
+		return listing, false, err
+	}
+	// The seller is the proprietor of the real estate while it is on sale
+	results, err = utils.GetStateByPartialCompositeKeys2(stub, model.RealEstateKey, []string{index.Seller, index.ObjectOfSale})
+	if err != nil {
+		return listing, false, err
+	}
+	if len(results) != 1 {
+		return listing, false, errcode.New(errcode.NotFound, "The real estate %s on sale by %s is missing", index.ObjectOfSale, index.Seller)
+	}
+	if err := json.Unmarshal(results[0], &listing.RealEstate); err != nil {
+		return listing, false, fmt.Errorf("RealEstate - Deserialization error: %s", err)
+	}
+	return listing, true, nil
+}
+
+// listingMatches reports whether a listing is within the price and area ranges of the query
+func listingMatches(listing model.ActiveListing, req *model.QueryActiveListingsRequest) bool {
+	price := listing.Selling.Price
+	area := listing.RealEstate.TotalArea
+	if price < req.MinPrice || (req.MaxPrice > 0 && price > req.MaxPrice) {
+		return false
+	}
+	return area >= req.MinArea && (req.MaxArea == 0 || area <= req.MaxArea)
+}
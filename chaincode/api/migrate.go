@@ -0,0 +1,277 @@
+package api
+
+import (
+	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/timeutil"
+	"chaincode/pkg/utils"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// QueryMigrationBatch returns the keys of one bounded batch of records of an object type, starting at the bookmark.
+// Fabric refuses writes after a paginated query, so the records are upgraded by a separate migrate transaction.
+func QueryMigrationBatch(stub shim.ChaincodeStubInterface, req *model.QueryMigrationBatchRequest) pb.Response {
+	objectType := req.ObjectType
+	if req.BatchSize <= 0 || req.BatchSize > model.MigrationBatchLimit {
+		return errcode.Responsef(errcode.InvalidArgument, "The batch size must be between 1 and %d", model.MigrationBatchLimit)
+	}
+	if err := checkMigratable(objectType); err != nil {
+		return errcode.Response(err)
+	}
+	resultIterator, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination(objectType, []string{}, int32(req.BatchSize), req.Bookmark)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s - Error getting all data: %s", objectType, err))
+	}
+	defer resultIterator.Close()
+	batch := model.MigrationBatch{ObjectType: objectType, Keys: []string{}}
+	for resultIterator.HasNext() {
+		val, err := resultIterator.Next()
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s - Error with returned data: %s", objectType, err))
+		}
+		batch.Keys = append(batch.Keys, val.GetKey())
+	}
+	batch.Bookmark = metadata.GetBookmark()
+	batch.Done = len(batch.Keys) < req.BatchSize || batch.Bookmark == ""
+	batchByte, err := json.Marshal(batch)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("QueryMigrationBatch - Serialization error: %s", err))
+	}
+	return shim.Success(batchByte)
+}
+
+// Migrate upgrades the stored records of one object type to the current schema version, reading only the records
+// of one batch returned by QueryMigrationBatch (admin)
+func Migrate(stub shim.ChaincodeStubInterface, req *model.MigrateRequest) pb.Response {
+	accountId := req.AccountId // Account ID for verifying admin rights
+	objectType := req.ObjectType
+	if len(req.Keys) == 0 || len(req.Keys) > model.MigrationBatchLimit {
+		return errcode.Responsef(errcode.InvalidArgument, "The batch must hold between 1 and %d keys", model.MigrationBatchLimit)
+	}
+	if err := checkMigratable(objectType); err != nil {
+		return errcode.Response(err)
+	}
+	// Verify if it's an admin operation
+	account, err := getAccount(stub, accountId)
+	if err != nil {
+		return errcode.Responsef(errcode.Forbidden, "Operator permission verification failed: %s", err)
+	}
+	if account.Role != model.AccountRoleConstant()["admin"] {
+		return errcode.Responsef(errcode.Forbidden, "Operator does not have sufficient permissions")
+	}
+	report := model.MigrationReport{ObjectType: objectType}
+	for _, key := range req.Keys {
+		keyType, _, err := stub.SplitCompositeKey(key)
+		if err != nil || keyType != objectType {
+			return errcode.Responsef(errcode.InvalidArgument, "%q is not a key of %s", key, objectType)
+		}
+		value, err := stub.GetState(key)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s - Error reading from the blockchain ledger: %s", objectType, err))
+		}
+		report.Scanned++
+		// A record moved or deleted since the batch was queried has nothing left to upgrade
+		if value == nil {
+			continue
+		}
+		upgraded, changed, err := model.UpgradeRecord(objectType, value)
+		if err != nil {
+			return errcode.Response(err)
+		}
+		// Real estate created before the ID index reserve their IDs as they are scanned
+		if objectType == model.RealEstateKey {
+			if err := backfillRealEstateID(stub, upgraded); err != nil {
+				return errcode.Response(err)
+			}
+		}
+		// Sales written before the status index are indexed as they are scanned
+		if objectType == model.SellingKey {
+			if err := backfillSellingStatus(stub, upgraded); err != nil {
+				return errcode.Response(err)
+			}
+		}
+		rewritten, err := migrateRecord(stub, objectType, key, upgraded)
+		if err != nil {
+			return errcode.Response(err)
+		}
+		if changed && !rewritten {
+			if err := stub.PutState(key, upgraded); err != nil {
+				return shim.Error(fmt.Sprintf("%s - Error writing to the blockchain ledger: %s", objectType, err))
+			}
+		}
+		if changed || rewritten {
+			report.Migrated++
+		}
+	}
+	reportByte, err := json.Marshal(report)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Migrate - Serialization error: %s", err))
+	}
+	return shim.Success(reportByte)
+}
+
+// checkMigratable ensures the records of an object type can be migrated
+func checkMigratable(objectType string) error {
+	for _, v := range model.MigratableObjectTypesConstant() {
+		if v == objectType {
+			return nil
+		}
+	}
+	return errcode.New(errcode.InvalidArgument, "Object type %s cannot be migrated", objectType)
+}
+
+// backfillRealEstateID adds a real estate record to the ID index when it is missing, or records its proprietor
+// in an entry written before the index recorded it
+func backfillRealEstateID(stub shim.ChaincodeStubInterface, data []byte) error {
+	var realEstate model.RealEstate
+	if err := json.Unmarshal(data, &realEstate); err != nil {
+		return fmt.Errorf("RealEstate - Deserialization error: %s", err)
+	}
+	index, err := getRealEstateIndex(stub, realEstate.RealEstateID)
+	if err != nil || (index != nil && index.Proprietor != "") {
+		return err
+	}
+	return indexRealEstateID(stub, realEstate)
+}
+
+// backfillSellingStatus adds a sale to the status index when it is missing
+func backfillSellingStatus(stub shim.ChaincodeStubInterface, data []byte) error {
+	var selling model.Selling
+	if err := json.Unmarshal(data, &selling); err != nil {
+		return fmt.Errorf("Selling - Deserialization error: %s", err)
+	}
+	index := sellingStatusIndexOf(selling)
+	key, err := stub.CreateCompositeKey(model.SellingStatusIndexKey, []string{index.SellingStatus, index.Seller, index.ObjectOfSale})
+	if err != nil {
+		return fmt.Errorf("%s - Failed to create the composite key: %s", model.SellingStatusIndexKey, err)
+	}
+	indexed, err := stub.GetState(key)
+	if err != nil || indexed != nil {
+		return err
+	}
+	return utils.WriteLedger(index, stub, model.SellingStatusIndexKey, []string{index.SellingStatus, index.Seller, index.ObjectOfSale})
+}
+
+// migrateRecord makes the changes UpgradeRecord cannot make to a single record. It rewrites the legacy times of the installments
+// sales keep in the private data collection, and moves disputes, whose key contains their creation time, to the key of the upgraded time.
+// The buyer's and grantee's copies of sales and donations are replaced by index entries. It reports whether it wrote the record.
+func migrateRecord(stub shim.ChaincodeStubInterface, objectType string, key string, data []byte) (bool, error) {
+	switch objectType {
+	case model.SellingKey:
+		var selling model.Selling
+		if err := json.Unmarshal(data, &selling); err != nil {
+			return false, fmt.Errorf("Selling - Deserialization error: %s", err)
+		}
+		if selling.PrivateHash == "" {
+			return false, nil
+		}
+		if err := readSellingPrivate(stub, &selling, model.SellingKey, []string{selling.Seller, selling.ObjectOfSale}); err != nil {
+			return false, err
+		}
+		if !upgradeInstallmentTimes(selling.Installments) {
+			return false, nil
+		}
+		return true, putSelling(stub, &selling)
+	case model.SellingBuyKey:
+		var sellingBuy model.SellingBuy
+		if err := json.Unmarshal(data, &sellingBuy); err != nil {
+			return false, fmt.Errorf("SellingBuy - Deserialization error: %s", err)
+		}
+		// The copy becomes an index entry if its sale is still on record; copies of purchases superseded
+		// by a later sale of the real estate are dropped, and remain in the history of their key
+		index := sellingBuyerIndexOf(sellingBuy)
+		index.CreateTime = timeutil.Upgrade(index.CreateTime)
+		results, err := utils.GetStateByPartialCompositeKeys2(stub, model.SellingKey, []string{index.Seller, index.ObjectOfSale})
+		if err != nil {
+			return false, err
+		}
+		if len(results) == 1 {
+			var selling model.Selling
+			if err := json.Unmarshal(results[0], &selling); err != nil {
+				return false, fmt.Errorf("Selling - Deserialization error: %s", err)
+			}
+			if selling.Buyer == index.Buyer && selling.CreateTime == index.ListingTime {
+				if err := utils.WriteLedger(index, stub, model.SellingBuyerIndexKey, []string{index.Buyer, index.Seller, index.ObjectOfSale}); err != nil {
+					return false, err
+				}
+			}
+		}
+		if err := stub.DelState(key); err != nil {
+			return false, fmt.Errorf("%s - Error deleting from the blockchain ledger: %s", objectType, err)
+		}
+		// The private details of the copy were kept under its own key
+		if sellingBuy.Selling.PrivateHash != "" {
+			if err := stub.DelPrivateData(model.SellingCollection, key); err != nil {
+				return false, fmt.Errorf("%s - Error deleting from the private data collection %s: %s", objectType, model.SellingCollection, err)
+			}
+		}
+		return true, nil
+	case model.DonatingGranteeKey:
+		var donatingGrantee model.DonatingGrantee
+		if err := json.Unmarshal(data, &donatingGrantee); err != nil {
+			return false, fmt.Errorf("DonatingGrantee - Deserialization error: %s", err)
+		}
+		// As for sales, copies of donations replaced by a later donation are dropped
+		index := donatingGranteeIndexOf(donatingGrantee)
+		results, err := utils.GetStateByPartialCompositeKeys2(stub, model.DonatingKey, []string{index.Donor, index.ObjectOfDonating, index.Grantee})
+		if err != nil {
+			return false, err
+		}
+		if len(results) == 1 {
+			var donating model.Donating
+			if err := json.Unmarshal(results[0], &donating); err != nil {
+				return false, fmt.Errorf("Donating - Deserialization error: %s", err)
+			}
+			if donating.CreateTime == donatingGrantee.Donating.CreateTime {
+				if err := utils.WriteLedger(index, stub, model.DonatingGranteeIndexKey, []string{index.Grantee, index.Donor, index.ObjectOfDonating}); err != nil {
+					return false, err
+				}
+			}
+		}
+		if err := stub.DelState(key); err != nil {
+			return false, fmt.Errorf("%s - Error deleting from the blockchain ledger: %s", objectType, err)
+		}
+		return true, nil
+	case model.DisputeKey:
+		var dispute model.Dispute
+		if err := json.Unmarshal(data, &dispute); err != nil {
+			return false, fmt.Errorf("Dispute - Deserialization error: %s", err)
+		}
+		createTime := timeutil.Upgrade(dispute.CreateTime)
+		if createTime == dispute.CreateTime {
+			return false, nil
+		}
+		dispute.CreateTime = createTime
+		return true, moveRecord(stub, key, dispute, model.DisputeKey, []string{dispute.Seller, dispute.ObjectOfSale, dispute.CreateTime})
+	}
+	return false, nil
+}
+
+// moveRecord writes a record under its new key and deletes it from the old one
+func moveRecord(stub shim.ChaincodeStubInterface, key string, obj interface{}, objectType string, keys []string) error {
+	if err := utils.WriteLedger(obj, stub, objectType, keys); err != nil {
+		return err
+	}
+	if err := stub.DelState(key); err != nil {
+		return fmt.Errorf("%s - Error deleting from the blockchain ledger: %s", objectType, err)
+	}
+	return nil
+}
+
+// upgradeInstallmentTimes rewrites the legacy times of installments and reports whether any changed
+func upgradeInstallmentTimes(installments []model.Installment) bool {
+	changed := false
+	for i, v := range installments {
+		installments[i].DueTime = timeutil.Upgrade(v.DueTime)
+		installments[i].GraceTime = timeutil.Upgrade(v.GraceTime)
+		installments[i].PaidTime = timeutil.Upgrade(v.PaidTime)
+		if installments[i] != v {
+			changed = true
+		}
+	}
+	return changed
+}
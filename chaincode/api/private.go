@@ -0,0 +1,41 @@
+package api
+
+import (
+	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"encoding/hex"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/cid"
+)
+
+// checkCollectionAccess ensures that the client's organization is a member of a private data collection.
+// Peers already refuse to serve memberOnlyRead collections to other organizations; this gives a clear error
+// and protects collections shared with peers of organizations that may not read them.
+func checkCollectionAccess(stub shim.ChaincodeStubInterface, collection string) error {
+	mspId, err := cid.GetMSPID(stub)
+	if err != nil {
+		return errcode.New(errcode.Forbidden, "Failed to identify the client organization: %s", err)
+	}
+	for _, member := range model.CollectionMembersConstant()[collection] {
+		if member == mspId {
+			return nil
+		}
+	}
+	return errcode.New(errcode.Forbidden, "Organization %s is not allowed to read %s", mspId, collection)
+}
+
+// privateSalt returns the salt of the private data written by the transaction: the random bytes the client passes
+// in the transient data, which unlike the transaction ID never reach the public ledger. Endorsing peers must write the
+// same data, so the chaincode cannot draw the salt itself.
+func privateSalt(stub shim.ChaincodeStubInterface) (string, error) {
+	transient, err := stub.GetTransient()
+	if err != nil {
+		return "", errcode.New(errcode.InvalidArgument, "Failed to read the transient data: %s", err)
+	}
+	salt := transient[model.TransientSaltKey]
+	if len(salt) < model.SaltMinLength {
+		return "", errcode.New(errcode.InvalidArgument, "A random salt of at least %d bytes must be passed in the transient data under %s", model.SaltMinLength, model.TransientSaltKey)
+	}
+	return hex.EncodeToString(salt), nil
+}
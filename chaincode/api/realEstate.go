@@ -2,71 +2,60 @@ package api
 
 import (
 	"chaincode/model"
+	"chaincode/pkg/errcode"
 	"chaincode/pkg/utils"
 	"encoding/json"
 	"fmt"
-	"strconv"
+	"regexp"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	pb "github.com/hyperledger/fabric/protos/peer"
 )
 
 // CreateRealEstate creates new real estate (admin)
-func CreateRealEstate(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// Validate parameters
-	if len(args) != 4 {
-		return shim.Error("Insufficient number of parameters")
-	}
-	accountId := args[0] // Account ID for verifying admin rights
-	proprietor := args[1]
-	totalArea := args[2]
-	livingSpace := args[3]
-	if accountId == "" || proprietor == "" || totalArea == "" || livingSpace == "" {
-		return shim.Error("Parameters contain empty values")
-	}
+func CreateRealEstate(stub shim.ChaincodeStubInterface, req *model.CreateRealEstateRequest) pb.Response {
+	accountId := req.AccountId // Account ID for verifying admin rights
+	proprietor := req.Proprietor
 	if accountId == proprietor {
-		return shim.Error("The operator should be an admin and cannot be the same as the owner")
-	}
-	// Convert parameter data format
-	var formattedTotalArea float64
-	if val, err := strconv.ParseFloat(totalArea, 64); err != nil {
-		return shim.Error(fmt.Sprintf("Failed to convert 'totalArea' parameter format: %s", err))
-	} else {
-		formattedTotalArea = val
-	}
-	var formattedLivingSpace float64
-	if val, err := strconv.ParseFloat(livingSpace, 64); err != nil {
-		return shim.Error(fmt.Sprintf("Failed to convert 'livingSpace' parameter format: %s", err))
-	} else {
-		formattedLivingSpace = val
+		return errcode.Responsef(errcode.Forbidden, "The operator should be an admin and cannot be the same as the owner")
 	}
 	// Verify if it's an admin operation
-	resultsAccount, err := utils.GetStateByPartialCompositeKeys(stub, model.AccountKey, []string{accountId})
-	if err != nil || len(resultsAccount) != 1 {
-		return shim.Error(fmt.Sprintf("Operator permission verification failed: %s", err))
-	}
-	var account model.Account
-	if err = json.Unmarshal(resultsAccount[0], &account); err != nil {
-		return shim.Error(fmt.Sprintf("Query operator information failed: %s", err))
+	account, err := getAccount(stub, accountId)
+	if err != nil {
+		return errcode.Responsef(errcode.Forbidden, "Operator permission verification failed: %s", err)
 	}
-	if account.UserName != "Admin" {
-		return shim.Error("Operator does not have sufficient permissions")
+	if account.Role != model.AccountRoleConstant()["admin"] {
+		return errcode.Responsef(errcode.Forbidden, "Operator does not have sufficient permissions")
 	}
 	// Verify the existence of the proprietor
 	resultsProprietor, err := utils.GetStateByPartialCompositeKeys(stub, model.AccountKey, []string{proprietor})
 	if err != nil || len(resultsProprietor) != 1 {
-		return shim.Error(fmt.Sprintf("Owner 'proprietor' information verification failed: %s", err))
+		return errcode.Responsef(errcode.NotFound, "Owner 'proprietor' information verification failed: %s", err)
+	}
+	propertyType, err := propertyTypeOf(req.PropertyType)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	realEstateID, err := assignRealEstateID(stub, req.RealEstateID, stub.GetTxID()[:16], nil)
+	if err != nil {
+		return errcode.Response(err)
 	}
 	realEstate := &model.RealEstate{
-		RealEstateID: stub.GetTxID()[:16],
-		Proprietor:   proprietor,
-		Encumbrance:  false,
-		TotalArea:    formattedTotalArea,
-		LivingSpace:  formattedLivingSpace,
+		RealEstateID:  realEstateID,
+		Proprietor:    proprietor,
+		Encumbrances:  []model.Encumbrance{},
+		TotalArea:     req.TotalArea,
+		LivingSpace:   req.LivingSpace,
+		PropertyType:  propertyType,
+		TransferTxID:  stub.GetTxID(),
+		SchemaVersion: model.SchemaVersion,
 	}
-	// Write to the ledger
+	// Write to the ledger and reserve the ID
 	if err := utils.WriteLedger(realEstate, stub, model.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
-		return shim.Error(fmt.Sprintf("%s", err))
+		return errcode.Response(err)
+	}
+	if err := indexRealEstateID(stub, *realEstate); err != nil {
+		return errcode.Response(err)
 	}
 	// Return the information of the successfully created real estate
 	realEstateByte, err := json.Marshal(realEstate)
@@ -77,12 +66,81 @@ func CreateRealEstate(stub shim.ChaincodeStubInterface, args []string) pb.Respon
 	return shim.Success(realEstateByte)
 }
 
+// CreateRealEstateBatch creates several real estates in one transaction, all of them or none (admin)
+func CreateRealEstateBatch(stub shim.ChaincodeStubInterface, req *model.CreateRealEstateBatchRequest) pb.Response {
+	accountId := req.AccountId // Account ID for verifying admin rights
+	if len(req.RealEstates) > model.RealEstateBatchLimit {
+		return errcode.Responsef(errcode.InvalidArgument, "A batch may create at most %d real estates", model.RealEstateBatchLimit)
+	}
+	// Verify if it's an admin operation
+	account, err := getAccount(stub, accountId)
+	if err != nil {
+		return errcode.Responsef(errcode.Forbidden, "Operator permission verification failed: %s", err)
+	}
+	if account.Role != model.AccountRoleConstant()["admin"] {
+		return errcode.Responsef(errcode.Forbidden, "Operator does not have sufficient permissions")
+	}
+	// The transaction cannot read its own writes, so the IDs assigned earlier in the batch are tracked here
+	assigned := map[string]bool{}
+	proprietors := map[string]bool{}
+	realEstates := make([]model.RealEstate, 0, len(req.RealEstates))
+	for i, item := range req.RealEstates {
+		if item.Proprietor == accountId {
+			return errcode.Responsef(errcode.Forbidden, "Real estate %d: the operator should be an admin and cannot be the same as the owner", i+1)
+		}
+		if item.TotalArea <= 0 || item.LivingSpace <= 0 || item.LivingSpace > item.TotalArea {
+			return errcode.Responsef(errcode.InvalidArgument, "Real estate %d: the areas must be greater than 0 and the living space at most the total area", i+1)
+		}
+		// Verify the existence of the proprietor
+		if !proprietors[item.Proprietor] {
+			resultsProprietor, err := utils.GetStateByPartialCompositeKeys(stub, model.AccountKey, []string{item.Proprietor})
+			if err != nil || len(resultsProprietor) != 1 {
+				return errcode.Responsef(errcode.NotFound, "Real estate %d: owner 'proprietor' information verification failed: %s", i+1, err)
+			}
+			proprietors[item.Proprietor] = true
+		}
+		propertyType, err := propertyTypeOf(item.PropertyType)
+		if err != nil {
+			return errcode.Responsef(errcode.CodeOf(err), "Real estate %d: %s", i+1, err)
+		}
+		realEstateID, err := assignRealEstateID(stub, item.RealEstateID, fmt.Sprintf("%s.%d", stub.GetTxID()[:16], i+1), assigned)
+		if err != nil {
+			return errcode.Responsef(errcode.CodeOf(err), "Real estate %d: %s", i+1, err)
+		}
+		assigned[realEstateID] = true
+		realEstates = append(realEstates, model.RealEstate{
+			RealEstateID:  realEstateID,
+			Proprietor:    item.Proprietor,
+			Encumbrances:  []model.Encumbrance{},
+			TotalArea:     item.TotalArea,
+			LivingSpace:   item.LivingSpace,
+			PropertyType:  propertyType,
+			TransferTxID:  stub.GetTxID(),
+			SchemaVersion: model.SchemaVersion,
+		})
+	}
+	// Write to the ledger and reserve the IDs only once every real estate is valid
+	for i := range realEstates {
+		if err := utils.WriteLedger(&realEstates[i], stub, model.RealEstateKey, []string{realEstates[i].Proprietor, realEstates[i].RealEstateID}); err != nil {
+			return errcode.Response(err)
+		}
+		if err := indexRealEstateID(stub, realEstates[i]); err != nil {
+			return errcode.Response(err)
+		}
+	}
+	realEstatesByte, err := json.Marshal(realEstates)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Failed to serialize the information of the created real estates: %s", err))
+	}
+	return shim.Success(realEstatesByte)
+}
+
 // QueryRealEstateList queries real estate (can query all or by owner)
-func QueryRealEstateList(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+func QueryRealEstateList(stub shim.ChaincodeStubInterface, req *model.QueryRealEstateListRequest) pb.Response {
 	var realEstateList []model.RealEstate
-	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.RealEstateKey, args)
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.RealEstateKey, utils.KeyPrefix(req.Proprietor, req.RealEstateID))
 	if err != nil {
-		return shim.Error(fmt.Sprintf("%s", err))
+		return errcode.Response(err)
 	}
 	for _, v := range results {
 		if v != nil {
@@ -94,9 +152,188 @@ func QueryRealEstateList(stub shim.ChaincodeStubInterface, args []string) pb.Res
 			realEstateList = append(realEstateList, realEstate)
 		}
 	}
+	if err := attachEasements(stub, realEstateList); err != nil {
+		return errcode.Response(err)
+	}
 	realEstateListByte, err := json.Marshal(realEstateList)
 	if err != nil {
 		return shim.Error(fmt.Sprintf("Failed to serialize QueryRealEstateList: %s", err))
 	}
 	return shim.Success(realEstateListByte)
 }
+
+// QueryRealEstate queries a real estate by ID, along with the transaction that gave it to its proprietor
+func QueryRealEstate(stub shim.ChaincodeStubInterface, req *model.QueryRealEstateRequest) pb.Response {
+	realEstate, err := getRealEstate(stub, req.RealEstateID)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	if realEstate.TransferTxID == "" {
+		// Real estate written before transfers were recorded, found from the history of its key
+		if realEstate.TransferTxID, err = lastTransferTxID(stub, realEstate); err != nil {
+			return errcode.Response(err)
+		}
+	}
+	realEstates := []model.RealEstate{realEstate}
+	if err := attachEasements(stub, realEstates); err != nil {
+		return errcode.Response(err)
+	}
+	realEstateByte, err := json.Marshal(realEstates[0])
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Failed to serialize QueryRealEstate: %s", err))
+	}
+	return shim.Success(realEstateByte)
+}
+
+// getRealEstate finds a real estate by ID under the proprietor recorded in the ID index
+func getRealEstate(stub shim.ChaincodeStubInterface, realEstateID string) (model.RealEstate, error) {
+	index, err := getRealEstateIndex(stub, realEstateID)
+	if err != nil {
+		return model.RealEstate{}, err
+	}
+	if index == nil {
+		return model.RealEstate{}, errcode.New(errcode.NotFound, "Real estate %s does not exist", realEstateID)
+	}
+	// Entries written before the index recorded the proprietor are looked up among every proprietor until migrated
+	if index.Proprietor == "" {
+		return findRealEstate(stub, realEstateID)
+	}
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.RealEstateKey, []string{index.Proprietor, realEstateID})
+	if err != nil {
+		return model.RealEstate{}, err
+	}
+	if len(results) != 1 {
+		return model.RealEstate{}, errcode.New(errcode.NotFound, "Real estate %s of %s in the ID index is missing", realEstateID, index.Proprietor)
+	}
+	var realEstate model.RealEstate
+	if err := json.Unmarshal(results[0], &realEstate); err != nil {
+		return model.RealEstate{}, fmt.Errorf("Failed to deserialize the real estate: %s", err)
+	}
+	return realEstate, nil
+}
+
+// findRealEstate finds a real estate by ID among those of every proprietor
+func findRealEstate(stub shim.ChaincodeStubInterface, realEstateID string) (model.RealEstate, error) {
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.RealEstateKey, []string{})
+	if err != nil {
+		return model.RealEstate{}, err
+	}
+	for _, v := range results {
+		var realEstate model.RealEstate
+		if err := json.Unmarshal(v, &realEstate); err != nil {
+			return model.RealEstate{}, fmt.Errorf("Failed to deserialize the real estate: %s", err)
+		}
+		if realEstate.RealEstateID == realEstateID {
+			return realEstate, nil
+		}
+	}
+	return model.RealEstate{}, errcode.New(errcode.NotFound, "Real estate %s does not exist", realEstateID)
+}
+
+// lastTransferTxID returns the transaction that first wrote the key of the real estate under its proprietor,
+// the first write since the key was last deleted when the real estate came back to a former proprietor
+func lastTransferTxID(stub shim.ChaincodeStubInterface, realEstate model.RealEstate) (string, error) {
+	key, err := stub.CreateCompositeKey(model.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID})
+	if err != nil {
+		return "", fmt.Errorf("%s - Failed to create the composite key: %s", model.RealEstateKey, err)
+	}
+	history, err := stub.GetHistoryForKey(key)
+	if err != nil {
+		return "", fmt.Errorf("%s - Failed to read the history: %s", model.RealEstateKey, err)
+	}
+	defer history.Close()
+	txID := ""
+	for history.HasNext() {
+		modification, err := history.Next()
+		if err != nil {
+			return "", fmt.Errorf("%s - Failed to read the history: %s", model.RealEstateKey, err)
+		}
+		if modification.GetIsDelete() {
+			txID = ""
+		} else if txID == "" {
+			txID = modification.GetTxId()
+		}
+	}
+	return txID, nil
+}
+
+// propertyTypeOf returns the property type of a model.PropertyTypeConstant key, or no type when the key is empty
+func propertyTypeOf(key string) (string, error) {
+	if key == "" {
+		return "", nil
+	}
+	propertyType, ok := model.PropertyTypeConstant()[key]
+	if !ok {
+		return "", errcode.New(errcode.InvalidArgument, "Unknown property type %s", key)
+	}
+	return propertyType, nil
+}
+
+// realEstateIDPattern is the format of registrar-assigned IDs such as cadastral or parcel numbers
+var realEstateIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9./-]{0,63}$`)
+
+// generatedIDAttempts bounds the suffixes tried when a generated ID is already taken
+const generatedIDAttempts = 10
+
+// assignRealEstateID returns the ID assigned by the registrar once it is known to be free,
+// or the generated ID, suffixed if it is taken, when the registrar gave none.
+// assigned holds the IDs already assigned in the transaction, which the ledger does not show yet.
+func assignRealEstateID(stub shim.ChaincodeStubInterface, requested string, generated string, assigned map[string]bool) (string, error) {
+	if requested != "" {
+		if !realEstateIDPattern.MatchString(requested) {
+			return "", errcode.New(errcode.InvalidArgument, "Real estate ID %s must be up to 64 letters, digits, '.', '/' or '-'", requested)
+		}
+		taken, err := realEstateIDTaken(stub, requested)
+		if err != nil {
+			return "", err
+		}
+		if taken || assigned[requested] {
+			return "", errcode.New(errcode.Conflict, "Real estate ID %s is already registered", requested)
+		}
+		return requested, nil
+	}
+	id := generated
+	for n := 1; n <= generatedIDAttempts; n++ {
+		taken, err := realEstateIDTaken(stub, id)
+		if err != nil {
+			return "", err
+		}
+		if !taken && !assigned[id] {
+			return id, nil
+		}
+		id = fmt.Sprintf("%s-%d", generated, n)
+	}
+	return "", errcode.New(errcode.Conflict, "Failed to generate a unique real estate ID from %s", generated)
+}
+
+// realEstateIDTaken reports whether an ID is in the uniqueness index
+func realEstateIDTaken(stub shim.ChaincodeStubInterface, realEstateID string) (bool, error) {
+	index, err := getRealEstateIndex(stub, realEstateID)
+	return index != nil, err
+}
+
+// getRealEstateIndex returns the ID index entry of a real estate, or nil when the ID is not in the index
+func getRealEstateIndex(stub shim.ChaincodeStubInterface, realEstateID string) (*model.RealEstateIndex, error) {
+	key, err := stub.CreateCompositeKey(model.RealEstateIDKey, []string{realEstateID})
+	if err != nil {
+		return nil, fmt.Errorf("%s - Failed to create the composite key: %s", model.RealEstateIDKey, err)
+	}
+	value, err := stub.GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("%s - Failed to read the index: %s", model.RealEstateIDKey, err)
+	}
+	if value == nil {
+		return nil, nil
+	}
+	var index model.RealEstateIndex
+	if err := json.Unmarshal(value, &index); err != nil {
+		return nil, fmt.Errorf("RealEstateIndex - Deserialization error: %s", err)
+	}
+	return &index, nil
+}
+
+// indexRealEstateID reserves the ID of a real estate in the uniqueness index and records its proprietor
+func indexRealEstateID(stub shim.ChaincodeStubInterface, realEstate model.RealEstate) error {
+	index := &model.RealEstateIndex{RealEstateID: realEstate.RealEstateID, Proprietor: realEstate.Proprietor, SchemaVersion: model.SchemaVersion}
+	return utils.WriteLedger(index, stub, model.RealEstateIDKey, []string{realEstate.RealEstateID})
+}
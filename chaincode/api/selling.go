@@ -2,10 +2,13 @@ package api
 
 import (
 	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/statemachine"
+	"chaincode/pkg/timeutil"
 	"chaincode/pkg/utils"
 	"encoding/json"
 	"fmt"
-	"strconv"
+	"math"
 	"time"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
@@ -13,66 +16,75 @@ import (
 )
 
 // CreateSelling initiates a sale
-func CreateSelling(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// Validate parameters
-	if len(args) != 4 {
-		return shim.Error("Insufficient number of parameters")
-	}
-	objectOfSale := args[0]
-	seller := args[1]
-	price := args[2]
-	salePeriod := args[3]
-	if objectOfSale == "" || seller == "" || price == "" || salePeriod == "" {
-		return shim.Error("Parameters contain empty values")
-	}
-	// Convert parameter data formats
-	var formattedPrice float64
-	if val, err := strconv.ParseFloat(price, 64); err != nil {
-		return shim.Error(fmt.Sprintf("Failed to convert the price parameter: %s", err))
-	} else {
-		formattedPrice = val
-	}
-	var formattedSalePeriod int
-	if val, err := strconv.Atoi(salePeriod); err != nil {
-		return shim.Error(fmt.Sprintf("Failed to convert the salePeriod parameter: %s", err))
-	} else {
-		formattedSalePeriod = val
+// The sale may be paid with a payment schedule: a deposit, a missed payment policy and installments
+func CreateSelling(stub shim.ChaincodeStubInterface, req *model.CreateSellingRequest) pb.Response {
+	objectOfSale := req.ObjectOfSale
+	seller := req.Seller
+	transition, err := SellingMachine.Next(statemachine.Initial, "create", nil)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	var schedule paymentSchedule
+	if req.Deposit != 0 || req.OnMissedPayment != "" || len(req.Installments) > 0 {
+		val, err := parsePaymentSchedule(req)
+		if err != nil {
+			return errcode.Response(err)
+		}
+		schedule = val
 	}
 	// Check if 'objectOfSale' belongs to 'seller'
 	resultsRealEstate, err := utils.GetStateByPartialCompositeKeys2(stub, model.RealEstateKey, []string{seller, objectOfSale})
 	if err != nil || len(resultsRealEstate) != 1 {
-		return shim.Error(fmt.Sprintf("Validation failed: %s does not belong to %s: %s", objectOfSale, seller, err))
+		return errcode.Responsef(errcode.NotFound, "Validation failed: %s does not belong to %s: %s", objectOfSale, seller, err)
 	}
 	var realEstate model.RealEstate
 	if err = json.Unmarshal(resultsRealEstate[0], &realEstate); err != nil {
 		return shim.Error(fmt.Sprintf("CreateSelling - Deserialization error: %s", err))
 	}
-	// Check if the record already exists; a sale cannot be initiated more than once
-	// If Encumbrance is true, it means the real estate is already in a collateralized state
-	if realEstate.Encumbrance {
-		return shim.Error("This real estate is already in a collateralized state and cannot be initiated for sale again")
+	// An agent may list the real estate on behalf of the seller
+	if err := actOnBehalf(stub, seller, req.Agent, "sell", objectOfSale, "createSelling"); err != nil {
+		return errcode.Response(err)
+	}
+	// A sale cannot be initiated more than once, nor while another encumbrance blocks transfers
+	if err := checkTransferable(stub, realEstate); err != nil {
+		return errcode.Response(err)
+	}
+	// Only a seller with a verified identity can sell
+	sellerAccount, err := getAccount(stub, seller)
+	if err != nil {
+		return errcode.Responsef(errcode.NotFound, "Failed to validate seller information: %s", err)
+	}
+	if err := checkKyc(stub, sellerAccount); err != nil {
+		return errcode.Response(err)
 	}
 	createTime, _ := stub.GetTxTimestamp()
 	selling := &model.Selling{
 		ObjectOfSale:  objectOfSale,
 		Seller:        seller,
 		Buyer:         "",
-		Price:         formattedPrice,
-		CreateTime:    time.Unix(int64(createTime.GetSeconds()), int64(createTime.GetNanos())).Local().Format("2006-01-02 15:04:05"),
-		SalePeriod:    formattedSalePeriod,
-		SellingStatus: model.SellingStatusConstant()["saleStart"],
+		Price:         req.Price,
+		CreateTime:    timeutil.Format(time.Unix(int64(createTime.GetSeconds()), int64(createTime.GetNanos()))),
+		SalePeriod:    req.SalePeriod,
+		SellingStatus: transition.To,
+		// Payment schedule
+		Deposit:         schedule.Deposit,
+		Installments:    schedule.Installments,
+		OnMissedPayment: schedule.OnMissedPayment,
+		GracePeriod:     schedule.GracePeriod,
 	}
 	// Write to the ledger
-	if err := utils.WriteLedger(selling, stub, model.SellingKey, []string{selling.Seller, selling.ObjectOfSale}); err != nil {
-		return shim.Error(fmt.Sprintf("%s", err))
+	if err := putSelling(stub, selling); err != nil {
+		return errcode.Response(err)
+	}
+	// Encumber the real estate with the sale
+	if err := addEncumbrance(stub, &realEstate, "sale", sellingReference(seller, objectOfSale), seller, "", true); err != nil {
+		return errcode.Response(err)
 	}
-	// Set the real estate status to collateralized
-	realEstate.Encumbrance = true
 	if err := utils.WriteLedger(realEstate, stub, model.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
-		return shim.Error(fmt.Sprintf("%s", err))
+		return errcode.Response(err)
 	}
-	// Return information about the successful creation
-	sellingByte, err := json.Marshal(selling)
+	// Return information about the successful creation; the payload is recorded in the block, so it leaves out the price
+	sellingByte, err := json.Marshal(publicSelling(*selling))
 	if err != nil {
 		return shim.Error(fmt.Sprintf("Serialization error for the successful creation: %s", err))
 	}
@@ -81,77 +93,85 @@ func CreateSelling(stub shim.ChaincodeStubInterface, args []string) pb.Response
 }
 
 // CreateSellingByBuy participates in a sale (buyer purchases)
-func CreateSellingByBuy(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// Validate parameters
-	if len(args) != 3 {
-		return shim.Error("Insufficient number of parameters")
-	}
-	objectOfSale := args[0]
-	seller := args[1]
-	buyer := args[2]
-	if objectOfSale == "" || seller == "" || buyer == "" {
-		return shim.Error("Parameters contain empty values")
-	}
+func CreateSellingByBuy(stub shim.ChaincodeStubInterface, req *model.CreateSellingByBuyRequest) pb.Response {
+	objectOfSale := req.ObjectOfSale
+	seller := req.Seller
+	buyer := req.Buyer
 	if seller == buyer {
-		return shim.Error("The buyer and seller cannot be the same person")
+		return errcode.Responsef(errcode.InvalidArgument, "The buyer and seller cannot be the same person")
 	}
 	// Obtain real estate information to be purchased based on 'objectOfSale' and 'seller' and ensure it exists
 	resultsRealEstate, err := utils.GetStateByPartialCompositeKeys2(stub, model.RealEstateKey, []string{seller, objectOfSale})
 	if err != nil || len(resultsRealEstate) != 1 {
-		return shim.Error(fmt.Sprintf("Failed to retrieve real estate information based on %s and %s: %s", objectOfSale, seller, err))
+		return errcode.Responsef(errcode.NotFound, "Failed to retrieve real estate information based on %s and %s: %s", objectOfSale, seller, err)
 	}
 	// Obtain sale information based on 'objectOfSale' and 'seller'
-	resultsSelling, err := utils.GetStateByPartialCompositeKeys2(stub, model.SellingKey, []string{seller, objectOfSale})
-	if err != nil || len(resultsSelling) != 1 {
-		return shim.Error(fmt.Sprintf("Failed to retrieve sale information based on %s and %s: %s", objectOfSale, seller, err))
+	selling, err := getSelling(stub, seller, objectOfSale)
+	if err != nil {
+		return errcode.Response(err)
 	}
-	var selling model.Selling
-	if err = json.Unmarshal(resultsSelling[0], &selling); err != nil {
-		return shim.Error(fmt.Sprintf("CreateSellingBuy - Deserialization error: %s", err))
+	// An agent may buy on behalf of the buyer
+	if err := actOnBehalf(stub, buyer, req.Agent, "buy", objectOfSale, "createSellingByBuy"); err != nil {
+		return errcode.Response(err)
 	}
-	// Check if the selling status is 'saleStart'
-	if selling.SellingStatus != model.SellingStatusConstant()["saleStart"] {
-		return shim.Error("This transaction is not in the 'saleStart' status and cannot be purchased")
+	// Buying moves the sale to delivery, or to payment when installments are due
+	transition, err := SellingMachine.Next(selling.SellingStatus, "buy", &sale{stub: stub, selling: selling})
+	if err != nil {
+		return errcode.Response(err)
 	}
 	// Obtain buyer information based on 'buyer'
-	resultsAccount, err := utils.GetStateByPartialCompositeKeys(stub, model.AccountKey, []string{buyer})
-	if err != nil || len(resultsAccount) != 1 {
-		return shim.Error(fmt.Sprintf("Failed to validate buyer information: %s", err))
+	buyerAccount, err := getAccount(stub, buyer)
+	if err != nil {
+		return errcode.Responsef(errcode.NotFound, "Failed to validate buyer information: %s", err)
+	}
+	if buyerAccount.Role == model.AccountRoleConstant()["admin"] {
+		return errcode.Responsef(errcode.Forbidden, "The admin cannot make purchases")
 	}
-	var buyerAccount model.Account
-	if err = json.Unmarshal(resultsAccount[0], &buyerAccount); err != nil {
-		return shim.Error(fmt.Sprintf("Failed to query buyer information - Deserialization error: %s", err))
+	if err := checkKyc(stub, buyerAccount); err != nil {
+		return errcode.Response(err)
 	}
-	if buyerAccount.UserName == "admin" {
-		return shim.Error(fmt.Sprintf("The admin cannot make purchases: %s", err))
+	createTime, _ := stub.GetTxTimestamp()
+	// With a payment schedule the buyer commits with the deposit, otherwise with the full price
+	payment := selling.Price
+	if len(selling.Installments) > 0 {
+		payment = selling.Deposit
 	}
 	// Check if the balance is sufficient
-	if buyerAccount.Balance < selling.Price {
-		return shim.Error(fmt.Sprintf("The selling price is %f, and your current balance is %f. The purchase has failed.", selling.Price, buyerAccount.Balance))
+	if buyerAccount.Balance < payment {
+		return errcode.Responsef(errcode.InsufficientFunds, "The amount due is %f, and your current balance is %f. The purchase has failed.", payment, buyerAccount.Balance)
 	}
-	// Write the buyer information into the selling transaction and change the status to 'delivery'
+	// Write the buyer information into the selling transaction and change its status
 	selling.Buyer = buyer
-	selling.SellingStatus = model.SellingStatusConstant()["delivery"]
-	if err := utils.WriteLedger(selling, stub, model.SellingKey, []string{selling.Seller, selling.ObjectOfSale}); err != nil {
+	selling.AmountPaid = payment
+	// Fix the installment deadlines relative to the deposit
+	depositTime := time.Unix(int64(createTime.GetSeconds()), int64(createTime.GetNanos()))
+	for i := range selling.Installments {
+		selling.Installments[i].DueTime = timeutil.Format(depositTime.AddDate(0, 0, selling.Installments[i].DueDays))
+	}
+	selling.SellingStatus = transition.To
+	if err := putSelling(stub, &selling); err != nil {
 		return shim.Error(fmt.Sprintf("Failed to write buyer information into the selling transaction and change the status - %s", err))
 	}
-	createTime, _ := stub.GetTxTimestamp()
-	// Write this purchase transaction to the ledger for buyer's reference
-	sellingBuy := &model.SellingBuy{
-		Buyer:      buyer,
-		CreateTime: time.Unix(int64(createTime.GetSeconds()), int64(createTime.GetNanos())).Local().Format("2006-01-02 15:04:05"),
-		Selling:    selling,
+	// Index this purchase for the buyer's reference
+	index := &model.SellingBuyerIndex{
+		Buyer:         buyer,
+		Seller:        seller,
+		ObjectOfSale:  objectOfSale,
+		ListingTime:   selling.CreateTime,
+		CreateTime:    timeutil.Format(time.Unix(int64(createTime.GetSeconds()), int64(createTime.GetNanos()))),
+		SchemaVersion: model.SchemaVersion,
 	}
-	if err := utils.WriteLedger(sellingBuy, stub, model.SellingBuyKey, []string{sellingBuy.Buyer, sellingBuy.CreateTime}); err != nil {
-		return shim.Error(fmt.Sprintf("Failed to write this purchase transaction to the ledger - %s", err))
+	if err := utils.WriteLedger(index, stub, model.SellingBuyerIndexKey, []string{index.Buyer, index.Seller, index.ObjectOfSale}); err != nil {
+		return shim.Error(fmt.Sprintf("Failed to index this purchase transaction - %s", err))
 	}
-	sellingBuyByte, err := json.Marshal(sellingBuy)
+	sellingBuy := sellingBuyOf(selling, *index)
+	sellingBuyByte, err := json.Marshal(publicSellingBuy(sellingBuy))
 	if err != nil {
 		return shim.Error(fmt.Sprintf("Serialization error for the successful creation: %s", err))
 	}
 	// Purchase successful; deduct the balance. Note that the payment will be transferred to the seller's account after the seller confirms receipt. The balance is deducted from the buyer's account at this stage.
-	buyerAccount.Balance -= selling.Price
-	if err := utils.WriteLedger(buyerAccount, stub, model.AccountKey, []string{buyerAccount.AccountId}); err != nil {
+	buyerAccount.Balance -= payment
+	if err := PutAccount(stub, &buyerAccount); err != nil {
 		return shim.Error(fmt.Sprintf("Failed to deduct the buyer's balance - %s", err))
 	}
 	// Success response
@@ -159,11 +179,11 @@ func CreateSellingByBuy(stub shim.ChaincodeStubInterface, args []string) pb.Resp
 }
 
 // QuerySellingList retrieves sales (can be queried by all or by the initiating seller) - for sellers to query initiated sales
-func QuerySellingList(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+func QuerySellingList(stub shim.ChaincodeStubInterface, req *model.QuerySellingListRequest) pb.Response {
 	var sellingList []model.Selling
-	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.SellingKey, args)
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.SellingKey, utils.KeyPrefix(req.Seller, req.ObjectOfSale))
 	if err != nil {
-		return shim.Error(fmt.Sprintf("%s", err))
+		return errcode.Response(err)
 	}
 	for _, v := range results {
 		if v != nil {
@@ -183,201 +203,496 @@ func QuerySellingList(stub shim.ChaincodeStubInterface, args []string) pb.Respon
 }
 
 // QuerySellingListByBuyer retrieves sales based on the buyer's Account ID - for buyers to query their participated sales
-func QuerySellingListByBuyer(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 1 {
-		return shim.Error(fmt.Sprintf("Buyer Account ID must be specified for the query"))
+func QuerySellingListByBuyer(stub shim.ChaincodeStubInterface, req *model.QuerySellingListByBuyerRequest) pb.Response {
+	sellingBuyList, err := getSellingBuyList(stub, req.Buyer, false)
+	if err != nil {
+		return errcode.Response(err)
 	}
-	var sellingBuyList []model.SellingBuy
-	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.SellingBuyKey, args)
+	sellingBuyListByte, err := json.Marshal(sellingBuyList)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("QuerySellingListByBuyer - Serialization error: %s", err))
+	}
+	return shim.Success(sellingBuyListByte)
+}
+
+// QuerySellingPrivateList retrieves sales including their prices and payment details (collection members only)
+func QuerySellingPrivateList(stub shim.ChaincodeStubInterface, req *model.QuerySellingListRequest) pb.Response {
+	if err := checkCollectionAccess(stub, model.SellingCollection); err != nil {
+		return errcode.Response(err)
+	}
+	var sellingList []model.Selling
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.SellingKey, utils.KeyPrefix(req.Seller, req.ObjectOfSale))
 	if err != nil {
-		return shim.Error(fmt.Sprintf("%s", err))
+		return errcode.Response(err)
 	}
 	for _, v := range results {
 		if v != nil {
-			var sellingBuy model.SellingBuy
-			err := json.Unmarshal(v, &sellingBuy)
+			var selling model.Selling
+			err := json.Unmarshal(v, &selling)
 			if err != nil {
-				return shim.Error(fmt.Sprintf("QuerySellingListByBuyer - Deserialization error: %s", err))
+				return shim.Error(fmt.Sprintf("QuerySellingPrivateList - Deserialization error: %s", err))
+			}
+			if err := readSellingPrivate(stub, &selling, model.SellingKey, []string{selling.Seller, selling.ObjectOfSale}); err != nil {
+				return errcode.Response(err)
 			}
-			sellingBuyList = append(sellingBuyList, sellingBuy)
+			sellingList = append(sellingList, selling)
 		}
 	}
+	sellingListByte, err := json.Marshal(sellingList)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("QuerySellingPrivateList - Serialization error: %s", err))
+	}
+	return shim.Success(sellingListByte)
+}
+
+// QuerySellingPrivateListByBuyer retrieves the buyer's sales including their prices and payment details (collection members only)
+func QuerySellingPrivateListByBuyer(stub shim.ChaincodeStubInterface, req *model.QuerySellingListByBuyerRequest) pb.Response {
+	if err := checkCollectionAccess(stub, model.SellingCollection); err != nil {
+		return errcode.Response(err)
+	}
+	sellingBuyList, err := getSellingBuyList(stub, req.Buyer, true)
+	if err != nil {
+		return errcode.Response(err)
+	}
 	sellingBuyListByte, err := json.Marshal(sellingBuyList)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("QuerySellingListByBuyer - Serialization error: %s", err))
+		return shim.Error(fmt.Sprintf("QuerySellingPrivateListByBuyer - Serialization error: %s", err))
 	}
 	return shim.Success(sellingBuyListByte)
 }
 
 // UpdateSelling updates the selling status (buyer confirmation, seller or buyer cancellation)
-func UpdateSelling(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// Validate parameters
-	if len(args) != 4 {
-		return shim.Error("Insufficient number of parameters")
-	}
-	objectOfSale := args[0]
-	seller := args[1]
-	buyer := args[2]
-	status := args[3]
-	if objectOfSale == "" || seller == "" || status == "" {
-		return shim.Error("Parameters contain empty values")
-	}
+func UpdateSelling(stub shim.ChaincodeStubInterface, req *model.UpdateSellingRequest) pb.Response {
+	objectOfSale := req.ObjectOfSale
+	seller := req.Seller
+	buyer := req.Buyer
+	status := req.Status
 	if buyer == seller {
-		return shim.Error("The buyer and seller cannot be the same person")
+		return errcode.Responsef(errcode.InvalidArgument, "The buyer and seller cannot be the same person")
 	}
 	// Obtain real estate information based on 'objectOfSale' and 'seller' and confirm its existence
 	resultsRealEstate, err := utils.GetStateByPartialCompositeKeys2(stub, model.RealEstateKey, []string{seller, objectOfSale})
 	if err != nil || len(resultsRealEstate) != 1 {
-		return shim.Error(fmt.Sprintf("Failed to retrieve real estate information based on %s and %s: %s", objectOfSale, seller, err))
+		return errcode.Responsef(errcode.NotFound, "Failed to retrieve real estate information based on %s and %s: %s", objectOfSale, seller, err)
 	}
 	var realEstate model.RealEstate
 	if err = json.Unmarshal(resultsRealEstate[0], &realEstate); err != nil {
 		return shim.Error(fmt.Sprintf("UpdateSelling - Deserialization error: %s", err))
 	}
 	// Obtain selling information based on 'objectOfSale' and 'seller'
-	resultsSelling, err := utils.GetStateByPartialCompositeKeys2(stub, model.SellingKey, []string{seller, objectOfSale})
-	if err != nil || len(resultsSelling) != 1 {
-		return shim.Error(fmt.Sprintf("Failed to retrieve selling information based on %s and %s: %s", objectOfSale, seller, err))
-	}
-	var selling model.Selling
-	if err = json.Unmarshal(resultsSelling[0], &selling); err != nil {
-		return shim.Error(fmt.Sprintf("UpdateSelling - Deserialization error: %s", err))
+	selling, err := getSelling(stub, seller, objectOfSale)
+	if err != nil {
+		return errcode.Response(err)
 	}
 	// Obtain the buying information ('sellingBuy') based on 'buyer'
 	var sellingBuy model.SellingBuy
 	// If the current status is 'saleStart', there is no buyer
 	if selling.SellingStatus != model.SellingStatusConstant()["saleStart"] {
-		resultsSellingByBuyer, err := utils.GetStateByPartialCompositeKeys2(stub, model.SellingBuyKey, []string{buyer})
-		if err != nil || len(resultsSellingByBuyer) == 0 {
-			return shim.Error(fmt.Sprintf("Failed to retrieve buyer's buying information based on %s: %s", buyer, err))
+		if selling.Buyer != buyer {
+			return errcode.Responsef(errcode.NotFound, "Failed to retrieve buyer's buying information based on %s", buyer)
 		}
-		for _, v := range resultsSellingByBuyer {
-			if v != nil {
-				var s model.SellingBuy
-				err := json.Unmarshal(v, &s)
-				if err != nil {
-					return shim.Error(fmt.Sprintf("UpdateSelling - Deserialization error: %s", err))
-				}
-				if s.Selling.ObjectOfSale == objectOfSale && s.Selling.Seller == seller && s.Buyer == buyer {
-					// Ensure that the status is 'delivery' to prevent the case where the property has already been sold but was canceled
-					if s.Selling.SellingStatus == model.SellingStatusConstant()["delivery"] {
-						sellingBuy = s
-						break
-					}
-				}
-			}
+		if sellingBuy, err = getSellingBuy(stub, selling); err != nil {
+			return errcode.Response(err)
 		}
 	}
-	var data []byte
-	// Determine the selling status
-	switch status {
-	case "done":
-		// If it is a buyer's confirmation operation, ensure that the transaction is in the 'delivery' status
-		if selling.SellingStatus != model.SellingStatusConstant()["delivery"] {
-			return shim.Error("This transaction is not in 'delivery' status; confirmation of receipt failed")
-		}
-		// Obtain seller information based on 'seller'
-		resultsSellerAccount, err := utils.GetStateByPartialCompositeKeys(stub, model.AccountKey, []string{seller})
-		if err != nil || len(resultsSellerAccount) != 1 {
-			return shim.Error(fmt.Sprintf("Failed to verify seller information: %s", err))
-		}
-		var accountSeller model.Account
-		if err = json.Unmarshal(resultsSellerAccount[0], &accountSeller); err != nil {
-			return shim.Error(fmt.Sprintf("Failed to retrieve seller information - Deserialization error: %s", err))
-		}
-		// Confirm receipt, transfer the payment to the seller's account
-		accountSeller.Balance += selling.Price
-		if err := utils.WriteLedger(accountSeller, stub, model.AccountKey, []string{accountSeller.AccountId}); err != nil {
-			return shim.Error(fmt.Sprintf("Seller failed to confirm receipt of funds: %s", err))
-		}
-		// Transfer the property information to the buyer and reset the encumbrance status
-		realEstate.Proprietor = buyer
-		realEstate.Encumbrance = false
-		//realEstate.RealEstateID = stub.GetTxID() // Update the real estate ID
-		if err := utils.WriteLedger(realEstate, stub, model.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
-			return shim.Error(fmt.Sprintf("%s", err))
-		}
-		// Clear the original property information
-		if err := utils.DelLedger(stub, model.RealEstateKey, []string{seller, objectOfSale}); err != nil {
-			return shim.Error(fmt.Sprintf("%s", err))
-		}
-		// Set the order status to 'done' and write to the ledger
-		selling.SellingStatus = model.SellingStatusConstant()["done"]
-		selling.ObjectOfSale = realEstate.RealEstateID // Update the real estate ID
-		if err := utils.WriteLedger(selling, stub, model.SellingKey, []string{selling.Seller, objectOfSale}); err != nil {
-			return shim.Error(fmt.Sprintf("%s", err))
-		}
-		sellingBuy.Selling = selling
-		if err := utils.WriteLedger(sellingBuy, stub, model.SellingBuyKey, []string{sellingBuy.Buyer, sellingBuy.CreateTime}); err != nil {
-			return shim.Error(fmt.Sprintf("Failed to write this purchase transaction to the ledger: %s", err))
-		}
-		data, err = json.Marshal(sellingBuy)
-		if err != nil {
-			return shim.Error(fmt.Sprintf("Serialization error for the purchase transaction: %s", err))
-		}
-		break
-	case "cancelled":
-		data, err = closeSelling("cancelled", selling, realEstate, sellingBuy, buyer, stub)
-		if err != nil {
-			return shim.Error(fmt.Sprintf("%s", err))
-		}
-		break
-	case "expired":
-		data, err = closeSelling("expired", selling, realEstate, sellingBuy, buyer, stub)
-		if err != nil {
-			return shim.Error(fmt.Sprintf("%s", err))
-		}
-		break
-	default:
-		return shim.Error(fmt.Sprintf("Status %s is not supported", status))
+	// Map the requested status to the event of the selling state machine
+	event, ok := map[string]string{"done": "confirm", "cancelled": "cancel", "expired": "expire"}[status]
+	if !ok {
+		return errcode.Responsef(errcode.InvalidArgument, "Status %s is not supported", status)
+	}
+	// An agent acts for the party cancelling, otherwise for the seller
+	principal, scope := seller, "sell"
+	if status == "cancelled" && req.Operator == buyer {
+		principal, scope = buyer, "buy"
+	} else if status == "cancelled" {
+		principal = req.Operator
+	}
+	if err := actOnBehalf(stub, principal, req.Agent, scope, objectOfSale, "updateSelling"); err != nil {
+		return errcode.Response(err)
+	}
+	_, data, err := SellingMachine.Fire(selling.SellingStatus, event, &sale{
+		stub:       stub,
+		selling:    selling,
+		realEstate: realEstate,
+		sellingBuy: sellingBuy,
+		operator:   req.Operator,
+		reason:     req.Reason,
+	})
+	if err != nil {
+		return errcode.Response(err)
 	}
 	return shim.Success(data)
 }
 
+// completeSelling transfers sellerAmount of the escrowed price to the seller, hands the real estate over to the buyer
+// and marks the sale as done. Any remainder of the price must already have been settled by the caller.
+func completeSelling(selling model.Selling, realEstate model.RealEstate, sellingBuy model.SellingBuy, sellerAmount float64, stub shim.ChaincodeStubInterface) ([]byte, error) {
+	seller := selling.Seller
+	objectOfSale := selling.ObjectOfSale
+	// Obtain seller information based on 'seller'
+	accountSeller, err := getAccount(stub, seller)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to verify seller information: %s", err)
+	}
+	// A court lock placed while the sale was in progress holds the title
+	removeEncumbrance(&realEstate, "sale", sellingReference(seller, objectOfSale))
+	if err := checkTransferable(stub, realEstate); err != nil {
+		return nil, err
+	}
+	// Confirm receipt, transfer the payment to the seller's account
+	accountSeller.Balance += sellerAmount
+	if err := PutAccount(stub, &accountSeller); err != nil {
+		return nil, fmt.Errorf("Seller failed to confirm receipt of funds: %s", err)
+	}
+	// Transfer the property information to the buyer and clear the original
+	if err := transferRealEstate(stub, &realEstate, selling.Buyer); err != nil {
+		return nil, err
+	}
+	// Set the order status to 'done' and write to the ledger
+	now, err := utils.GetTxTime(stub)
+	if err != nil {
+		return nil, err
+	}
+	selling.SellingStatus = model.SellingStatusConstant()["done"]
+	selling.CompleteTime = timeutil.Format(now)
+	selling.ObjectOfSale = realEstate.RealEstateID // Update the real estate ID
+	if err := putSelling(stub, &selling); err != nil {
+		return nil, err
+	}
+	sellingBuy.Selling = selling
+	data, err := json.Marshal(publicSellingBuy(sellingBuy))
+	if err != nil {
+		return nil, fmt.Errorf("Serialization error for the purchase transaction: %s", err)
+	}
+	return data, nil
+}
+
 // closeSelling handles both cancellation and expiration cases in two scenarios:
 // 1. The transaction is in 'saleStart' status
-// 2. The transaction is in 'delivery' status
-func closeSelling(closeStart string, selling model.Selling, realEstate model.RealEstate, sellingBuy model.SellingBuy, buyer string, stub shim.ChaincodeStubInterface) ([]byte, error) {
-	switch selling.SellingStatus {
-	case model.SellingStatusConstant()["saleStart"]:
+// 2. The transaction has a buyer: the buyer is refunded the funds held in escrow, less or plus the cancellation penalty
+// SellingMachine decides which statuses a sale can be closed from.
+func closeSelling(closeStart string, selling model.Selling, realEstate model.RealEstate, penalty cancellationPenalty, stub shim.ChaincodeStubInterface) ([]byte, error) {
+	if selling.SellingStatus == model.SellingStatusConstant()["saleStart"] {
 		selling.SellingStatus = model.SellingStatusConstant()[closeStart]
-		// Reset the encumbrance status of the property information
-		realEstate.Encumbrance = false
+		// Remove the encumbrance of the sale
+		removeEncumbrance(&realEstate, "sale", sellingReference(selling.Seller, selling.ObjectOfSale))
 		if err := utils.WriteLedger(realEstate, stub, model.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
 			return nil, err
 		}
-		if err := utils.WriteLedger(selling, stub, model.SellingKey, []string{selling.Seller, selling.ObjectOfSale}); err != nil {
+		if err := putSelling(stub, &selling); err != nil {
 			return nil, err
 		}
-		data, err := json.Marshal(selling)
+		data, err := json.Marshal(publicSelling(selling))
 		if err != nil {
 			return nil, err
 		}
 		return data, nil
-	case model.SellingStatusConstant()["delivery"]:
-		selling.SellingStatus = model.SellingStatusConstant()[closeStart]
-		// Return the balance to the buyer's account
-		resultsAccount, err := utils.GetStateByPartialCompositeKeys(stub, model.AccountKey, []string{buyer})
-		if err != nil || len(resultsAccount) != 1 {
-			return nil, fmt.Errorf("Failed to verify buyer's information: %s", err)
+	}
+	// Settle the escrow: the buyer's penalty is kept from the refund, the seller's is paid on top of it
+	refund := sellingEscrow(selling)
+	var sellerAmount float64
+	switch penalty.PaidBy {
+	case "buyer":
+		selling.Penalty = math.Min(penalty.Amount, refund)
+		refund -= selling.Penalty
+		sellerAmount = selling.Penalty
+	case "seller":
+		selling.Penalty = penalty.Amount
+		refund += selling.Penalty
+		sellerAmount = -selling.Penalty
+	}
+	if selling.Penalty > 0 {
+		selling.PenaltyPaidBy = penalty.PaidBy
+	}
+	selling.SellingStatus = model.SellingStatusConstant()[closeStart]
+	// Remove the encumbrance of the sale
+	removeEncumbrance(&realEstate, "sale", sellingReference(selling.Seller, selling.ObjectOfSale))
+	if err := utils.WriteLedger(realEstate, stub, model.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
+		return nil, err
+	}
+	if sellerAmount != 0 {
+		if err := adjustBalance(stub, selling.Seller, sellerAmount); err != nil {
+			return nil, fmt.Errorf("Failed to settle the cancellation penalty with the seller: %w", err)
 		}
-		var buyerAccount model.Account
-		if err = json.Unmarshal(resultsAccount[0], &buyerAccount); err != nil {
-			return nil, fmt.Errorf("Failed to retrieve buyer information - Deserialization error: %s", err)
+	}
+	// Return the balance to the buyer's account
+	if err := adjustBalance(stub, selling.Buyer, refund); err != nil {
+		return nil, fmt.Errorf("Failed to refund the buyer's account: %w", err)
+	}
+	if err := putSelling(stub, &selling); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(publicSelling(selling))
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// getSelling loads a sale by seller and objectOfSale, including its private price and payment details
+func getSelling(stub shim.ChaincodeStubInterface, seller string, objectOfSale string) (model.Selling, error) {
+	var selling model.Selling
+	resultsSelling, err := utils.GetStateByPartialCompositeKeys2(stub, model.SellingKey, []string{seller, objectOfSale})
+	if err != nil || len(resultsSelling) != 1 {
+		return selling, errcode.New(errcode.NotFound, "Failed to retrieve selling information based on %s and %s: %v", objectOfSale, seller, err)
+	}
+	if err = json.Unmarshal(resultsSelling[0], &selling); err != nil {
+		return selling, fmt.Errorf("Selling - Deserialization error: %s", err)
+	}
+	if err := readSellingPrivate(stub, &selling, model.SellingKey, []string{selling.Seller, selling.ObjectOfSale}); err != nil {
+		return selling, err
+	}
+	return selling, nil
+}
+
+// putSelling writes a sale, keeping its price and payment details in the private data collection
+func putSelling(stub shim.ChaincodeStubInterface, selling *model.Selling) error {
+	selling.SchemaVersion = model.SchemaVersion
+	if err := putSellingStatusIndex(stub, *selling); err != nil {
+		return err
+	}
+	public := *selling
+	if err := stripSellingPrivate(stub, &public, model.SellingKey, []string{selling.Seller, selling.ObjectOfSale}); err != nil {
+		return err
+	}
+	if err := utils.WriteLedger(public, stub, model.SellingKey, []string{selling.Seller, selling.ObjectOfSale}); err != nil {
+		return err
+	}
+	selling.PrivateHash = public.PrivateHash
+	return nil
+}
+
+// putSellingStatusIndex moves a sale to the status index entry of its new status.
+// The status it leaves is read from the record last committed, as a transaction does not read its own writes.
+func putSellingStatusIndex(stub shim.ChaincodeStubInterface, selling model.Selling) error {
+	key, err := stub.CreateCompositeKey(model.SellingKey, []string{selling.Seller, selling.ObjectOfSale})
+	if err != nil {
+		return fmt.Errorf("%s - Failed to create the composite key: %s", model.SellingKey, err)
+	}
+	data, err := stub.GetState(key)
+	if err != nil {
+		return fmt.Errorf("%s - Error reading from the blockchain ledger: %s", model.SellingKey, err)
+	}
+	if data != nil {
+		upgraded, _, err := model.UpgradeRecord(model.SellingKey, data)
+		if err != nil {
+			return err
 		}
-		buyerAccount.Balance += selling.Price
-		if err := utils.WriteLedger(buyerAccount, stub, model.AccountKey, []string{buyerAccount.AccountId}); err != nil {
-			return nil, fmt.Errorf("Failed to refund the buyer's account: %s", err)
+		var stored model.Selling
+		if err := json.Unmarshal(upgraded, &stored); err != nil {
+			return fmt.Errorf("Selling - Deserialization error: %s", err)
 		}
-		if err := utils.WriteLedger(selling, stub, model.SellingKey, []string{selling.Seller, selling.ObjectOfSale}); err != nil {
-			return nil, err
+		if stored.SellingStatus != selling.SellingStatus {
+			if err := utils.DelLedger(stub, model.SellingStatusIndexKey, []string{stored.SellingStatus, stored.Seller, stored.ObjectOfSale}); err != nil {
+				return err
+			}
 		}
-		data, err := json.Marshal(selling)
+	}
+	index := sellingStatusIndexOf(selling)
+	return utils.WriteLedger(index, stub, model.SellingStatusIndexKey, []string{index.SellingStatus, index.Seller, index.ObjectOfSale})
+}
+
+// sellingStatusIndexOf returns the status index entry of a sale
+func sellingStatusIndexOf(selling model.Selling) model.SellingStatusIndex {
+	return model.SellingStatusIndex{
+		SellingStatus: selling.SellingStatus,
+		Seller:        selling.Seller,
+		ObjectOfSale:  selling.ObjectOfSale,
+		SchemaVersion: model.SchemaVersion,
+	}
+}
+
+// sellingBuyOf returns the buyer's view of a sale it bought
+func sellingBuyOf(selling model.Selling, index model.SellingBuyerIndex) model.SellingBuy {
+	return model.SellingBuy{
+		Buyer:         index.Buyer,
+		CreateTime:    index.CreateTime,
+		Selling:       selling,
+		SchemaVersion: model.SchemaVersion,
+	}
+}
+
+// getSellingBuy returns the buyer's view of a sale that has a buyer
+func getSellingBuy(stub shim.ChaincodeStubInterface, selling model.Selling) (model.SellingBuy, error) {
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.SellingBuyerIndexKey, []string{selling.Buyer, selling.Seller, selling.ObjectOfSale})
+	if err != nil {
+		return model.SellingBuy{}, err
+	}
+	indexes, err := unmarshalSellingBuyerIndexes(results)
+	if err != nil {
+		return model.SellingBuy{}, err
+	}
+	if len(indexes) == 0 {
+		// The purchase may still be recorded by a copy that has not been migrated
+		if indexes, err = getLegacySellingBuyerIndexes(stub, selling.Buyer); err != nil {
+			return model.SellingBuy{}, err
+		}
+	}
+	for _, index := range indexes {
+		if index.Seller == selling.Seller && index.ObjectOfSale == selling.ObjectOfSale && index.ListingTime == selling.CreateTime {
+			return sellingBuyOf(selling, index), nil
+		}
+	}
+	return model.SellingBuy{}, errcode.New(errcode.NotFound, "Failed to retrieve buyer's buying information based on %s", selling.Buyer)
+}
+
+// getSellingBuyList returns the sales a buyer bought that are still on record, including their private details when private is set.
+// Relisting a real estate replaces its sale, so the index entries of earlier purchases no longer match and are left out.
+func getSellingBuyList(stub shim.ChaincodeStubInterface, buyer string, private bool) ([]model.SellingBuy, error) {
+	indexes, err := getSellingBuyerIndexes(stub, buyer)
+	if err != nil {
+		return nil, err
+	}
+	var sellingBuyList []model.SellingBuy
+	for _, index := range indexes {
+		results, err := utils.GetStateByPartialCompositeKeys2(stub, model.SellingKey, []string{index.Seller, index.ObjectOfSale})
 		if err != nil {
 			return nil, err
 		}
-		return data, nil
-	default:
-		return nil, fmt.Errorf("The transaction cannot be closed because it is not in 'saleStart' or 'delivery' status")
+		if len(results) != 1 {
+			continue
+		}
+		var selling model.Selling
+		if err := json.Unmarshal(results[0], &selling); err != nil {
+			return nil, fmt.Errorf("Selling - Deserialization error: %s", err)
+		}
+		if selling.Buyer != index.Buyer || selling.CreateTime != index.ListingTime {
+			continue
+		}
+		if private {
+			if err := readSellingPrivate(stub, &selling, model.SellingKey, []string{selling.Seller, selling.ObjectOfSale}); err != nil {
+				return nil, err
+			}
+		}
+		sellingBuyList = append(sellingBuyList, sellingBuyOf(selling, index))
+	}
+	return sellingBuyList, nil
+}
+
+// getSellingBuyerIndexes returns the purchases indexed for a buyer, or for all buyers when it is empty.
+// Copies written before the index count as index entries until they are migrated.
+func getSellingBuyerIndexes(stub shim.ChaincodeStubInterface, buyer string) ([]model.SellingBuyerIndex, error) {
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.SellingBuyerIndexKey, utils.KeyPrefix(buyer))
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := unmarshalSellingBuyerIndexes(results)
+	if err != nil {
+		return nil, err
+	}
+	legacy, err := getLegacySellingBuyerIndexes(stub, buyer)
+	if err != nil {
+		return nil, err
+	}
+	indexed := map[[3]string]bool{}
+	for _, index := range indexes {
+		indexed[[3]string{index.Buyer, index.Seller, index.ObjectOfSale}] = true
+	}
+	for _, index := range legacy {
+		if !indexed[[3]string{index.Buyer, index.Seller, index.ObjectOfSale}] {
+			indexes = append(indexes, index)
+		}
+	}
+	return indexes, nil
+}
+
+// getLegacySellingBuyerIndexes reads the buyer's copies of sales written before the index as index entries
+func getLegacySellingBuyerIndexes(stub shim.ChaincodeStubInterface, buyer string) ([]model.SellingBuyerIndex, error) {
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.SellingBuyKey, utils.KeyPrefix(buyer))
+	if err != nil {
+		return nil, err
 	}
+	var indexes []model.SellingBuyerIndex
+	for _, v := range results {
+		var sellingBuy model.SellingBuy
+		if err := json.Unmarshal(v, &sellingBuy); err != nil {
+			return nil, fmt.Errorf("SellingBuy - Deserialization error: %s", err)
+		}
+		indexes = append(indexes, sellingBuyerIndexOf(sellingBuy))
+	}
+	return indexes, nil
+}
+
+// sellingBuyerIndexOf returns the index entry of a buyer's copy of a sale
+func sellingBuyerIndexOf(sellingBuy model.SellingBuy) model.SellingBuyerIndex {
+	return model.SellingBuyerIndex{
+		Buyer:         sellingBuy.Buyer,
+		Seller:        sellingBuy.Selling.Seller,
+		ObjectOfSale:  sellingBuy.Selling.ObjectOfSale,
+		ListingTime:   sellingBuy.Selling.CreateTime,
+		CreateTime:    sellingBuy.CreateTime,
+		SchemaVersion: model.SchemaVersion,
+	}
+}
+
+// unmarshalSellingBuyerIndexes decodes purchase index entries
+func unmarshalSellingBuyerIndexes(results [][]byte) ([]model.SellingBuyerIndex, error) {
+	var indexes []model.SellingBuyerIndex
+	for _, v := range results {
+		var index model.SellingBuyerIndex
+		if err := json.Unmarshal(v, &index); err != nil {
+			return nil, fmt.Errorf("SellingBuyerIndex - Deserialization error: %s", err)
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+// stripSellingPrivate moves the private fields of a sale into the private data collection and anchors their hash
+func stripSellingPrivate(stub shim.ChaincodeStubInterface, selling *model.Selling, objectType string, keys []string) error {
+	salt, err := privateSalt(stub)
+	if err != nil {
+		return err
+	}
+	sellingPrivate := model.SellingPrivate{
+		Price:         selling.Price,
+		Deposit:       selling.Deposit,
+		Installments:  selling.Installments,
+		AmountPaid:    selling.AmountPaid,
+		Penalty:       selling.Penalty,
+		Salt:          salt,
+		SchemaVersion: model.SchemaVersion,
+	}
+	hash, err := utils.WritePrivateLedger(sellingPrivate, stub, model.SellingCollection, objectType, keys)
+	if err != nil {
+		return err
+	}
+	*selling = publicSelling(*selling)
+	selling.PrivateHash = hash
+	return nil
+}
+
+// publicSelling returns a sale without its private price and payment details.
+// Submitted transactions return it, as their payload is recorded in the block for every organization to read.
+func publicSelling(selling model.Selling) model.Selling {
+	selling.Price = 0
+	selling.Deposit = 0
+	selling.Installments = nil
+	selling.AmountPaid = 0
+	selling.Penalty = 0
+	return selling
+}
+
+// publicSellingBuy returns the buyer's view of a sale without its private price and payment details
+func publicSellingBuy(sellingBuy model.SellingBuy) model.SellingBuy {
+	sellingBuy.Selling = publicSelling(sellingBuy.Selling)
+	return sellingBuy
+}
+
+// readSellingPrivate fills in the private fields of a sale read from the public ledger
+func readSellingPrivate(stub shim.ChaincodeStubInterface, selling *model.Selling, objectType string, keys []string) error {
+	// Sales written before the private data collections keep everything on the public ledger
+	if selling.PrivateHash == "" {
+		return nil
+	}
+	var sellingPrivate model.SellingPrivate
+	if err := utils.ReadPrivateLedger(&sellingPrivate, stub, model.SellingCollection, objectType, keys, selling.PrivateHash); err != nil {
+		return err
+	}
+	selling.Price = sellingPrivate.Price
+	selling.Deposit = sellingPrivate.Deposit
+	selling.Installments = sellingPrivate.Installments
+	selling.AmountPaid = sellingPrivate.AmountPaid
+	selling.Penalty = sellingPrivate.Penalty
+	return nil
 }
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/statemachine"
+	"chaincode/pkg/timeutil"
+	"chaincode/pkg/utils"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// sale is the subject of SellingMachine
+type sale struct {
+	stub       shim.ChaincodeStubInterface
+	selling    model.Selling
+	realEstate model.RealEstate
+	sellingBuy model.SellingBuy
+	operator   string              // Party cancelling the sale
+	reason     string              // Reason for cancelling
+	penalty    cancellationPenalty // Cancellation penalty found by the cancellation guard
+	decision   string              // Arbitrator's decision on a dispute
+}
+
+// donation is the subject of DonatingMachine
+type donation struct {
+	stub       shim.ChaincodeStubInterface
+	donating   model.Donating
+	realEstate model.RealEstate
+}
+
+// Guards of the selling transitions
+var (
+	withoutSchedule = &statemachine.Guard{Name: "paid in full", Check: func(subject interface{}) error {
+		if len(subject.(*sale).selling.Installments) > 0 {
+			return errcode.New(errcode.Conflict, "The sale has a payment schedule")
+		}
+		return nil
+	}}
+	withSchedule = &statemachine.Guard{Name: "payment schedule", Check: func(subject interface{}) error {
+		if len(subject.(*sale).selling.Installments) == 0 {
+			return errcode.New(errcode.Conflict, "The sale has no payment schedule")
+		}
+		return nil
+	}}
+	finalInstallment = &statemachine.Guard{Name: "final installment", Check: func(subject interface{}) error {
+		selling := subject.(*sale).selling
+		if nextInstallment(selling) != len(selling.Installments)-1 {
+			return errcode.New(errcode.Conflict, "Installments remain due after this one")
+		}
+		return nil
+	}}
+	graceAvailable = &statemachine.Guard{Name: "grace period not used", Check: func(subject interface{}) error {
+		selling := subject.(*sale).selling
+		if selling.OnMissedPayment != "grace" || selling.Installments[nextInstallment(selling)].GraceTime != "" {
+			return errcode.New(errcode.Conflict, "No grace period is available")
+		}
+		return nil
+	}}
+	cancellationAllowed = &statemachine.Guard{Name: "party allowed to cancel", Check: func(subject interface{}) error {
+		s := subject.(*sale)
+		penalty, err := checkCancellation(s.stub, &s.selling, s.operator, s.reason)
+		s.penalty = penalty
+		return err
+	}}
+	salePeriodOver = &statemachine.Guard{Name: "sale period over", Check: func(subject interface{}) error {
+		s := subject.(*sale)
+		now, err := utils.GetTxTime(s.stub)
+		if err != nil {
+			return err
+		}
+		createTime, err := timeutil.Parse(s.selling.CreateTime)
+		if err != nil {
+			return err
+		}
+		if !now.After(createTime.AddDate(0, 0, s.selling.SalePeriod)) {
+			return errcode.New(errcode.Conflict, "The sale period has not ended yet")
+		}
+		return nil
+	}}
+	saleUndone = &statemachine.Guard{Name: "refund or penalty", Check: func(subject interface{}) error {
+		return checkDecision(subject.(*sale).decision, "refund", "penalty")
+	}}
+	saleUpheld = &statemachine.Guard{Name: "completion or partial refund", Check: func(subject interface{}) error {
+		return checkDecision(subject.(*sale).decision, "complete", "partialRefund")
+	}}
+)
+
+// Side effects of the selling transitions
+var (
+	transferTitle = &statemachine.Effect{Name: "pay seller, transfer title", Run: func(subject interface{}) ([]byte, error) {
+		s := subject.(*sale)
+		return completeSelling(s.selling, s.realEstate, s.sellingBuy, s.selling.Price, s.stub)
+	}}
+	cancelSale = &statemachine.Effect{Name: "release property, refund buyer less penalty", Run: func(subject interface{}) ([]byte, error) {
+		s := subject.(*sale)
+		return closeSelling("cancelled", s.selling, s.realEstate, s.penalty, s.stub)
+	}}
+	expireSale = &statemachine.Effect{Name: "release property, refund buyer", Run: func(subject interface{}) ([]byte, error) {
+		s := subject.(*sale)
+		return closeSelling("expired", s.selling, s.realEstate, cancellationPenalty{}, s.stub)
+	}}
+	courtCancelSale = &statemachine.Effect{Name: "release property, refund buyer in full", Run: func(subject interface{}) ([]byte, error) {
+		s := subject.(*sale)
+		return closeSelling("cancelled", s.selling, s.realEstate, cancellationPenalty{}, s.stub)
+	}}
+	forfeitDeposit = &statemachine.Effect{Name: "deposit to seller, refund installments", Run: func(subject interface{}) ([]byte, error) {
+		s := subject.(*sale)
+		return nil, forfeitSelling(s.stub, s.selling)
+	}}
+)
+
+// SellingMachine declares the statuses of a sale and the operations that move it between them
+var SellingMachine = func() *statemachine.Machine {
+	status := model.SellingStatusConstant()
+	return statemachine.MustNew("selling", []string{
+		status["saleStart"], status["payment"], status["delivery"], status["dispute"],
+		status["done"], status["cancelled"], status["expired"], status["forfeited"],
+	}, []statemachine.Transition{
+		{From: statemachine.Initial, Event: "create", To: status["saleStart"]},
+		{From: status["saleStart"], Event: "buy", To: status["delivery"], Guard: withoutSchedule},
+		{From: status["saleStart"], Event: "buy", To: status["payment"], Guard: withSchedule},
+		{From: status["saleStart"], Event: "cancel", To: status["cancelled"], Guard: cancellationAllowed, Effect: cancelSale},
+		{From: status["saleStart"], Event: "expire", To: status["expired"], Guard: salePeriodOver, Effect: expireSale},
+		{From: status["saleStart"], Event: "courtOrder", To: status["cancelled"], Effect: courtCancelSale},
+		{From: status["payment"], Event: "payInstallment", To: status["delivery"], Guard: finalInstallment},
+		{From: status["payment"], Event: "payInstallment", To: status["payment"]},
+		{From: status["payment"], Event: "missInstallment", To: status["payment"], Guard: graceAvailable},
+		{From: status["payment"], Event: "missInstallment", To: status["forfeited"], Effect: forfeitDeposit},
+		{From: status["payment"], Event: "cancel", To: status["cancelled"], Guard: cancellationAllowed, Effect: cancelSale},
+		{From: status["payment"], Event: "courtOrder", To: status["cancelled"], Effect: courtCancelSale},
+		{From: status["delivery"], Event: "confirm", To: status["done"], Effect: transferTitle},
+		{From: status["delivery"], Event: "cancel", To: status["cancelled"], Guard: cancellationAllowed, Effect: cancelSale},
+		{From: status["delivery"], Event: "expire", To: status["expired"], Guard: salePeriodOver, Effect: expireSale},
+		{From: status["delivery"], Event: "raiseDispute", To: status["dispute"]},
+		{From: status["delivery"], Event: "courtOrder", To: status["cancelled"], Effect: courtCancelSale},
+		{From: status["dispute"], Event: "resolve", To: status["cancelled"], Guard: saleUndone},
+		{From: status["dispute"], Event: "resolve", To: status["done"], Guard: saleUpheld},
+		{From: status["dispute"], Event: "courtOrder", To: status["cancelled"], Effect: courtCancelSale},
+	})
+}()
+
+// Side effects of the donation transitions
+var (
+	transferDonation = &statemachine.Effect{Name: "transfer title", Run: func(subject interface{}) ([]byte, error) {
+		d := subject.(*donation)
+		return completeDonating(d.stub, d.donating, d.realEstate)
+	}}
+	cancelDonation = &statemachine.Effect{Name: "release property", Run: func(subject interface{}) ([]byte, error) {
+		d := subject.(*donation)
+		return closeDonating(d.stub, d.donating, d.realEstate)
+	}}
+)
+
+// DonatingMachine declares the statuses of a donation and the operations that move it between them
+var DonatingMachine = func() *statemachine.Machine {
+	status := model.DonatingStatusConstant()
+	return statemachine.MustNew("donating", []string{
+		status["donatingStart"], status["done"], status["cancelled"],
+	}, []statemachine.Transition{
+		{From: statemachine.Initial, Event: "create", To: status["donatingStart"]},
+		{From: status["donatingStart"], Event: "confirm", To: status["done"], Effect: transferDonation},
+		{From: status["donatingStart"], Event: "cancel", To: status["cancelled"], Effect: cancelDonation},
+		{From: status["donatingStart"], Event: "courtOrder", To: status["cancelled"], Effect: cancelDonation},
+	})
+}()
+
+// checkDecision ensures an arbitrator's decision is one of those given
+func checkDecision(decision string, decisions ...string) error {
+	for _, v := range decisions {
+		if decision == v {
+			return nil
+		}
+	}
+	return errcode.New(errcode.InvalidArgument, "Decision %s does not lead to this status", decision)
+}
+
+// QueryStateMachine exports the transition table of a state machine along with its diagrams
+func QueryStateMachine(stub shim.ChaincodeStubInterface, req *model.QueryStateMachineRequest) pb.Response {
+	machines := map[string]*statemachine.Machine{
+		SellingMachine.Name():  SellingMachine,
+		DonatingMachine.Name(): DonatingMachine,
+	}
+	machine, ok := machines[req.Machine]
+	if !ok {
+		return errcode.Responsef(errcode.NotFound, "State machine %s does not exist", req.Machine)
+	}
+	diagramByte, err := json.Marshal(machine.Diagram())
+	if err != nil {
+		return shim.Error(fmt.Sprintf("QueryStateMachine - Serialization error: %s", err))
+	}
+	return shim.Success(diagramByte)
+}
@@ -0,0 +1,299 @@
+package api
+
+import (
+	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Each real estate is a non-fungible token whose token ID is its RealEstateID.
+// The functions below follow the ERC-721 interface on top of the real estate records,
+// and transfers through them obey the same encumbrance and identity rules as sales and donations.
+
+// OwnerOf returns the proprietor of a real estate token
+func OwnerOf(stub shim.ChaincodeStubInterface, req *model.OwnerOfRequest) pb.Response {
+	realEstate, err := getRealEstate(stub, req.TokenID)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	return shim.Success([]byte(realEstate.Proprietor))
+}
+
+// BalanceOf returns the number of real estate tokens of an owner
+func BalanceOf(stub shim.ChaincodeStubInterface, req *model.BalanceOfRequest) pb.Response {
+	if _, err := getAccount(stub, req.Owner); err != nil {
+		return errcode.Responsef(errcode.NotFound, "Owner information verification failed: %s", err)
+	}
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.RealEstateKey, []string{req.Owner})
+	if err != nil {
+		return errcode.Response(err)
+	}
+	return shim.Success([]byte(strconv.Itoa(len(results))))
+}
+
+// Approve approves an account to transfer a real estate token, or clears the approval (owner or operator)
+func Approve(stub shim.ChaincodeStubInterface, req *model.ApproveRequest) pb.Response {
+	realEstate, err := getRealEstate(stub, req.TokenID)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	owner := realEstate.Proprietor
+	if req.AccountId != owner {
+		operator, err := isApprovedForAll(stub, owner, req.AccountId)
+		if err != nil {
+			return errcode.Response(err)
+		}
+		if !operator {
+			return errcode.Responsef(errcode.Forbidden, "%s is neither the owner of real estate %s nor an operator of its owner", req.AccountId, realEstate.RealEstateID)
+		}
+	}
+	if req.Approved == owner {
+		return errcode.Responsef(errcode.InvalidArgument, "The owner cannot be approved for its own real estate")
+	}
+	if req.Approved != "" {
+		if _, err := getAccount(stub, req.Approved); err != nil {
+			return errcode.Responsef(errcode.NotFound, "Approved account information verification failed: %s", err)
+		}
+	}
+	approval := &model.TokenApproval{
+		TokenID:       realEstate.RealEstateID,
+		Owner:         owner,
+		Approved:      req.Approved,
+		SchemaVersion: model.SchemaVersion,
+	}
+	if req.Approved == "" {
+		err = utils.DelLedger(stub, model.TokenApprovalKey, []string{approval.TokenID})
+	} else {
+		err = utils.WriteLedger(approval, stub, model.TokenApprovalKey, []string{approval.TokenID})
+	}
+	if err != nil {
+		return errcode.Response(err)
+	}
+	if err := setEvent(stub, "Approval", model.ApprovalEvent{Owner: owner, Approved: req.Approved, TokenID: approval.TokenID}); err != nil {
+		return errcode.Response(err)
+	}
+	approvalByte, err := json.Marshal(approval)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("Approve - Serialization error: %s", err))
+	}
+	return shim.Success(approvalByte)
+}
+
+// SetApprovalForAll approves or revokes an operator for every real estate token of an owner (owner)
+func SetApprovalForAll(stub shim.ChaincodeStubInterface, req *model.SetApprovalForAllRequest) pb.Response {
+	if req.Operator == req.AccountId {
+		return errcode.Responsef(errcode.InvalidArgument, "The owner cannot be its own operator")
+	}
+	if _, err := getAccount(stub, req.AccountId); err != nil {
+		return errcode.Responsef(errcode.NotFound, "Owner information verification failed: %s", err)
+	}
+	if _, err := getAccount(stub, req.Operator); err != nil {
+		return errcode.Responsef(errcode.NotFound, "Operator information verification failed: %s", err)
+	}
+	approval := &model.OperatorApproval{
+		Owner:         req.AccountId,
+		Operator:      req.Operator,
+		Approved:      req.Approved,
+		SchemaVersion: model.SchemaVersion,
+	}
+	var err error
+	if req.Approved {
+		err = utils.WriteLedger(approval, stub, model.OperatorApprovalKey, []string{approval.Owner, approval.Operator})
+	} else {
+		err = utils.DelLedger(stub, model.OperatorApprovalKey, []string{approval.Owner, approval.Operator})
+	}
+	if err != nil {
+		return errcode.Response(err)
+	}
+	if err := setEvent(stub, "ApprovalForAll", model.ApprovalForAllEvent{Owner: approval.Owner, Operator: approval.Operator, Approved: approval.Approved}); err != nil {
+		return errcode.Response(err)
+	}
+	approvalByte, err := json.Marshal(approval)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("SetApprovalForAll - Serialization error: %s", err))
+	}
+	return shim.Success(approvalByte)
+}
+
+// TransferFrom transfers a real estate token (owner, approved account or operator).
+// Like a completed sale or donation, it is refused while a blocking encumbrance is in force
+// and requires both parties to have verified identities.
+func TransferFrom(stub shim.ChaincodeStubInterface, req *model.TransferFromRequest) pb.Response {
+	realEstate, err := getRealEstate(stub, req.TokenID)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	if realEstate.Proprietor != req.From {
+		return errcode.Responsef(errcode.Conflict, "Real estate %s does not belong to %s", realEstate.RealEstateID, req.From)
+	}
+	if req.To == req.From {
+		return errcode.Responsef(errcode.InvalidArgument, "The real estate already belongs to %s", req.To)
+	}
+	if req.AccountId != req.From {
+		approved, err := getApproved(stub, realEstate)
+		if err != nil {
+			return errcode.Response(err)
+		}
+		operator, err := isApprovedForAll(stub, req.From, req.AccountId)
+		if err != nil {
+			return errcode.Response(err)
+		}
+		if approved != req.AccountId && !operator {
+			return errcode.Responsef(errcode.Forbidden, "%s is not approved to transfer real estate %s", req.AccountId, realEstate.RealEstateID)
+		}
+	}
+	accountFrom, err := getAccount(stub, req.From)
+	if err != nil {
+		return errcode.Responsef(errcode.NotFound, "Owner information verification failed: %s", err)
+	}
+	accountTo, err := getAccount(stub, req.To)
+	if err != nil {
+		return errcode.Responsef(errcode.NotFound, "Recipient information verification failed: %s", err)
+	}
+	if accountTo.Role == model.AccountRoleConstant()["admin"] {
+		return errcode.Responsef(errcode.Forbidden, "Cannot transfer real estate to the admin")
+	}
+	if err := checkTransferable(stub, realEstate); err != nil {
+		return errcode.Response(err)
+	}
+	if err := checkKyc(stub, accountFrom); err != nil {
+		return errcode.Response(err)
+	}
+	if err := checkKyc(stub, accountTo); err != nil {
+		return errcode.Response(err)
+	}
+	if err := transferRealEstate(stub, &realEstate, req.To); err != nil {
+		return errcode.Response(err)
+	}
+	realEstateByte, err := json.Marshal(realEstate)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("TransferFrom - Serialization error: %s", err))
+	}
+	return shim.Success(realEstateByte)
+}
+
+// GetApproved returns the account approved to transfer a real estate token, empty when there is none
+func GetApproved(stub shim.ChaincodeStubInterface, req *model.GetApprovedRequest) pb.Response {
+	realEstate, err := getRealEstate(stub, req.TokenID)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	approved, err := getApproved(stub, realEstate)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	return shim.Success([]byte(approved))
+}
+
+// IsApprovedForAll returns whether an operator is approved for every real estate token of an owner
+func IsApprovedForAll(stub shim.ChaincodeStubInterface, req *model.IsApprovedForAllRequest) pb.Response {
+	approved, err := isApprovedForAll(stub, req.Owner, req.Operator)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	return shim.Success([]byte(strconv.FormatBool(approved)))
+}
+
+// TokenMetadata returns the metadata of a real estate token
+func TokenMetadata(stub shim.ChaincodeStubInterface, req *model.TokenMetadataRequest) pb.Response {
+	realEstate, err := getRealEstate(stub, req.TokenID)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	description := fmt.Sprintf("Real estate of %.2f m² total area and %.2f m² living space", realEstate.TotalArea, realEstate.LivingSpace)
+	if realEstate.PropertyType != "" {
+		description = fmt.Sprintf("%s (%s)", description, realEstate.PropertyType)
+	}
+	metadata := &model.TokenMetadata{
+		TokenID:      realEstate.RealEstateID,
+		Name:         model.TokenName,
+		Symbol:       model.TokenSymbol,
+		Description:  description,
+		Owner:        realEstate.Proprietor,
+		PropertyType: realEstate.PropertyType,
+		TotalArea:    realEstate.TotalArea,
+		LivingSpace:  realEstate.LivingSpace,
+		Encumbrances: realEstate.Encumbrances,
+		Transferable: checkTransferable(stub, realEstate) == nil,
+	}
+	metadataByte, err := json.Marshal(metadata)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("TokenMetadata - Serialization error: %s", err))
+	}
+	return shim.Success(metadataByte)
+}
+
+// transferRealEstate gives a real estate to a new proprietor, records it in the ID index, carries its endorsement policy over,
+// clears the approval given by the former one and sets the Transfer event. The record under the former proprietor is deleted after any write to it by the caller.
+func transferRealEstate(stub shim.ChaincodeStubInterface, realEstate *model.RealEstate, to string) error {
+	from := realEstate.Proprietor
+	realEstate.Proprietor = to
+	realEstate.TransferTxID = stub.GetTxID()
+	if err := utils.WriteLedger(realEstate, stub, model.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
+		return err
+	}
+	if err := utils.DelLedger(stub, model.RealEstateKey, []string{from, realEstate.RealEstateID}); err != nil {
+		return err
+	}
+	if err := indexRealEstateID(stub, *realEstate); err != nil {
+		return err
+	}
+	if err := moveEndorsementPolicy(stub, *realEstate, from); err != nil {
+		return err
+	}
+	if err := utils.DelLedger(stub, model.TokenApprovalKey, []string{realEstate.RealEstateID}); err != nil {
+		return err
+	}
+	return setEvent(stub, "Transfer", model.TransferEvent{From: from, To: to, TokenID: realEstate.RealEstateID})
+}
+
+// getApproved returns the account approved to transfer a real estate, ignoring approvals given by a former proprietor
+func getApproved(stub shim.ChaincodeStubInterface, realEstate model.RealEstate) (string, error) {
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.TokenApprovalKey, []string{realEstate.RealEstateID})
+	if err != nil {
+		return "", err
+	}
+	for _, v := range results {
+		var approval model.TokenApproval
+		if err := json.Unmarshal(v, &approval); err != nil {
+			return "", fmt.Errorf("TokenApproval - Deserialization error: %s", err)
+		}
+		if approval.Owner == realEstate.Proprietor {
+			return approval.Approved, nil
+		}
+	}
+	return "", nil
+}
+
+// isApprovedForAll reports whether an operator is approved for every real estate of an owner
+func isApprovedForAll(stub shim.ChaincodeStubInterface, owner string, operator string) (bool, error) {
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.OperatorApprovalKey, []string{owner, operator})
+	if err != nil {
+		return false, err
+	}
+	for _, v := range results {
+		var approval model.OperatorApproval
+		if err := json.Unmarshal(v, &approval); err != nil {
+			return false, fmt.Errorf("OperatorApproval - Deserialization error: %s", err)
+		}
+		if approval.Approved {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// setEvent sets the chaincode event of the transaction; Fabric keeps only the last one set
+func setEvent(stub shim.ChaincodeStubInterface, name string, payload interface{}) error {
+	payloadByte, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("%s event - Serialization error: %s", name, err)
+	}
+	return stub.SetEvent(name, payloadByte)
+}
@@ -3,9 +3,13 @@ package main
 import (
 	"chaincode/api"
 	"chaincode/model"
+	"chaincode/pkg/contract"
+	"chaincode/pkg/server"
+	"chaincode/pkg/statemachine"
+	"chaincode/pkg/timeutil"
 	"chaincode/pkg/utils"
 	"fmt"
-	"time"
+	"os"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	pb "github.com/hyperledger/fabric/protos/peer"
@@ -18,73 +22,129 @@ type BlockChainRealEstate struct {
 func (t *BlockChainRealEstate) Init(stub shim.ChaincodeStubInterface) pb.Response {
 	fmt.Println("Chaincode initialization")
 	// Initialize default data
-	var accountIds = [6]string{
+	var accountIds = [9]string{
 		"5feceb66ffc8",
 		"6b86b273ff34",
 		"d4735e3a265e",
 		"4e07408562be",
 		"4b227777d4dd",
 		"ef2d127de37b",
+		"e7f6c011776e",
+		"7902699be42c",
+		"2c624232cdd2",
+	}
+	var userNames = [9]string{"Admin", "Owner #1", "Owner #2", "Owner #3", "Owner #4", "Owner #5", "Arbitrator", "Authority", "Verifier"}
+	var balances = [9]float64{0, 5000000, 5000000, 5000000, 5000000, 5000000, 0, 0, 0}
+	var roles = [9]string{"admin", "owner", "owner", "owner", "owner", "owner", "arbitrator", "authority", "verifier"}
+	// The owners start verified for a year
+	now, err := utils.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
 	}
-	var userNames = [6]string{"Admin", "Owner #1", "Owner #2", "Owner #3", "Owner #4", "Owner #5"}
-	var balances = [6]float64{0, 5000000, 5000000, 5000000, 5000000, 5000000}
 	// Initialize account data
+	supply := model.Supply{SchemaVersion: model.SchemaVersion}
 	for i, val := range accountIds {
-		account := &model.Account{
+		account := model.Account{
 			AccountId: val,
 			UserName:  userNames[i],
 			Balance:   balances[i],
+			Role:      model.AccountRoleConstant()[roles[i]],
 		}
-		// Write to the ledger
-		if err := utils.WriteLedger(account, stub, model.AccountKey, []string{val}); err != nil {
+		if roles[i] == "owner" {
+			account.KycStatus = model.KycStatusConstant()["verified"]
+			account.KycVerifier = accountIds[8]
+			account.KycExpiry = timeutil.Format(now.AddDate(1, 0, 0))
+		}
+		// Write to the ledger, keeping the name and balance in the private data collection
+		if err := api.PutSeedAccount(stub, &account); err != nil {
 			return shim.Error(fmt.Sprintf("%s", err))
 		}
+		supply.TotalMinted += balances[i]
+	}
+	// Record the funds minted so that auditLedger can check that none are created or lost
+	if err := utils.WriteLedger(supply, stub, model.SupplyKey, []string{}); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
 	}
 	return shim.Success(nil)
 }
 
 // Invoke Implements the Invoke interface to call the smart contract
 func (t *BlockChainRealEstate) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
-	funcName, args := stub.GetFunctionAndParameters()
-	switch funcName {
-	case "hello":
-		return api.Hello(stub, args)
-	case "queryAccountList":
-		return api.QueryAccountList(stub, args)
-	case "createRealEstate":
-		return api.CreateRealEstate(stub, args)
-	case "queryRealEstateList":
-		return api.QueryRealEstateList(stub, args)
-	case "createSelling":
-		return api.CreateSelling(stub, args)
-	case "createSellingByBuy":
-		return api.CreateSellingByBuy(stub, args)
-	case "querySellingList":
-		return api.QuerySellingList(stub, args)
-	case "querySellingListByBuyer":
-		return api.QuerySellingListByBuyer(stub, args)
-	case "updateSelling":
-		return api.UpdateSelling(stub, args)
-	case "createDonating":
-		return api.CreateDonating(stub, args)
-	case "queryDonatingList":
-		return api.QueryDonatingList(stub, args)
-	case "queryDonatingListByGrantee":
-		return api.QueryDonatingListByGrantee(stub, args)
-	case "updateDonating":
-		return api.UpdateDonating(stub, args)
-	default:
-		return shim.Error(fmt.Sprintf("Function not found: %s", funcName))
-	}
+	return realEstateContract.Invoke(stub)
 }
 
+// realEstateContract declares the chaincode functions, their typed requests and responses.
+// Clients fetch the generated metadata with the getMetadata function.
+var realEstateContract = contract.MustNew("BlockChainRealEstate", "1.0.0", []contract.Transaction{
+	{Name: "hello", Description: "Test function", Submit: true, Handler: api.Hello, Returns: ""},
+	{Name: "queryAccountList", Description: "Query accounts without their names and balances", Handler: api.QueryAccountList, Returns: []model.Account{}},
+	{Name: "queryAccountPrivateList", Description: "Query accounts with their names and balances (collection members only)", Handler: api.QueryAccountPrivateList, Returns: []model.Account{}},
+	{Name: "createRealEstate", Description: "Create a real estate (admin)", Submit: true, Handler: api.CreateRealEstate, Returns: model.RealEstate{}},
+	{Name: "createRealEstateBatch", Description: "Create up to 100 real estates at once, all or none (admin)", Submit: true, Handler: api.CreateRealEstateBatch, Returns: []model.RealEstate{}},
+	{Name: "queryRealEstate", Description: "Query a real estate by ID with the transaction that gave it to its proprietor", Handler: api.QueryRealEstate, Returns: model.RealEstate{}},
+	{Name: "queryRealEstateList", Description: "Query real estate by owner", Handler: api.QueryRealEstateList, Returns: []model.RealEstate{}},
+	{Name: "createSelling", Description: "Initiate a sale, optionally with a payment schedule", Submit: true, Handler: api.CreateSelling, Returns: model.Selling{}},
+	{Name: "createSellingByBuy", Description: "Buy a real estate on sale", Submit: true, Handler: api.CreateSellingByBuy, Returns: model.SellingBuy{}},
+	{Name: "querySellingList", Description: "Query sales by seller without their prices", Handler: api.QuerySellingList, Returns: []model.Selling{}},
+	{Name: "querySellingListByBuyer", Description: "Query the purchases of a buyer without their prices", Handler: api.QuerySellingListByBuyer, Returns: []model.SellingBuy{}},
+	{Name: "querySellingPrivateList", Description: "Query sales by seller with their prices (collection members only)", Handler: api.QuerySellingPrivateList, Returns: []model.Selling{}},
+	{Name: "querySellingPrivateListByBuyer", Description: "Query the purchases of a buyer with their prices (collection members only)", Handler: api.QuerySellingPrivateListByBuyer, Returns: []model.SellingBuy{}},
+	{Name: "queryActiveListings", Description: "Query a page of the sales in progress across all sellers with their real estate, optionally within price and area ranges (collection members only)", Handler: api.QueryActiveListings, Returns: model.ActiveListingPage{}},
+	{Name: "updateSelling", Description: "Confirm, cancel or expire a sale; cancelling requires the operator and a reason. Returns the sale, or the buyer's copy once bought", Submit: true, Handler: api.UpdateSelling, Returns: map[string]interface{}{}},
+	{Name: "setCancellationPenalty", Description: "Set the penalty owed when a party cancels a sale in a status (admin)", Submit: true, Handler: api.SetCancellationPenalty, Returns: model.CancellationPenalty{}},
+	{Name: "queryCancellationPenaltyList", Description: "Query the cancellation penalties in force", Handler: api.QueryCancellationPenaltyList, Returns: []model.CancellationPenalty{}},
+	{Name: "payInstallment", Description: "Pay the next installment of a sale", Submit: true, Handler: api.PayInstallment, Returns: model.SellingBuy{}},
+	{Name: "processMissedInstallment", Description: "Apply the missed payment policy of a sale", Submit: true, Handler: api.ProcessMissedInstallment, Returns: model.Selling{}},
+	{Name: "createDonating", Description: "Initiate a donation", Submit: true, Handler: api.CreateDonating, Returns: model.DonatingGrantee{}},
+	{Name: "queryDonatingList", Description: "Query donations by donor", Handler: api.QueryDonatingList, Returns: []model.Donating{}},
+	{Name: "queryDonatingListByGrantee", Description: "Query the donations received by a grantee", Handler: api.QueryDonatingListByGrantee, Returns: []model.DonatingGrantee{}},
+	{Name: "updateDonating", Description: "Confirm or cancel a donation", Submit: true, Handler: api.UpdateDonating, Returns: model.DonatingGrantee{}},
+	{Name: "raiseDispute", Description: "Raise a dispute on a sale in delivery", Submit: true, Handler: api.RaiseDispute, Returns: model.Dispute{}},
+	{Name: "addDisputeEvidence", Description: "Add evidence to the open dispute of a sale", Submit: true, Handler: api.AddDisputeEvidence, Returns: model.Dispute{}},
+	{Name: "resolveDispute", Description: "Resolve the open dispute of a sale (arbitrator)", Submit: true, Handler: api.ResolveDispute, Returns: model.Dispute{}},
+	{Name: "queryDisputeList", Description: "Query disputes by seller", Handler: api.QueryDisputeList, Returns: []model.Dispute{}},
+	{Name: "freezeRealEstate", Description: "Freeze a real estate by a court order, blocking its sale, donation and transfer (admin or authority)", Submit: true, Handler: api.FreezeRealEstate, Returns: model.RealEstate{}},
+	{Name: "unfreezeRealEstate", Description: "Lift the freeze of a court order (admin or authority)", Submit: true, Handler: api.UnfreezeRealEstate, Returns: model.RealEstate{}},
+	{Name: "forceTransfer", Description: "Transfer a real estate by a court order, cancelling the sale or donation in progress (admin or authority)", Submit: true, Handler: api.ForceTransfer, Returns: model.RealEstate{}},
+	{Name: "queryCourtOrderList", Description: "Query the court orders carried out on real estate", Handler: api.QueryCourtOrderList, Returns: []model.CourtOrder{}},
+	{Name: "registerEasement", Description: "Register an easement of a dominant real estate over a servient one (admin)", Submit: true, Handler: api.RegisterEasement, Returns: model.Easement{}},
+	{Name: "releaseEasement", Description: "Release an easement before the end of its term (admin)", Submit: true, Handler: api.ReleaseEasement, Returns: model.Easement{}},
+	{Name: "queryEasementList", Description: "Query the easements of a real estate, as dominant or servient", Handler: api.QueryEasementList, Returns: []model.Easement{}},
+	{Name: "requestKyc", Description: "Request identity verification for an account", Submit: true, Handler: api.RequestKyc, Returns: model.Account{}},
+	{Name: "reviewKyc", Description: "Verify or reject a pending identity verification (verifier)", Submit: true, Handler: api.ReviewKyc, Returns: model.Account{}},
+	{Name: "queryPendingKycList", Description: "Query the accounts waiting for identity verification", Handler: api.QueryPendingKycList, Returns: []model.Account{}},
+	{Name: "setEndorsementPolicy", Description: "Set the organizations that must endorse changes to a real estate, none to remove the policy (admin)", Submit: true, Handler: api.SetEndorsementPolicy, Returns: model.EndorsementPolicy{}},
+	{Name: "queryEndorsementPolicy", Description: "Query the organizations that must endorse changes to a real estate", Handler: api.QueryEndorsementPolicy, Returns: model.EndorsementPolicy{}},
+	{Name: "grantDelegation", Description: "Let an agent act on behalf of the principal within a scope, for a limited time", Submit: true, Handler: api.GrantDelegation, Returns: model.Delegation{}},
+	{Name: "revokeDelegation", Description: "Revoke a delegation before it lapses (principal)", Submit: true, Handler: api.RevokeDelegation, Returns: model.Delegation{}},
+	{Name: "queryDelegationList", Description: "Query the delegations granted by a principal and/or to an agent", Handler: api.QueryDelegationList, Returns: []model.Delegation{}},
+	{Name: "queryDelegatedActionList", Description: "Query the actions agents took on behalf of a principal", Handler: api.QueryDelegatedActionList, Returns: []model.DelegatedAction{}},
+	{Name: "ownerOf", Description: "Query the owner of a real estate token", Handler: api.OwnerOf, Returns: ""},
+	{Name: "balanceOf", Description: "Count the real estate tokens of an owner", Handler: api.BalanceOf, Returns: 0},
+	{Name: "approve", Description: "Approve an account to transfer a real estate token, or clear the approval (owner or operator)", Submit: true, Handler: api.Approve, Returns: model.TokenApproval{}},
+	{Name: "setApprovalForAll", Description: "Approve or revoke an operator for every real estate token of an owner", Submit: true, Handler: api.SetApprovalForAll, Returns: model.OperatorApproval{}},
+	{Name: "transferFrom", Description: "Transfer a real estate token (owner, approved account or operator)", Submit: true, Handler: api.TransferFrom, Returns: model.RealEstate{}},
+	{Name: "getApproved", Description: "Query the account approved to transfer a real estate token", Handler: api.GetApproved, Returns: ""},
+	{Name: "isApprovedForAll", Description: "Query whether an operator is approved for every real estate token of an owner", Handler: api.IsApprovedForAll, Returns: false},
+	{Name: "tokenMetadata", Description: "Query the metadata of a real estate token", Handler: api.TokenMetadata, Returns: model.TokenMetadata{}},
+	{Name: "auditLedger", Description: "Check the ledger invariants (admin)", Handler: api.AuditLedger, Returns: []model.AuditViolation{}},
+	{Name: "queryMigrationBatch", Description: "Query the keys of a batch of records to migrate", Handler: api.QueryMigrationBatch, Returns: model.MigrationBatch{}},
+	{Name: "migrate", Description: "Migrate a batch of records, given by their keys, to the current schema version (admin)", Submit: true, Handler: api.Migrate, Returns: model.MigrationReport{}},
+	{Name: "queryStateMachine", Description: "Export the transition table of the selling or donating state machine", Handler: api.QueryStateMachine, Returns: statemachine.Diagram{}},
+})
+
 func main() {
-	timeLocal, err := time.LoadLocation("Asia/Shanghai")
+	config, err := server.ParseConfig(os.Args[1:])
 	if err != nil {
 		panic(err)
 	}
-	time.Local = timeLocal
-	err = shim.Start(new(BlockChainRealEstate))
+	// With a server address the chaincode runs as an external service the peer connects to,
+	// otherwise it connects to the peer that launched it
+	if config.Address != "" {
+		err = server.Start(config, new(BlockChainRealEstate))
+	} else {
+		err = shim.Start(new(BlockChainRealEstate))
+	}
 	if err != nil {
 		fmt.Printf("Error starting Simple chaincode: %s", err)
 	}
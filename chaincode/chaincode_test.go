@@ -2,13 +2,39 @@ package main
 
 import (
 	"bytes"
+	"chaincode/api"
 	"chaincode/model"
+	"chaincode/pkg/contract"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/server"
+	"chaincode/pkg/statemachine"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+	"github.com/hyperledger/fabric/protos/msp"
 	pb "github.com/hyperledger/fabric/protos/peer"
+	"google.golang.org/grpc"
 )
 
 func initTest(t *testing.T) *shim.MockStub {
@@ -26,8 +52,17 @@ func checkInit(t *testing.T, stub *shim.MockStub, args [][]byte) {
 	}
 }
 
-func checkInvoke(t *testing.T, stub *shim.MockStub, args [][]byte) pb.Response {
-	res := stub.MockInvoke("1", args)
+var txCount int
+
+// nextTxID returns a unique transaction ID shaped like the ones issued by a peer
+func nextTxID() string {
+	txCount++
+	sum := sha256.Sum256([]byte(strconv.Itoa(txCount)))
+	return hex.EncodeToString(sum[:])
+}
+
+func checkInvoke(t *testing.T, stub *shim.MockStub, args [][]byte, transient ...map[string][]byte) pb.Response {
+	res := invoke(stub, &timedStub{MockStub: stub, args: args, at: time.Now()}, transient...)
 	if res.Status != shim.OK {
 		fmt.Println("Invoke", args, "failed", string(res.Message))
 		t.FailNow()
@@ -35,6 +70,195 @@ func checkInvoke(t *testing.T, stub *shim.MockStub, args [][]byte) pb.Response {
 	return res
 }
 
+// timedStub runs a transaction at a fixed time instead of the current time, optionally on behalf of a creator
+type timedStub struct {
+	*shim.MockStub
+	args      [][]byte
+	at        time.Time
+	creator   []byte
+	transient map[string][]byte
+}
+
+// invoke runs one transaction on the stub, passing a fresh salt along with the given transient data as a client would
+func invoke(stub *shim.MockStub, s *timedStub, transient ...map[string][]byte) pb.Response {
+	salt := make([]byte, model.SaltMinLength)
+	if _, err := rand.Read(salt); err != nil {
+		return shim.Error(err.Error())
+	}
+	s.transient = map[string][]byte{model.TransientSaltKey: salt}
+	for _, data := range transient {
+		for key, value := range data {
+			s.transient[key] = value
+		}
+	}
+	txID := nextTxID()
+	stub.MockTransactionStart(txID)
+	res := new(BlockChainRealEstate).Invoke(s)
+	stub.MockTransactionEnd(txID)
+	return res
+}
+
+// sellingTransient returns the private terms of a sale: the price, then the deposit and the amount and due days of each installment
+func sellingTransient(price string, schedule ...string) map[string][]byte {
+	transient := map[string][]byte{}
+	if price != "" {
+		transient["price"] = []byte(price)
+	}
+	if len(schedule) > 0 && schedule[0] != "" {
+		transient["deposit"] = []byte(schedule[0])
+	}
+	if len(schedule) > 1 {
+		var installments []map[string]json.RawMessage
+		for i := 1; i+1 < len(schedule); i += 2 {
+			installments = append(installments, map[string]json.RawMessage{"amount": json.RawMessage(schedule[i]), "dueDays": json.RawMessage(schedule[i+1])})
+		}
+		transient["installments"], _ = json.Marshal(installments)
+	}
+	return transient
+}
+
+func (s *timedStub) GetArgs() [][]byte {
+	return s.args
+}
+
+func (s *timedStub) GetStringArgs() []string {
+	var strargs []string
+	for _, barg := range s.args {
+		strargs = append(strargs, string(barg))
+	}
+	return strargs
+}
+
+func (s *timedStub) GetFunctionAndParameters() (string, []string) {
+	allargs := s.GetStringArgs()
+	if len(allargs) == 0 {
+		return "", []string{}
+	}
+	return allargs[0], allargs[1:]
+}
+
+func (s *timedStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	return &timestamp.Timestamp{Seconds: s.at.Unix(), Nanos: int32(s.at.Nanosecond())}, nil
+}
+
+func (s *timedStub) GetCreator() ([]byte, error) {
+	return s.creator, nil
+}
+
+func (s *timedStub) GetTransient() (map[string][]byte, error) {
+	return s.transient, nil
+}
+
+// GetStateByPartialCompositeKeyWithPagination pages through the keys like a peer backed by LevelDB, where the bookmark is the key
+// the next page starts at and is empty after the last page. MockStub does not implement paginated queries.
+func (s *timedStub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	prefix, err := s.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	startKey := prefix
+	if bookmark != "" {
+		startKey = bookmark
+	}
+	rangeIterator := shim.NewMockStateRangeQueryIterator(s.MockStub, startKey, prefix+string(utf8.MaxRune))
+	defer rangeIterator.Close()
+	page := &pageIterator{}
+	metadata := &pb.QueryResponseMetadata{}
+	for rangeIterator.HasNext() {
+		kv, err := rangeIterator.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(page.kvs) == int(pageSize) {
+			metadata.Bookmark = kv.Key
+			break
+		}
+		page.kvs = append(page.kvs, kv)
+	}
+	metadata.FetchedRecordsCount = int32(len(page.kvs))
+	return page, metadata, nil
+}
+
+// pageIterator iterates over one page of a paginated query
+type pageIterator struct {
+	kvs []*queryresult.KV
+}
+
+func (it *pageIterator) HasNext() bool {
+	return len(it.kvs) > 0
+}
+
+func (it *pageIterator) Next() (*queryresult.KV, error) {
+	kv := it.kvs[0]
+	it.kvs = it.kvs[1:]
+	return kv, nil
+}
+
+func (it *pageIterator) Close() error {
+	return nil
+}
+
+// testCreator returns a serialized identity of the given MSP with a freshly generated certificate
+func testCreator(t *testing.T, mspId string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "user@" + mspId},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creator, err := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   mspId,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return creator
+}
+
+// invokeAs invokes the chaincode on behalf of a client of the given MSP
+func invokeAs(t *testing.T, stub *shim.MockStub, mspId string, args [][]byte, transient ...map[string][]byte) pb.Response {
+	return invoke(stub, &timedStub{MockStub: stub, args: args, at: time.Now(), creator: testCreator(t, mspId)}, transient...)
+}
+
+// checkInvokeAt invokes the chaincode as if the transaction happened at the given time
+func checkInvokeAt(t *testing.T, stub *shim.MockStub, at time.Time, args [][]byte, transient ...map[string][]byte) pb.Response {
+	res := invoke(stub, &timedStub{MockStub: stub, args: args, at: at}, transient...)
+	if res.Status != shim.OK {
+		fmt.Println("Invoke", args, "at", at, "failed", string(res.Message))
+		t.FailNow()
+	}
+	return res
+}
+
+func checkInvokeFail(t *testing.T, stub *shim.MockStub, args [][]byte, transient ...map[string][]byte) pb.Response {
+	res := invoke(stub, &timedStub{MockStub: stub, args: args, at: time.Now()}, transient...)
+	if res.Status == shim.OK {
+		fmt.Println("Invoke", args, "was expected to fail")
+		t.FailNow()
+	}
+	return res
+}
+
+// Invoke a chaincode function expected to fail with an error code
+func checkInvokeError(t *testing.T, stub *shim.MockStub, args [][]byte, code string, transient ...map[string][]byte) *errcode.Error {
+	res := checkInvokeFail(t, stub, args, transient...)
+	e, ok := errcode.Parse(res.Message)
+	if !ok || e.Code != code {
+		fmt.Println("Invoke", args, "was expected to fail with", code, "but returned", res.Message)
+		t.FailNow()
+	}
+	return e
+}
+
 // Test chaincode initialization
 func TestBlockChainRealEstate_Init(t *testing.T) {
 	initTest(t)
@@ -77,7 +301,7 @@ func Test_CreateRealEstate(t *testing.T) {
 		[]byte("30"),           // Living space
 	})
 	// Insufficient operator permissions
-	checkInvoke(t, stub, [][]byte{
+	checkInvokeFail(t, stub, [][]byte{
 		[]byte("createRealEstate"),
 		[]byte("6b86b273ff34"), // Operator
 		[]byte("4e07408562be"), // Owner
@@ -85,7 +309,7 @@ func Test_CreateRealEstate(t *testing.T) {
 		[]byte("30"),           // Living space
 	})
 	// Operator should be an administrator and different from the owner
-	checkInvoke(t, stub, [][]byte{
+	checkInvokeFail(t, stub, [][]byte{
 		[]byte("createRealEstate"),
 		[]byte("5feceb66ffc8"), // Operator
 		[]byte("5feceb66ffc8"), // Owner
@@ -93,7 +317,7 @@ func Test_CreateRealEstate(t *testing.T) {
 		[]byte("30"),           // Living space
 	})
 	// Owner (proprietor) information validation failed
-	checkInvoke(t, stub, [][]byte{
+	checkInvokeFail(t, stub, [][]byte{
 		[]byte("createRealEstate"),
 		[]byte("5feceb66ffc8"),    // Operator
 		[]byte("6b86b273ff34555"), // Owner
@@ -101,14 +325,14 @@ func Test_CreateRealEstate(t *testing.T) {
 		[]byte("30"),              // Living space
 	})
 	// Incorrect number of parameters
-	checkInvoke(t, stub, [][]byte{
+	checkInvokeFail(t, stub, [][]byte{
 		[]byte("createRealEstate"),
 		[]byte("5feceb66ffc8"), // Operator
 		[]byte("6b86b273ff34"), // Owner
 		[]byte("50"),           // Total area
 	})
 	// Parameter format conversion error
-	checkInvoke(t, stub, [][]byte{
+	checkInvokeFail(t, stub, [][]byte{
 		[]byte("createRealEstate"),
 		[]byte("5feceb66ffc8"), // Operator
 		[]byte("6b86b273ff34"), // Owner
@@ -192,38 +416,33 @@ func Test_CreateSelling(t *testing.T) {
 		[]byte("createSelling"),
 		[]byte(realEstateList[0].RealEstateID), // Object for sale (RealEstateID being sold)
 		[]byte(realEstateList[0].Proprietor),   // Seller (Seller's AccountId)
-		[]byte("50"),                           // Price
 		[]byte("30"),                           // Smart contract validity period (in days)
-	})
+	}, sellingTransient("50"))
 	// Validation fails as object for sale does not belong to the seller
-	checkInvoke(t, stub, [][]byte{
+	checkInvokeFail(t, stub, [][]byte{
 		[]byte("createSelling"),
 		[]byte(realEstateList[0].RealEstateID), // Object for sale (RealEstateID being sold)
 		[]byte(realEstateList[2].Proprietor),   // Seller (Seller's AccountId)
-		[]byte("50"),                           // Price
 		[]byte("30"),                           // Smart contract validity period (in days)
-	})
-	checkInvoke(t, stub, [][]byte{
+	}, sellingTransient("50"))
+	checkInvokeFail(t, stub, [][]byte{
 		[]byte("createSelling"),
 		[]byte("123"),                        // Object for sale (RealEstateID being sold)
 		[]byte(realEstateList[0].Proprietor), // Seller (Seller's AccountId)
-		[]byte("50"),                        // Price
-		[]byte("30"),                        // Smart contract validity period (in days)
-	})
+		[]byte("30"),                         // Smart contract validity period (in days)
+	}, sellingTransient("50"))
 	// Parameter errors
-	checkInvoke(t, stub, [][]byte{
+	checkInvokeFail(t, stub, [][]byte{
 		[]byte("createSelling"),
 		[]byte(realEstateList[0].RealEstateID), // Object for sale (RealEstateID being sold)
 		[]byte(realEstateList[0].Proprietor),   // Seller (Seller's AccountId)
-		[]byte("50"),                           // Price
-	})
-	checkInvoke(t, stub, [][]byte{
+	}, sellingTransient("50"))
+	checkInvokeFail(t, stub, [][]byte{
 		[]byte("createSelling"),
 		[]byte(""),                           // Object for sale (RealEstateID being sold)
 		[]byte(realEstateList[0].Proprietor), // Seller (Seller's AccountId)
-		[]byte("50"),                        // Price
-		[]byte("30"),                        // Smart contract validity period (in days)
-	})
+		[]byte("30"),                         // Smart contract validity period (in days)
+	}, sellingTransient("50"))
 }
 
 // Test sales initiation, purchase, and related operations
@@ -235,16 +454,14 @@ func Test_QuerySellingList(t *testing.T) {
 		[]byte("createSelling"),
 		[]byte(realEstateList[0].RealEstateID), // Object for sale (RealEstateID being sold)
 		[]byte(realEstateList[0].Proprietor),   // Seller (Seller's AccountId)
-		[]byte("500000"),                       // Price
 		[]byte("30"),                           // Smart contract validity period (in days)
-	}).Payload)))
+	}, sellingTransient("500000")).Payload)))
 	fmt.Println(fmt.Sprintf("Initiate sales\n%s", string(checkInvoke(t, stub, [][]byte{
 		[]byte("createSelling"),
 		[]byte(realEstateList[2].RealEstateID), // Object for sale (RealEstateID being sold)
 		[]byte(realEstateList[2].Proprietor),   // Seller (Seller's AccountId)
-		[]byte("600000"),                       // Price
 		[]byte("40"),                           // Smart contract validity period (in days)
-	}).Payload)))
+	}, sellingTransient("600000")).Payload)))
 	// Query successfully
 	fmt.Println(fmt.Sprintf("1. Query all\n%s", string(checkInvoke(t, stub, [][]byte{
 		[]byte("querySellingList"),
@@ -341,16 +558,14 @@ func Test_Purchase(t *testing.T) {
 		[]byte("createSelling"),
 		[]byte(realEstateList[0].RealEstateID), // Object for sale (RealEstateID being sold)
 		[]byte(realEstateList[0].Proprietor),   // Seller (Seller's AccountId)
-		[]byte("500000"),                       // Price
 		[]byte("30"),                           // Smart contract validity period (in days)
-	}).Payload)))
+	}, sellingTransient("500000")).Payload)))
 	fmt.Println(fmt.Sprintf("Initiate sales\n%s", string(checkInvoke(t, stub, [][]byte{
 		[]byte("createSelling"),
 		[]byte(realEstateList[2].RealEstateID), // Object for sale (RealEstateID being sold)
 		[]byte(realEstateList[2].Proprietor),   // Seller (Seller's AccountId)
-		[]byte("600000"),                       // Price
 		[]byte("40"),                           // Smart contract validity period (in days)
-	}).Payload)))
+	}, sellingTransient("600000")).Payload)))
 	// Purchase
 	fmt.Println(fmt.Sprintf("1. Start purchase\n%s", string(checkInvoke(t, stub, [][]byte{
 		[]byte("createSellingByBuy"),
@@ -358,24 +573,1812 @@ func Test_Purchase(t *testing.T) {
 		[]byte(realEstateList[0].Proprietor),   // Seller (Seller's AccountId)
 		[]byte(realEstateList[2].Proprietor),   // Buyer (Buyer's AccountId)
 	}).Payload)))
-	fmt.Println(fmt.Sprintf("2. Seller %s confirms receipt\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, [][]byte{
+	resp := checkInvoke(t, stub, [][]byte{
 		[]byte("updateSelling"),
 		[]byte(realEstateList[0].RealEstateID), // Object for sale (RealEstateID being sold)
 		[]byte(realEstateList[0].Proprietor),   // Seller (Seller's AccountId)
 		[]byte(realEstateList[2].Proprietor),   // Buyer (Buyer's AccountId)
 		[]byte("done"),                         // Confirm receipt
+	})
+	fmt.Println(fmt.Sprintf("2. Seller %s confirms receipt\n%s", realEstateList[0].Proprietor, string(resp.Payload)))
+	// The completion time is recorded for the market statistics
+	var sellingBuy model.SellingBuy
+	if err := json.Unmarshal(resp.Payload, &sellingBuy); err != nil || sellingBuy.Selling.CompleteTime == "" {
+		fmt.Println("Completion time was not recorded", err, sellingBuy)
+		t.FailNow()
+	}
+}
+
+// Query a single account
+func checkQueryAccount(t *testing.T, stub *shim.MockStub, accountId string) model.Account {
+	var accountList []model.Account
+	resp := invokeAs(t, stub, "JDMSP", [][]byte{
+		[]byte("queryAccountPrivateList"),
+		[]byte(accountId),
+	})
+	if resp.Status != shim.OK {
+		fmt.Println("Query account", accountId, "failed", resp.Message)
+		t.FailNow()
+	}
+	if err := json.Unmarshal(resp.Payload, &accountList); err != nil || len(accountList) != 1 {
+		fmt.Println("Query account", accountId, "failed", err)
+		t.FailNow()
+	}
+	return accountList[0]
+}
+
+// Query a sale with its private details, as a collection member would
+func checkQuerySelling(t *testing.T, stub *shim.MockStub, seller string, objectOfSale string) model.Selling {
+	var sellingList []model.Selling
+	resp := invokeAs(t, stub, "TaobaoMSP", [][]byte{
+		[]byte("querySellingPrivateList"),
+		[]byte(seller),
+		[]byte(objectOfSale),
+	})
+	if err := json.Unmarshal(resp.Payload, &sellingList); resp.Status != shim.OK || err != nil || len(sellingList) != 1 {
+		fmt.Println("Query selling", objectOfSale, "failed", resp.Message, err)
+		t.FailNow()
+	}
+	return sellingList[0]
+}
+
+// Put realEstate up for sale and let buyer purchase it
+func checkSellAndBuy(t *testing.T, stub *shim.MockStub, realEstate model.RealEstate, buyer string, price string) {
+	checkInvoke(t, stub, [][]byte{
+		[]byte("createSelling"),
+		[]byte(realEstate.RealEstateID), // Object for sale (RealEstateID being sold)
+		[]byte(realEstate.Proprietor),   // Seller (Seller's AccountId)
+		[]byte("30"),                    // Smart contract validity period (in days)
+	}, sellingTransient(price))
+	checkInvoke(t, stub, [][]byte{
+		[]byte("createSellingByBuy"),
+		[]byte(realEstate.RealEstateID), // Object for sale (RealEstateID being sold)
+		[]byte(realEstate.Proprietor),   // Seller (Seller's AccountId)
+		[]byte(buyer),                   // Buyer (Buyer's AccountId)
+	})
+}
+
+// Test browsing the sales in progress across all sellers
+func Test_QueryActiveListings(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	for i, price := range map[int]string{0: "100", 1: "300", 3: "200"} {
+		checkInvoke(t, stub, [][]byte{
+			[]byte("createSelling"),
+			[]byte(realEstateList[i].RealEstateID), // Object for sale (RealEstateID being sold)
+			[]byte(realEstateList[i].Proprietor),   // Seller (Seller's AccountId)
+			[]byte("30"),                           // Smart contract validity period (in days)
+		}, sellingTransient(price))
+	}
+	// A sale that was bought is no longer listed
+	checkInvoke(t, stub, [][]byte{
+		[]byte("createSellingByBuy"),
+		[]byte(realEstateList[1].RealEstateID), // Object for sale (RealEstateID being sold)
+		[]byte(realEstateList[1].Proprietor),   // Seller (Seller's AccountId)
+		[]byte("4e07408562be"),                 // Buyer (Buyer's AccountId)
+	})
+	queryActiveListings := func(args ...string) model.ActiveListingPage {
+		var page model.ActiveListingPage
+		bytes := [][]byte{[]byte("queryActiveListings")}
+		for _, arg := range args {
+			bytes = append(bytes, []byte(arg))
+		}
+		// Prices are only served to members of the collection
+		resp := invokeAs(t, stub, "TaobaoMSP", bytes)
+		if err := json.Unmarshal(resp.Payload, &page); resp.Status != shim.OK || err != nil {
+			fmt.Println("Query active listings failed", resp.Message, err)
+			t.FailNow()
+		}
+		return page
+	}
+	page := queryActiveListings("10")
+	if len(page.Listings) != 2 || !page.Done {
+		fmt.Println("Unexpected active listings", page)
+		t.FailNow()
+	}
+	for _, listing := range page.Listings {
+		if listing.Selling.SellingStatus != model.SellingStatusConstant()["saleStart"] || listing.Selling.Price == 0 ||
+			listing.RealEstate.RealEstateID != listing.Selling.ObjectOfSale {
+			fmt.Println("Listing was not joined with its price and real estate", listing)
+			t.FailNow()
+		}
+	}
+	// Price and area ranges
+	if page := queryActiveListings("10", "", "150"); len(page.Listings) != 1 || page.Listings[0].Selling.Price != 200 {
+		fmt.Println("Unexpected listings from 150", page)
+		t.FailNow()
+	}
+	if page := queryActiveListings("10", "", "0", "0", "0", "60"); len(page.Listings) != 1 || page.Listings[0].RealEstate.TotalArea != 50 {
+		fmt.Println("Unexpected listings up to 60 square meters", page)
+		t.FailNow()
+	}
+	// Pages continue from the bookmark
+	first := queryActiveListings("1")
+	if len(first.Listings) != 1 || first.Done {
+		fmt.Println("Unexpected first page", first)
+		t.FailNow()
+	}
+	second := queryActiveListings("1", first.Bookmark)
+	if len(second.Listings) != 1 || !second.Done || second.Listings[0].Selling.ObjectOfSale == first.Listings[0].Selling.ObjectOfSale {
+		fmt.Println("Unexpected second page", second)
+		t.FailNow()
+	}
+	for _, args := range [][][]byte{
+		{[]byte("queryActiveListings"), []byte("0")},
+		{[]byte("queryActiveListings"), []byte("10"), []byte(""), []byte("300"), []byte("100")},
+	} {
+		if resp := invokeAs(t, stub, "TaobaoMSP", args); resp.Status == shim.OK {
+			fmt.Println("Invalid listing query was accepted", string(args[1]))
+			t.FailNow()
+		}
+	}
+	if resp := invokeAs(t, stub, "OtherMSP", [][]byte{[]byte("queryActiveListings"), []byte("10")}); resp.Status == shim.OK {
+		fmt.Println("queryActiveListings was expected to refuse OtherMSP")
+		t.FailNow()
+	}
+	// An index entry whose sale is missing is reported instead of being skipped
+	txID := nextTxID()
+	stub.MockTransactionStart(txID)
+	if err := stub.PutState(mustCompositeKey(t, stub, model.SellingStatusIndexKey, []string{model.SellingStatusConstant()["saleStart"], "6b86b273ff34", "missing"}),
+		[]byte(`{"sellingStatus":"In Sale","seller":"6b86b273ff34","objectOfSale":"missing"}`)); err != nil {
+		t.Fatal(err)
+	}
+	stub.MockTransactionEnd(txID)
+	if resp := invokeAs(t, stub, "TaobaoMSP", [][]byte{[]byte("queryActiveListings"), []byte("10")}); resp.Status == shim.OK || !strings.Contains(resp.Message, errcode.NotFound) {
+		fmt.Println("A broken status index was not reported", resp.Status, resp.Message)
+		t.FailNow()
+	}
+}
+
+// Test raising and resolving disputes on sales in delivery
+func Test_Dispute(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	seller := realEstateList[0].Proprietor
+	buyer := realEstateList[2].Proprietor
+	arbitrator := "e7f6c011776e"
+	evidence := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	// A sale in "saleStart" status cannot be disputed
+	checkInvoke(t, stub, [][]byte{
+		[]byte("createSelling"),
+		[]byte(realEstateList[3].RealEstateID),
+		[]byte(realEstateList[3].Proprietor),
+		[]byte("30"),
+	}, sellingTransient("500000"))
+	checkInvokeFail(t, stub, [][]byte{
+		[]byte("raiseDispute"),
+		[]byte(realEstateList[3].RealEstateID),
+		[]byte(realEstateList[3].Proprietor),
+		[]byte(realEstateList[3].Proprietor),
+		[]byte("No buyer yet"),
+	})
+	checkSellAndBuy(t, stub, realEstateList[0], buyer, "500000")
+	// Only the parties of the sale can raise a dispute, and evidence must be a SHA-256 hash
+	checkInvokeFail(t, stub, [][]byte{
+		[]byte("raiseDispute"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+		[]byte(realEstateList[3].Proprietor),
+		[]byte("Not a party"),
+	})
+	checkInvokeFail(t, stub, [][]byte{
+		[]byte("raiseDispute"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+		[]byte(buyer),
+		[]byte("Bad evidence"),
+		[]byte("not-a-hash"),
+	})
+	fmt.Println(fmt.Sprintf("1. Buyer %s raises a dispute\n%s", buyer, string(checkInvoke(t, stub, [][]byte{
+		[]byte("raiseDispute"),
+		[]byte(realEstateList[0].RealEstateID), // Object for sale (RealEstateID being sold)
+		[]byte(seller),                         // Seller (Seller's AccountId)
+		[]byte(buyer),                          // Party raising the dispute
+		[]byte("The roof leaks"),               // Reason
+		[]byte(evidence),                       // Evidence hash
+	}).Payload)))
+	// The sale is frozen: neither confirmation nor cancellation is possible
+	checkInvokeFail(t, stub, [][]byte{
+		[]byte("updateSelling"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+		[]byte(buyer),
+		[]byte("done"),
+	})
+	checkInvokeFail(t, stub, [][]byte{
+		[]byte("updateSelling"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+		[]byte(buyer),
+		[]byte("cancelled"),
+	})
+	fmt.Println(fmt.Sprintf("2. Seller %s adds evidence\n%s", seller, string(checkInvoke(t, stub, [][]byte{
+		[]byte("addDisputeEvidence"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+		[]byte(seller),
+		[]byte(evidence),
+	}).Payload)))
+	// Only an arbitrator can resolve the dispute
+	checkInvokeFail(t, stub, [][]byte{
+		[]byte("resolveDispute"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+		[]byte("5feceb66ffc8"),
+		[]byte("complete"),
+		[]byte(""),
+		[]byte(""),
+		[]byte(""),
+	})
+	fmt.Println(fmt.Sprintf("3. Arbitrator resolves with a partial refund\n%s", string(checkInvoke(t, stub, [][]byte{
+		[]byte("resolveDispute"),
+		[]byte(realEstateList[0].RealEstateID), // Object for sale (RealEstateID being sold)
+		[]byte(seller),                         // Seller (Seller's AccountId)
+		[]byte(arbitrator),                     // Arbitrator
+		[]byte("partialRefund"),                // Decision
+		[]byte("100000"),                       // Amount refunded to the buyer
+		[]byte(""),                             // Penalized party
+		[]byte("Roof repair costs"),            // Decision note
+	}).Payload)))
+	if balance := checkQueryAccount(t, stub, seller).Balance; balance != 5400000 {
+		fmt.Println("Unexpected seller balance", balance)
+		t.FailNow()
+	}
+	if balance := checkQueryAccount(t, stub, buyer).Balance; balance != 4600000 {
+		fmt.Println("Unexpected buyer balance", balance)
+		t.FailNow()
+	}
+	// The dispute is closed and the real estate now belongs to the buyer
+	checkInvokeFail(t, stub, [][]byte{
+		[]byte("addDisputeEvidence"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+		[]byte(seller),
+		[]byte(evidence),
+	})
+	checkInvoke(t, stub, [][]byte{
+		[]byte("createSelling"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(buyer),
+		[]byte("30"),
+	}, sellingTransient("600000"))
+
+	// The seller is penalized: the buyer is refunded and compensated
+	checkSellAndBuy(t, stub, realEstateList[1], buyer, "200000")
+	checkInvoke(t, stub, [][]byte{
+		[]byte("raiseDispute"),
+		[]byte(realEstateList[1].RealEstateID),
+		[]byte(seller),
+		[]byte(seller),
+		[]byte("The buyer asks for more"),
+	})
+	// The penalty is settled out of the escrowed price and cannot exceed it
+	for _, penalizedParty := range []string{seller, buyer} {
+		checkInvokeFail(t, stub, [][]byte{
+			[]byte("resolveDispute"),
+			[]byte(realEstateList[1].RealEstateID),
+			[]byte(seller),
+			[]byte(arbitrator),
+			[]byte("penalty"),
+			[]byte("200001"),
+			[]byte(penalizedParty),
+			[]byte("The penalty exceeds the price"),
+		})
+	}
+	fmt.Println(fmt.Sprintf("4. Arbitrator penalizes the seller\n%s", string(checkInvoke(t, stub, [][]byte{
+		[]byte("resolveDispute"),
+		[]byte(realEstateList[1].RealEstateID),
+		[]byte(seller),
+		[]byte(arbitrator),
+		[]byte("penalty"),
+		[]byte("10000"),
+		[]byte(seller),
+		[]byte("The seller withheld the keys"),
+	}).Payload)))
+	if balance := checkQueryAccount(t, stub, seller).Balance; balance != 5390000 {
+		fmt.Println("Unexpected seller balance", balance)
+		t.FailNow()
+	}
+	if balance := checkQueryAccount(t, stub, buyer).Balance; balance != 4610000 {
+		fmt.Println("Unexpected buyer balance", balance)
+		t.FailNow()
+	}
+	// A buyer penalized by the whole price forfeits it to the seller
+	checkInvoke(t, stub, [][]byte{
+		[]byte("createSellingByBuy"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(buyer),
+		[]byte(seller),
+	})
+	checkInvoke(t, stub, [][]byte{
+		[]byte("raiseDispute"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(buyer),
+		[]byte(buyer),
+		[]byte("The buyer does not respond"),
+	})
+	checkInvoke(t, stub, [][]byte{
+		[]byte("resolveDispute"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(buyer),
+		[]byte(arbitrator),
+		[]byte("penalty"),
+		[]byte("600000"),
+		[]byte(seller),
+		[]byte("The buyer abandoned the purchase"),
+	})
+	if balance := checkQueryAccount(t, stub, seller).Balance; balance != 4790000 {
+		fmt.Println("Unexpected balance of the penalized buyer", balance)
+		t.FailNow()
+	}
+	if balance := checkQueryAccount(t, stub, buyer).Balance; balance != 5210000 {
+		fmt.Println("Unexpected balance of the compensated seller", balance)
+		t.FailNow()
+	}
+	fmt.Println(fmt.Sprintf("5. Query disputes of the seller %s\n%s", seller, string(checkInvoke(t, stub, [][]byte{
+		[]byte("queryDisputeList"),
+		[]byte(seller),
+	}).Payload)))
+}
+
+// Test sales paid with a deposit and installments
+func Test_Installments(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	seller := realEstateList[0].Proprietor
+	buyer := realEstateList[2].Proprietor
+	// The deposit and installments must add up to the price
+	checkInvokeFail(t, stub, [][]byte{
+		[]byte("createSelling"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+		[]byte("90"),
+		[]byte("forfeit"),
+		[]byte("0"),
+		[]byte(""),
+	}, sellingTransient("300000", "30000", "100000", "30"))
+	fmt.Println(fmt.Sprintf("1. Initiate a sale with a deposit and two installments\n%s", string(checkInvoke(t, stub, [][]byte{
+		[]byte("createSelling"),
+		[]byte(realEstateList[0].RealEstateID), // Object for sale (RealEstateID being sold)
+		[]byte(seller),                         // Seller (Seller's AccountId)
+		[]byte("90"),                           // Smart contract validity period (in days)
+		[]byte("grace"),                        // Missed payment policy
+		[]byte("10"),                           // Grace period (in days)
+		[]byte(""),                             // No agent, the seller acts itself
+	}, sellingTransient("300000", "30000", "120000", "30", "150000", "60")).Payload)))
+	start := time.Now()
+	fmt.Println(fmt.Sprintf("2. Buyer pays the deposit\n%s", string(checkInvokeAt(t, stub, start, [][]byte{
+		[]byte("createSellingByBuy"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+		[]byte(buyer),
+	}).Payload)))
+	if balance := checkQueryAccount(t, stub, buyer).Balance; balance != 4970000 {
+		fmt.Println("Unexpected buyer balance", balance)
+		t.FailNow()
+	}
+	// The title cannot be transferred before the final payment, and the deadline has not been missed yet
+	checkInvokeFail(t, stub, [][]byte{
+		[]byte("updateSelling"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+		[]byte(buyer),
+		[]byte("done"),
+	})
+	checkInvokeFail(t, stub, [][]byte{
+		[]byte("processMissedInstallment"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+	})
+	// The amounts paid stay in the collection, out of the transaction payload
+	var selling model.Selling
+	if err := json.Unmarshal(checkInvokeAt(t, stub, start.AddDate(0, 0, 20), [][]byte{
+		[]byte("payInstallment"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+		[]byte(buyer),
+	}).Payload, &selling); err != nil || selling.Price != 0 || selling.AmountPaid != 0 || len(selling.Installments) != 0 {
+		fmt.Println("Pay installment exposed the private terms", err, selling)
+		t.FailNow()
+	}
+	if selling = checkQuerySelling(t, stub, seller, realEstateList[0].RealEstateID); selling.AmountPaid != 150000 {
+		fmt.Println("Unexpected amount paid", selling.AmountPaid)
+		t.FailNow()
+	}
+	// The second installment is missed and the grace period starts
+	fmt.Println(fmt.Sprintf("3. The second installment is missed\n%s", string(checkInvokeAt(t, stub, start.AddDate(0, 0, 61), [][]byte{
+		[]byte("processMissedInstallment"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+	}).Payload)))
+	fmt.Println(fmt.Sprintf("4. Buyer pays within the grace period\n%s", string(checkInvokeAt(t, stub, start.AddDate(0, 0, 65), [][]byte{
+		[]byte("payInstallment"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+		[]byte(buyer),
+	}).Payload)))
+	checkInvoke(t, stub, [][]byte{
+		[]byte("updateSelling"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+		[]byte(buyer),
+		[]byte("done"),
+	})
+	if balance := checkQueryAccount(t, stub, seller).Balance; balance != 5300000 {
+		fmt.Println("Unexpected seller balance", balance)
+		t.FailNow()
+	}
+
+	// Without a grace period the deposit is forfeited to the seller on the first missed deadline
+	checkInvoke(t, stub, [][]byte{
+		[]byte("createSelling"),
+		[]byte(realEstateList[1].RealEstateID),
+		[]byte(seller),
+		[]byte("90"),
+		[]byte("forfeit"),
+		[]byte("0"),
+		[]byte(""),
+	}, sellingTransient("200000", "20000", "80000", "30", "100000", "60"))
+	checkInvokeAt(t, stub, start, [][]byte{
+		[]byte("createSellingByBuy"),
+		[]byte(realEstateList[1].RealEstateID),
+		[]byte(seller),
+		[]byte(buyer),
+	})
+	checkInvokeAt(t, stub, start.AddDate(0, 0, 10), [][]byte{
+		[]byte("payInstallment"),
+		[]byte(realEstateList[1].RealEstateID),
+		[]byte(seller),
+		[]byte(buyer),
+	})
+	fmt.Println(fmt.Sprintf("5. The deposit is forfeited\n%s", string(checkInvokeAt(t, stub, start.AddDate(0, 0, 61), [][]byte{
+		[]byte("processMissedInstallment"),
+		[]byte(realEstateList[1].RealEstateID),
+		[]byte(seller),
 	}).Payload)))
+	if balance := checkQueryAccount(t, stub, seller).Balance; balance != 5320000 {
+		fmt.Println("Unexpected seller balance", balance)
+		t.FailNow()
+	}
+	if balance := checkQueryAccount(t, stub, buyer).Balance; balance != 4680000 {
+		fmt.Println("Unexpected buyer balance", balance)
+		t.FailNow()
+	}
+	// The real estate is released and can be put up for sale again
+	checkInvoke(t, stub, [][]byte{
+		[]byte("createSelling"),
+		[]byte(realEstateList[1].RealEstateID),
+		[]byte(seller),
+		[]byte("30"),
+	}, sellingTransient("200000"))
 }
 
-func main() {
-	test := &BlockChainRealEstate{}
-	t := &testing.T{}
-	TestBlockChainRealEstate_Init(t)
-	Test_QueryAccountList(t)
-	Test_CreateRealEstate(t)
-	Test_QueryRealEstateList(t)
-	Test_CreateSelling(t)
-	Test_QuerySellingList(t)
-	Test_Purchase(t)
+// Test that prices and balances are only readable from the private data collections
+func Test_PrivateData(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	seller := realEstateList[0].Proprietor
+	buyer := realEstateList[2].Proprietor
+	checkSellAndBuy(t, stub, realEstateList[0], buyer, "500000")
+	// The public state only carries the hash of the private details
+	var accountList []model.Account
+	resp := checkInvoke(t, stub, [][]byte{
+		[]byte("queryAccountList"),
+		[]byte(buyer),
+	})
+	if err := json.Unmarshal(resp.Payload, &accountList); err != nil || len(accountList) != 1 {
+		fmt.Println("Query account", buyer, "failed", err)
+		t.FailNow()
+	}
+	if accountList[0].Balance != 0 || accountList[0].UserName != "" || accountList[0].PrivateHash == "" {
+		fmt.Println("Public account exposes private details", accountList[0])
+		t.FailNow()
+	}
+	var sellingList []model.Selling
+	resp = checkInvoke(t, stub, [][]byte{
+		[]byte("querySellingList"),
+		[]byte(seller),
+	})
+	if err := json.Unmarshal(resp.Payload, &sellingList); err != nil || len(sellingList) != 1 {
+		fmt.Println("Query selling", seller, "failed", err)
+		t.FailNow()
+	}
+	if sellingList[0].Price != 0 || sellingList[0].AmountPaid != 0 || sellingList[0].PrivateHash == "" {
+		fmt.Println("Public selling exposes private details", sellingList[0])
+		t.FailNow()
+	}
+	// Collection members see the private details
+	if account := checkQueryAccount(t, stub, buyer); account.Balance != 4500000 || account.UserName == "" {
+		fmt.Println("Unexpected private account", account)
+		t.FailNow()
+	}
+	var sellingBuyList []model.SellingBuy
+	resp = invokeAs(t, stub, "TaobaoMSP", [][]byte{
+		[]byte("querySellingPrivateListByBuyer"),
+		[]byte(buyer),
+	})
+	if err := json.Unmarshal(resp.Payload, &sellingBuyList); err != nil || len(sellingBuyList) != 1 || sellingBuyList[0].Selling.Price != 500000 {
+		fmt.Println("Unexpected private purchases", resp.Message, sellingBuyList)
+		t.FailNow()
+	}
+	// Private data is only written with a random salt passed by the client in the transient data
+	sale := [][]byte{[]byte("createSelling"), []byte(realEstateList[1].RealEstateID), []byte(realEstateList[1].Proprietor), []byte("30")}
+	checkInvokeError(t, stub, sale, errcode.InvalidArgument, sellingTransient("500000"), map[string][]byte{model.TransientSaltKey: nil})
+	checkInvokeError(t, stub, sale, errcode.InvalidArgument, sellingTransient("500000"), map[string][]byte{model.TransientSaltKey: []byte("short")})
+	checkInvoke(t, stub, sale, sellingTransient("500000"))
+	if selling := checkQuerySelling(t, stub, realEstateList[1].Proprietor, realEstateList[1].RealEstateID); selling.Price != 500000 {
+		fmt.Println("Unexpected private selling", selling)
+		t.FailNow()
+	}
+	// Other organizations are refused
+	for _, function := range []string{"queryAccountPrivateList", "querySellingPrivateList", "querySellingPrivateListByBuyer"} {
+		if resp := invokeAs(t, stub, "OtherMSP", [][]byte{[]byte(function), []byte(buyer)}); resp.Status == shim.OK {
+			fmt.Println(function, "was expected to refuse OtherMSP")
+			t.FailNow()
+		}
+	}
+	// Private details that do not match the public hash are rejected
+	key, _ := stub.CreateCompositeKey(model.AccountKey, []string{buyer})
+	txID := nextTxID()
+	stub.MockTransactionStart(txID)
+	stub.PutPrivateData(model.AccountCollection, key, []byte(`{"userName":"Owner #2","balance":9999999,"salt":""}`))
+	stub.MockTransactionEnd(txID)
+	if resp := invokeAs(t, stub, "JDMSP", [][]byte{[]byte("queryAccountPrivateList"), []byte(buyer)}); resp.Status == shim.OK {
+		fmt.Println("Tampered private data was accepted")
+		t.FailNow()
+	}
 }
 
+// Test the ledger invariant audit
+func Test_AuditLedger(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	buyer := realEstateList[2].Proprietor
+	// Cancelling a sale in delivery refunds the buyer and closes the buyer's copy as well
+	checkSellAndBuy(t, stub, realEstateList[0], realEstateList[3].Proprietor, "200000")
+	checkInvoke(t, stub, [][]byte{
+		[]byte("updateSelling"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(realEstateList[0].Proprietor),
+		[]byte(realEstateList[3].Proprietor),
+		[]byte("cancelled"),
+		[]byte(realEstateList[3].Proprietor),
+		[]byte("The seller did not confirm"),
+	})
+	// One sale in delivery holding the buyer's funds in escrow and one donation in progress
+	checkSellAndBuy(t, stub, realEstateList[1], buyer, "500000")
+	checkInvoke(t, stub, [][]byte{
+		[]byte("createDonating"),
+		[]byte(realEstateList[3].RealEstateID),
+		[]byte(realEstateList[3].Proprietor),
+		[]byte(buyer),
+	})
+	audit := func() []model.AuditViolation {
+		var violations []model.AuditViolation
+		resp := invokeAs(t, stub, "JDMSP", [][]byte{[]byte("auditLedger"), []byte("5feceb66ffc8")})
+		if resp.Status != shim.OK {
+			fmt.Println("Audit failed", resp.Message)
+			t.FailNow()
+		}
+		if err := json.Unmarshal(resp.Payload, &violations); err != nil {
+			fmt.Println("Audit - Deserialization error", err)
+			t.FailNow()
+		}
+		return violations
+	}
+	if violations := audit(); len(violations) != 0 {
+		fmt.Println("Unexpected violations", violations)
+		t.FailNow()
+	}
+	// Only the admin can audit the ledger
+	if resp := invokeAs(t, stub, "JDMSP", [][]byte{[]byte("auditLedger"), []byte(buyer)}); resp.Status == shim.OK {
+		fmt.Println("auditLedger was expected to refuse a non-admin")
+		t.FailNow()
+	}
+	// Break one of each invariant
+	txID := nextTxID()
+	stub.MockTransactionStart(txID)
+	realEstate := realEstateList[0]
+	realEstate.Encumbrances = []model.Encumbrance{{Type: model.EncumbranceTypeConstant()["sale"], Reference: "missing", BlocksTransfer: true}}
+	if err := stub.PutState(mustCompositeKey(t, stub, model.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}), mustMarshal(t, realEstate)); err != nil {
+		t.Fatal(err)
+	}
+	orphan := model.DonatingGrantee{Grantee: buyer, CreateTime: "2000-01-01 00:00:00", Donating: model.Donating{ObjectOfDonating: "missing", Donor: realEstateList[1].Proprietor}}
+	if err := stub.PutState(mustCompositeKey(t, stub, model.DonatingGranteeKey, []string{orphan.Grantee, orphan.CreateTime}), mustMarshal(t, orphan)); err != nil {
+		t.Fatal(err)
+	}
+	if err := stub.PutState(mustCompositeKey(t, stub, model.SupplyKey, []string{}), mustMarshal(t, model.Supply{TotalMinted: 1})); err != nil {
+		t.Fatal(err)
+	}
+	stub.MockTransactionEnd(txID)
+	violations := audit()
+	found := map[string]int{}
+	for _, v := range violations {
+		found[v.Invariant]++
+	}
+	for _, invariant := range []string{"encumbrance", "supply", "donatingGrantee"} {
+		if found[model.AuditInvariantConstant()[invariant]] != 1 {
+			fmt.Println("Expected one", invariant, "violation", violations)
+			t.FailNow()
+		}
+	}
+}
+
+func mustCompositeKey(t *testing.T, stub *shim.MockStub, objectType string, keys []string) string {
+	key, err := stub.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// checkMigrate migrates all the records of an object type in batches of batchSize and returns the number of batches
+func checkMigrate(t *testing.T, stub *shim.MockStub, objectType string, batchSize int) int {
+	bookmark := ""
+	for batches := 1; ; batches++ {
+		var batch model.MigrationBatch
+		resp := checkInvoke(t, stub, [][]byte{[]byte("queryMigrationBatch"), []byte(objectType), []byte(strconv.Itoa(batchSize)), []byte(bookmark)})
+		if err := json.Unmarshal(resp.Payload, &batch); err != nil || len(batch.Keys) > batchSize {
+			fmt.Println("Unexpected migration batch", err, batch)
+			t.FailNow()
+		}
+		if len(batch.Keys) > 0 {
+			args := [][]byte{[]byte("migrate"), []byte("5feceb66ffc8"), []byte(objectType)}
+			for _, key := range batch.Keys {
+				args = append(args, []byte(key))
+			}
+			var report model.MigrationReport
+			if err := json.Unmarshal(checkInvoke(t, stub, args).Payload, &report); err != nil || report.Scanned != len(batch.Keys) {
+				fmt.Println("Unexpected migration report", err, report)
+				t.FailNow()
+			}
+		}
+		if batch.Done {
+			return batches
+		}
+		bookmark = batch.Bookmark
+	}
+}
+
+// Test that version 1 records are read as the current schema and upgraded by migrate
+func Test_Migrate(t *testing.T) {
+	stub := initTest(t)
+	seller := "6b86b273ff34"
+	buyer := "4e07408562be"
+	// Records written before schema versioning, with the old status names
+	legacy := map[string][]byte{
+		mustCompositeKey(t, stub, model.SellingKey, []string{seller, "0000000000000001"}):                        []byte(`{"objectOfSale":"0000000000000001","seller":"6b86b273ff34","buyer":"","price":100,"createTime":"2020-01-01 00:00:00","salePeriod":30,"sellingStatus":"Selling"}`),
+		mustCompositeKey(t, stub, model.SellingKey, []string{seller, "0000000000000002"}):                        []byte(`{"objectOfSale":"0000000000000002","seller":"6b86b273ff34","buyer":"4e07408562be","price":200,"createTime":"2020-01-01 00:00:00","salePeriod":30,"sellingStatus":"In Progress"}`),
+		mustCompositeKey(t, stub, model.SellingKey, []string{seller, "0000000000000003"}):                        []byte(`{"objectOfSale":"0000000000000003","seller":"6b86b273ff34","buyer":"","price":300,"createTime":"2020-01-01 00:00:00","salePeriod":30,"sellingStatus":"Cancelled"}`),
+		mustCompositeKey(t, stub, model.SellingBuyKey, []string{buyer, "2020-01-01 00:00:00"}):                   []byte(`{"buyer":"4e07408562be","createTime":"2020-01-01 00:00:00","selling":{"objectOfSale":"0000000000000002","seller":"6b86b273ff34","buyer":"4e07408562be","price":200,"createTime":"2020-01-01 00:00:00","salePeriod":30,"sellingStatus":"In Progress"}}`),
+		mustCompositeKey(t, stub, model.DonatingKey, []string{seller, "0000000000000004", buyer}):                []byte(`{"objectOfDonating":"0000000000000004","donor":"6b86b273ff34","grantee":"4e07408562be","createTime":"2020-01-01 00:00:00","donatingStatus":"In Progress"}`),
+		mustCompositeKey(t, stub, model.DonatingGranteeKey, []string{buyer, "2020-01-01 00:00:00"}):              []byte(`{"grantee":"4e07408562be","createTime":"2020-01-01 00:00:00","donating":{"objectOfDonating":"0000000000000004","donor":"6b86b273ff34","grantee":"4e07408562be","createTime":"2020-01-01 00:00:00","donatingStatus":"In Progress"}}`),
+		mustCompositeKey(t, stub, model.DisputeKey, []string{seller, "0000000000000002", "2020-01-01 00:00:00"}): []byte(`{"objectOfSale":"0000000000000002","seller":"6b86b273ff34","buyer":"4e07408562be","raisedBy":"4e07408562be","createTime":"2020-01-01 00:00:00","disputeStatus":"Resolved","resolveTime":"2020-01-02 08:00:00","schemaVersion":3}`),
+	}
+	txID := nextTxID()
+	stub.MockTransactionStart(txID)
+	for key, value := range legacy {
+		if err := stub.PutState(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stub.MockTransactionEnd(txID)
+	// Readers see the current status names
+	var sellingList []model.Selling
+	if err := json.Unmarshal(checkInvoke(t, stub, [][]byte{[]byte("querySellingList"), []byte(seller)}).Payload, &sellingList); err != nil || len(sellingList) != 3 {
+		fmt.Println("Query legacy sales failed", err, sellingList)
+		t.FailNow()
+	}
+	// Legacy times were written in China Standard Time
+	createTime := "2019-12-31T16:00:00Z"
+	for i, status := range []string{"saleStart", "delivery", "cancelled"} {
+		if sellingList[i].SellingStatus != model.SellingStatusConstant()[status] || sellingList[i].SchemaVersion != model.SchemaVersion ||
+			sellingList[i].CreateTime != createTime {
+			fmt.Println("Legacy sale was not upgraded", sellingList[i])
+			t.FailNow()
+		}
+	}
+	var donatingGranteeList []model.DonatingGrantee
+	if err := json.Unmarshal(checkInvoke(t, stub, [][]byte{[]byte("queryDonatingListByGrantee"), []byte(buyer)}).Payload, &donatingGranteeList); err != nil || len(donatingGranteeList) != 1 ||
+		donatingGranteeList[0].Donating.DonatingStatus != model.DonatingStatusConstant()["donatingStart"] {
+		fmt.Println("Legacy donation was not upgraded", err, donatingGranteeList)
+		t.FailNow()
+	}
+	// Only the admin can migrate, in bounded batches of keys of the object type
+	saleKey := mustCompositeKey(t, stub, model.SellingKey, []string{seller, "0000000000000001"})
+	checkInvokeFail(t, stub, [][]byte{[]byte("migrate"), []byte(seller), []byte(model.SellingKey), []byte(saleKey)})
+	checkInvokeFail(t, stub, [][]byte{[]byte("migrate"), []byte("5feceb66ffc8"), []byte(model.SellingKey)})
+	checkInvokeFail(t, stub, [][]byte{[]byte("migrate"), []byte("5feceb66ffc8"), []byte(model.DisputeKey), []byte(saleKey)})
+	checkInvokeFail(t, stub, [][]byte{[]byte("migrate"), []byte("5feceb66ffc8"), []byte("unknown-key"), []byte(saleKey)})
+	checkInvokeFail(t, stub, [][]byte{[]byte("queryMigrationBatch"), []byte(model.SellingKey), []byte("0")})
+	checkInvokeFail(t, stub, [][]byte{[]byte("queryMigrationBatch"), []byte("unknown-key"), []byte("1")})
+	for _, objectType := range []string{model.SellingKey, model.SellingBuyKey, model.DonatingKey, model.DonatingGranteeKey, model.DisputeKey} {
+		if batches := checkMigrate(t, stub, objectType, 1); batches > 4 {
+			fmt.Println("Migration of", objectType, "took", batches, "batches")
+			t.FailNow()
+		}
+	}
+	// The buyer's and grantee's copies were replaced by index entries
+	indexed := map[string]string{
+		mustCompositeKey(t, stub, model.SellingBuyKey, []string{buyer, "2020-01-01 00:00:00"}):      mustCompositeKey(t, stub, model.SellingBuyerIndexKey, []string{buyer, seller, "0000000000000002"}),
+		mustCompositeKey(t, stub, model.DonatingGranteeKey, []string{buyer, "2020-01-01 00:00:00"}): mustCompositeKey(t, stub, model.DonatingGranteeIndexKey, []string{buyer, seller, "0000000000000004"}),
+	}
+	for key, indexKey := range indexed {
+		if value, _ := stub.GetState(key); value != nil {
+			fmt.Println("Copy was not removed", key)
+			t.FailNow()
+		}
+		if value, _ := stub.GetState(indexKey); value == nil {
+			fmt.Println("Copy was not indexed", indexKey)
+			t.FailNow()
+		}
+	}
+	var sellingBuyList []model.SellingBuy
+	if err := json.Unmarshal(checkInvoke(t, stub, [][]byte{[]byte("querySellingListByBuyer"), []byte(buyer)}).Payload, &sellingBuyList); err != nil || len(sellingBuyList) != 1 ||
+		sellingBuyList[0].CreateTime != createTime || sellingBuyList[0].Selling.ObjectOfSale != "0000000000000002" {
+		fmt.Println("Migrated purchase was not listed", err, sellingBuyList)
+		t.FailNow()
+	}
+	donatingGranteeList = nil
+	if err := json.Unmarshal(checkInvoke(t, stub, [][]byte{[]byte("queryDonatingListByGrantee"), []byte(buyer)}).Payload, &donatingGranteeList); err != nil || len(donatingGranteeList) != 1 ||
+		donatingGranteeList[0].CreateTime != createTime {
+		fmt.Println("Migrated donation was not listed", err, donatingGranteeList)
+		t.FailNow()
+	}
+	// Sales written before the status index were indexed
+	if value, _ := stub.GetState(mustCompositeKey(t, stub, model.SellingStatusIndexKey, []string{model.SellingStatusConstant()["saleStart"], seller, "0000000000000001"})); value == nil {
+		fmt.Println("Legacy sale was not indexed by status")
+		t.FailNow()
+	}
+	// The other stored records are now current, and disputes, keyed by their creation time, moved to the key of the upgraded time
+	moved := map[string]string{
+		mustCompositeKey(t, stub, model.DisputeKey, []string{seller, "0000000000000002", "2020-01-01 00:00:00"}): mustCompositeKey(t, stub, model.DisputeKey, []string{seller, "0000000000000002", createTime}),
+	}
+	for key := range legacy {
+		if _, ok := indexed[key]; ok {
+			continue
+		}
+		if newKey, ok := moved[key]; ok {
+			if value, _ := stub.GetState(key); value != nil {
+				fmt.Println("Record was not moved", key)
+				t.FailNow()
+			}
+			key = newKey
+		}
+		value, _ := stub.GetState(key)
+		if _, changed, err := model.UpgradeRecord(model.SellingKey, value); err != nil || changed || !strings.Contains(string(value), `"createTime":"`+createTime+`"`) {
+			fmt.Println("Record was not migrated", key, string(value))
+			t.FailNow()
+		}
+	}
+	value, _ := stub.GetState(moved[mustCompositeKey(t, stub, model.DisputeKey, []string{seller, "0000000000000002", "2020-01-01 00:00:00"})])
+	if !strings.Contains(string(value), `"resolveTime":"2020-01-02T00:00:00Z"`) {
+		fmt.Println("Dispute resolution time was not migrated", string(value))
+		t.FailNow()
+	}
+}
+
+// Test the contract metadata and the decoding of positional arguments into typed requests
+func Test_Metadata(t *testing.T) {
+	stub := initTest(t)
+	var metadata contract.Metadata
+	if err := json.Unmarshal(checkInvoke(t, stub, [][]byte{[]byte("getMetadata")}).Payload, &metadata); err != nil {
+		fmt.Println("Get metadata failed", err)
+		t.FailNow()
+	}
+	transactions := map[string]contract.TransactionMetadata{}
+	for _, tx := range metadata.Contracts["BlockChainRealEstate"].Transactions {
+		transactions[tx.Name] = tx
+	}
+	createSelling, ok := transactions["createSelling"]
+	if !ok || len(transactions) != 55 || createSelling.Tag[0] != "submit" || transactions["querySellingList"].Tag[0] != "evaluate" {
+		fmt.Println("Unexpected transactions", transactions)
+		t.FailNow()
+	}
+	// Positional parameters in order, then the private terms passed in the transient data, with the installments as a list of objects
+	var names []string
+	for _, p := range createSelling.Parameters {
+		names = append(names, p.Name)
+	}
+	if fmt.Sprint(names) != "[objectOfSale seller salePeriod onMissedPayment gracePeriod agent price deposit installments]" ||
+		!createSelling.Parameters[2].Required || createSelling.Parameters[3].Required ||
+		!createSelling.Parameters[6].Transient || !createSelling.Parameters[6].Required || createSelling.Parameters[7].Required || createSelling.Parameters[5].Transient ||
+		createSelling.Parameters[6].Schema.Type != "number" || createSelling.Parameters[8].Schema.Items.Properties["dueDays"].Type != "integer" {
+		fmt.Println("Unexpected createSelling parameters", createSelling.Parameters)
+		t.FailNow()
+	}
+	if createSelling.Returns == nil || createSelling.Returns.Properties["sellingStatus"].Type != "string" {
+		fmt.Println("Unexpected createSelling returns", createSelling.Returns)
+		t.FailNow()
+	}
+	// The Fabric contract API name is served too
+	checkInvoke(t, stub, [][]byte{[]byte("org.hyperledger.fabric:GetMetadata")})
+	// Arguments are checked against the request types
+	realEstateList := checkCreateRealEstate(stub, t)
+	for _, c := range []struct {
+		args      []string
+		transient map[string][]byte
+	}{
+		{[]string{"createSelling", realEstateList[0].RealEstateID, realEstateList[0].Proprietor}, sellingTransient("50")},
+		{[]string{"createSelling", realEstateList[0].RealEstateID, realEstateList[0].Proprietor, ""}, sellingTransient("50")},
+		{[]string{"createSelling", realEstateList[0].RealEstateID, realEstateList[0].Proprietor, "thirty"}, sellingTransient("50")},
+		{[]string{"createSelling", realEstateList[0].RealEstateID, realEstateList[0].Proprietor, "30", "forfeit", "0", "", "extra"}, sellingTransient("50")},
+		{[]string{"createSelling", realEstateList[0].RealEstateID, realEstateList[0].Proprietor, "30"}, nil},
+		{[]string{"createSelling", realEstateList[0].RealEstateID, realEstateList[0].Proprietor, "30"}, map[string][]byte{"price": []byte(`"fifty"`)}},
+		{[]string{"createSellingByBuy", realEstateList[0].RealEstateID, realEstateList[0].Proprietor, "4e07408562be", "extra"}, nil},
+		{[]string{"addDisputeEvidence", realEstateList[0].RealEstateID, realEstateList[0].Proprietor, "4e07408562be"}, nil},
+		{[]string{"unknown"}, nil},
+	} {
+		var bytes [][]byte
+		for _, arg := range c.args {
+			bytes = append(bytes, []byte(arg))
+		}
+		checkInvokeFail(t, stub, bytes, c.transient)
+	}
+	// A complete payment schedule decodes into the installments list
+	checkInvoke(t, stub, [][]byte{[]byte("createSelling"), []byte(realEstateList[0].RealEstateID), []byte(realEstateList[0].Proprietor), []byte("30"), []byte("forfeit"), []byte("0"), []byte("")}, sellingTransient("50", "10", "40", "5"))
+}
+
+// Test the chaincode server mode by connecting to it as a peer and querying the contract metadata
+func Test_ChaincodeServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := server.Config{Address: listener.Addr().String(), ChaincodeID: "realty:1"}
+	go server.Serve(listener, config, new(BlockChainRealEstate))
+	conn, err := grpc.Dial(config.Address, grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}, "/protos.Chaincode/Connect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recv := func(expected pb.ChaincodeMessage_Type) *pb.ChaincodeMessage {
+		msg := new(pb.ChaincodeMessage)
+		if err := stream.RecvMsg(msg); err != nil || msg.Type != expected {
+			fmt.Println("Expected", expected, "from the chaincode, got", msg.Type, err)
+			t.FailNow()
+		}
+		return msg
+	}
+	// The chaincode registers under its ID, then the peer readies it
+	var chaincodeID pb.ChaincodeID
+	if err := proto.Unmarshal(recv(pb.ChaincodeMessage_REGISTER).Payload, &chaincodeID); err != nil || chaincodeID.Name != "realty:1" {
+		fmt.Println("Unexpected chaincode ID", chaincodeID.Name, err)
+		t.FailNow()
+	}
+	for _, msgType := range []pb.ChaincodeMessage_Type{pb.ChaincodeMessage_REGISTERED, pb.ChaincodeMessage_READY} {
+		if err := stream.SendMsg(&pb.ChaincodeMessage{Type: msgType}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	input, err := proto.Marshal(&pb.ChaincodeInput{Args: [][]byte{[]byte("getMetadata")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.SendMsg(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_TRANSACTION, Txid: nextTxID(), ChannelId: "appchannel", Payload: input}); err != nil {
+		t.Fatal(err)
+	}
+	var res pb.Response
+	if err := proto.Unmarshal(recv(pb.ChaincodeMessage_COMPLETED).Payload, &res); err != nil || res.Status != shim.OK {
+		fmt.Println("Query over the chaincode server failed", res.Message, err)
+		t.FailNow()
+	}
+	var metadata contract.Metadata
+	if err := json.Unmarshal(res.Payload, &metadata); err != nil || len(metadata.Contracts["BlockChainRealEstate"].Transactions) == 0 {
+		fmt.Println("Unexpected metadata", err, string(res.Payload))
+		t.FailNow()
+	}
+}
+
+// Test who may cancel a sale in each status and the cancellation penalties
+func Test_Cancellation(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	admin := "5feceb66ffc8"
+	seller := realEstateList[0].Proprietor
+	buyer := realEstateList[2].Proprietor
+	cancel := func(realEstate model.RealEstate, buyer string, operator string, reason string) [][]byte {
+		return [][]byte{
+			[]byte("updateSelling"),
+			[]byte(realEstate.RealEstateID),
+			[]byte(realEstate.Proprietor),
+			[]byte(buyer),
+			[]byte("cancelled"),
+			[]byte(operator),
+			[]byte(reason),
+		}
+	}
+	// Before purchase only the seller or an admin may cancel, with a reason
+	checkInvoke(t, stub, [][]byte{
+		[]byte("createSelling"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+		[]byte("30"),
+	}, sellingTransient("50000"))
+	checkInvokeFail(t, stub, cancel(realEstateList[0], "", buyer, "Changed my mind"))
+	checkInvokeFail(t, stub, cancel(realEstateList[0], "", seller, ""))
+	var selling model.Selling
+	if err := json.Unmarshal(checkInvoke(t, stub, cancel(realEstateList[0], "", seller, "Changed my mind")).Payload, &selling); err != nil ||
+		selling.SellingStatus != model.SellingStatusConstant()["cancelled"] || selling.CancelledBy != seller || selling.CancelReason != "Changed my mind" {
+		fmt.Println("Cancellation was not recorded", err, selling)
+		t.FailNow()
+	}
+	// Penalties are set by an admin, for statuses where the sale has a buyer
+	setPenalty := func(accountId string, canceller string, status string, paidBy string, rate string) [][]byte {
+		return [][]byte{[]byte("setCancellationPenalty"), []byte(accountId), []byte(canceller), []byte(status), []byte(paidBy), []byte(rate)}
+	}
+	checkInvokeFail(t, stub, setPenalty(seller, "buyer", "delivery", "buyer", "10"))
+	checkInvokeFail(t, stub, setPenalty(admin, "seller", "saleStart", "seller", "10"))
+	checkInvokeFail(t, stub, setPenalty(admin, "seller", "delivery", "seller", "10"))
+	checkInvokeFail(t, stub, setPenalty(admin, "buyer", "delivery", "buyer", "150"))
+	checkInvoke(t, stub, setPenalty(admin, "buyer", "delivery", "buyer", "10"))
+	checkInvoke(t, stub, setPenalty(admin, "admin", "delivery", "seller", "5"))
+	var penaltyList []model.CancellationPenalty
+	if err := json.Unmarshal(checkInvoke(t, stub, [][]byte{[]byte("queryCancellationPenaltyList")}).Payload, &penaltyList); err != nil || len(penaltyList) != 2 {
+		fmt.Println("Query cancellation penalties failed", err, penaltyList)
+		t.FailNow()
+	}
+	// During delivery the seller may not cancel; the buyer loses 10% of the price to the seller
+	checkSellAndBuy(t, stub, realEstateList[1], buyer, "100000")
+	checkInvokeFail(t, stub, cancel(realEstateList[1], buyer, seller, "Found a better offer"))
+	if err := json.Unmarshal(checkInvoke(t, stub, cancel(realEstateList[1], buyer, buyer, "Financing fell through")).Payload, &selling); err != nil ||
+		selling.PenaltyPaidBy != "buyer" || checkQuerySelling(t, stub, seller, realEstateList[1].RealEstateID).Penalty != 10000 {
+		fmt.Println("Buyer penalty was not recorded", err, selling)
+		t.FailNow()
+	}
+	if balance := checkQueryAccount(t, stub, buyer).Balance; balance != 4990000 {
+		fmt.Println("Buyer should have been refunded 90000, balance is", balance)
+		t.FailNow()
+	}
+	if balance := checkQueryAccount(t, stub, seller).Balance; balance != 5010000 {
+		fmt.Println("Seller should have received the 10000 penalty, balance is", balance)
+		t.FailNow()
+	}
+	// An admin may cancel at any time; here the seller compensates the buyer with 5% of the price
+	other := realEstateList[3].Proprietor
+	checkSellAndBuy(t, stub, realEstateList[2], other, "200000")
+	if err := json.Unmarshal(checkInvoke(t, stub, cancel(realEstateList[2], other, admin, "Title defect")).Payload, &selling); err != nil ||
+		selling.PenaltyPaidBy != "seller" || checkQuerySelling(t, stub, realEstateList[2].Proprietor, realEstateList[2].RealEstateID).Penalty != 10000 || selling.CancelledBy != admin {
+		fmt.Println("Seller penalty was not recorded", err, selling)
+		t.FailNow()
+	}
+	if balance := checkQueryAccount(t, stub, other).Balance; balance != 5010000 {
+		fmt.Println("Buyer should have been refunded 210000, balance is", balance)
+		t.FailNow()
+	}
+	if balance := checkQueryAccount(t, stub, buyer).Balance; balance != 4980000 {
+		fmt.Println("Seller should have paid the 10000 penalty, balance is", balance)
+		t.FailNow()
+	}
+	var violations []model.AuditViolation
+	resp := invokeAs(t, stub, "JDMSP", [][]byte{[]byte("auditLedger"), []byte(admin)})
+	if err := json.Unmarshal(resp.Payload, &violations); resp.Status != shim.OK || err != nil || len(violations) != 0 {
+		fmt.Println("Audit after cancellations failed", resp.Message, err, violations)
+		t.FailNow()
+	}
+}
+
+// Test that the state machines declare exactly the expected transitions and refuse every other one
+func Test_StateMachine(t *testing.T) {
+	selling := model.SellingStatusConstant()
+	donating := model.DonatingStatusConstant()
+	tests := []struct {
+		machine *statemachine.Machine
+		edges   [][3]string // From, event, to
+	}{
+		{api.SellingMachine, [][3]string{
+			{statemachine.Initial, "create", selling["saleStart"]},
+			{selling["saleStart"], "buy", selling["delivery"]},
+			{selling["saleStart"], "buy", selling["payment"]},
+			{selling["saleStart"], "cancel", selling["cancelled"]},
+			{selling["saleStart"], "expire", selling["expired"]},
+			{selling["saleStart"], "courtOrder", selling["cancelled"]},
+			{selling["payment"], "payInstallment", selling["delivery"]},
+			{selling["payment"], "payInstallment", selling["payment"]},
+			{selling["payment"], "missInstallment", selling["payment"]},
+			{selling["payment"], "missInstallment", selling["forfeited"]},
+			{selling["payment"], "cancel", selling["cancelled"]},
+			{selling["payment"], "courtOrder", selling["cancelled"]},
+			{selling["delivery"], "confirm", selling["done"]},
+			{selling["delivery"], "cancel", selling["cancelled"]},
+			{selling["delivery"], "expire", selling["expired"]},
+			{selling["delivery"], "raiseDispute", selling["dispute"]},
+			{selling["delivery"], "courtOrder", selling["cancelled"]},
+			{selling["dispute"], "resolve", selling["cancelled"]},
+			{selling["dispute"], "resolve", selling["done"]},
+			{selling["dispute"], "courtOrder", selling["cancelled"]},
+		}},
+		{api.DonatingMachine, [][3]string{
+			{statemachine.Initial, "create", donating["donatingStart"]},
+			{donating["donatingStart"], "confirm", donating["done"]},
+			{donating["donatingStart"], "cancel", donating["cancelled"]},
+			{donating["donatingStart"], "courtOrder", donating["cancelled"]},
+		}},
+	}
+	for _, test := range tests {
+		table := test.machine.Table()
+		if len(table) != len(test.edges) {
+			fmt.Println(test.machine.Name(), "has", len(table), "transitions, expected", len(test.edges))
+			t.FailNow()
+		}
+		allowed := map[[2]string]bool{}
+		for i, edge := range test.edges {
+			if table[i].From != edge[0] || table[i].Event != edge[1] || table[i].To != edge[2] {
+				fmt.Println(test.machine.Name(), "transition", i, "is", table[i], "expected", edge)
+				t.FailNow()
+			}
+			allowed[[2]string{edge[0], edge[1]}] = true
+		}
+		// Every other event in every state is refused with a TransitionError
+		for _, from := range append(test.machine.States(), statemachine.Initial) {
+			for _, event := range test.machine.Events() {
+				if allowed[[2]string{from, event}] {
+					continue
+				}
+				if _, err := test.machine.Next(from, event, nil); err == nil {
+					fmt.Println(test.machine.Name(), "allows", event, "in status", from)
+					t.FailNow()
+				} else if _, ok := err.(*statemachine.TransitionError); !ok {
+					fmt.Println(test.machine.Name(), "refused", event, "in status", from, "with", err)
+					t.FailNow()
+				}
+			}
+		}
+		var diagram statemachine.Diagram
+		if err := json.Unmarshal(checkInvoke(t, initTest(t), [][]byte{[]byte("queryStateMachine"), []byte(test.machine.Name())}).Payload, &diagram); err != nil ||
+			len(diagram.Transitions) != len(test.edges) || !strings.Contains(diagram.Mermaid, "[*] --> ") || !strings.HasPrefix(diagram.Dot, "digraph") {
+			fmt.Println("Query state machine", test.machine.Name(), "failed", err, diagram)
+			t.FailNow()
+		}
+	}
+	// Handlers refuse invalid transitions with the same error
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	checkInvoke(t, stub, [][]byte{
+		[]byte("createSelling"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(realEstateList[0].Proprietor),
+		[]byte("30"),
+	}, sellingTransient("50000"))
+	for _, args := range [][][]byte{
+		{[]byte("updateSelling"), []byte(realEstateList[0].RealEstateID), []byte(realEstateList[0].Proprietor), []byte(""), []byte("done")},
+		{[]byte("raiseDispute"), []byte(realEstateList[0].RealEstateID), []byte(realEstateList[0].Proprietor), []byte(realEstateList[0].Proprietor), []byte("Late")},
+		{[]byte("payInstallment"), []byte(realEstateList[0].RealEstateID), []byte(realEstateList[0].Proprietor), []byte(realEstateList[2].Proprietor)},
+	} {
+		if e := checkInvokeError(t, stub, args, errcode.Conflict); !strings.HasPrefix(e.Message, "Invalid selling transition") {
+			fmt.Println("Invalid transition", string(args[0]), "returned", e.Message)
+			t.FailNow()
+		}
+	}
+	checkInvokeFail(t, stub, [][]byte{[]byte("queryStateMachine"), []byte("renting")})
+	checkGuards(t)
+}
+
+// checkGuards runs each guard of SellingMachine once where it accepts the transition and once where it rejects it,
+// checking the status the sale ends in. A guard of a pair (buy, resolve) rejects when the other edge is taken.
+func checkGuards(t *testing.T) {
+	selling := model.SellingStatusConstant()
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	seller, buyer, other := realEstateList[0].Proprietor, realEstateList[2].Proprietor, realEstateList[3].Proprietor
+	arbitrator := "e7f6c011776e"
+	start := time.Now()
+	checkStatus := func(guard string, realEstate model.RealEstate, status string) {
+		if got := checkQuerySelling(t, stub, realEstate.Proprietor, realEstate.RealEstateID).SellingStatus; got != status {
+			fmt.Println("Guard", guard, "left the sale in", got, "expected", status)
+			t.FailNow()
+		}
+	}
+	buy := func(realEstate model.RealEstate, buyer string) {
+		checkInvokeAt(t, stub, start, [][]byte{[]byte("createSellingByBuy"), []byte(realEstate.RealEstateID), []byte(realEstate.Proprietor), []byte(buyer)})
+	}
+	cancel := func(realEstate model.RealEstate, operator string) [][]byte {
+		return [][]byte{[]byte("updateSelling"), []byte(realEstate.RealEstateID), []byte(realEstate.Proprietor), []byte(buyer), []byte("cancelled"), []byte(operator), []byte("Changed my mind")}
+	}
+	pay := func(realEstate model.RealEstate, days int) {
+		checkInvokeAt(t, stub, start.AddDate(0, 0, days), [][]byte{[]byte("payInstallment"), []byte(realEstate.RealEstateID), []byte(realEstate.Proprietor), []byte(buyer)})
+	}
+	miss := func(realEstate model.RealEstate, days int) {
+		checkInvokeAt(t, stub, start.AddDate(0, 0, days), [][]byte{[]byte("processMissedInstallment"), []byte(realEstate.RealEstateID), []byte(realEstate.Proprietor)})
+	}
+	resolve := func(realEstate model.RealEstate, buyer string, decision string) {
+		checkInvoke(t, stub, [][]byte{[]byte("raiseDispute"), []byte(realEstate.RealEstateID), []byte(realEstate.Proprietor), []byte(buyer), []byte("The roof leaks")})
+		checkInvoke(t, stub, [][]byte{[]byte("resolveDispute"), []byte(realEstate.RealEstateID), []byte(realEstate.Proprietor), []byte(arbitrator), []byte(decision), []byte(""), []byte(""), []byte("")})
+	}
+	// A sale paid in full, one paid in installments with a grace period and one forfeiting the deposit on a missed deadline
+	checkInvoke(t, stub, [][]byte{[]byte("createSelling"), []byte(realEstateList[0].RealEstateID), []byte(seller), []byte("90")}, sellingTransient("100000"))
+	checkInvoke(t, stub, [][]byte{[]byte("createSelling"), []byte(realEstateList[1].RealEstateID), []byte(seller), []byte("90"), []byte("grace"), []byte("10"), []byte("")},
+		sellingTransient("300000", "30000", "120000", "30", "150000", "60"))
+	checkInvoke(t, stub, [][]byte{[]byte("createSelling"), []byte(realEstateList[3].RealEstateID), []byte(other), []byte("90"), []byte("forfeit"), []byte("0"), []byte("")},
+		sellingTransient("200000", "20000", "80000", "30", "100000", "60"))
+	// cancellationAllowed rejects a buyer cancelling before purchase
+	checkInvokeError(t, stub, cancel(realEstateList[0], buyer), errcode.Forbidden)
+	checkStatus("cancellationAllowed", realEstateList[0], selling["saleStart"])
+	// withoutSchedule accepts and withSchedule rejects a sale paid in full
+	buy(realEstateList[0], buyer)
+	checkStatus("withoutSchedule", realEstateList[0], selling["delivery"])
+	// withoutSchedule rejects and withSchedule accepts a sale with a payment schedule
+	buy(realEstateList[1], buyer)
+	checkStatus("withSchedule", realEstateList[1], selling["payment"])
+	buy(realEstateList[3], buyer)
+	// cancellationAllowed accepts the buyer cancelling after payment
+	checkInvoke(t, stub, cancel(realEstateList[0], buyer))
+	checkStatus("cancellationAllowed", realEstateList[0], selling["cancelled"])
+	// finalInstallment rejects the first of two installments
+	pay(realEstateList[1], 10)
+	checkStatus("finalInstallment", realEstateList[1], selling["payment"])
+	// graceAvailable accepts a first missed deadline under the grace policy
+	miss(realEstateList[1], 61)
+	checkStatus("graceAvailable", realEstateList[1], selling["payment"])
+	// finalInstallment accepts the last installment
+	pay(realEstateList[1], 65)
+	checkStatus("finalInstallment", realEstateList[1], selling["delivery"])
+	// graceAvailable rejects a missed deadline under the forfeit policy
+	miss(realEstateList[3], 31)
+	checkStatus("graceAvailable", realEstateList[3], selling["forfeited"])
+	// saleUndone rejects and saleUpheld accepts completing the sale
+	resolve(realEstateList[1], buyer, "complete")
+	checkStatus("saleUpheld", realEstateList[1], selling["done"])
+	// saleUndone accepts and saleUpheld rejects refunding the buyer
+	checkInvoke(t, stub, [][]byte{[]byte("createSelling"), []byte(realEstateList[2].RealEstateID), []byte(buyer), []byte("90")}, sellingTransient("100000"))
+	checkInvoke(t, stub, [][]byte{[]byte("createSellingByBuy"), []byte(realEstateList[2].RealEstateID), []byte(buyer), []byte(other)})
+	resolve(realEstateList[2], other, "refund")
+	checkStatus("saleUndone", realEstateList[2], selling["cancelled"])
+	// salePeriodOver rejects expiring a sale early, whichever party asks, and accepts it once the sale period is over
+	expire := func(operator string) [][]byte {
+		return [][]byte{[]byte("updateSelling"), []byte(realEstateList[0].RealEstateID), []byte(seller), []byte(buyer), []byte("expired"), []byte(operator), []byte("")}
+	}
+	checkInvoke(t, stub, [][]byte{[]byte("createSelling"), []byte(realEstateList[0].RealEstateID), []byte(seller), []byte("30")}, sellingTransient("100000"))
+	checkInvokeError(t, stub, expire(seller), errcode.Conflict)
+	checkStatus("salePeriodOver", realEstateList[0], selling["saleStart"])
+	buy(realEstateList[0], buyer)
+	checkInvokeError(t, stub, expire(seller), errcode.Conflict)
+	checkInvokeError(t, stub, expire(buyer), errcode.Conflict)
+	checkStatus("salePeriodOver", realEstateList[0], selling["delivery"])
+	checkInvokeAt(t, stub, start.AddDate(0, 0, 31), expire(seller))
+	checkStatus("salePeriodOver", realEstateList[0], selling["expired"])
+}
+
+// Test that failures carry machine-readable error codes
+func Test_ErrorCodes(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	seller := realEstateList[0].Proprietor
+	buyer := realEstateList[2].Proprietor
+	checkInvokeError(t, stub, [][]byte{[]byte("createSelling"), []byte(realEstateList[0].RealEstateID), []byte(seller)}, errcode.InvalidArgument, sellingTransient(""))
+	checkInvokeError(t, stub, [][]byte{[]byte("createSelling"), []byte(realEstateList[0].RealEstateID), []byte(seller), []byte("30")}, errcode.InvalidArgument, sellingTransient("a lot"))
+	checkInvokeError(t, stub, [][]byte{[]byte("sellEverything")}, errcode.NotFound)
+	checkInvokeError(t, stub, [][]byte{[]byte("createSelling"), []byte("missing"), []byte(seller), []byte("30")}, errcode.NotFound, sellingTransient("50000"))
+	checkInvokeError(t, stub, [][]byte{[]byte("auditLedger"), []byte(seller)}, errcode.Forbidden)
+	checkInvoke(t, stub, [][]byte{[]byte("createSelling"), []byte(realEstateList[0].RealEstateID), []byte(seller), []byte("30")}, sellingTransient("6000000"))
+	checkInvokeError(t, stub, [][]byte{[]byte("createSelling"), []byte(realEstateList[0].RealEstateID), []byte(seller), []byte("30")}, errcode.Conflict, sellingTransient("50000"))
+	checkInvokeError(t, stub, [][]byte{[]byte("createSellingByBuy"), []byte(realEstateList[0].RealEstateID), []byte(seller), []byte(buyer)}, errcode.InsufficientFunds)
+	checkInvokeError(t, stub, [][]byte{
+		[]byte("updateSelling"), []byte(realEstateList[0].RealEstateID), []byte(seller), []byte(""), []byte("cancelled"), []byte(buyer), []byte("Too expensive"),
+	}, errcode.Forbidden)
+}
+
+// Test chaincode functions called with a single JSON request document
+func Test_RequestDocument(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	seller := realEstateList[0].Proprietor
+	buyer := realEstateList[2].Proprietor
+	document := func(function string, request string) [][]byte {
+		return [][]byte{[]byte(function), []byte(request)}
+	}
+	// The private terms travel in the transient data and stay out of the transaction payload
+	var selling model.Selling
+	if err := json.Unmarshal(checkInvoke(t, stub, document("createSelling", fmt.Sprintf(`{"schemaVersion":1,"objectOfSale":%q,"seller":%q,"salePeriod":90,
+		"onMissedPayment":"grace","gracePeriod":10}`, realEstateList[0].RealEstateID, seller)),
+		sellingTransient("300000", "30000", "120000", "30", "150000", "60")).Payload, &selling); err != nil || selling.Price != 0 || len(selling.Installments) != 0 {
+		fmt.Println("Create selling from a request document exposed its private terms", err, selling)
+		t.FailNow()
+	}
+	if selling = checkQuerySelling(t, stub, seller, realEstateList[0].RealEstateID); selling.Price != 300000 || len(selling.Installments) != 2 || selling.Installments[1].Amount != 150000 {
+		fmt.Println("Create selling from a request document failed", selling)
+		t.FailNow()
+	}
+	// Private terms are refused in the document, and the price is required in the transient data
+	checkInvokeError(t, stub, document("createSelling", fmt.Sprintf(`{"schemaVersion":1,"objectOfSale":%q,"seller":%q,"price":150000,"salePeriod":30}`,
+		realEstateList[1].RealEstateID, seller)), errcode.InvalidArgument, sellingTransient("150000"))
+	checkInvokeError(t, stub, document("createSelling", fmt.Sprintf(`{"schemaVersion":1,"objectOfSale":%q,"seller":%q,"salePeriod":30}`,
+		realEstateList[1].RealEstateID, seller)), errcode.InvalidArgument)
+	// Positional calls in the layout older clients used, with the price after the seller, are refused with a message naming the moved fields
+	for _, args := range [][]string{
+		{"createSelling", realEstateList[1].RealEstateID, seller, "150000", "30"},
+		{"createSelling", realEstateList[1].RealEstateID, seller, "150000.5", "30", "15000", "grace", "10"},
+		{"createSelling", realEstateList[1].RealEstateID, seller, "150000", "30", "15000", "forfeit", "", "", "135000", "30"},
+	} {
+		var legacy [][]byte
+		for _, v := range args {
+			legacy = append(legacy, []byte(v))
+		}
+		if e := checkInvokeError(t, stub, legacy, errcode.InvalidArgument, sellingTransient("150000")); !strings.Contains(e.Message, "price, deposit, installments") {
+			fmt.Println("A positional call with the price in its old place was not refused explicitly", args, e.Message)
+			t.FailNow()
+		}
+	}
+	// Fields that may be empty can be left out, instead of being passed as empty strings
+	checkInvoke(t, stub, document("createSelling", fmt.Sprintf(`{"schemaVersion":1,"objectOfSale":%q,"seller":%q,"salePeriod":30}`,
+		realEstateList[1].RealEstateID, seller)), sellingTransient("1.5e5"))
+	checkInvoke(t, stub, document("updateSelling", fmt.Sprintf(`{"schemaVersion":1,"objectOfSale":%q,"seller":%q,"status":"cancelled","operator":%q,"reason":"Withdrawn"}`,
+		realEstateList[1].RealEstateID, seller, seller)))
+	checkInvoke(t, stub, document("queryCancellationPenaltyList", `{"schemaVersion":1}`))
+	var metadata contract.Metadata
+	if err := json.Unmarshal(checkInvoke(t, stub, [][]byte{[]byte("getMetadata")}).Payload, &metadata); err != nil ||
+		metadata.Info.RequestSchemaVersion != contract.RequestSchemaVersion {
+		fmt.Println("Metadata does not give the request schema version", err, metadata.Info)
+		t.FailNow()
+	}
+	for _, request := range []string{
+		`{"accountIds":[]}`,
+		`{"schemaVersion":2}`,
+		`{"schemaVersion":1,"accountIds":"5feceb66ffc8"}`,
+		`{"schemaVersion":1,"accountId":"5feceb66ffc8"}`,
+		`{"schemaVersion":1,`,
+	} {
+		if e := checkInvokeError(t, stub, document("queryAccountList", request), errcode.InvalidArgument); e.Message == "" {
+			t.FailNow()
+		}
+	}
+	for _, request := range []string{
+		fmt.Sprintf(`{"schemaVersion":1,"objectOfSale":%q,"seller":%q}`, realEstateList[0].RealEstateID, seller),
+		fmt.Sprintf(`{"schemaVersion":1,"objectOfSale":%q,"seller":%q,"buyer":""}`, realEstateList[0].RealEstateID, seller),
+		fmt.Sprintf(`{"schemaVersion":1,"objectOfSale":%q,"seller":%q,"buyer":%q,"price":1}`, realEstateList[0].RealEstateID, seller, buyer),
+	} {
+		checkInvokeError(t, stub, document("createSellingByBuy", request), errcode.InvalidArgument)
+	}
+	checkInvoke(t, stub, document("createSellingByBuy", fmt.Sprintf(`{"schemaVersion":1,"objectOfSale":%q,"seller":%q,"buyer":%q}`,
+		realEstateList[0].RealEstateID, seller, buyer)))
+}
+
+// Test registrar-assigned real estate IDs and their uniqueness
+func Test_RealEstateID(t *testing.T) {
+	stub := initTest(t)
+	create := func(realEstateID string) [][]byte {
+		return [][]byte{[]byte("createRealEstate"), []byte("5feceb66ffc8"), []byte("6b86b273ff34"), []byte("80"), []byte("60"), []byte(realEstateID)}
+	}
+	var realEstate model.RealEstate
+	if err := json.Unmarshal(checkInvoke(t, stub, create("LOT-2024/0001")).Payload, &realEstate); err != nil || realEstate.RealEstateID != "LOT-2024/0001" {
+		fmt.Println("Registrar-assigned ID was not used", err, realEstate)
+		t.FailNow()
+	}
+	// The ID stays unique whoever owns the real estate
+	checkInvokeError(t, stub, [][]byte{[]byte("createRealEstate"), []byte("5feceb66ffc8"), []byte("4e07408562be"), []byte("80"), []byte("60"), []byte("LOT-2024/0001")}, errcode.Conflict)
+	checkInvokeError(t, stub, create("LOT 2024"), errcode.InvalidArgument)
+	checkInvokeError(t, stub, create("-LOT"), errcode.InvalidArgument)
+	// A generated ID that is already taken gets a suffix
+	txID := strings.Repeat("a", 64)
+	checkInvoke(t, stub, create(txID[:16]))
+	resp := stub.MockInvoke(txID, create(""))
+	if err := json.Unmarshal(resp.Payload, &realEstate); resp.Status != shim.OK || err != nil || realEstate.RealEstateID != txID[:16]+"-1" {
+		fmt.Println("Generated ID collision was not avoided", resp.Message, err, realEstate)
+		t.FailNow()
+	}
+	// Records created before the index have no entry, so their IDs are only reserved once the real estate is migrated
+	key, _ := stub.CreateCompositeKey(model.RealEstateIDKey, []string{"LOT-2024/0001"})
+	stub.MockTransactionStart(nextTxID())
+	if err := stub.DelState(key); err != nil {
+		t.FailNow()
+	}
+	stub.MockTransactionEnd("")
+	checkInvoke(t, stub, create("LOT-2024/0001"))
+	checkMigrate(t, stub, model.RealEstateKey, 100)
+	checkInvokeError(t, stub, create("LOT-2024/0001"), errcode.Conflict)
+	// The index records the proprietor, so that a real estate is read by ID alone; entries written before it did are
+	// still found, and migrate records their proprietor
+	index := func() model.RealEstateIndex {
+		var index model.RealEstateIndex
+		value, _ := stub.GetState(key)
+		if err := json.Unmarshal(value, &index); err != nil {
+			t.Fatal(err)
+		}
+		return index
+	}
+	if proprietor := index().Proprietor; proprietor != "6b86b273ff34" {
+		fmt.Println("The ID index does not record the proprietor", proprietor)
+		t.FailNow()
+	}
+	stub.MockTransactionStart(nextTxID())
+	if err := stub.PutState(key, []byte(`{"realEstateId":"LOT-2024/0001","schemaVersion":4}`)); err != nil {
+		t.Fatal(err)
+	}
+	stub.MockTransactionEnd("")
+	checkInvoke(t, stub, [][]byte{[]byte("queryRealEstate"), []byte("LOT-2024/0001")})
+	checkMigrate(t, stub, model.RealEstateKey, 100)
+	if proprietor := index().Proprietor; proprietor != "6b86b273ff34" {
+		fmt.Println("Migrate did not record the proprietor in the ID index", proprietor)
+		t.FailNow()
+	}
+	// An entry whose real estate is missing is reported
+	stub.MockTransactionStart(nextTxID())
+	if err := stub.PutState(key, []byte(`{"realEstateId":"LOT-2024/0001","proprietor":"4e07408562be","schemaVersion":4}`)); err != nil {
+		t.Fatal(err)
+	}
+	stub.MockTransactionEnd("")
+	checkInvokeError(t, stub, [][]byte{[]byte("queryRealEstate"), []byte("LOT-2024/0001")}, errcode.NotFound)
+}
+
+// Test registering real estates in batches
+func Test_CreateRealEstateBatch(t *testing.T) {
+	stub := initTest(t)
+	batch := func(accountId string, items ...string) [][]byte {
+		args := [][]byte{[]byte("createRealEstateBatch"), []byte(accountId)}
+		for _, item := range items {
+			args = append(args, []byte(item))
+		}
+		return args
+	}
+	countOwned := func(proprietor string) int {
+		var list []model.RealEstate
+		if err := json.Unmarshal(checkInvoke(t, stub, [][]byte{[]byte("queryRealEstateList"), []byte(proprietor)}).Payload, &list); err != nil {
+			t.FailNow()
+		}
+		return len(list)
+	}
+	var realEstates []model.RealEstate
+	resp := checkInvoke(t, stub, batch("5feceb66ffc8",
+		"6b86b273ff34", "80", "60", "LOT-B/1", "apartment",
+		"4e07408562be", "120", "100", "", "house",
+		"4e07408562be", "90", "70", "", ""))
+	if err := json.Unmarshal(resp.Payload, &realEstates); err != nil || len(realEstates) != 3 || realEstates[0].RealEstateID != "LOT-B/1" ||
+		realEstates[1].RealEstateID == realEstates[2].RealEstateID || realEstates[2].Proprietor != "4e07408562be" ||
+		realEstates[0].PropertyType != model.PropertyTypeConstant()["apartment"] || realEstates[2].PropertyType != "" {
+		fmt.Println("Batch was not created", err, realEstates)
+		t.FailNow()
+	}
+	if n := countOwned("4e07408562be"); n != 2 {
+		fmt.Println("Batch real estates are not on the ledger", n)
+		t.FailNow()
+	}
+	// A batch is created as a whole or not at all
+	document := `{"schemaVersion":1,"accountId":"5feceb66ffc8","realEstates":[
+		{"proprietor":"ef2d127de37b","totalArea":50,"livingSpace":40,"realEstateId":"LOT-B/2"},
+		{"proprietor":"ef2d127de37b","totalArea":60,"livingSpace":40,"realEstateId":"LOT-B/2"}]}`
+	e := checkInvokeError(t, stub, [][]byte{[]byte("createRealEstateBatch"), []byte(document)}, errcode.Conflict)
+	if !strings.HasPrefix(e.Message, "Real estate 2:") {
+		fmt.Println("The failing real estate is not reported", e.Message)
+		t.FailNow()
+	}
+	checkInvokeError(t, stub, batch("5feceb66ffc8", "ef2d127de37b", "50", "40", "LOT-B/3", "", "ef2d127de37b", "60", "40", "LOT-B/1", ""), errcode.Conflict)
+	checkInvokeError(t, stub, batch("5feceb66ffc8", "ef2d127de37b", "50", "40", "LOT-B/3", "", "unknown", "60", "40", "", ""), errcode.NotFound)
+	checkInvokeError(t, stub, batch("5feceb66ffc8", "ef2d127de37b", "50", "40", "LOT-B/3", "", "ef2d127de37b", "40", "60", "", ""), errcode.InvalidArgument)
+	if n := countOwned("ef2d127de37b"); n != 0 {
+		fmt.Println("A failed batch left real estates on the ledger", n)
+		t.FailNow()
+	}
+	checkInvokeError(t, stub, batch("5feceb66ffc8", "ef2d127de37b", "50", "40", "", "castle"), errcode.InvalidArgument)
+	checkInvokeError(t, stub, batch("6b86b273ff34", "ef2d127de37b", "50", "40", "", ""), errcode.Forbidden)
+	var items []string
+	for i := 0; i <= model.RealEstateBatchLimit; i++ {
+		items = append(items, "ef2d127de37b", "50", "40", "", "")
+	}
+	checkInvokeError(t, stub, batch("5feceb66ffc8", items...), errcode.InvalidArgument)
+}
+
+// Test querying a real estate by ID with its last transfer
+func Test_QueryRealEstate(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	query := func(realEstateID string) model.RealEstate {
+		var realEstate model.RealEstate
+		if err := json.Unmarshal(checkInvoke(t, stub, [][]byte{[]byte("queryRealEstate"), []byte(realEstateID)}).Payload, &realEstate); err != nil {
+			t.FailNow()
+		}
+		return realEstate
+	}
+	created := query(realEstateList[0].RealEstateID)
+	if created.Proprietor != realEstateList[0].Proprietor || created.TransferTxID == "" || created.TransferTxID != realEstateList[0].TransferTxID {
+		fmt.Println("Creation was not recorded as the last transfer", created)
+		t.FailNow()
+	}
+	// Listing the real estate is not a transfer, completing the sale is
+	checkSellAndBuy(t, stub, realEstateList[0], realEstateList[2].Proprietor, "500000")
+	if listed := query(realEstateList[0].RealEstateID); listed.TransferTxID != created.TransferTxID {
+		fmt.Println("Listing was recorded as a transfer", listed)
+		t.FailNow()
+	}
+	checkInvoke(t, stub, [][]byte{[]byte("updateSelling"), []byte(realEstateList[0].RealEstateID), []byte(realEstateList[0].Proprietor), []byte(realEstateList[2].Proprietor), []byte("done")})
+	transferred := query(realEstateList[0].RealEstateID)
+	if transferred.Proprietor != realEstateList[2].Proprietor || transferred.TransferTxID == "" || transferred.TransferTxID == created.TransferTxID {
+		fmt.Println("Sale was not recorded as the last transfer", transferred)
+		t.FailNow()
+	}
+	checkInvokeError(t, stub, [][]byte{[]byte("queryRealEstate"), []byte("unknown")}, errcode.NotFound)
+}
+
+// Test that sales and donations add and remove only their own encumbrances
+func Test_Encumbrances(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	seller, buyer := realEstateList[0].Proprietor, realEstateList[2].Proprietor
+	realEstateID := realEstateList[0].RealEstateID
+	query := func() model.RealEstate {
+		var realEstate model.RealEstate
+		if err := json.Unmarshal(checkInvoke(t, stub, [][]byte{[]byte("queryRealEstate"), []byte(realEstateID)}).Payload, &realEstate); err != nil {
+			t.FailNow()
+		}
+		return realEstate
+	}
+	put := func(realEstate model.RealEstate, value []byte) {
+		txID := nextTxID()
+		stub.MockTransactionStart(txID)
+		if err := stub.PutState(mustCompositeKey(t, stub, model.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}), value); err != nil {
+			t.Fatal(err)
+		}
+		stub.MockTransactionEnd(txID)
+	}
+	types := model.EncumbranceTypeConstant()
+	// A mortgage that does not block transfers stays through the sale
+	realEstate := query()
+	realEstate.Encumbrances = append(realEstate.Encumbrances, model.Encumbrance{Type: types["mortgage"], Reference: "DEED-1", CreatedBy: "5feceb66ffc8"})
+	put(realEstate, mustMarshal(t, realEstate))
+	checkInvoke(t, stub, [][]byte{[]byte("createSelling"), []byte(realEstateID), []byte(seller), []byte("30")}, sellingTransient("500000"))
+	listed := query()
+	if len(listed.Encumbrances) != 2 || listed.Encumbrances[1].Type != types["sale"] || listed.Encumbrances[1].CreatedBy != seller ||
+		!listed.Encumbrances[1].BlocksTransfer || listed.Encumbrances[1].StartTime == "" {
+		fmt.Println("Sale encumbrance was not added", listed.Encumbrances)
+		t.FailNow()
+	}
+	// The sale blocks a donation or a second sale
+	checkInvokeError(t, stub, [][]byte{[]byte("createDonating"), []byte(realEstateID), []byte(seller), []byte(buyer)}, errcode.Conflict)
+	checkInvokeError(t, stub, [][]byte{[]byte("createSelling"), []byte(realEstateID), []byte(seller), []byte("30")}, errcode.Conflict, sellingTransient("400000"))
+	checkInvoke(t, stub, [][]byte{[]byte("updateSelling"), []byte(realEstateID), []byte(seller), []byte(""), []byte("cancelled"), []byte(seller), []byte("Withdrawn")})
+	if cancelled := query(); len(cancelled.Encumbrances) != 1 || cancelled.Encumbrances[0].Type != types["mortgage"] {
+		fmt.Println("Cancelling the sale did not remove only its encumbrance", cancelled.Encumbrances)
+		t.FailNow()
+	}
+	// The mortgage passes to the grantee with the real estate
+	checkInvoke(t, stub, [][]byte{[]byte("createDonating"), []byte(realEstateID), []byte(seller), []byte(buyer)})
+	checkInvoke(t, stub, [][]byte{[]byte("updateDonating"), []byte(realEstateID), []byte(seller), []byte(buyer), []byte("done")})
+	if donated := query(); donated.Proprietor != buyer || len(donated.Encumbrances) != 1 || donated.Encumbrances[0].Type != types["mortgage"] {
+		fmt.Println("Completing the donation did not remove only its encumbrance", donated)
+		t.FailNow()
+	}
+	// A real estate encumbered before encumbrances were typed is migrated to a legacy encumbrance, removed by its sale
+	legacy := realEstateList[1]
+	put(legacy, []byte(fmt.Sprintf(`{"realEstateId":%q,"proprietor":%q,"encumbrance":true,"totalArea":100,"livingSpace":80,"transferTxId":"tx-1","schemaVersion":2}`, legacy.RealEstateID, legacy.Proprietor)))
+	checkMigrate(t, stub, model.RealEstateKey, 100)
+	realEstateID = legacy.RealEstateID
+	if migrated := query(); len(migrated.Encumbrances) != 1 || migrated.Encumbrances[0].Type != types["legacy"] || !migrated.Encumbrances[0].BlocksTransfer {
+		fmt.Println("Encumbrance flag was not migrated", migrated)
+		t.FailNow()
+	}
+	checkInvokeError(t, stub, [][]byte{[]byte("createSelling"), []byte(realEstateID), []byte(legacy.Proprietor), []byte("30")}, errcode.Conflict, sellingTransient("500000"))
+}
+
+// Test freezing, unfreezing and forcing the transfer of real estate by court orders
+func Test_CourtOrders(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	authority := "7902699be42c"
+	seller, buyer, heir := realEstateList[0].Proprietor, realEstateList[2].Proprietor, "d4735e3a265e"
+	realEstateID := realEstateList[0].RealEstateID
+	// Only an admin or authority can freeze, once per order
+	checkInvokeError(t, stub, [][]byte{[]byte("freezeRealEstate"), []byte(seller), []byte(realEstateID), []byte("CASE-1")}, errcode.Forbidden)
+	checkInvoke(t, stub, [][]byte{[]byte("freezeRealEstate"), []byte(authority), []byte(realEstateID), []byte("CASE-1")})
+	checkInvokeError(t, stub, [][]byte{[]byte("freezeRealEstate"), []byte("5feceb66ffc8"), []byte(realEstateID), []byte("CASE-1")}, errcode.Conflict)
+	// The freeze blocks listing and donation
+	checkInvokeError(t, stub, [][]byte{[]byte("createSelling"), []byte(realEstateID), []byte(seller), []byte("30")}, errcode.Conflict, sellingTransient("500000"))
+	checkInvokeError(t, stub, [][]byte{[]byte("createDonating"), []byte(realEstateID), []byte(seller), []byte(buyer)}, errcode.Conflict)
+	checkInvokeError(t, stub, [][]byte{[]byte("unfreezeRealEstate"), []byte(authority), []byte(realEstateID), []byte("CASE-2")}, errcode.NotFound)
+	checkInvoke(t, stub, [][]byte{[]byte("unfreezeRealEstate"), []byte(authority), []byte(realEstateID), []byte("CASE-1")})
+	// A freeze placed during delivery blocks the transfer to the buyer
+	checkSellAndBuy(t, stub, realEstateList[0], buyer, "500000")
+	checkInvoke(t, stub, [][]byte{[]byte("freezeRealEstate"), []byte(authority), []byte(realEstateID), []byte("CASE-3")})
+	checkInvokeError(t, stub, [][]byte{[]byte("updateSelling"), []byte(realEstateID), []byte(seller), []byte(buyer), []byte("done")}, errcode.Conflict)
+	// A forced transfer must come from the order that froze the real estate
+	checkInvokeError(t, stub, [][]byte{[]byte("forceTransfer"), []byte(authority), []byte(realEstateID), []byte(heir), []byte("CASE-4")}, errcode.Conflict)
+	var transferred model.RealEstate
+	if err := json.Unmarshal(checkInvoke(t, stub, [][]byte{[]byte("forceTransfer"), []byte(authority), []byte(realEstateID), []byte(heir), []byte("CASE-3")}).Payload, &transferred); err != nil ||
+		transferred.Proprietor != heir || len(transferred.Encumbrances) != 0 {
+		fmt.Println("Forced transfer failed", err, transferred)
+		t.FailNow()
+	}
+	// The sale was cancelled and the buyer refunded in full
+	var sellingList []model.Selling
+	if err := json.Unmarshal(checkInvoke(t, stub, [][]byte{[]byte("querySellingList"), []byte(seller)}).Payload, &sellingList); err != nil ||
+		len(sellingList) != 1 || sellingList[0].SellingStatus != model.SellingStatusConstant()["cancelled"] || sellingList[0].CancelledBy != authority {
+		fmt.Println("Forced transfer did not cancel the sale", err, sellingList)
+		t.FailNow()
+	}
+	if account := checkQueryAccount(t, stub, buyer); account.Balance != 5000000 {
+		fmt.Println("Buyer was not refunded", account)
+		t.FailNow()
+	}
+	var courtOrders []model.CourtOrder
+	if err := json.Unmarshal(checkInvoke(t, stub, [][]byte{[]byte("queryCourtOrderList"), []byte(realEstateID)}).Payload, &courtOrders); err != nil || len(courtOrders) != 4 {
+		fmt.Println("Court orders were not recorded", err, courtOrders)
+		t.FailNow()
+	}
+	var violations []model.AuditViolation
+	if err := json.Unmarshal(invokeAs(t, stub, "JDMSP", [][]byte{[]byte("auditLedger"), []byte("5feceb66ffc8")}).Payload, &violations); err != nil || len(violations) != 0 {
+		fmt.Println("Forced transfer broke the ledger invariants", err, violations)
+		t.FailNow()
+	}
+}
+
+// Test registering easements and carrying them over when the real estate changes hands
+func Test_Easements(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	dominant, servient := realEstateList[2], realEstateList[0]
+	buyer := realEstateList[3].Proprietor
+	register := func(args ...string) [][]byte {
+		bytes := [][]byte{[]byte("registerEasement"), []byte("5feceb66ffc8")}
+		for _, arg := range args {
+			bytes = append(bytes, []byte(arg))
+		}
+		return bytes
+	}
+	checkInvokeError(t, stub, register("tunnel", "Road to the river", dominant.RealEstateID, servient.RealEstateID), errcode.InvalidArgument)
+	checkInvokeError(t, stub, register("rightOfWay", "Road to the river", servient.RealEstateID, servient.RealEstateID), errcode.InvalidArgument)
+	checkInvokeError(t, stub, register("rightOfWay", "Road to the river", dominant.RealEstateID, "missing"), errcode.NotFound)
+	var easement model.Easement
+	if err := json.Unmarshal(checkInvoke(t, stub, register("rightOfWay", "Road to the river", dominant.RealEstateID, servient.RealEstateID, "365")).Payload, &easement); err != nil ||
+		easement.Type != model.EasementTypeConstant()["rightOfWay"] || easement.EndTime == "" {
+		fmt.Println("Register easement failed", err, easement)
+		t.FailNow()
+	}
+	checkInvokeError(t, stub, register("rightOfWay", "Road to the river", dominant.RealEstateID, servient.RealEstateID), errcode.Conflict)
+	query := func(realEstateID string) model.RealEstate {
+		var realEstate model.RealEstate
+		if err := json.Unmarshal(checkInvoke(t, stub, [][]byte{[]byte("queryRealEstate"), []byte(realEstateID)}).Payload, &realEstate); err != nil {
+			t.FailNow()
+		}
+		return realEstate
+	}
+	// The servient real estate carries an encumbrance that does not block its sale; it and the easement pass to the buyer
+	checkSellAndBuy(t, stub, servient, buyer, "500000")
+	checkInvoke(t, stub, [][]byte{[]byte("updateSelling"), []byte(servient.RealEstateID), []byte(servient.Proprietor), []byte(buyer), []byte("done")})
+	sold := query(servient.RealEstateID)
+	if sold.Proprietor != buyer || len(sold.Encumbrances) != 1 || sold.Encumbrances[0].Reference != easement.EasementID ||
+		len(sold.Easements) != 1 || sold.Easements[0].EasementID != easement.EasementID {
+		fmt.Println("Easement did not carry over to the buyer", sold)
+		t.FailNow()
+	}
+	// The easement stays with the dominant real estate when it is donated
+	checkInvoke(t, stub, [][]byte{[]byte("createDonating"), []byte(dominant.RealEstateID), []byte(dominant.Proprietor), []byte(buyer)})
+	checkInvoke(t, stub, [][]byte{[]byte("updateDonating"), []byte(dominant.RealEstateID), []byte(dominant.Proprietor), []byte(buyer), []byte("done")})
+	var owned []model.RealEstate
+	if err := json.Unmarshal(checkInvoke(t, stub, [][]byte{[]byte("queryRealEstateList"), []byte(buyer)}).Payload, &owned); err != nil || len(owned) != 3 {
+		fmt.Println("Query real estate list failed", err, owned)
+		t.FailNow()
+	}
+	for _, v := range owned {
+		if (v.RealEstateID == dominant.RealEstateID || v.RealEstateID == servient.RealEstateID) && len(v.Easements) != 1 {
+			fmt.Println("Easement is missing from", v)
+			t.FailNow()
+		}
+	}
+	// Releasing the easement removes the encumbrance
+	checkInvoke(t, stub, [][]byte{[]byte("releaseEasement"), []byte("5feceb66ffc8"), []byte(easement.EasementID)})
+	checkInvokeError(t, stub, [][]byte{[]byte("releaseEasement"), []byte("5feceb66ffc8"), []byte(easement.EasementID)}, errcode.Conflict)
+	if released := query(servient.RealEstateID); len(released.Encumbrances) != 0 || len(released.Easements) != 0 {
+		fmt.Println("Release easement failed", released)
+		t.FailNow()
+	}
+	var easements []model.Easement
+	if err := json.Unmarshal(checkInvoke(t, stub, [][]byte{[]byte("queryEasementList"), []byte(dominant.RealEstateID)}).Payload, &easements); err != nil ||
+		len(easements) != 1 || easements[0].EasementStatus != model.EasementStatusConstant()["released"] {
+		fmt.Println("Query easements failed", err, easements)
+		t.FailNow()
+	}
+}
+
+// Test that identity verification gates sales, purchases and donations
+func Test_Kyc(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	seller, buyer, grantee, verifier := realEstateList[0].Proprietor, "4b227777d4dd", "d4735e3a265e", "2c624232cdd2"
+	later := time.Now().AddDate(2, 0, 0)
+	// Invoke at a time the seeded verifications have expired, expecting a Forbidden error
+	checkExpired := func(args [][]byte, transient ...map[string][]byte) {
+		res := invoke(stub, &timedStub{MockStub: stub, args: args, at: later}, transient...)
+		if e, ok := errcode.Parse(res.Message); res.Status == shim.OK || !ok || e.Code != errcode.Forbidden {
+			fmt.Println("Invoke", string(args[0]), "with an expired verification returned", res.Status, res.Message)
+			t.FailNow()
+		}
+	}
+	checkInvokeError(t, stub, [][]byte{[]byte("requestKyc"), []byte(seller)}, errcode.Conflict)
+	checkExpired([][]byte{[]byte("createSelling"), []byte(realEstateList[0].RealEstateID), []byte(seller), []byte("30")}, sellingTransient("500000"))
+	checkInvoke(t, stub, [][]byte{[]byte("createDonating"), []byte(realEstateList[1].RealEstateID), []byte(seller), []byte(grantee)})
+	checkExpired([][]byte{[]byte("updateDonating"), []byte(realEstateList[1].RealEstateID), []byte(seller), []byte(grantee), []byte("done")})
+	// Once expired the buyer requests verification again and cannot buy while it is pending
+	checkInvokeAt(t, stub, later, [][]byte{[]byte("requestKyc"), []byte(buyer)})
+	checkInvokeError(t, stub, [][]byte{[]byte("requestKyc"), []byte(buyer)}, errcode.Conflict)
+	checkInvoke(t, stub, [][]byte{[]byte("createSelling"), []byte(realEstateList[0].RealEstateID), []byte(seller), []byte("30")}, sellingTransient("500000"))
+	buy := [][]byte{[]byte("createSellingByBuy"), []byte(realEstateList[0].RealEstateID), []byte(seller), []byte(buyer)}
+	checkInvokeError(t, stub, buy, errcode.Forbidden)
+	var pending []model.Account
+	if err := json.Unmarshal(checkInvoke(t, stub, [][]byte{[]byte("queryPendingKycList")}).Payload, &pending); err != nil ||
+		len(pending) != 1 || pending[0].AccountId != buyer || pending[0].Balance != 0 {
+		fmt.Println("Query pending verifications failed", err, pending)
+		t.FailNow()
+	}
+	// Only a verifier reviews, and a verification needs a validity
+	checkInvokeError(t, stub, [][]byte{[]byte("reviewKyc"), []byte("5feceb66ffc8"), []byte(buyer), []byte("verified"), []byte("30")}, errcode.Forbidden)
+	checkInvokeError(t, stub, [][]byte{[]byte("reviewKyc"), []byte(verifier), []byte(buyer), []byte("verified")}, errcode.InvalidArgument)
+	var account model.Account
+	if err := json.Unmarshal(checkInvoke(t, stub, [][]byte{[]byte("reviewKyc"), []byte(verifier), []byte(buyer), []byte("verified"), []byte("30")}).Payload, &account); err != nil ||
+		account.KycStatus != model.KycStatusConstant()["verified"] || account.KycVerifier != verifier || account.KycExpiry == "" {
+		fmt.Println("Review verification failed", err, account)
+		t.FailNow()
+	}
+	checkInvokeError(t, stub, [][]byte{[]byte("reviewKyc"), []byte(verifier), []byte(buyer), []byte("rejected")}, errcode.Conflict)
+	checkInvoke(t, stub, buy)
+}
+
+func Test_Tokens(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	owner, approved, operator, recipient := realEstateList[0].Proprietor, "d4735e3a265e", "4e07408562be", "4b227777d4dd"
+	tokenID := realEstateList[0].RealEstateID
+	checkQuery := func(args [][]byte, want string) {
+		if got := string(checkInvoke(t, stub, args).Payload); got != want {
+			fmt.Println("Invoke", string(args[0]), "returned", got, "instead of", want)
+			t.FailNow()
+		}
+	}
+	// lastEvent drains the events set so far and returns the last one
+	lastEvent := func() *pb.ChaincodeEvent {
+		var event *pb.ChaincodeEvent
+		for len(stub.ChaincodeEventsChannel) > 0 {
+			event = <-stub.ChaincodeEventsChannel
+		}
+		return event
+	}
+	checkQuery([][]byte{[]byte("ownerOf"), []byte(tokenID)}, owner)
+	checkQuery([][]byte{[]byte("balanceOf"), []byte(owner)}, "2")
+	transfer := [][]byte{[]byte("transferFrom"), []byte(approved), []byte(owner), []byte(recipient), []byte(tokenID)}
+	checkInvokeError(t, stub, transfer, errcode.Forbidden)
+	// Only the owner or its operators approve
+	checkInvokeError(t, stub, [][]byte{[]byte("approve"), []byte(approved), []byte(approved), []byte(tokenID)}, errcode.Forbidden)
+	checkInvoke(t, stub, [][]byte{[]byte("approve"), []byte(owner), []byte(approved), []byte(tokenID)})
+	if event := lastEvent(); event == nil || event.EventName != "Approval" {
+		fmt.Println("Approve did not set the Approval event", event)
+		t.FailNow()
+	}
+	checkQuery([][]byte{[]byte("getApproved"), []byte(tokenID)}, approved)
+	checkInvoke(t, stub, [][]byte{[]byte("setApprovalForAll"), []byte(owner), []byte(operator), []byte("true")})
+	checkQuery([][]byte{[]byte("isApprovedForAll"), []byte(owner), []byte(operator)}, "true")
+	// A real estate on sale is encumbered and cannot be transferred, even by an operator
+	onSale := realEstateList[1].RealEstateID
+	checkInvoke(t, stub, [][]byte{[]byte("createSelling"), []byte(onSale), []byte(owner), []byte("30")}, sellingTransient("500000"))
+	checkInvokeError(t, stub, [][]byte{[]byte("transferFrom"), []byte(operator), []byte(owner), []byte(recipient), []byte(onSale)}, errcode.Conflict)
+	var metadata model.TokenMetadata
+	if err := json.Unmarshal(checkInvoke(t, stub, [][]byte{[]byte("tokenMetadata"), []byte(onSale)}).Payload, &metadata); err != nil ||
+		metadata.Symbol != model.TokenSymbol || metadata.Owner != owner || metadata.Transferable || len(metadata.Encumbrances) != 1 {
+		fmt.Println("Query token metadata failed", err, metadata)
+		t.FailNow()
+	}
+	// The approved account transfers; neither the admin nor the former owner may receive it
+	checkInvokeError(t, stub, [][]byte{[]byte("transferFrom"), []byte(approved), []byte(owner), []byte("5feceb66ffc8"), []byte(tokenID)}, errcode.Forbidden)
+	checkInvokeError(t, stub, [][]byte{[]byte("transferFrom"), []byte(approved), []byte(owner), []byte(owner), []byte(tokenID)}, errcode.InvalidArgument)
+	lastEvent()
+	var realEstate model.RealEstate
+	if err := json.Unmarshal(checkInvoke(t, stub, transfer).Payload, &realEstate); err != nil || realEstate.Proprietor != recipient {
+		fmt.Println("Transfer failed", err, realEstate)
+		t.FailNow()
+	}
+	var transferEvent model.TransferEvent
+	if event := lastEvent(); event == nil || event.EventName != "Transfer" || json.Unmarshal(event.Payload, &transferEvent) != nil ||
+		transferEvent != (model.TransferEvent{From: owner, To: recipient, TokenID: tokenID}) {
+		fmt.Println("Transfer did not set the Transfer event", event)
+		t.FailNow()
+	}
+	// The approval lapses with the transfer, the operator is still approved for the remaining real estate
+	checkQuery([][]byte{[]byte("ownerOf"), []byte(tokenID)}, recipient)
+	checkQuery([][]byte{[]byte("getApproved"), []byte(tokenID)}, "")
+	checkQuery([][]byte{[]byte("balanceOf"), []byte(owner)}, "1")
+	checkInvokeError(t, stub, [][]byte{[]byte("transferFrom"), []byte(approved), []byte(recipient), []byte(owner), []byte(tokenID)}, errcode.Forbidden)
+	checkInvokeError(t, stub, [][]byte{[]byte("transferFrom"), []byte(operator), []byte(owner), []byte(recipient), []byte(tokenID)}, errcode.Conflict)
+	checkInvoke(t, stub, [][]byte{[]byte("setApprovalForAll"), []byte(owner), []byte(operator), []byte("false")})
+	checkQuery([][]byte{[]byte("isApprovedForAll"), []byte(owner), []byte(operator)}, "false")
+}
+
+func Test_Delegations(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	seller, buyer, agent, donor := realEstateList[0].Proprietor, "4b227777d4dd", "d4735e3a265e", realEstateList[2].Proprietor
+	checkGrant := func(args ...string) model.Delegation {
+		bytes := [][]byte{[]byte("grantDelegation")}
+		for _, arg := range args {
+			bytes = append(bytes, []byte(arg))
+		}
+		var delegation model.Delegation
+		if err := json.Unmarshal(checkInvoke(t, stub, bytes).Payload, &delegation); err != nil || delegation.DelegationStatus != model.DelegationStatusConstant()["active"] {
+			fmt.Println("Grant delegation failed", err, delegation)
+			t.FailNow()
+		}
+		return delegation
+	}
+	// The seller lets the agent sell one real estate for 30 days
+	checkInvokeError(t, stub, [][]byte{[]byte("grantDelegation"), []byte(seller), []byte(agent), []byte("manage"), []byte(""), []byte("30")}, errcode.InvalidArgument)
+	sell := checkGrant(seller, agent, "sell", realEstateList[0].RealEstateID, "30")
+	checkInvokeError(t, stub, [][]byte{[]byte("grantDelegation"), []byte(seller), []byte(agent), []byte("sell"), []byte(realEstateList[0].RealEstateID), []byte("10")}, errcode.Conflict)
+	checkInvokeError(t, stub, [][]byte{[]byte("createSelling"), []byte(realEstateList[1].RealEstateID), []byte(seller), []byte("30"), []byte(""), []byte(""), []byte(agent)}, errcode.Forbidden, sellingTransient("500000"))
+	checkInvoke(t, stub, [][]byte{[]byte("createSelling"), []byte(realEstateList[0].RealEstateID), []byte(seller), []byte("30"), []byte(""), []byte(""), []byte(agent)}, sellingTransient("500000"))
+	// The buyer lets another account buy any real estate on its behalf
+	checkGrant(buyer, realEstateList[3].Proprietor, "buy", "", "30")
+	checkInvokeError(t, stub, [][]byte{[]byte("createSellingByBuy"), []byte(realEstateList[0].RealEstateID), []byte(seller), []byte(buyer), []byte(agent)}, errcode.Forbidden)
+	checkInvoke(t, stub, [][]byte{[]byte("createSellingByBuy"), []byte(realEstateList[0].RealEstateID), []byte(seller), []byte(buyer), []byte(realEstateList[3].Proprietor)})
+	var actions []model.DelegatedAction
+	if err := json.Unmarshal(checkInvoke(t, stub, [][]byte{[]byte("queryDelegatedActionList"), []byte(seller)}).Payload, &actions); err != nil ||
+		len(actions) != 1 || actions[0].Agent != agent || actions[0].Function != "createSelling" || actions[0].DelegationID != sell.DelegationID {
+		fmt.Println("Query delegated actions failed", err, actions)
+		t.FailNow()
+	}
+	// Once revoked the agent can no longer confirm the sale for the seller
+	checkInvoke(t, stub, [][]byte{[]byte("revokeDelegation"), []byte(seller), []byte(sell.DelegationID)})
+	checkInvokeError(t, stub, [][]byte{[]byte("revokeDelegation"), []byte(seller), []byte(sell.DelegationID)}, errcode.Conflict)
+	checkInvokeError(t, stub, [][]byte{[]byte("updateSelling"), []byte(realEstateList[0].RealEstateID), []byte(seller), []byte(buyer), []byte("done"),
+		[]byte(""), []byte(""), []byte(agent)}, errcode.Forbidden)
+	checkInvoke(t, stub, [][]byte{[]byte("updateSelling"), []byte(realEstateList[0].RealEstateID), []byte(seller), []byte(buyer), []byte("done")})
+	// A delegation lapses at the end of its validity
+	checkGrant(donor, agent, "donate", "", "1")
+	donate := [][]byte{[]byte("createDonating"), []byte(realEstateList[2].RealEstateID), []byte(donor), []byte(buyer), []byte(agent)}
+	res := invoke(stub, &timedStub{MockStub: stub, args: donate, at: time.Now().AddDate(0, 0, 2)})
+	if e, ok := errcode.Parse(res.Message); res.Status == shim.OK || !ok || e.Code != errcode.Forbidden {
+		fmt.Println("Donating with a lapsed delegation returned", res.Status, res.Message)
+		t.FailNow()
+	}
+	// The donor's agent may cancel the donation, but only the grantee's agent may accept it
+	checkInvoke(t, stub, donate)
+	checkInvokeError(t, stub, [][]byte{[]byte("updateDonating"), []byte(realEstateList[2].RealEstateID), []byte(donor), []byte(buyer), []byte("done"), []byte(agent)}, errcode.Forbidden)
+	checkInvoke(t, stub, [][]byte{[]byte("updateDonating"), []byte(realEstateList[2].RealEstateID), []byte(donor), []byte(buyer), []byte("cancelled"), []byte(agent)})
+	var delegations []model.Delegation
+	if err := json.Unmarshal(checkInvoke(t, stub, [][]byte{[]byte("queryDelegationList"), []byte(""), []byte(agent)}).Payload, &delegations); err != nil || len(delegations) != 2 {
+		fmt.Println("Query delegations failed", err, delegations)
+		t.FailNow()
+	}
+}
+
+func Test_EndorsementPolicies(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	admin, owner, recipient := "5feceb66ffc8", realEstateList[0].Proprietor, "4b227777d4dd"
+	realEstateID := realEstateList[0].RealEstateID
+	checkPolicy := func(realEstateID string, want string) {
+		var policy model.EndorsementPolicy
+		if err := json.Unmarshal(checkInvoke(t, stub, [][]byte{[]byte("queryEndorsementPolicy"), []byte(realEstateID)}).Payload, &policy); err != nil ||
+			fmt.Sprint(policy.Orgs) != want {
+			fmt.Println("Query endorsement policy failed", err, policy)
+			t.FailNow()
+		}
+	}
+	checkInvokeError(t, stub, [][]byte{[]byte("setEndorsementPolicy"), []byte(owner), []byte(realEstateID), []byte("JDMSP")}, errcode.Forbidden)
+	checkInvokeError(t, stub, [][]byte{[]byte("setEndorsementPolicy"), []byte(admin), []byte("missing"), []byte("JDMSP")}, errcode.NotFound)
+	checkInvoke(t, stub, [][]byte{[]byte("setEndorsementPolicy"), []byte(admin), []byte(realEstateID), []byte("TaobaoMSP"), []byte("JDMSP")})
+	// The key of the real estate requires a signature of a member of both organizations
+	key, _ := stub.CreateCompositeKey(model.RealEstateKey, []string{owner, realEstateID})
+	parameter, _ := stub.GetStateValidationParameter(key)
+	envelope := &common.SignaturePolicyEnvelope{}
+	if err := proto.Unmarshal(parameter, envelope); err != nil || envelope.Rule.GetNOutOf().GetN() != 2 || len(envelope.Identities) != 2 {
+		fmt.Println("Unexpected key-level endorsement policy", err, envelope)
+		t.FailNow()
+	}
+	checkPolicy(realEstateID, "[JDMSP TaobaoMSP]")
+	checkPolicy(realEstateList[1].RealEstateID, "[]")
+	// The policy follows the real estate to its new proprietor
+	checkInvoke(t, stub, [][]byte{[]byte("transferFrom"), []byte(owner), []byte(owner), []byte(recipient), []byte(realEstateID)})
+	checkPolicy(realEstateID, "[JDMSP TaobaoMSP]")
+	// Without organizations the chaincode endorsement policy applies again
+	checkInvoke(t, stub, [][]byte{[]byte("setEndorsementPolicy"), []byte(admin), []byte(realEstateID)})
+	checkPolicy(realEstateID, "[]")
+}
@@ -6,6 +6,7 @@ require (
 	github.com/Knetic/govaluate v3.0.0+incompatible // indirect
 	github.com/Shopify/sarama v1.32.0 // indirect
 	github.com/fsouza/go-dockerclient v1.7.10 // indirect
+	github.com/golang/protobuf v1.5.2
 	github.com/hashicorp/go-version v1.4.0 // indirect
 	github.com/hyperledger/fabric v1.4.12
 	github.com/hyperledger/fabric-amcl v0.0.0-20210603140002-2670f91851c8 // indirect
@@ -19,5 +20,5 @@ require (
 	go.uber.org/zap v1.21.0 // indirect
 	golang.org/x/crypto v0.0.0-20220315160706-3147a52a75dd // indirect
 	golang.org/x/net v0.0.0-20220225172249-27dd8689420f // indirect
-	google.golang.org/grpc v1.45.0 // indirect
+	google.golang.org/grpc v1.45.0
 )
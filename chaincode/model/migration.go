@@ -0,0 +1,266 @@
+package model
+
+import (
+	"chaincode/pkg/timeutil"
+	"encoding/json"
+	"fmt"
+)
+
+// Migration upgrades the stored records of one object type from version From to From+1.
+// Upgrade works on the decoded JSON so that it can rename or restructure fields the current structs no longer have.
+type Migration struct {
+	ObjectType  string                              // Object type of the records, i.e. the composite key prefix
+	From        int                                 // Version upgraded from
+	Description string                              // What the migration changes
+	Upgrade     func(record map[string]interface{}) // Upgrades a decoded record in place
+}
+
+// MigrationsConstant is the registry of migrations. Each object type is upgraded one version at a time;
+// object types without a migration for a version are only stamped with the new version.
+var MigrationsConstant = func() []Migration {
+	return []Migration{
+		{
+			ObjectType:  SellingKey,
+			From:        1,
+			Description: "Rename the sale statuses to the names used by the application",
+			Upgrade:     renameStatus("sellingStatus", sellingStatusV1),
+		},
+		{
+			ObjectType:  SellingBuyKey,
+			From:        1,
+			Description: "Rename the status of the buyer's copy of the sale",
+			Upgrade:     nested("selling", renameStatus("sellingStatus", sellingStatusV1)),
+		},
+		{
+			ObjectType:  DonatingKey,
+			From:        1,
+			Description: "Rename the donation statuses to the names used by the application",
+			Upgrade:     renameStatus("donatingStatus", donatingStatusV1),
+		},
+		{
+			ObjectType:  DonatingGranteeKey,
+			From:        1,
+			Description: "Rename the status of the grantee's copy of the donation",
+			Upgrade:     nested("donating", renameStatus("donatingStatus", donatingStatusV1)),
+		},
+		{
+			ObjectType:  RealEstateKey,
+			From:        2,
+			Description: "Replace the encumbrance flag with a list of typed encumbrances",
+			Upgrade:     typeEncumbrance,
+		},
+		{
+			ObjectType:  AccountKey,
+			From:        3,
+			Description: "Store the identity verification expiry in UTC RFC 3339",
+			Upgrade:     upgradeTimes("kycExpiry"),
+		},
+		{
+			ObjectType:  RealEstateKey,
+			From:        3,
+			Description: "Store the terms of the encumbrances in UTC RFC 3339",
+			Upgrade:     upgradeListTimes("encumbrances", "startTime", "endTime"),
+		},
+		{
+			ObjectType:  SellingKey,
+			From:        3,
+			Description: "Store the times of the sale and of its public installments in UTC RFC 3339",
+			Upgrade:     upgradeSellingTimes,
+		},
+		{
+			// The creation time is part of the key, so migrate re-keys the record instead
+			ObjectType:  SellingBuyKey,
+			From:        3,
+			Description: "Store the times of the sale in the buyer's copy in UTC RFC 3339",
+			Upgrade:     nested("selling", upgradeSellingTimes),
+		},
+		{
+			ObjectType:  DonatingKey,
+			From:        3,
+			Description: "Store the creation time of the donation in UTC RFC 3339",
+			Upgrade:     upgradeTimes("createTime"),
+		},
+		{
+			// The creation time is part of the key, so migrate re-keys the record instead
+			ObjectType:  DonatingGranteeKey,
+			From:        3,
+			Description: "Store the creation time of the donation in the grantee's copy in UTC RFC 3339",
+			Upgrade:     nested("donating", upgradeTimes("createTime")),
+		},
+		{
+			// The creation time is part of the key, so migrate re-keys the record instead
+			ObjectType:  DisputeKey,
+			From:        3,
+			Description: "Store the resolution time of the dispute in UTC RFC 3339",
+			Upgrade:     upgradeTimes("resolveTime"),
+		},
+		{
+			ObjectType:  CourtOrderKey,
+			From:        3,
+			Description: "Store the time of the court order in UTC RFC 3339",
+			Upgrade:     upgradeTimes("createTime"),
+		},
+		{
+			ObjectType:  EasementKey,
+			From:        3,
+			Description: "Store the term of the easement in UTC RFC 3339",
+			Upgrade:     upgradeTimes("startTime", "endTime"),
+		},
+		{
+			ObjectType:  DelegationKey,
+			From:        3,
+			Description: "Store the term of the delegation in UTC RFC 3339",
+			Upgrade:     upgradeTimes("startTime", "endTime", "revokeTime"),
+		},
+		{
+			ObjectType:  DelegatedActionKey,
+			From:        3,
+			Description: "Store the time of the delegated action in UTC RFC 3339",
+			Upgrade:     upgradeTimes("createTime"),
+		},
+	}
+}
+
+// Statuses of version 1 records and their current names
+var (
+	sellingStatusV1 = map[string]string{
+		"Selling":     SellingStatusConstant()["saleStart"],
+		"Cancelled":   SellingStatusConstant()["cancelled"],
+		"In Progress": SellingStatusConstant()["delivery"],
+	}
+	donatingStatusV1 = map[string]string{
+		"In Progress": DonatingStatusConstant()["donatingStart"],
+		"Cancelled":   DonatingStatusConstant()["cancelled"],
+	}
+)
+
+// UpgradeRecord upgrades a stored record of the given object type to SchemaVersion.
+// It returns the record unchanged, and false, when it is already current.
+func UpgradeRecord(objectType string, data []byte) ([]byte, bool, error) {
+	var header struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, false, fmt.Errorf("%s - Error reading the schema version: %s", objectType, err)
+	}
+	version := header.SchemaVersion
+	if version == 0 {
+		version = 1
+	}
+	if version == SchemaVersion {
+		return data, false, nil
+	}
+	if version > SchemaVersion {
+		return nil, false, fmt.Errorf("%s - Record version %d is newer than the chaincode version %d", objectType, version, SchemaVersion)
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, false, fmt.Errorf("%s - Error deserializing the record: %s", objectType, err)
+	}
+	for ; version < SchemaVersion; version++ {
+		for _, migration := range MigrationsConstant() {
+			if migration.ObjectType == objectType && migration.From == version {
+				migration.Upgrade(record)
+			}
+		}
+	}
+	record["schemaVersion"] = SchemaVersion
+	upgraded, err := json.Marshal(record)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s - Error serializing the upgraded record: %s", objectType, err)
+	}
+	return upgraded, true, nil
+}
+
+// renameStatus renames the values of a status field
+func renameStatus(field string, names map[string]string) func(record map[string]interface{}) {
+	return func(record map[string]interface{}) {
+		if status, ok := record[field].(string); ok {
+			if name, ok := names[status]; ok {
+				record[field] = name
+			}
+		}
+	}
+}
+
+// typeEncumbrance turns the encumbrance flag set by a sale or donation into a legacy encumbrance,
+// which the sale or donation removes when it closes
+func typeEncumbrance(record map[string]interface{}) {
+	encumbrances := []interface{}{}
+	if encumbered, _ := record["encumbrance"].(bool); encumbered {
+		encumbrances = append(encumbrances, map[string]interface{}{
+			"type":           EncumbranceTypeConstant()["legacy"],
+			"reference":      "",
+			"createdBy":      "",
+			"startTime":      "",
+			"endTime":        "",
+			"blocksTransfer": true,
+		})
+	}
+	delete(record, "encumbrance")
+	record["encumbrances"] = encumbrances
+}
+
+// upgradeTimes rewrites legacy time fields, written in China Standard Time without a zone, in UTC RFC 3339
+func upgradeTimes(fields ...string) func(record map[string]interface{}) {
+	return func(record map[string]interface{}) {
+		for _, field := range fields {
+			if value, ok := record[field].(string); ok {
+				record[field] = timeutil.Upgrade(value)
+			}
+		}
+	}
+}
+
+// upgradeListTimes rewrites the legacy time fields of each element of a list field
+func upgradeListTimes(list string, fields ...string) func(record map[string]interface{}) {
+	return func(record map[string]interface{}) {
+		elements, _ := record[list].([]interface{})
+		for _, element := range elements {
+			if embedded, ok := element.(map[string]interface{}); ok {
+				upgradeTimes(fields...)(embedded)
+			}
+		}
+	}
+}
+
+// upgradeSellingTimes rewrites the legacy times of a sale. Installments kept in the private data collection
+// are not part of the record; migrate rewrites them.
+func upgradeSellingTimes(record map[string]interface{}) {
+	upgradeTimes("createTime", "completeTime")(record)
+	upgradeListTimes("installments", "dueTime", "graceTime", "paidTime")(record)
+}
+
+// nested applies an upgrade to a record embedded in another one, and stamps it with the new version
+func nested(field string, upgrade func(record map[string]interface{})) func(record map[string]interface{}) {
+	return func(record map[string]interface{}) {
+		if embedded, ok := record[field].(map[string]interface{}); ok {
+			upgrade(embedded)
+			embedded["schemaVersion"] = SchemaVersion
+		}
+	}
+}
+
+// MigrationBatchLimit is the largest number of records a single batch may hold
+const MigrationBatchLimit = 500
+
+// MigratableObjectTypesConstant lists the object types of the records stored on the public ledger
+var MigratableObjectTypesConstant = func() []string {
+	return []string{AccountKey, RealEstateKey, SellingKey, SellingBuyKey, DonatingKey, DonatingGranteeKey, DisputeKey, SupplyKey, CancellationPenaltyKey, CourtOrderKey, EasementKey, TokenApprovalKey, OperatorApprovalKey, DelegationKey, DelegatedActionKey, SellingBuyerIndexKey, DonatingGranteeIndexKey, SellingStatusIndexKey}
+}
+
+// MigrationBatch is one batch of record keys to pass to migrate.
+// Passing Bookmark to the next query continues after the last key returned, until Done is true.
+type MigrationBatch struct {
+	ObjectType string   `json:"objectType"` // Object type of the records
+	Keys       []string `json:"keys"`       // Keys of the records in this batch
+	Bookmark   string   `json:"bookmark"`   // Bookmark of the next record
+	Done       bool     `json:"done"`       // Whether all records of the object type have been returned
+}
+
+// MigrationReport is the outcome of one migrate batch
+type MigrationReport struct {
+	ObjectType string `json:"objectType"` // Object type migrated
+	Scanned    int    `json:"scanned"`    // Records scanned in this batch
+	Migrated   int    `json:"migrated"`   // Records upgraded in this batch
+}
@@ -1,21 +1,103 @@
 package model
 
+// SchemaVersion is the version of the records written by this chaincode.
+// Records written before versioning have no schemaVersion and are read as version 1.
+const SchemaVersion = 4
+
 // Account represents an account, including virtual administrators and several owner accounts.
+// UserName and Balance are kept in the account private data collection and anchored by PrivateHash.
 type Account struct {
-	AccountId string  `json:"accountId"` // Account ID
-	UserName  string  `json:"userName"`  // Account name
-	Balance   float64 `json:"balance"`   // Balance
+	AccountId     string  `json:"accountId"`          // Account ID
+	UserName      string  `json:"userName,omitempty"` // Account name (private)
+	Balance       float64 `json:"balance,omitempty"`  // Balance (private)
+	Role          string  `json:"role"`               // Account role
+	KycStatus     string  `json:"kycStatus"`          // Identity verification status, see KycStatusConstant; empty reads as unverified
+	KycVerifier   string  `json:"kycVerifier"`        // Verifier who reviewed the last verification request (AccountId)
+	KycExpiry     string  `json:"kycExpiry"`          // Time a verified identity expires
+	PrivateHash   string  `json:"privateHash"`        // SHA-256 hash of the AccountPrivate record
+	SchemaVersion int     `json:"schemaVersion"`      // Schema version of the record, see migration.go
+}
+
+// AccountPrivate is the part of an Account stored in the AccountCollection private data collection.
+// Salt is a random value the client passes in the transient data and is only stored here,
+// so that the hash anchored on the public ledger cannot be guessed from likely balances.
+type AccountPrivate struct {
+	UserName      string  `json:"userName"`      // Account name
+	Balance       float64 `json:"balance"`       // Balance
+	Salt          string  `json:"salt"`          // Salt of the hash anchored on the public ledger
+	SchemaVersion int     `json:"schemaVersion"` // Schema version of the record, see migration.go
 }
 
-// RealEstate is used as collateral for sale, donation, or pledge with Encumbrance set to true by default.
-// Initiating sale, donation, or pledge is only possible when Encumbrance is false.
+// AccountRoleConstant defines constants for account roles.
+var AccountRoleConstant = func() map[string]string {
+	return map[string]string{
+		"admin":      "Admin",      // Registry administrator
+		"owner":      "Owner",      // Ordinary account that owns, sells and buys real estate
+		"arbitrator": "Arbitrator", // Resolves disputes raised on sales in delivery
+		"authority":  "Authority",  // Carries out court orders: freezes real estate and forces transfers
+		"verifier":   "Verifier",   // Reviews identity verification (KYC) requests
+	}
+}
+
+// KycStatusConstant defines constants for identity verification (KYC) status.
+// Only verified accounts whose verification has not expired can sell, buy or receive a donation.
+var KycStatusConstant = func() map[string]string {
+	return map[string]string{
+		"unverified": "Unverified", // No verification requested
+		"pending":    "Pending",    // Verification requested, waiting for a verifier
+		"verified":   "Verified",   // Identity verified until KycExpiry
+		"rejected":   "Rejected",   // Verification refused by a verifier; the account may request it again
+	}
+}
+
+// RealEstate carries the encumbrances of the processes and rights that bind it, such as a sale or donation in progress.
+// Initiating a sale or donation is only possible while no encumbrance in force blocks transfers.
 // Proprietor and RealEstateID together form a composite key, ensuring that all real estate information can be queried by Proprietor.
 type RealEstate struct {
-	RealEstateID string  `json:"realEstateId"` // Real estate ID
-	Proprietor   string  `json:"proprietor"`   // Owner (proprietor) (Owner's AccountId)
-	Encumbrance  bool    `json:"encumbrance"`  // Whether it is used as collateral
-	TotalArea    float64 `json:"totalArea"`    // Total area
-	LivingSpace  float64 `json:"livingSpace"`  // Living space
+	RealEstateID  string        `json:"realEstateId"`  // Real estate ID
+	Proprietor    string        `json:"proprietor"`    // Owner (proprietor) (Owner's AccountId)
+	Encumbrances  []Encumbrance `json:"encumbrances"`  // Encumbrances on the real estate
+	TotalArea     float64       `json:"totalArea"`     // Total area
+	LivingSpace   float64       `json:"livingSpace"`   // Living space
+	PropertyType  string        `json:"propertyType"`  // Property type, see PropertyTypeConstant; empty when registered without one
+	TransferTxID  string        `json:"transferTxId"`  // ID of the transaction that gave the real estate to its proprietor
+	SchemaVersion int           `json:"schemaVersion"` // Schema version of the record, see migration.go
+	// Easements benefiting or burdening the real estate, attached by the real estate queries and not stored with the record
+	Easements []Easement `json:"easements,omitempty"`
+}
+
+// Encumbrance is a process or right that binds a real estate.
+// Each process adds and removes only its own entry, identified by its type and reference.
+type Encumbrance struct {
+	Type           string `json:"type"`           // Encumbrance type, see EncumbranceTypeConstant
+	Reference      string `json:"reference"`      // Reference of the record or document that created it, e.g. the sale
+	CreatedBy      string `json:"createdBy"`      // AccountId of the party that created it
+	StartTime      string `json:"startTime"`      // Time it takes effect
+	EndTime        string `json:"endTime"`        // Time it lapses, empty when it lasts until it is removed
+	BlocksTransfer bool   `json:"blocksTransfer"` // Whether the real estate cannot be sold or donated while it is in force
+}
+
+// EncumbranceTypeConstant defines constants for encumbrance types.
+var EncumbranceTypeConstant = func() map[string]string {
+	return map[string]string{
+		"sale":      "Sale",          // Listed for sale, from CreateSelling until the sale is closed
+		"donation":  "Donation",      // Offered as a donation, from CreateDonating until the donation is closed
+		"courtLock": "Court Lock",    // Frozen by a court order
+		"mortgage":  "Mortgage Lien", // Secures a loan
+		"easement":  "Easement",      // Right of way or use granted to another party
+		"lease":     "Lease",         // Let to a tenant
+		"legacy":    "Legacy",        // Sale or donation recorded before encumbrances were typed; removed by the sale or donation that closes
+	}
+}
+
+// PropertyTypeConstant defines constants for the types of real estate.
+var PropertyTypeConstant = func() map[string]string {
+	return map[string]string{
+		"apartment":  "Apartment",  // Unit in a multi-unit residential building
+		"house":      "House",      // Detached or semi-detached residential building
+		"commercial": "Commercial", // Office, retail or industrial premises
+		"land":       "Land",       // Undeveloped plot
+	}
 }
 
 // Selling represents a sales offer.
@@ -23,35 +105,155 @@ type RealEstate struct {
 // The buyer is initially empty.
 // Seller and ObjectOfSale together form a composite key, ensuring that all sales initiated by the seller can be queried.
 type Selling struct {
-	ObjectOfSale  string  `json:"objectOfSale"`  // Object being sold (RealEstateID currently for sale)
-	Seller        string  `json:"seller"`        // Initiator of the sale, seller (Seller's AccountId)
-	Buyer         string  `json:"buyer"`         // Participant in the sale, buyer (Buyer's AccountId)
-	Price         float64 `json:"price"`         // Price
-	CreateTime    string  `json:"createTime"`    // Creation time
-	SalePeriod    int     `json:"salePeriod"`    // Validity period of the smart contract (in days)
-	SellingStatus string  `json:"sellingStatus"` // Sale status
+	ObjectOfSale  string  `json:"objectOfSale"`    // Object being sold (RealEstateID currently for sale)
+	Seller        string  `json:"seller"`          // Initiator of the sale, seller (Seller's AccountId)
+	Buyer         string  `json:"buyer"`           // Participant in the sale, buyer (Buyer's AccountId)
+	Price         float64 `json:"price,omitempty"` // Price (private)
+	CreateTime    string  `json:"createTime"`      // Creation time
+	SalePeriod    int     `json:"salePeriod"`      // Validity period of the smart contract (in days)
+	SellingStatus string  `json:"sellingStatus"`   // Sale status
+	// Payment schedule; without installments the buyer pays the full price at once
+	Deposit         float64       `json:"deposit,omitempty"`      // Earnest money paid when the buyer commits (private)
+	Installments    []Installment `json:"installments,omitempty"` // Installments due after the deposit (private)
+	OnMissedPayment string        `json:"onMissedPayment"`        // What happens when an installment deadline is missed
+	GracePeriod     int           `json:"gracePeriod"`            // Extra time granted for a missed installment (in days)
+	AmountPaid      float64       `json:"amountPaid,omitempty"`   // Amount paid by the buyer and held in escrow (private)
+	// Cancellation
+	CancelledBy   string  `json:"cancelledBy,omitempty"`   // AccountId of the party that cancelled the sale
+	CancelReason  string  `json:"cancelReason,omitempty"`  // Reason given for cancelling
+	PenaltyPaidBy string  `json:"penaltyPaidBy,omitempty"` // Party that paid the cancellation penalty (seller or buyer)
+	Penalty       float64 `json:"penalty,omitempty"`       // Cancellation penalty paid to the other party (private)
+	// Completion
+	CompleteTime  string `json:"completeTime,omitempty"` // Time the title was transferred to the buyer
+	PrivateHash   string `json:"privateHash"`            // SHA-256 hash of the SellingPrivate record
+	SchemaVersion int    `json:"schemaVersion"`          // Schema version of the record, see migration.go
+}
+
+// SellingPrivate is the part of a Selling stored in the SellingCollection private data collection, under the key of the Selling record.
+// Like AccountPrivate, it is salted with a random value passed in the transient data so that its hash cannot be guessed from likely prices.
+type SellingPrivate struct {
+	Price         float64       `json:"price"`         // Negotiated price
+	Deposit       float64       `json:"deposit"`       // Earnest money paid when the buyer commits
+	Installments  []Installment `json:"installments"`  // Installments due after the deposit
+	AmountPaid    float64       `json:"amountPaid"`    // Amount paid by the buyer and held in escrow
+	Penalty       float64       `json:"penalty"`       // Cancellation penalty paid to the other party
+	Salt          string        `json:"salt"`          // Salt of the hash anchored on the public ledger
+	SchemaVersion int           `json:"schemaVersion"` // Schema version of the record, see migration.go
+}
+
+// Installment is one dated payment of a sale's payment schedule.
+// DueTime is fixed from DueDays when the buyer pays the deposit.
+type Installment struct {
+	Amount    float64 `json:"amount"`    // Amount due
+	DueDays   int     `json:"dueDays"`   // Days after the deposit when the installment is due
+	DueTime   string  `json:"dueTime"`   // Deadline of the installment
+	GraceTime string  `json:"graceTime"` // Extended deadline granted after the deadline was missed
+	PaidTime  string  `json:"paidTime"`  // Payment time, empty while unpaid
+}
+
+// MissedPaymentConstant defines what happens when the buyer misses an installment deadline.
+var MissedPaymentConstant = func() map[string]string {
+	return map[string]string{
+		"forfeit": "Forfeit",      // The sale is cancelled, the deposit goes to the seller and installments paid are refunded
+		"grace":   "Grace Period", // The deadline is extended once by the grace period, then the deposit is forfeited
+	}
 }
 
 // SellingStatusConstant defines constants for selling status.
 var SellingStatusConstant = func() map[string]string {
 	return map[string]string{
-		"saleStart": "Selling",   // Currently in the sale state, waiting for the buyer's visit
-		"cancelled": "Cancelled", // Canceled by the seller or buyer's refund operation
-		"expired":   "Expired",   // Sale period has expired
-		"delivery":  "In Progress", // Buyer has bought and paid, waiting for the seller to confirm the payment; if the seller fails to confirm the payment, the buyer can cancel and get a refund
+		"saleStart": "In Sale",     // Currently in the sale state, waiting for the buyer's visit
+		"cancelled": "Canceled",    // Canceled by the seller or buyer's refund operation
+		"expired":   "Expired",     // Sale period has expired
+		"delivery":  "In Delivery", // Buyer has bought and paid, waiting for the seller to confirm the payment; if the seller fails to confirm the payment, the buyer can cancel and get a refund
 		"done":      "Completed",   // Seller confirms the receipt of funds, completing the transaction
+		"dispute":   "In Dispute",  // The buyer or seller raised a dispute during delivery; the sale is frozen until an arbitrator resolves it
+		"payment":   "In Payment",  // Buyer paid the deposit and is paying installments; the sale moves to delivery after the final payment
+		"forfeited": "Forfeited",   // Buyer missed an installment deadline and the deposit went to the seller
 	}
 }
 
+// CancellationPartyConstant defines the parties that may cancel a sale.
+var CancellationPartyConstant = func() map[string]string {
+	return map[string]string{
+		"seller": "Seller", // The seller of the sale
+		"buyer":  "Buyer",  // The buyer of the sale
+		"admin":  "Admin",  // An admin account
+	}
+}
+
+// CancellationRulesConstant defines which parties may cancel a sale in each status, by SellingStatusConstant key.
+// Sales in dispute are closed by an arbitrator only.
+var CancellationRulesConstant = func() map[string][]string {
+	return map[string][]string{
+		"saleStart": {"seller", "admin"}, // Before purchase
+		"payment":   {"admin"},           // While installments are paid; missed payments are handled by the payment schedule
+		"delivery":  {"buyer", "admin"},  // After payment, while waiting for the seller to confirm
+	}
+}
+
+// CancellationPenalty is the compensation owed when a party cancels a sale in a given status.
+// The party PaidBy pays Rate percent of the price to the other party: the buyer out of the funds held in escrow,
+// the seller out of its balance. Without a penalty the buyer is refunded in full.
+type CancellationPenalty struct {
+	Canceller     string  `json:"canceller"`     // Party cancelling, a CancellationPartyConstant key
+	SellingStatus string  `json:"sellingStatus"` // Status of the sale when it is cancelled, a SellingStatusConstant key
+	PaidBy        string  `json:"paidBy"`        // Party paying the penalty, seller or buyer
+	Rate          float64 `json:"rate"`          // Percentage of the price paid
+	SchemaVersion int     `json:"schemaVersion"` // Schema version of the record, see migration.go
+}
+
 // SellingBuy represents a buyer's participation in the sale.
 // The Object of Sale cannot be initiated by the buyer.
-// Buyer and CreateTime form a composite key, ensuring that all sales participated by the buyer can be queried.
+// It is built from the Selling record and the SellingBuyerIndex of the buyer. Copies written under SellingBuyKey,
+// with Buyer and CreateTime as composite key, predate the index and are replaced by it when migrated.
 type SellingBuy struct {
-	Buyer      string  `json:"buyer"`      // Participant in the sale, buyer (Buyer's AccountId)
-	CreateTime string  `json:"createTime"` // Creation time
-	Selling    Selling `json:"selling"`    // Sale object
+	Buyer         string  `json:"buyer"`         // Participant in the sale, buyer (Buyer's AccountId)
+	CreateTime    string  `json:"createTime"`    // Creation time
+	Selling       Selling `json:"selling"`       // Sale object
+	SchemaVersion int     `json:"schemaVersion"` // Schema version of the record, see migration.go
 }
 
+// SellingBuyerIndex points a buyer to a sale it bought, so that the Selling record stays the only record of the purchase.
+// Buyer, Seller and ObjectOfSale form a composite key, ensuring that all sales bought by the buyer can be queried.
+type SellingBuyerIndex struct {
+	Buyer         string `json:"buyer"`         // Buyer (Buyer's AccountId)
+	Seller        string `json:"seller"`        // Seller of the sale (Seller's AccountId)
+	ObjectOfSale  string `json:"objectOfSale"`  // Object of the sale (RealEstateID)
+	ListingTime   string `json:"listingTime"`   // Creation time of the sale, telling it apart from later sales of the same real estate
+	CreateTime    string `json:"createTime"`    // Purchase time
+	SchemaVersion int    `json:"schemaVersion"` // Schema version of the record, see migration.go
+}
+
+// SellingStatusIndex lists a sale under its status, so that the sales in a status can be found across all sellers.
+// SellingStatus, Seller and ObjectOfSale form a composite key; the entry moves to the new status on every transition.
+type SellingStatusIndex struct {
+	SellingStatus string `json:"sellingStatus"` // Status of the sale, see SellingStatusConstant
+	Seller        string `json:"seller"`        // Seller of the sale (Seller's AccountId)
+	ObjectOfSale  string `json:"objectOfSale"`  // Object of the sale (RealEstateID)
+	SchemaVersion int    `json:"schemaVersion"` // Schema version of the record, see migration.go
+}
+
+// ActiveListing is a sale in progress joined with the real estate it sells
+type ActiveListing struct {
+	Selling    Selling    `json:"selling"`    // Sale object, including its price
+	RealEstate RealEstate `json:"realEstate"` // Real estate on sale
+}
+
+// ActiveListingPage is one page of active listings.
+// Passing Bookmark to the next query continues after the last listing examined, until Done is true.
+// A page holds fewer listings than asked when the filters leave out the listings examined.
+type ActiveListingPage struct {
+	Listings []ActiveListing `json:"listings"` // Listings matching the filters
+	Bookmark string          `json:"bookmark"` // Bookmark of the next listing to examine
+	Done     bool            `json:"done"`     // Whether all active listings have been examined
+}
+
+const (
+	ActiveListingPageLimit = 100 // Largest number of listings a page may hold
+	ActiveListingScanLimit = 500 // Largest number of listings a query may examine, whether they match the filters or not
+)
+
 // Donating represents a donation offer.
 // It's necessary to confirm if ObjectOfDonating belongs to Donor.
 // Specify the Grantee and wait for the Grantee's agreement to accept.
@@ -61,22 +263,295 @@ type Donating struct {
 	Grantee          string `json:"grantee"`          // Grantee (Grantee's AccountId)
 	CreateTime       string `json:"createTime"`       // Creation time
 	DonatingStatus   string `json:"donatingStatus"`   // Donation status
+	SchemaVersion    int    `json:"schemaVersion"`    // Schema version of the record, see migration.go
 }
 
 // DonatingStatusConstant defines constants for donation status.
 var DonatingStatusConstant = func() map[string]string {
 	return map[string]string{
-		"donatingStart": "In Progress", // Donor initiates a donation contract, waiting for the Grantee to confirm the donation
-		"cancelled":     "Cancelled",  // Donor cancels the donation before the Grantee confirms the donation, or the Grantee cancels the acceptance of the donation
-		"done":          "Completed",  // Grantee confirms receipt, completing the transaction
+		"donatingStart": "In Donation", // Donor initiates a donation contract, waiting for the Grantee to confirm the donation
+		"cancelled":     "Canceled",    // Donor cancels the donation before the Grantee confirms the donation, or the Grantee cancels the acceptance of the donation
+		"done":          "Completed",   // Grantee confirms receipt, completing the transaction
 	}
 }
 
 // DonatingGrantee is used for Grantee to query donations.
+// It is built from the Donating record, found through the DonatingGranteeIndex of the grantee. Copies written under
+// DonatingGranteeKey, with Grantee and CreateTime as composite key, predate the index and are replaced by it when migrated.
 type DonatingGrantee struct {
-	Grantee    string   `json:"grantee"`    // Grantee (Grantee's AccountId)
-	CreateTime string   `json:"createTime"` // Creation time
-	Donating   Donating `json:"donating"`   // Donation object
+	Grantee       string   `json:"grantee"`       // Grantee (Grantee's AccountId)
+	CreateTime    string   `json:"createTime"`    // Creation time
+	Donating      Donating `json:"donating"`      // Donation object
+	SchemaVersion int      `json:"schemaVersion"` // Schema version of the record, see migration.go
+}
+
+// DonatingGranteeIndex points a grantee to a donation offered to it, so that the Donating record stays the only record of the donation.
+// Grantee, Donor and ObjectOfDonating form a composite key, ensuring that all donations to the grantee can be queried.
+type DonatingGranteeIndex struct {
+	Grantee          string `json:"grantee"`          // Grantee (Grantee's AccountId)
+	Donor            string `json:"donor"`            // Donor (Donor's AccountId)
+	ObjectOfDonating string `json:"objectOfDonating"` // Object being donated (RealEstateID)
+	SchemaVersion    int    `json:"schemaVersion"`    // Schema version of the record, see migration.go
+}
+
+// Dispute records a dispute raised by the buyer or seller on a sale in delivery.
+// While a dispute is open the sale is frozen and only an arbitrator can close it.
+// Seller, ObjectOfSale and CreateTime form a composite key, ensuring that all disputes of a sale can be queried.
+type Dispute struct {
+	ObjectOfSale   string   `json:"objectOfSale"`   // Object being sold (RealEstateID of the disputed sale)
+	Seller         string   `json:"seller"`         // Seller (Seller's AccountId)
+	Buyer          string   `json:"buyer"`          // Buyer (Buyer's AccountId)
+	RaisedBy       string   `json:"raisedBy"`       // Party who raised the dispute (AccountId)
+	Reason         string   `json:"reason"`         // Reason given by the party raising the dispute
+	Evidence       []string `json:"evidence"`       // SHA-256 hashes of the evidence submitted by either party
+	CreateTime     string   `json:"createTime"`     // Creation time
+	DisputeStatus  string   `json:"disputeStatus"`  // Dispute status
+	Arbitrator     string   `json:"arbitrator"`     // Arbitrator who resolved the dispute (AccountId)
+	Decision       string   `json:"decision"`       // Decision taken by the arbitrator
+	Amount         float64  `json:"amount"`         // Partial refund or penalty amount, depending on the decision
+	PenalizedParty string   `json:"penalizedParty"` // Party paying the penalty (AccountId)
+	DecisionNote   string   `json:"decisionNote"`   // Arbitrator's reasoning
+	ResolveTime    string   `json:"resolveTime"`    // Resolution time
+	SchemaVersion  int      `json:"schemaVersion"`  // Schema version of the record, see migration.go
+}
+
+// Easement is a right of a dominant real estate over a servient one, such as a right of way or a utility line.
+// It is recorded by RealEstateID, so it stays with both real estates when either changes hands,
+// and the servient real estate carries an easement encumbrance that does not block transfers.
+// EasementID forms the composite key.
+type Easement struct {
+	EasementID           string `json:"easementId"`           // Easement ID
+	Type                 string `json:"type"`                 // Easement type, see EasementTypeConstant
+	Description          string `json:"description"`          // What the easement allows, e.g. the route of a right of way
+	DominantRealEstateID string `json:"dominantRealEstateId"` // Real estate that benefits from the easement
+	ServientRealEstateID string `json:"servientRealEstateId"` // Real estate burdened by the easement
+	StartTime            string `json:"startTime"`            // Time it was registered
+	EndTime              string `json:"endTime"`              // End of its term, empty when permanent
+	CreatedBy            string `json:"createdBy"`            // Admin that registered it (AccountId)
+	EasementStatus       string `json:"easementStatus"`       // Easement status
+	SchemaVersion        int    `json:"schemaVersion"`        // Schema version of the record, see migration.go
+}
+
+// EasementTypeConstant defines constants for easement types.
+var EasementTypeConstant = func() map[string]string {
+	return map[string]string{
+		"rightOfWay": "Right of Way",    // Passage over the servient real estate
+		"utility":    "Utility",         // Pipes, cables or lines across the servient real estate
+		"drainage":   "Drainage",        // Water drained through the servient real estate
+		"light":      "Light and Air",   // The servient real estate may not be built up to block light or air
+		"support":    "Support",         // The servient real estate supports buildings of the dominant one
+		"other":      "Other Servitude", // Any other servitude, described in the description
+	}
+}
+
+// EasementStatusConstant defines constants for easement status.
+var EasementStatusConstant = func() map[string]string {
+	return map[string]string{
+		"active":   "Active",   // In force until the end of its term, if any
+		"released": "Released", // Released before the end of its term
+	}
+}
+
+// EndorsementPolicy is the key-level endorsement policy of a real estate, see api/endorsement.go.
+// Changes to the real estate must be endorsed by a member of every organization listed;
+// without any, the chaincode endorsement policy applies.
+type EndorsementPolicy struct {
+	RealEstateID string   `json:"realEstateId"` // Real estate ID
+	Orgs         []string `json:"orgs"`         // MSP IDs of the organizations that must endorse, in sorted order
+}
+
+// Each RealEstate is also a non-fungible token whose token ID is its RealEstateID, see api/token.go
+const (
+	TokenName   = "Real Estate Title" // Name of the token collection
+	TokenSymbol = "RET"               // Symbol of the token collection
+)
+
+// TokenApproval lets an account other than the owner transfer one real estate token.
+// It only applies while Owner still owns the real estate, so it lapses when the real estate changes hands.
+// TokenID forms the composite key.
+type TokenApproval struct {
+	TokenID       string `json:"tokenId"`       // RealEstateID of the token
+	Owner         string `json:"owner"`         // Owner that gave the approval (AccountId)
+	Approved      string `json:"approved"`      // Account approved to transfer the token (AccountId)
+	SchemaVersion int    `json:"schemaVersion"` // Schema version of the record, see migration.go
+}
+
+// OperatorApproval lets an operator transfer every real estate token of an owner.
+// Owner and Operator form a composite key.
+type OperatorApproval struct {
+	Owner         string `json:"owner"`         // Owner (AccountId)
+	Operator      string `json:"operator"`      // Operator (AccountId)
+	Approved      bool   `json:"approved"`      // Whether the operator is approved
+	SchemaVersion int    `json:"schemaVersion"` // Schema version of the record, see migration.go
+}
+
+// TokenMetadata describes a real estate token for wallets
+type TokenMetadata struct {
+	TokenID      string        `json:"tokenId"`      // RealEstateID of the token
+	Name         string        `json:"name"`         // Name of the token collection
+	Symbol       string        `json:"symbol"`       // Symbol of the token collection
+	Description  string        `json:"description"`  // Human readable description of the real estate
+	Owner        string        `json:"owner"`        // Proprietor (AccountId)
+	PropertyType string        `json:"propertyType"` // Property type
+	TotalArea    float64       `json:"totalArea"`    // Total area
+	LivingSpace  float64       `json:"livingSpace"`  // Living space
+	Encumbrances []Encumbrance `json:"encumbrances"` // Encumbrances on the real estate
+	Transferable bool          `json:"transferable"` // Whether no encumbrance in force blocks its transfer
+}
+
+// TransferEvent is the payload of the Transfer event set when a real estate changes hands
+type TransferEvent struct {
+	From    string `json:"from"`    // Former proprietor (AccountId)
+	To      string `json:"to"`      // New proprietor (AccountId)
+	TokenID string `json:"tokenId"` // RealEstateID
+}
+
+// ApprovalEvent is the payload of the Approval event
+type ApprovalEvent struct {
+	Owner    string `json:"owner"`    // Owner (AccountId)
+	Approved string `json:"approved"` // Account approved, empty when the approval is cleared (AccountId)
+	TokenID  string `json:"tokenId"`  // RealEstateID
+}
+
+// ApprovalForAllEvent is the payload of the ApprovalForAll event
+type ApprovalForAllEvent struct {
+	Owner    string `json:"owner"`    // Owner (AccountId)
+	Operator string `json:"operator"` // Operator (AccountId)
+	Approved bool   `json:"approved"` // Whether the operator is approved
+}
+
+// CourtOrder records an action taken on a real estate to carry out a court order.
+// RealEstateID and TxID form a composite key, ensuring that all court orders on a real estate can be queried.
+type CourtOrder struct {
+	RealEstateID   string `json:"realEstateId"`   // Real estate acted on
+	Action         string `json:"action"`         // Action taken, see CourtOrderActionConstant
+	OrderReference string `json:"orderReference"` // Reference of the court order
+	Authority      string `json:"authority"`      // Admin or authority that carried out the order (AccountId)
+	Proprietor     string `json:"proprietor"`     // Proprietor when the order was carried out (AccountId)
+	NewProprietor  string `json:"newProprietor"`  // Proprietor the real estate was transferred to, for a forced transfer (AccountId)
+	TxID           string `json:"txId"`           // Transaction that carried out the order
+	CreateTime     string `json:"createTime"`     // Time the order was carried out
+	SchemaVersion  int    `json:"schemaVersion"`  // Schema version of the record, see migration.go
+}
+
+// CourtOrderActionConstant defines the actions taken on court orders.
+var CourtOrderActionConstant = func() map[string]string {
+	return map[string]string{
+		"freeze":        "Freeze",          // Encumber the real estate with a court lock that blocks sales, donations and transfers
+		"unfreeze":      "Unfreeze",        // Remove the court lock of the order
+		"forceTransfer": "Forced Transfer", // Cancel the sale or donation in progress and transfer the real estate
+	}
+}
+
+// Delegation is a power of attorney: the principal lets the agent act on its behalf
+// within a scope, on one real estate or on all of them, until EndTime.
+// Principal and DelegationID form a composite key, ensuring that all delegations of a principal can be queried.
+type Delegation struct {
+	DelegationID     string `json:"delegationId"`     // Delegation ID
+	Principal        string `json:"principal"`        // Account granting the authority (AccountId)
+	Agent            string `json:"agent"`            // Account acting on its behalf (AccountId)
+	Scope            string `json:"scope"`            // What the agent may do, see DelegationScopeConstant
+	RealEstateID     string `json:"realEstateId"`     // Real estate the authority is limited to, empty for any
+	StartTime        string `json:"startTime"`        // Time it was granted
+	EndTime          string `json:"endTime"`          // Time it lapses
+	DelegationStatus string `json:"delegationStatus"` // Delegation status
+	RevokeTime       string `json:"revokeTime"`       // Time it was revoked, empty while active
+	SchemaVersion    int    `json:"schemaVersion"`    // Schema version of the record, see migration.go
+}
+
+// DelegationScopeConstant defines the scopes of delegations.
+var DelegationScopeConstant = func() map[string]string {
+	return map[string]string{
+		"sell":           "Sell",            // List for sale, confirm or cancel the sale as seller
+		"buy":            "Buy",             // Buy, or cancel the purchase as buyer
+		"donate":         "Donate",          // Start or cancel a donation as donor
+		"acceptDonation": "Accept Donation", // Accept or decline a donation as grantee
+	}
+}
+
+// DelegationStatusConstant defines constants for delegation status.
+var DelegationStatusConstant = func() map[string]string {
+	return map[string]string{
+		"active":  "Active",  // In force until its end time
+		"revoked": "Revoked", // Revoked by the principal before its end time
+	}
+}
+
+// DelegatedAction records a transaction an agent carried out on behalf of a principal.
+// Principal and TxID form a composite key, ensuring that all actions taken on behalf of a principal can be queried.
+type DelegatedAction struct {
+	DelegationID  string `json:"delegationId"`  // Delegation the agent acted under
+	Principal     string `json:"principal"`     // Account acted for (AccountId)
+	Agent         string `json:"agent"`         // Account that acted (AccountId)
+	Function      string `json:"function"`      // Chaincode function invoked
+	RealEstateID  string `json:"realEstateId"`  // Real estate acted on
+	TxID          string `json:"txId"`          // Transaction of the action
+	CreateTime    string `json:"createTime"`    // Time of the action
+	SchemaVersion int    `json:"schemaVersion"` // Schema version of the record, see migration.go
+}
+
+// DisputeStatusConstant defines constants for dispute status.
+var DisputeStatusConstant = func() map[string]string {
+	return map[string]string{
+		"open":     "Open",     // Waiting for an arbitrator's decision
+		"resolved": "Resolved", // An arbitrator has taken a decision and the sale is closed
+	}
+}
+
+// DisputeDecisionConstant defines the decisions an arbitrator can take.
+var DisputeDecisionConstant = func() map[string]string {
+	return map[string]string{
+		"refund":        "Refund",         // The sale is cancelled and the buyer is refunded in full
+		"partialRefund": "Partial Refund", // The sale completes and part of the price is returned to the buyer
+		"complete":      "Completed",      // The sale completes and the seller receives the full price
+		"penalty":       "Penalty",        // The sale is cancelled, the buyer is refunded and the penalized party compensates the other
+	}
+}
+
+// Supply records the total amount of funds minted into accounts.
+// Balances plus funds held in escrow by sales must always add up to TotalMinted.
+type Supply struct {
+	TotalMinted   float64 `json:"totalMinted"`   // Total amount minted
+	SchemaVersion int     `json:"schemaVersion"` // Schema version of the record, see migration.go
+}
+
+// AuditViolation is a broken ledger invariant found by auditLedger.
+type AuditViolation struct {
+	Invariant string   `json:"invariant"` // Invariant that does not hold
+	Keys      []string `json:"keys"`      // Composite key attributes of the offending record
+	Detail    string   `json:"detail"`    // Description of the violation
+}
+
+// AuditInvariantConstant defines the invariants checked by auditLedger.
+var AuditInvariantConstant = func() map[string]string {
+	return map[string]string{
+		"encumbrance":     "Encumbrance",      // Every real estate encumbered by a sale or donation has exactly one active listing or donation, and vice versa
+		"sellingBuy":      "Selling Buy",      // Every sold Selling is indexed for its buyer
+		"supply":          "Supply",           // Balances plus escrowed funds add up to the total minted
+		"donatingGrantee": "Donating Grantee", // Every Donating is indexed for its grantee, and every index entry has its Donating record
+	}
+}
+
+// Private data collections, see collections_config.json
+const (
+	AccountCollection = "collectionAccountPrivate" // Account names and balances
+	SellingCollection = "collectionSellingPrivate" // Negotiated prices and payment schedules
+)
+
+// TransientSaltKey is the transient data key of the random salt of the private data written by a transaction.
+// Transactions that write private data must pass at least SaltMinLength random bytes under it.
+const (
+	TransientSaltKey = "salt"
+	SaltMinLength    = 16
+)
+
+// CollectionMembersConstant lists the organizations (MSP IDs) allowed to read each private data collection.
+// It must match the member policies in collections_config.json.
+var CollectionMembersConstant = func() map[string][]string {
+	return map[string][]string{
+		AccountCollection: {"JDMSP", "TaobaoMSP"},
+		SellingCollection: {"JDMSP", "TaobaoMSP"},
+	}
 }
 
 const (
@@ -86,4 +561,29 @@ const (
 	SellingBuyKey      = "selling-buy-key"
 	DonatingKey        = "donating-key"
 	DonatingGranteeKey = "donating-grantee-key"
+	DisputeKey         = "dispute-key"
+	SupplyKey          = "supply-key"
+
+	CancellationPenaltyKey  = "cancellation-penalty-key"
+	CourtOrderKey           = "court-order-key"
+	EasementKey             = "easement-key"
+	TokenApprovalKey        = "token-approval-key"
+	OperatorApprovalKey     = "operator-approval-key"
+	DelegationKey           = "delegation-key"
+	DelegatedActionKey      = "delegated-action-key"
+	RealEstateIDKey         = "real-estate-id-key"         // Uniqueness index of RealEstateID across proprietors
+	SellingBuyerIndexKey    = "selling-buyer-index-key"    // Index of the sales bought by each buyer
+	DonatingGranteeIndexKey = "donating-grantee-index-key" // Index of the donations offered to each grantee
+	SellingStatusIndexKey   = "selling-status-index-key"   // Index of the sales in each status
 )
+
+// RealEstateIndex reserves a RealEstateID, which stays with the real estate across transfers,
+// and records the current proprietor so that the real estate can be read by ID alone
+type RealEstateIndex struct {
+	RealEstateID  string `json:"realEstateId"`  // Real estate ID
+	Proprietor    string `json:"proprietor"`    // Current proprietor, updated on every transfer
+	SchemaVersion int    `json:"schemaVersion"` // Schema version of the record, see migration.go
+}
+
+// RealEstateBatchLimit is the largest number of real estates a single createRealEstateBatch transaction may register
+const RealEstateBatchLimit = 100
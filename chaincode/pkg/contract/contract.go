@@ -0,0 +1,179 @@
+package contract
+
+import (
+	"chaincode/pkg/errcode"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// MetadataFunction is the function name clients use to fetch the contract metadata.
+// SystemMetadataFunction is the name used by the Fabric contract API, so that generic tooling finds it too.
+const (
+	MetadataFunction       = "getMetadata"
+	SystemMetadataFunction = "org.hyperledger.fabric:GetMetadata"
+)
+
+var (
+	stubType     = reflect.TypeOf((*shim.ChaincodeStubInterface)(nil)).Elem()
+	responseType = reflect.TypeOf(pb.Response{})
+)
+
+// Transaction declares a chaincode function.
+// Handler is either func(shim.ChaincodeStubInterface) pb.Response, or func(shim.ChaincodeStubInterface, *Request) pb.Response
+// where Request is a struct decoded from a single JSON request document, see decodeDocument,
+// or from positional arguments for older clients, see decodeArgs.
+type Transaction struct {
+	Name        string      // Function name used by clients
+	Description string      // What the function does
+	Submit      bool        // Whether the function writes to the ledger and must be submitted rather than evaluated
+	Handler     interface{} // Typed handler
+	Returns     interface{} // Value of the type of the response payload, nil when the payload is empty
+}
+
+// Contract dispatches the chaincode functions to their typed handlers
+type Contract struct {
+	name         string
+	version      string
+	transactions []Transaction
+	requests     map[string]reflect.Type // Request struct type of each function, nil for functions without parameters
+}
+
+// New checks the handlers of the transactions and builds the contract
+func New(name string, version string, transactions []Transaction) (*Contract, error) {
+	c := &Contract{name: name, version: version, transactions: transactions, requests: map[string]reflect.Type{}}
+	for _, tx := range transactions {
+		if _, ok := c.requests[tx.Name]; ok || tx.Name == MetadataFunction || tx.Name == SystemMetadataFunction {
+			return nil, fmt.Errorf("Function %s is declared more than once", tx.Name)
+		}
+		handler := reflect.TypeOf(tx.Handler)
+		if handler == nil || handler.Kind() != reflect.Func || handler.NumOut() != 1 || handler.Out(0) != responseType ||
+			handler.NumIn() < 1 || handler.NumIn() > 2 || handler.In(0) != stubType {
+			return nil, fmt.Errorf("Function %s has an unsupported handler %v", tx.Name, handler)
+		}
+		var request reflect.Type
+		if handler.NumIn() == 2 {
+			if handler.In(1).Kind() != reflect.Ptr || handler.In(1).Elem().Kind() != reflect.Struct {
+				return nil, fmt.Errorf("Function %s must take a pointer to a request struct", tx.Name)
+			}
+			request = handler.In(1).Elem()
+			if err := checkRequest(request); err != nil {
+				return nil, fmt.Errorf("Function %s: %s", tx.Name, err)
+			}
+		}
+		c.requests[tx.Name] = request
+	}
+	return c, nil
+}
+
+// MustNew is like New but panics when a handler is invalid
+func MustNew(name string, version string, transactions []Transaction) *Contract {
+	c, err := New(name, version, transactions)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Invoke decodes the arguments of the called function and runs its handler
+func (c *Contract) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	funcName, args := stub.GetFunctionAndParameters()
+	if funcName == MetadataFunction || funcName == SystemMetadataFunction {
+		metadata, err := json.Marshal(c.Metadata())
+		if err != nil {
+			return errcode.Responsef(errcode.Internal, "Metadata - Serialization error: %s", err)
+		}
+		return shim.Success(metadata)
+	}
+	for _, tx := range c.transactions {
+		if tx.Name != funcName {
+			continue
+		}
+		in := []reflect.Value{reflect.ValueOf(stub)}
+		request := c.requests[tx.Name]
+		if request == nil {
+			// A document for a function without parameters still has its schema version checked
+			request = reflect.TypeOf(struct{}{})
+		}
+		value := reflect.New(request)
+		var err error
+		if isDocument(args) {
+			err = decodeDocument(args[0], value.Elem())
+		} else {
+			err = decodeArgs(args, value.Elem())
+		}
+		if err == nil && hasTransient(request) {
+			var transient map[string][]byte
+			if transient, err = stub.GetTransient(); err == nil {
+				err = decodeTransient(transient, value.Elem())
+			}
+		}
+		if err != nil {
+			return errcode.Responsef(errcode.InvalidArgument, "%s", err)
+		}
+		if c.requests[tx.Name] != nil {
+			in = append(in, value)
+		}
+		// Every failure reaches the client as an error envelope, with the Internal code unless the handler chose one
+		return errcode.Wrap(reflect.ValueOf(tx.Handler).Call(in)[0].Interface().(pb.Response))
+	}
+	return errcode.Responsef(errcode.NotFound, "Function not found: %s", funcName)
+}
+
+// checkRequest ensures that positional arguments can be decoded into a request struct.
+// Fields read from the transient data are not positional, so they may come anywhere.
+func checkRequest(request reflect.Type) error {
+	optional := false
+	last := -1
+	for i := 0; i < request.NumField(); i++ {
+		if !hasOption(request.Field(i), "transient") {
+			last = i
+		}
+	}
+	for i := 0; i < request.NumField(); i++ {
+		field := request.Field(i)
+		if field.Tag.Get("json") == "" {
+			return fmt.Errorf("field %s has no json name", field.Name)
+		}
+		transient := hasOption(field, "transient")
+		if field.Type.Kind() == reflect.Slice {
+			if !transient && i != last {
+				return fmt.Errorf("list field %s must be the last field", field.Name)
+			}
+			element := field.Type.Elem()
+			if element.Kind() == reflect.Struct {
+				for j := 0; j < element.NumField(); j++ {
+					if !scalar(element.Field(j).Type) {
+						return fmt.Errorf("list field %s may only hold scalar fields", field.Name)
+					}
+				}
+			} else if !scalar(element) {
+				return fmt.Errorf("list field %s has an unsupported type", field.Name)
+			}
+		} else if !scalar(field.Type) {
+			return fmt.Errorf("field %s has an unsupported type", field.Name)
+		}
+		if transient {
+			continue
+		}
+		if hasOption(field, "optional") {
+			optional = true
+		} else if optional {
+			return errors.New("optional fields must come last")
+		}
+	}
+	return nil
+}
+
+// scalar reports whether a type can be decoded from a single argument
+func scalar(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Float64, reflect.Int, reflect.Bool:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+package contract
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// decodeArgs fills a request struct from positional arguments, one argument per field in declaration order.
+// Fields must be present and non-empty unless tagged:
+//   - contract:"optional" may be left out at the end of the arguments, or passed empty
+//   - contract:"allowEmpty" must be passed but may be empty, which decodes to the zero value
+//   - contract:"transient" is not a positional argument but read from the transient data, see decodeTransient
+//
+// Arguments that still pass the transient fields in their place among the positional ones, as older clients did,
+// are refused with a message naming those fields rather than decoded into the wrong fields.
+//
+// A trailing list field takes all remaining arguments; a list of structs takes one argument per struct field for each element,
+// which may only be empty for optional struct fields.
+// A list needs at least one element unless it is optional.
+func decodeArgs(args []string, request reflect.Value) error {
+	if hasTransient(request.Type()) && decodeLayout(args, reflect.New(request.Type()).Elem(), false) == nil {
+		return fmt.Errorf("The %s parameters are no longer positional and must be passed in the transient data",
+			strings.Join(transientNames(request.Type()), ", "))
+	}
+	return decodeLayout(args, request, true)
+}
+
+// decodeLayout fills a request struct from positional arguments. Fields read from the transient data are skipped,
+// unless the arguments are checked against the layout older clients used, when those fields were positional.
+func decodeLayout(args []string, request reflect.Value, skipTransient bool) error {
+	requestType := request.Type()
+	i := 0
+	for f := 0; f < requestType.NumField(); f++ {
+		field := requestType.Field(f)
+		if skipTransient && hasOption(field, "transient") {
+			continue
+		}
+		name := jsonName(field)
+		if field.Type.Kind() == reflect.Slice {
+			return decodeList(args[i:], request.Field(f), name, hasOption(field, "optional"))
+		}
+		if i >= len(args) {
+			if hasOption(field, "optional") {
+				return nil
+			}
+			return errors.New("Insufficient number of parameters")
+		}
+		if args[i] == "" && required(field) {
+			return errors.New("Parameters contain empty values")
+		}
+		if err := decodeScalar(args[i], request.Field(f), name); err != nil {
+			return err
+		}
+		i++
+	}
+	if i < len(args) {
+		return errors.New("Incorrect number of parameters")
+	}
+	return nil
+}
+
+// decodeList fills a trailing list field with the remaining arguments
+func decodeList(args []string, list reflect.Value, name string, optional bool) error {
+	if len(args) == 0 && !optional {
+		return errors.New("Insufficient number of parameters")
+	}
+	element := list.Type().Elem()
+	width := 1
+	if element.Kind() == reflect.Struct {
+		width = element.NumField()
+	}
+	if len(args)%width != 0 {
+		return fmt.Errorf("Incorrect number of %s parameters", name)
+	}
+	values := reflect.MakeSlice(list.Type(), len(args)/width, len(args)/width)
+	for i := 0; i < values.Len(); i++ {
+		item := values.Index(i)
+		for j := 0; j < width; j++ {
+			arg := args[i*width+j]
+			target, targetName, optional := item, name, false
+			if element.Kind() == reflect.Struct {
+				target, targetName = item.Field(j), jsonName(element.Field(j))
+				optional = hasOption(element.Field(j), "optional")
+			}
+			if arg == "" && !optional {
+				return errors.New("Parameters contain empty values")
+			}
+			if err := decodeScalar(arg, target, targetName); err != nil {
+				return err
+			}
+		}
+	}
+	list.Set(values)
+	return nil
+}
+
+// decodeScalar parses one argument into a field, an empty argument leaves the zero value
+func decodeScalar(arg string, field reflect.Value, name string) error {
+	if arg == "" {
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(arg)
+	case reflect.Float64:
+		value, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return fmt.Errorf("Failed to convert the %s parameter: %s", name, err)
+		}
+		field.SetFloat(value)
+	case reflect.Int:
+		value, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("Failed to convert the %s parameter: %s", name, err)
+		}
+		field.SetInt(int64(value))
+	case reflect.Bool:
+		value, err := strconv.ParseBool(arg)
+		if err != nil {
+			return fmt.Errorf("Failed to convert the %s parameter: %s", name, err)
+		}
+		field.SetBool(value)
+	}
+	return nil
+}
+
+// decodeTransient fills the fields tagged contract:"transient" from the transient data of the proposal.
+// Each field is passed as JSON under its json name. Transient data is not written to the ledger,
+// so it carries the values only the organizations of a private data collection may see.
+func decodeTransient(transient map[string][]byte, request reflect.Value) error {
+	requestType := request.Type()
+	for f := 0; f < requestType.NumField(); f++ {
+		field := requestType.Field(f)
+		if !hasOption(field, "transient") {
+			continue
+		}
+		name := jsonName(field)
+		raw, ok := transient[name]
+		if !ok || len(raw) == 0 {
+			if hasOption(field, "optional") {
+				continue
+			}
+			return fmt.Errorf("The %s parameter must be passed in the transient data", name)
+		}
+		if err := decodeField(field, json.RawMessage(raw), request.Field(f)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasTransient reports whether a request struct has fields read from the transient data
+func hasTransient(requestType reflect.Type) bool {
+	for f := 0; f < requestType.NumField(); f++ {
+		if hasOption(requestType.Field(f), "transient") {
+			return true
+		}
+	}
+	return false
+}
+
+// transientNames returns the json names of the fields read from the transient data
+func transientNames(requestType reflect.Type) []string {
+	var names []string
+	for f := 0; f < requestType.NumField(); f++ {
+		if hasOption(requestType.Field(f), "transient") {
+			names = append(names, jsonName(requestType.Field(f)))
+		}
+	}
+	return names
+}
+
+// hasOption reports whether a field carries an option of its contract tag, e.g. contract:"transient,optional"
+func hasOption(field reflect.StructField, option string) bool {
+	for _, v := range strings.Split(field.Tag.Get("contract"), ",") {
+		if v == option {
+			return true
+		}
+	}
+	return false
+}
+
+// required reports whether a field must be passed with a value
+func required(field reflect.StructField) bool {
+	return !hasOption(field, "optional") && !hasOption(field, "allowEmpty")
+}
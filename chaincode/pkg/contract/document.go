@@ -0,0 +1,100 @@
+package contract
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// RequestSchemaVersion is the version of the JSON request documents the contract accepts.
+// It changes whenever a request struct changes in a way older clients would get wrong.
+const RequestSchemaVersion = 1
+
+// isDocument reports whether the arguments are a single JSON request document rather than positional arguments
+func isDocument(args []string) bool {
+	return len(args) == 1 && strings.HasPrefix(strings.TrimSpace(args[0]), "{")
+}
+
+// decodeDocument fills a request struct from a JSON request document.
+// The document carries the fields of the request by their json names along with its schemaVersion.
+// Fields must be present, and strings and lists non-empty, unless tagged contract:"optional" or contract:"allowEmpty".
+// Unknown fields are refused so that a misspelt field is not silently ignored, and so are the fields tagged contract:"transient",
+// which would otherwise be written to the ledger with the proposal.
+func decodeDocument(arg string, request reflect.Value) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(arg), &fields); err != nil {
+		return fmt.Errorf("The request document is not a JSON object: %s", err)
+	}
+	raw, ok := fields["schemaVersion"]
+	if !ok {
+		return errors.New("The request document has no schemaVersion")
+	}
+	var version int
+	if err := json.Unmarshal(raw, &version); err != nil || version != RequestSchemaVersion {
+		return fmt.Errorf("Request schema version %s is not supported, expected %d", raw, RequestSchemaVersion)
+	}
+	delete(fields, "schemaVersion")
+	return decodeObject(fields, request)
+}
+
+// decodeObject fills a struct from the fields of a JSON object
+func decodeObject(fields map[string]json.RawMessage, value reflect.Value) error {
+	valueType := value.Type()
+	known := map[string]bool{}
+	for f := 0; f < valueType.NumField(); f++ {
+		field := valueType.Field(f)
+		if hasOption(field, "transient") {
+			continue
+		}
+		name := jsonName(field)
+		known[name] = true
+		raw, ok := fields[name]
+		if !ok || string(raw) == "null" {
+			if required(field) {
+				return fmt.Errorf("The %s parameter is missing", name)
+			}
+			continue
+		}
+		if err := decodeField(field, raw, value.Field(f)); err != nil {
+			return err
+		}
+	}
+	var unknown []string
+	for name := range fields {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("Unknown parameters: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// decodeField fills a field from its JSON value
+func decodeField(field reflect.StructField, raw json.RawMessage, target reflect.Value) error {
+	name := jsonName(field)
+	if field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct {
+		var items []map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return fmt.Errorf("Failed to convert the %s parameter: %s", name, err)
+		}
+		values := reflect.MakeSlice(field.Type, len(items), len(items))
+		for i, item := range items {
+			if err := decodeObject(item, values.Index(i)); err != nil {
+				return err
+			}
+		}
+		target.Set(values)
+	} else if err := json.Unmarshal(raw, target.Addr().Interface()); err != nil {
+		return fmt.Errorf("Failed to convert the %s parameter: %s", name, err)
+	}
+	if required(field) && (target.Kind() == reflect.String || target.Kind() == reflect.Slice) && target.Len() == 0 {
+		return errors.New("Parameters contain empty values")
+	}
+	return nil
+}
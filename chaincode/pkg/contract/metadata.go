@@ -0,0 +1,121 @@
+package contract
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Metadata describes the contract in the layout of the Fabric contract API metadata
+type Metadata struct {
+	Info      InfoMetadata                `json:"info"`      // Contract package information
+	Contracts map[string]ContractMetadata `json:"contracts"` // Contracts by name
+}
+
+// InfoMetadata names and versions the contract package
+type InfoMetadata struct {
+	Title                string `json:"title"`                // Name of the chaincode
+	Version              string `json:"version"`              // Version of the chaincode
+	RequestSchemaVersion int    `json:"requestSchemaVersion"` // Version of the JSON request documents
+}
+
+// ContractMetadata lists the functions of a contract
+type ContractMetadata struct {
+	Name         string                `json:"name"`         // Name of the contract
+	Transactions []TransactionMetadata `json:"transactions"` // Functions, in declaration order
+}
+
+// TransactionMetadata describes a function, its parameters in positional order and its response payload
+type TransactionMetadata struct {
+	Name        string              `json:"name"`              // Function name
+	Description string              `json:"description"`       // What the function does
+	Tag         []string            `json:"tag"`               // "submit" or "evaluate"
+	Parameters  []ParameterMetadata `json:"parameters"`        // Positional parameters, followed by the transient ones
+	Returns     *Schema             `json:"returns,omitempty"` // Response payload, absent when the payload is empty
+}
+
+// ParameterMetadata describes a parameter
+type ParameterMetadata struct {
+	Name      string  `json:"name"`                // Name of the parameter
+	Required  bool    `json:"required"`            // Whether the parameter must be passed
+	Transient bool    `json:"transient,omitempty"` // Whether the parameter is passed in the transient data rather than as an argument
+	Schema    *Schema `json:"schema"`              // Type of the parameter
+}
+
+// Schema is the subset of JSON schema needed to describe the parameters and payloads
+type Schema struct {
+	Type       string             `json:"type"`                 // string, number, integer, boolean, array or object
+	Items      *Schema            `json:"items,omitempty"`      // Type of the elements of an array
+	Properties map[string]*Schema `json:"properties,omitempty"` // Fields of an object
+}
+
+// Metadata describes the functions of the contract
+func (c *Contract) Metadata() Metadata {
+	transactions := make([]TransactionMetadata, 0, len(c.transactions))
+	for _, tx := range c.transactions {
+		metadata := TransactionMetadata{
+			Name:        tx.Name,
+			Description: tx.Description,
+			Tag:         []string{"evaluate"},
+			Parameters:  []ParameterMetadata{},
+		}
+		if tx.Submit {
+			metadata.Tag = []string{"submit"}
+		}
+		if request := c.requests[tx.Name]; request != nil {
+			for _, transient := range []bool{false, true} {
+				for i := 0; i < request.NumField(); i++ {
+					field := request.Field(i)
+					if hasOption(field, "transient") != transient {
+						continue
+					}
+					metadata.Parameters = append(metadata.Parameters, ParameterMetadata{
+						Name:      jsonName(field),
+						Required:  !hasOption(field, "optional"),
+						Transient: transient,
+						Schema:    schemaOf(field.Type),
+					})
+				}
+			}
+		}
+		if tx.Returns != nil {
+			metadata.Returns = schemaOf(reflect.TypeOf(tx.Returns))
+		}
+		transactions = append(transactions, metadata)
+	}
+	return Metadata{
+		Info:      InfoMetadata{Title: c.name, Version: c.version, RequestSchemaVersion: RequestSchemaVersion},
+		Contracts: map[string]ContractMetadata{c.name: {Name: c.name, Transactions: transactions}},
+	}
+}
+
+// schemaOf describes a Go type
+func schemaOf(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaOf(t.Elem())
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: schemaOf(t.Elem())}
+	case reflect.Struct:
+		schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		for i := 0; i < t.NumField(); i++ {
+			if name := jsonName(t.Field(i)); name != "" && name != "-" {
+				schema.Properties[name] = schemaOf(t.Field(i).Type)
+			}
+		}
+		return schema
+	}
+	return &Schema{Type: "object"}
+}
+
+// jsonName is the name of a field in its json tag
+func jsonName(field reflect.StructField) string {
+	return strings.Split(field.Tag.Get("json"), ",")[0]
+}
@@ -0,0 +1,89 @@
+package errcode
+
+import (
+	"chaincode/pkg/statemachine"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Machine-readable codes of chaincode errors, so that clients can tell failures apart without parsing messages
+const (
+	NotFound          = "NOT_FOUND"          // A record the request refers to does not exist
+	Forbidden         = "FORBIDDEN"          // The operator may not perform the request
+	Conflict          = "CONFLICT"           // The request does not fit the current status of the records
+	InvalidArgument   = "INVALID_ARGUMENT"   // The request itself is malformed
+	InsufficientFunds = "INSUFFICIENT_FUNDS" // An account cannot pay the amount due
+	Internal          = "INTERNAL"           // Anything else, such as ledger or serialization failures
+)
+
+// Error is an error with a code. Chaincode responses carry it as a JSON envelope in their message.
+type Error struct {
+	Code    string `json:"code"`    // One of the codes above
+	Message string `json:"message"` // Human-readable description
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// New returns an error with a code
+func New(code string, format string, args ...interface{}) error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// CodeOf returns the code of an error, looking through wrapped errors.
+// Invalid state transitions are conflicts; errors without a code are internal.
+func CodeOf(err error) string {
+	var coded *Error
+	if errors.As(err, &coded) {
+		return coded.Code
+	}
+	var transition *statemachine.TransitionError
+	if errors.As(err, &transition) {
+		return Conflict
+	}
+	return Internal
+}
+
+// Response returns the error response of a chaincode function for err
+func Response(err error) pb.Response {
+	return envelope(&Error{Code: CodeOf(err), Message: err.Error()})
+}
+
+// Responsef returns the error response of a chaincode function with a code and a formatted message
+func Responsef(code string, format string, args ...interface{}) pb.Response {
+	return envelope(&Error{Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// Parse extracts the error envelope from a chaincode error message
+func Parse(message string) (*Error, bool) {
+	var e Error
+	if err := json.Unmarshal([]byte(message), &e); err != nil || e.Code == "" {
+		return nil, false
+	}
+	return &e, true
+}
+
+// Wrap turns an error response without an envelope into one with the Internal code
+func Wrap(resp pb.Response) pb.Response {
+	if resp.Status < shim.ERRORTHRESHOLD {
+		return resp
+	}
+	if _, ok := Parse(resp.Message); ok {
+		return resp
+	}
+	return envelope(&Error{Code: Internal, Message: resp.Message})
+}
+
+// envelope serializes the error into the message of an error response
+func envelope(e *Error) pb.Response {
+	message, err := json.Marshal(e)
+	if err != nil {
+		return shim.Error(e.Message)
+	}
+	return shim.Error(string(message))
+}
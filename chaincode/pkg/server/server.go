@@ -0,0 +1,189 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+)
+
+// Config configures the chaincode-as-a-service run mode, where the chaincode listens on its own address
+// and the peer connects to it, instead of the chaincode connecting to a peer that launched it.
+type Config struct {
+	Address          string // Address to listen on, e.g. 0.0.0.0:9999. The server mode is off when empty
+	ChaincodeID      string // Chaincode package ID (name:hash) the peer knows the chaincode by
+	TLSKeyFile       string // Server TLS private key in PEM format, TLS is off when empty
+	TLSCertFile      string // Server TLS certificate in PEM format
+	ClientCACertFile string // CA certificate of the peer clients in PEM format, enables mutual TLS when set
+}
+
+// ParseConfig reads the configuration from the command line flags, falling back on the environment:
+//
+//	-chaincode.address          CHAINCODE_SERVER_ADDRESS
+//	-chaincode.id               CHAINCODE_ID
+//	-chaincode.tls.key          CHAINCODE_TLS_KEY_FILE
+//	-chaincode.tls.cert         CHAINCODE_TLS_CERT_FILE
+//	-chaincode.tls.clientcacert CHAINCODE_TLS_CLIENT_CA_CERT_FILE
+func ParseConfig(args []string) (Config, error) {
+	var config Config
+	flags := flag.NewFlagSet("chaincode", flag.ContinueOnError)
+	flags.StringVar(&config.Address, "chaincode.address", os.Getenv("CHAINCODE_SERVER_ADDRESS"), "address to listen on as a chaincode server")
+	flags.StringVar(&config.ChaincodeID, "chaincode.id", os.Getenv("CHAINCODE_ID"), "chaincode package ID")
+	flags.StringVar(&config.TLSKeyFile, "chaincode.tls.key", os.Getenv("CHAINCODE_TLS_KEY_FILE"), "server TLS private key file")
+	flags.StringVar(&config.TLSCertFile, "chaincode.tls.cert", os.Getenv("CHAINCODE_TLS_CERT_FILE"), "server TLS certificate file")
+	flags.StringVar(&config.ClientCACertFile, "chaincode.tls.clientcacert", os.Getenv("CHAINCODE_TLS_CLIENT_CA_CERT_FILE"), "peer client CA certificate file")
+	// The peer passes its address when it launches the chaincode itself, which is left to the shim
+	flags.String("peer.address", "", "peer address")
+	if err := flags.Parse(args); err != nil {
+		return config, err
+	}
+	if config.Address != "" && config.ChaincodeID == "" {
+		return config, errors.New("The chaincode ID must be set to run as a chaincode server")
+	}
+	if (config.TLSKeyFile == "") != (config.TLSCertFile == "") {
+		return config, errors.New("The TLS key and certificate must be set together")
+	}
+	if config.ClientCACertFile != "" && config.TLSKeyFile == "" {
+		return config, errors.New("Mutual TLS requires the TLS key and certificate")
+	}
+	return config, nil
+}
+
+// Start listens on config.Address and serves the chaincode to the peers that connect, until the listener fails
+func Start(config Config, cc shim.Chaincode) error {
+	listener, err := net.Listen("tcp", config.Address)
+	if err != nil {
+		return fmt.Errorf("Failed to listen on %s: %s", config.Address, err)
+	}
+	return Serve(listener, config, cc)
+}
+
+// Serve serves the chaincode on an existing listener
+func Serve(listener net.Listener, config Config, cc shim.Chaincode) error {
+	options := []grpc.ServerOption{
+		// Match the keepalive settings peers use for chaincode connections
+		grpc.KeepaliveParams(keepalive.ServerParameters{Time: time.Minute, Timeout: 20 * time.Second}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{MinTime: time.Minute, PermitWithoutStream: true}),
+	}
+	if config.TLSKeyFile != "" {
+		tlsConfig, err := loadTLSConfig(config)
+		if err != nil {
+			return err
+		}
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	grpcServer := grpc.NewServer(options...)
+	grpcServer.RegisterService(&chaincodeServiceDesc, &chaincodeServer{id: config.ChaincodeID, cc: cc})
+	return grpcServer.Serve(listener)
+}
+
+// loadTLSConfig loads the server certificate, and the client CA for mutual TLS
+func loadTLSConfig(config Config) (*tls.Config, error) {
+	certificate, err := tls.LoadX509KeyPair(config.TLSCertFile, config.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load the TLS key pair: %s", err)
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{certificate}, MinVersion: tls.VersionTLS12}
+	if config.ClientCACertFile != "" {
+		caCert, err := ioutil.ReadFile(config.ClientCACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read the client CA certificate: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, errors.New("The client CA certificate file contains no PEM certificate")
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// chaincodeService is implemented by the handler of the protos.Chaincode service
+type chaincodeService interface {
+	connect(stream grpc.ServerStream) error
+}
+
+// chaincodeServiceDesc describes the protos.Chaincode service peers dial to reach chaincode servers.
+// Its Connect stream carries the same messages as the ChaincodeSupport Register stream the shim uses, in the same order.
+var chaincodeServiceDesc = grpc.ServiceDesc{
+	ServiceName: "protos.Chaincode",
+	HandlerType: (*chaincodeService)(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName: "Connect",
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			return srv.(chaincodeService).connect(stream)
+		},
+		ServerStreams: true,
+		ClientStreams: true,
+	}},
+	Metadata: "peer/chaincode_shim.proto",
+}
+
+// chaincodeServer runs the shim for each peer connection
+type chaincodeServer struct {
+	id string
+	cc shim.Chaincode
+}
+
+// connect relays the peer stream to the shim, which registers the chaincode and handles its transactions
+func (s *chaincodeServer) connect(stream grpc.ServerStream) error {
+	recv := make(chan *pb.ChaincodeMessage)
+	send := make(chan *pb.ChaincodeMessage)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(recv)
+		for {
+			msg := new(pb.ChaincodeMessage)
+			if err := stream.RecvMsg(msg); err != nil {
+				return
+			}
+			select {
+			case recv <- msg:
+			case <-done:
+				return
+			}
+		}
+	}()
+	sendErr := make(chan error, 1)
+	go func() {
+		// Keep draining after a failure so that the shim never blocks on a dead stream
+		failed := false
+		for {
+			select {
+			case msg := <-send:
+				if failed {
+					continue
+				}
+				if err := stream.SendMsg(msg); err != nil {
+					sendErr <- err
+					failed = true
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	env := []string{"CORE_CHAINCODE_ID_NAME=" + s.id}
+	if err := shim.StartInProc(env, nil, s.cc, recv, send); err != nil {
+		select {
+		case err := <-sendErr:
+			return fmt.Errorf("Failed to send to the peer: %s", err)
+		default:
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package statemachine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Initial is the pseudo-state records are in before they are created
+const Initial = ""
+
+// Guard decides whether a transition applies to the subject, returning why not otherwise
+type Guard struct {
+	Name  string                          // Shown in the diagram
+	Check func(subject interface{}) error // May record what it found on the subject for the effect
+}
+
+// Effect is what a transition does besides changing the status, such as moving funds or the title
+type Effect struct {
+	Name string                                    // Shown in the diagram
+	Run  func(subject interface{}) ([]byte, error) // Returns the response payload
+}
+
+// Transition moves a subject from one state to another on an event.
+// Several transitions may share From and Event, in which case the first whose guard passes is taken.
+type Transition struct {
+	From   string  // State before, Initial for creation
+	Event  string  // Operation causing the transition
+	To     string  // State after
+	Guard  *Guard  // nil when the transition always applies
+	Effect *Effect // nil when the caller applies the change itself
+}
+
+// TransitionError is returned for an event that is not allowed in the current state
+type TransitionError struct {
+	Machine string // Name of the machine
+	From    string // Current state
+	Event   string // Event refused
+}
+
+func (e *TransitionError) Error() string {
+	from := e.From
+	if from == Initial {
+		from = "[initial]"
+	}
+	return fmt.Sprintf("Invalid %s transition: %s is not allowed in status %s", e.Machine, e.Event, from)
+}
+
+// Machine is a set of states and the transitions between them
+type Machine struct {
+	name        string
+	states      []string
+	transitions []Transition
+}
+
+// New checks that every transition goes between declared states and builds the machine
+func New(name string, states []string, transitions []Transition) (*Machine, error) {
+	declared := map[string]bool{Initial: true}
+	for _, state := range states {
+		declared[state] = true
+	}
+	for _, t := range transitions {
+		if !declared[t.From] || !declared[t.To] || t.To == Initial {
+			return nil, fmt.Errorf("%s: transition %s from %q to %q uses an undeclared state", name, t.Event, t.From, t.To)
+		}
+	}
+	return &Machine{name: name, states: states, transitions: transitions}, nil
+}
+
+// MustNew is like New but panics when a transition is invalid
+func MustNew(name string, states []string, transitions []Transition) *Machine {
+	m, err := New(name, states, transitions)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Name returns the name of the machine
+func (m *Machine) Name() string {
+	return m.name
+}
+
+// Next returns the transition taken by event from state, checking the guards against the subject.
+// It returns a *TransitionError when the event is not allowed in that state, or the error of the first guard that failed.
+func (m *Machine) Next(from string, event string, subject interface{}) (Transition, error) {
+	var guardErr error
+	found := false
+	for _, t := range m.transitions {
+		if t.From != from || t.Event != event {
+			continue
+		}
+		found = true
+		if t.Guard != nil {
+			if err := t.Guard.Check(subject); err != nil {
+				if guardErr == nil {
+					guardErr = err
+				}
+				continue
+			}
+		}
+		return t, nil
+	}
+	if !found {
+		return Transition{}, &TransitionError{Machine: m.name, From: from, Event: event}
+	}
+	return Transition{}, guardErr
+}
+
+// Fire takes the transition of event from state and runs its effect
+func (m *Machine) Fire(from string, event string, subject interface{}) (Transition, []byte, error) {
+	t, err := m.Next(from, event, subject)
+	if err != nil {
+		return t, nil, err
+	}
+	data, err := t.Apply(subject)
+	return t, data, err
+}
+
+// Apply runs the effect of a transition returned by Next, for callers with checks to make in between
+func (t Transition) Apply(subject interface{}) ([]byte, error) {
+	if t.Effect == nil {
+		return nil, nil
+	}
+	return t.Effect.Run(subject)
+}
+
+// Row is one transition of the transition table
+type Row struct {
+	From   string `json:"from"`   // State before, empty for creation
+	Event  string `json:"event"`  // Operation causing the transition
+	To     string `json:"to"`     // State after
+	Guard  string `json:"guard"`  // Condition, empty when the transition always applies
+	Effect string `json:"effect"` // Side effect, empty when there is none
+}
+
+// Diagram is the transition table of a machine along with its renderings
+type Diagram struct {
+	Name        string   `json:"name"`        // Name of the machine
+	States      []string `json:"states"`      // Declared states
+	Transitions []Row    `json:"transitions"` // Transition table
+	Dot         string   `json:"dot"`         // Graphviz rendering
+	Mermaid     string   `json:"mermaid"`     // Mermaid stateDiagram rendering
+}
+
+// Table returns the transitions in declaration order
+func (m *Machine) Table() []Row {
+	rows := make([]Row, 0, len(m.transitions))
+	for _, t := range m.transitions {
+		row := Row{From: t.From, Event: t.Event, To: t.To}
+		if t.Guard != nil {
+			row.Guard = t.Guard.Name
+		}
+		if t.Effect != nil {
+			row.Effect = t.Effect.Name
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// Events returns the events the machine knows, sorted
+func (m *Machine) Events() []string {
+	seen := map[string]bool{}
+	var events []string
+	for _, t := range m.transitions {
+		if !seen[t.Event] {
+			seen[t.Event] = true
+			events = append(events, t.Event)
+		}
+	}
+	sort.Strings(events)
+	return events
+}
+
+// States returns the declared states
+func (m *Machine) States() []string {
+	return append([]string{}, m.states...)
+}
+
+// Diagram exports the transition table as Graphviz and Mermaid diagrams
+func (m *Machine) Diagram() Diagram {
+	var dot, mermaid strings.Builder
+	fmt.Fprintf(&dot, "digraph %q {\n\t__initial [shape=point];\n", m.name)
+	for _, state := range m.states {
+		fmt.Fprintf(&dot, "\t%q;\n", state)
+	}
+	mermaid.WriteString("stateDiagram-v2\n")
+	for _, row := range m.Table() {
+		label := row.Event
+		if row.Guard != "" {
+			label += " [" + row.Guard + "]"
+		}
+		if row.Effect != "" {
+			label += " / " + row.Effect
+		}
+		from, mermaidFrom := fmt.Sprintf("%q", row.From), mermaidState(row.From)
+		if row.From == Initial {
+			from, mermaidFrom = "__initial", "[*]"
+		}
+		fmt.Fprintf(&dot, "\t%s -> %q [label=%q];\n", from, row.To, label)
+		fmt.Fprintf(&mermaid, "\t%s --> %s : %s\n", mermaidFrom, mermaidState(row.To), strings.Replace(label, ":", "", -1))
+	}
+	dot.WriteString("}\n")
+	return Diagram{Name: m.name, States: m.States(), Transitions: m.Table(), Dot: dot.String(), Mermaid: mermaid.String()}
+}
+
+// mermaidState turns a state name into a Mermaid identifier
+func mermaidState(state string) string {
+	return strings.Replace(state, " ", "_", -1)
+}
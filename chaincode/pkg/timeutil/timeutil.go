@@ -0,0 +1,41 @@
+package timeutil
+
+import (
+	"time"
+)
+
+// Layout is the layout of the times stored on the ledger: RFC 3339 in UTC, with nanoseconds.
+// Clients localize them, so the chaincode never depends on the time zone of the peer.
+const Layout = time.RFC3339Nano
+
+// LegacyLayout is the layout of the times written before schema version 4, without a time zone
+const LegacyLayout = "2006-01-02 15:04:05"
+
+// LegacyLocation is the time zone the legacy times were written in, China Standard Time
+var LegacyLocation = time.FixedZone("CST", 8*60*60)
+
+// Format formats a time for the ledger
+func Format(t time.Time) string {
+	return t.UTC().Format(Layout)
+}
+
+// Parse parses a time read from the ledger, accepting the legacy layout of records not migrated yet
+func Parse(value string) (time.Time, error) {
+	t, err := time.Parse(Layout, value)
+	if err == nil {
+		return t.UTC(), nil
+	}
+	if legacy, legacyErr := time.ParseInLocation(LegacyLayout, value, LegacyLocation); legacyErr == nil {
+		return legacy.UTC(), nil
+	}
+	return t, err
+}
+
+// Upgrade rewrites a legacy time in the current layout and returns any other value unchanged
+func Upgrade(value string) string {
+	legacy, err := time.ParseInLocation(LegacyLayout, value, LegacyLocation)
+	if err != nil {
+		return value
+	}
+	return Format(legacy)
+}
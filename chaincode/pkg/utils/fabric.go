@@ -1,9 +1,13 @@
 package utils
 
 import (
+	"chaincode/model"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 )
@@ -63,7 +67,11 @@ func GetStateByPartialCompositeKeys(stub shim.ChaincodeStubInterface, objectType
 				return nil, errors.New(fmt.Sprintf("%s - Error with returned data: %s", objectType, err))
 			}
 
-			results = append(results, val.GetValue())
+			bytes, _, err := model.UpgradeRecord(objectType, val.GetValue())
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, bytes)
 		}
 	} else {
 		// If the length of keys passed is not 0, it means to retrieve the corresponding data
@@ -80,6 +88,10 @@ func GetStateByPartialCompositeKeys(stub shim.ChaincodeStubInterface, objectType
 			}
 
 			if bytes != nil {
+				bytes, _, err = model.UpgradeRecord(objectType, bytes)
+				if err != nil {
+					return nil, err
+				}
 				results = append(results, bytes)
 			}
 		}
@@ -104,7 +116,75 @@ func GetStateByPartialCompositeKeys2(stub shim.ChaincodeStubInterface, objectTyp
 			return nil, errors.New(fmt.Sprintf("%s - Error with returned data: %s", objectType, err))
 		}
 
-		results = append(results, val.GetValue())
+		// Older records are upgraded in memory so that readers only see the current schema
+		bytes, _, err := model.UpgradeRecord(objectType, val.GetValue())
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, bytes)
 	}
 	return results, nil
 }
+
+// KeyPrefix returns the leading non-empty keys, for querying by optional key parts
+func KeyPrefix(keys ...string) []string {
+	for i, key := range keys {
+		if key == "" {
+			return keys[:i]
+		}
+	}
+	return keys
+}
+
+// GetTxTime returns the transaction timestamp in UTC
+func GetTxTime(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	txTimestamp, err := stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, errors.New(fmt.Sprintf("Error getting the transaction timestamp: %s", err))
+	}
+	return time.Unix(txTimestamp.GetSeconds(), int64(txTimestamp.GetNanos())).UTC(), nil
+}
+
+// WritePrivateLedger writes to a private data collection and returns the hash to anchor on the public ledger
+func WritePrivateLedger(obj interface{}, stub shim.ChaincodeStubInterface, collection string, objectType string, keys []string) (string, error) {
+	// Create a composite key
+	key, err := stub.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("%s - Error creating composite key: %s", objectType, err))
+	}
+	bytes, err := json.Marshal(obj)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("%s - Error serializing JSON data: %s", objectType, err))
+	}
+	// Write to the private data collection
+	if err := stub.PutPrivateData(collection, key, bytes); err != nil {
+		return "", errors.New(fmt.Sprintf("%s - Error writing to the private data collection %s: %s", objectType, collection, err))
+	}
+	sum := sha256.Sum256(bytes)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ReadPrivateLedger reads from a private data collection into obj and checks it against the hash anchored on the public ledger
+func ReadPrivateLedger(obj interface{}, stub shim.ChaincodeStubInterface, collection string, objectType string, keys []string, hash string) error {
+	// Create a composite key
+	key, err := stub.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s - Error creating composite key: %s", objectType, err))
+	}
+	// Read from the private data collection
+	bytes, err := stub.GetPrivateData(collection, key)
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s - Error reading the private data collection %s: %s", objectType, collection, err))
+	}
+	if bytes == nil {
+		return errors.New(fmt.Sprintf("%s - No private data found in %s", objectType, collection))
+	}
+	sum := sha256.Sum256(bytes)
+	if hex.EncodeToString(sum[:]) != hash {
+		return errors.New(fmt.Sprintf("%s - Private data in %s does not match the hash on the ledger", objectType, collection))
+	}
+	if err := json.Unmarshal(bytes, obj); err != nil {
+		return errors.New(fmt.Sprintf("%s - Error deserializing JSON data: %s", objectType, err))
+	}
+	return nil
+}
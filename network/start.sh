@@ -73,8 +73,9 @@ docker exec cli bash -c "$JDPeer0Cli peer chaincode install -n fabric-realty -v
 # -v Version
 # -C is the channel; in the fabric world, a channel is a different chain
 # -c is for passing parameters, passing the init parameter
+# --collections-config defines the private data collections holding prices, balances and account names
 echo "11. Instantiate chaincode"
-docker exec cli bash -c "$TaobaoPeer0Cli peer chaincode instantiate -o orderer.qq.com:7050 -C appchannel -n fabric-realty -l golang -v 1.0.0 -c '{\"Args\":[\"init\"]}' -P \"AND ('TaobaoMSP.member','JDMSP.member')\""
+docker exec cli bash -c "$TaobaoPeer0Cli peer chaincode instantiate -o orderer.qq.com:7050 -C appchannel -n fabric-realty -l golang -v 1.0.0 -c '{\"Args\":[\"init\"]}' -P \"AND ('TaobaoMSP.member','JDMSP.member')\" --collections-config /opt/gopath/src/chaincode/collections_config.json"
 
 echo "Waiting for chaincode instantiation to complete, waiting for 5 seconds"
 sleep 5